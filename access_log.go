@@ -0,0 +1,108 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+)
+
+// AccessLogEntry summarizes an RPC handled by the server. Its numbers come
+// from the same measurements as the metrics, so logs and dashboards agree.
+type AccessLogEntry struct {
+	Type    string
+	Service string
+	Method  string
+	Code    codes.Code
+	// Peer is the address of the client, empty if unknown.
+	Peer string
+	// Duration is the handling time observed in the handling time metrics.
+	Duration time.Duration
+	// MsgsReceived and MsgsSent are the numbers of messages counted in
+	// grpc_server_msg_received_total and grpc_server_msg_sent_total.
+	MsgsReceived int64
+	MsgsSent     int64
+	// BytesReceived and BytesSent are the sums of the serialized sizes of the
+	// received and sent messages.
+	BytesReceived int64
+	BytesSent     int64
+}
+
+// AccessLogger receives a summary of every RPC handled by the server
+// interceptors, e.g. to emit a structured access log line with zap or slog.
+// It is called on the RPC's goroutine once the RPC completed, so
+// implementations should be cheap and must be safe for concurrent use.
+type AccessLogger interface {
+	LogRPC(ctx context.Context, entry AccessLogEntry)
+}
+
+// AccessLoggerFunc is an adapter allowing the use of an ordinary function as
+// an AccessLogger.
+type AccessLoggerFunc func(ctx context.Context, entry AccessLogEntry)
+
+// LogRPC calls f(ctx, entry).
+func (f AccessLoggerFunc) LogRPC(ctx context.Context, entry AccessLogEntry) {
+	f(ctx, entry)
+}
+
+// WithAccessLogger reports a summary of every RPC observed by the server
+// interceptors to l, avoiding a second timing middleware whose numbers would
+// not match the metrics.
+func WithAccessLogger(l AccessLogger) ServerMetricsOption {
+	return func(m *ServerMetrics) { m.accessLogger = l }
+}
+
+// WithRPCSampler hands a random sample of the RPCs observed by the server
+// interceptors to fn, each RPC being sampled independently with probability
+// rate, between 0 and 1. Samples are taken from the same measurement points
+// as the metrics, e.g. for feeding capacity simulations, and each stands for
+// 1/rate RPCs. fn is called on the RPC's goroutine once the RPC completed,
+// and must be safe for concurrent use.
+func WithRPCSampler(rate float64, fn func(ctx context.Context, entry AccessLogEntry)) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.rpcSampleRate = rate
+		m.rpcSampler = fn
+	}
+}
+
+// sampleRPC decides whether an RPC is handed to the RPC sampler.
+func (m *ServerMetrics) sampleRPC() bool {
+	return m.rpcSampler != nil && rand.Float64() < m.rpcSampleRate
+}
+
+// accessLogStats accumulates the message counts and sizes of an RPC, which
+// may be updated concurrently by the sending and receiving goroutines of a
+// stream.
+type accessLogStats struct {
+	msgsReceived, msgsSent   int64
+	bytesReceived, bytesSent int64
+}
+
+func (r *serverReporter) logAccess(ctx context.Context, code codes.Code) {
+	if r.accessLog == nil {
+		return
+	}
+	entry := AccessLogEntry{
+		Type:          string(r.rpcType),
+		Service:       r.serviceName,
+		Method:        r.methodName,
+		Code:          code,
+		Duration:      r.elapsed,
+		MsgsReceived:  atomic.LoadInt64(&r.accessLog.msgsReceived),
+		MsgsSent:      atomic.LoadInt64(&r.accessLog.msgsSent),
+		BytesReceived: atomic.LoadInt64(&r.accessLog.bytesReceived),
+		BytesSent:     atomic.LoadInt64(&r.accessLog.bytesSent),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		entry.Peer = p.Addr.String()
+	}
+	if r.metrics.accessLogger != nil {
+		r.metrics.accessLogger.LogRPC(ctx, entry)
+	}
+	if r.sampled {
+		r.metrics.rpcSampler(ctx, entry)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build go1.21
+// +build go1.21
+
+package grpc_prometheus
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogAccessLogger returns an AccessLogger emitting one "rpc" record per RPC
+// at info level to l, with the fields of the AccessLogEntry as attributes.
+func SlogAccessLogger(l *slog.Logger) AccessLogger {
+	return AccessLoggerFunc(func(ctx context.Context, e AccessLogEntry) {
+		l.LogAttrs(ctx, slog.LevelInfo, "rpc",
+			slog.String("grpc_type", e.Type),
+			slog.String("grpc_service", e.Service),
+			slog.String("grpc_method", e.Method),
+			slog.String("grpc_code", e.Code.String()),
+			slog.String("peer", e.Peer),
+			slog.Duration("duration", e.Duration),
+			slog.Int64("msgs_received", e.MsgsReceived),
+			slog.Int64("msgs_sent", e.MsgsSent),
+			slog.Int64("bytes_received", e.BytesReceived),
+			slog.Int64("bytes_sent", e.BytesSent),
+		)
+	})
+}
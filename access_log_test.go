@@ -0,0 +1,64 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccessLoggerMatchesMetrics(t *testing.T) {
+	var entries []AccessLogEntry
+	m := NewServerMetrics()
+	m.EnableHandlingTimeHistogram()
+	m.Configure(WithAccessLogger(AccessLoggerFunc(func(ctx context.Context, e AccessLogEntry) {
+		entries = append(entries, e)
+	})))
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}})
+	req := &pb_testproto.PingRequest{Value: "ping"}
+	resp := &pb_testproto.PingResponse{Value: "pong", Counter: 42}
+	m.UnaryServerInterceptor()(ctx, req, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return resp, nil })
+
+	require.Len(t, entries, 1)
+	e := entries[0]
+	require.Equal(t, "unary", e.Type)
+	require.Equal(t, "mwitkow.testproto.TestService", e.Service)
+	require.Equal(t, "Ping", e.Method)
+	require.Equal(t, codes.OK, e.Code)
+	require.Equal(t, "10.0.0.1:1234", e.Peer)
+	require.Equal(t, int64(1), e.MsgsReceived)
+	require.Equal(t, int64(1), e.MsgsSent)
+	require.Equal(t, int64(6), e.BytesReceived)
+	require.Equal(t, int64(8), e.BytesSent)
+
+	var metric dto.Metric
+	h := m.serverHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping")
+	require.NoError(t, h.(prometheus.Metric).Write(&metric))
+	require.Equal(t, e.Duration.Seconds(), metric.GetHistogram().GetSampleSum())
+}
+
+func TestRPCSamplerSamplesAtRate(t *testing.T) {
+	sampled := 0
+	m := NewServerMetrics()
+	m.Configure(WithRPCSampler(0.1, func(ctx context.Context, e AccessLogEntry) {
+		require.Equal(t, "Ping", e.Method)
+		require.Equal(t, codes.NotFound, e.Code)
+		sampled++
+	}))
+	for i := 0; i < 10000; i++ {
+		m.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, status.Error(codes.NotFound, "")
+			})
+	}
+	require.InDelta(t, 1000, sampled, 150)
+}
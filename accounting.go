@@ -0,0 +1,150 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// A ClientMetricsOption configures ClientMetrics, see
+// ClientMetrics.Configure.
+type ClientMetricsOption func(*ClientMetrics)
+
+// Configure applies optional behaviour to the ClientMetrics. It must be called
+// before the ClientMetrics is registered and its interceptors handle RPCs.
+func (m *ClientMetrics) Configure(opts ...ClientMetricsOption) {
+	m.checkNotFrozen("Configure")
+	for _, o := range opts {
+		o(m)
+	}
+}
+
+// WithPerCallAccounting makes grpc_client_started_total and
+// grpc_client_handled_total count logical calls, as seen by the
+// interceptors, however many attempts gRPC made for them. This is the
+// default.
+func WithPerCallAccounting() ClientMetricsOption {
+	return func(m *ClientMetrics) {
+		if !m.clientPerAttempt {
+			return
+		}
+		m.clientPerAttempt = false
+		m.clientStartedCounter = prom.NewCounterVec(
+			m.counterOpts.apply(clientStartedCounterOpts), []string{"grpc_type", "grpc_service", "grpc_method"})
+		m.clientHandledCounter = prom.NewCounterVec(
+			m.counterOpts.apply(clientHandledCounterOpts), []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"})
+	}
+}
+
+// WithPerAttemptAccounting makes grpc_client_started_total and
+// grpc_client_handled_total count the attempts of calls, labeled by their
+// number in grpc_attempt, starting at "1". Each attempt is counted as
+// handled once, with the code it ended with, including attempts superseded
+// by a retry. Calls failed by RecordShortCircuit made no attempt and are
+// counted with the grpc_attempt "0".
+//
+// Attempts are only visible to a stats handler: AttemptStatsHandler must be
+// installed with grpc.WithStatsHandler, in addition to the interceptors.
+// The other metrics keep counting calls.
+func WithPerAttemptAccounting() ClientMetricsOption {
+	return func(m *ClientMetrics) {
+		if m.clientPerAttempt {
+			return
+		}
+		m.clientPerAttempt = true
+		m.clientStartedCounter = prom.NewCounterVec(
+			m.counterOpts.apply(prom.CounterOpts{
+				Name: clientStartedCounterOpts.Name,
+				Help: "Total number of RPC attempts started on the client.",
+			}), []string{"grpc_type", "grpc_service", "grpc_method", "grpc_attempt"})
+		m.clientHandledCounter = prom.NewCounterVec(
+			m.counterOpts.apply(prom.CounterOpts{
+				Name: clientHandledCounterOpts.Name,
+				Help: "Total number of RPC attempts completed by the client, regardless of success or failure.",
+			}), []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code", "grpc_attempt"})
+	}
+}
+
+// AttemptStatsHandler returns a stats.Handler, to install with
+// grpc.WithStatsHandler, counting the attempts of calls intercepted by the
+// client interceptors of m when WithPerAttemptAccounting is on. Along with
+// RetryStatsHandler, it is installed as one handler with ChainStatsHandlers.
+func (m *ClientMetrics) AttemptStatsHandler() stats.Handler {
+	return attemptStatsHandler{m}
+}
+
+type callAttemptsKey struct{}
+
+// callAttempts counts the attempts of a client-side call.
+type callAttempts struct {
+	rpcType     grpcType
+	serviceName string
+	methodName  string
+	// attempts is the number of attempts started, and ended the number of
+	// attempts handled. Both are accessed atomically.
+	attempts int32
+	ended    int32
+}
+
+// withCallAttempts attaches the attempt count of the call of r to ctx if
+// attempts are counted.
+func (m *ClientMetrics) withCallAttempts(ctx context.Context, r *clientReporter) context.Context {
+	if !m.clientPerAttempt {
+		return ctx
+	}
+	return context.WithValue(ctx, callAttemptsKey{}, &callAttempts{rpcType: r.rpcType, serviceName: r.serviceName, methodName: r.methodName})
+}
+
+func (a *callAttempts) started(m *ClientMetrics, attempt int32) {
+	if !m.runtimeConfig().startedCounterDisabled {
+		m.clientStartedCounter.WithLabelValues(string(a.rpcType), a.serviceName, a.methodName, strconv.Itoa(int(attempt))).Inc()
+	}
+}
+
+func (a *callAttempts) handled(m *ClientMetrics, code string, attempt int32) {
+	m.clientHandledCounter.WithLabelValues(string(a.rpcType), a.serviceName, a.methodName, code, strconv.Itoa(int(attempt))).Inc()
+}
+
+// attemptStatsHandler is a stats.Handler counting the attempts of
+// client-side calls: gRPC reports the request headers and the end of each
+// attempt, with its own status.
+type attemptStatsHandler struct {
+	m *ClientMetrics
+}
+
+func (h attemptStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h attemptStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if !s.IsClient() {
+		return
+	}
+	a, ok := ctx.Value(callAttemptsKey{}).(*callAttempts)
+	if !ok {
+		return
+	}
+	switch s := s.(type) {
+	case *stats.OutHeader:
+		a.started(h.m, atomic.AddInt32(&a.attempts, 1))
+	case *stats.End:
+		attempt := atomic.AddInt32(&a.ended, 1)
+		if attempt > atomic.LoadInt32(&a.attempts) {
+			// The attempt failed before sending its headers, e.g. without
+			// connection, which still is an attempt.
+			a.started(h.m, atomic.AddInt32(&a.attempts, 1))
+		}
+		st, _ := status.FromError(s.Error)
+		a.handled(h.m, st.Code().String(), attempt)
+	}
+}
+
+func (h attemptStatsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h attemptStatsHandler) HandleConn(ctx context.Context, s stats.ConnStats) {}
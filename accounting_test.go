@@ -0,0 +1,68 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// attemptingInvoker returns an invoker reporting the given number of
+// attempts to h, as gRPC does when retrying: each attempt sends its headers
+// and ends with its own status, Unavailable for the retried ones, the last
+// one with err. With no attempts, the call fails with err before sending
+// headers.
+func attemptingInvoker(h stats.Handler, attempts int, err error) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		ctx = h.TagRPC(ctx, &stats.RPCTagInfo{FullMethodName: method})
+		h.HandleRPC(ctx, &stats.Begin{Client: true})
+		for i := 1; i <= attempts; i++ {
+			h.HandleRPC(ctx, &stats.OutHeader{Client: true, FullMethod: method})
+			if i < attempts {
+				h.HandleRPC(ctx, &stats.End{Client: true, Error: status.Error(codes.Unavailable, "retried")})
+			}
+		}
+		h.HandleRPC(ctx, &stats.End{Client: true, Error: err})
+		return err
+	}
+}
+
+func TestPerAttemptAccounting(t *testing.T) {
+	m := NewClientMetrics()
+	m.Configure(WithPerAttemptAccounting())
+	h := m.AttemptStatsHandler()
+	call := func(invoker grpc.UnaryInvoker) {
+		m.UnaryClientInterceptor()(context.Background(), "/mwitkow.testproto.TestService/Ping", &pb_testproto.PingRequest{}, &pb_testproto.PingResponse{}, nil, invoker)
+	}
+
+	call(attemptingInvoker(h, 3, nil))
+	requireValue(t, 1, m.clientStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "1"))
+	requireValue(t, 1, m.clientStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "3"))
+	// Each attempt is handled once, with its own code.
+	requireValue(t, 1, m.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "Unavailable", "1"))
+	requireValue(t, 1, m.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "Unavailable", "2"))
+	requireValue(t, 1, m.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK", "3"))
+	requireValue(t, 0, m.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK", "1"))
+
+	// Calls failing before sending headers still made an attempt.
+	call(attemptingInvoker(h, 0, status.Error(codes.Unavailable, "no connection")))
+	requireValue(t, 2, m.clientStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "1"))
+	requireValue(t, 2, m.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "Unavailable", "1"))
+
+	m.RecordShortCircuit("/mwitkow.testproto.TestService/Ping", codes.Unavailable, "breaker_open")
+	requireValue(t, 1, m.clientStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "0"))
+	requireValue(t, 1, m.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "Unavailable", "0"))
+}
+
+func TestPerCallAccounting(t *testing.T) {
+	m := NewClientMetrics()
+	m.Configure(WithPerAttemptAccounting(), WithPerCallAccounting())
+	h := m.AttemptStatsHandler()
+	m.UnaryClientInterceptor()(context.Background(), "/mwitkow.testproto.TestService/Ping", &pb_testproto.PingRequest{}, &pb_testproto.PingResponse{}, nil, attemptingInvoker(h, 2, nil))
+	requireValue(t, 1, m.clientStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValue(t, 1, m.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+}
@@ -0,0 +1,67 @@
+package grpc_prometheus
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultAPIVersionPattern matches version segments of package-qualified
+// service names such as "myapi.v1.Service" or "myapi.v2beta1.Service".
+var DefaultAPIVersionPattern = regexp.MustCompile(`(?:^|\.)(v\d+(?:(?:alpha|beta)\d*)?)(?:\.|$)`)
+
+// apiVersions parses and caches the API version of service names.
+type apiVersions struct {
+	pattern *regexp.Regexp
+	counter *counterVec
+	// parsed maps service names to their *apiVersion, or nil if unversioned.
+	parsed sync.Map
+}
+
+type apiVersion struct {
+	api, version string
+}
+
+// EnableAPIVersionCounter turns on grpc_server_api_version_started_total,
+// counting started RPCs per API and version, which gives a direct adoption
+// curve of new API versions. The first submatch of pattern in the service
+// name is the version, the part of the service name before the match the
+// API, e.g. "myapi" and "v1" for "myapi.v1.Service". Services not matching
+// pattern are not counted. A nil pattern means DefaultAPIVersionPattern.
+func (m *ServerMetrics) EnableAPIVersionCounter(pattern *regexp.Regexp, counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnableAPIVersionCounter")
+	if pattern == nil {
+		pattern = DefaultAPIVersionPattern
+	}
+	m.serverAPIVersions = &apiVersions{
+		pattern: pattern,
+		counter: m.newCounterVec(
+			counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_server_api_version_started_total",
+				Help: "Total number of RPCs started on the server per API version.",
+			})), []string{"api", "api_version"}),
+	}
+}
+
+func (v *apiVersions) parse(service string) *apiVersion {
+	if parsed, ok := v.parsed.Load(service); ok {
+		return parsed.(*apiVersion)
+	}
+	var parsed *apiVersion
+	if match := v.pattern.FindStringSubmatchIndex(service); match != nil && len(match) >= 4 && match[2] >= 0 {
+		parsed = &apiVersion{
+			api:     strings.TrimSuffix(service[:match[2]], "."),
+			version: service[match[2]:match[3]],
+		}
+	}
+	v.parsed.Store(service, parsed)
+	return parsed
+}
+
+func (v *apiVersions) started(service string) {
+	if parsed := v.parse(service); parsed != nil {
+		v.counter.WithLabelValues(parsed.api, parsed.version).Inc()
+	}
+}
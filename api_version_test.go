@@ -0,0 +1,25 @@
+package grpc_prometheus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIVersionParse(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableAPIVersionCounter(nil)
+	for service, expected := range map[string]*apiVersion{
+		"myapi.v1.Service":              {"myapi", "v1"},
+		"company.myapi.v2beta1.Svc":     {"company.myapi", "v2beta1"},
+		"v3.Service":                    {"", "v3"},
+		"mwitkow.testproto.TestService": nil,
+		"myapi.vintage.Service":         nil,
+	} {
+		require.Equal(t, expected, m.serverAPIVersions.parse(service), service)
+	}
+
+	m.serverAPIVersions.started("myapi.v1.Service")
+	m.serverAPIVersions.started("myapi.v1.Service")
+	requireValue(t, 2, m.serverAPIVersions.counter.WithLabelValues("myapi", "v1"))
+}
@@ -0,0 +1,82 @@
+package grpc_prometheus
+
+import (
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// WithBackpressureThreshold turns on the grpc_server_backpressured_streams
+// gauge, the number of streams of each method which had a SendMsg call take
+// longer than threshold within the sliding window. A slow SendMsg means the
+// flow control window of the stream is exhausted, i.e. the client consumes
+// messages slower than the server produces them. This gives an explicit
+// "consumer too slow" signal rather than one inferred from send times.
+func WithBackpressureThreshold(threshold, window time.Duration) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.serverBackpressure = &backpressureTracker{
+			desc: prom.NewDesc(
+				"grpc_server_backpressured_streams",
+				"Number of streams on the server which had a message send exceed the backpressure threshold within a sliding window.",
+				[]string{"grpc_service", "grpc_method"}, m.counterOpts.apply(prom.CounterOpts{}).ConstLabels),
+			threshold: threshold,
+			window:    window,
+			methods:   make(map[methodKey]map[*serverReporter]time.Time),
+		}
+	}
+}
+
+// backpressureTracker remembers, per method, when each stream last had a
+// slow send.
+type backpressureTracker struct {
+	desc      *prom.Desc
+	threshold time.Duration
+	window    time.Duration
+
+	mu      sync.Mutex
+	methods map[methodKey]map[*serverReporter]time.Time
+}
+
+// sent records a send of the stream of r which took d and finished at t.
+func (b *backpressureTracker) sent(r *serverReporter, d time.Duration, t time.Time) {
+	if d <= b.threshold {
+		return
+	}
+	key := methodKey{r.serviceName, r.methodName}
+	b.mu.Lock()
+	streams, ok := b.methods[key]
+	if !ok {
+		streams = make(map[*serverReporter]time.Time)
+		b.methods[key] = streams
+	}
+	streams[r] = t
+	b.mu.Unlock()
+}
+
+func (b *backpressureTracker) collect(ch chan<- prom.Metric, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key, streams := range b.methods {
+		for r, t := range streams {
+			if now.Sub(t) > b.window {
+				delete(streams, r)
+			}
+		}
+		ch <- prom.MustNewConstMetric(b.desc, prom.GaugeValue, float64(len(streams)), key.service, key.method)
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (b *backpressureTracker) Describe(ch chan<- *prom.Desc) {
+	ch <- b.desc
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (b *backpressureTracker) Collect(ch chan<- prom.Metric) {
+	b.collect(ch, time.Now())
+}
@@ -0,0 +1,46 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// slowServerStream is a server stream whose sends take a fixed time.
+type slowServerStream struct {
+	fakeServerStream
+	sendTime time.Duration
+}
+
+func (s *slowServerStream) SendMsg(m interface{}) error {
+	time.Sleep(s.sendTime)
+	return nil
+}
+
+func TestBackpressuredStreams(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithBackpressureThreshold(20*time.Millisecond, time.Minute))
+	info := &grpc.StreamServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingList", IsServerStream: true}
+	for _, sendTime := range []time.Duration{0, 50 * time.Millisecond, 50 * time.Millisecond} {
+		ss := &slowServerStream{fakeServerStream{ctx: context.Background()}, sendTime}
+		m.StreamServerInterceptor()(nil, ss, info, func(srv interface{}, stream grpc.ServerStream) error {
+			stream.SendMsg(nil)
+			return stream.SendMsg(nil)
+		})
+	}
+
+	collect := func(now time.Time) float64 {
+		ch := make(chan prometheus.Metric, 1)
+		m.serverBackpressure.collect(ch, now)
+		pb := &dto.Metric{}
+		require.NoError(t, (<-ch).Write(pb))
+		return pb.GetGauge().GetValue()
+	}
+	require.Equal(t, 2.0, collect(time.Now()), "each stream is counted once")
+	require.Equal(t, 0.0, collect(time.Now().Add(2*time.Minute)))
+}
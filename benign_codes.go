@@ -0,0 +1,46 @@
+package grpc_prometheus
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+)
+
+// WithBenignCodes marks the given codes as expected terminations of the
+// method fullMethod, e.g. "/mwitkow.testproto.TestService/Watch", or of all
+// methods if fullMethod is empty. A typical example is Canceled on watch
+// streams, which clients end by disconnecting. RPCs completing with a benign
+// code are still counted in grpc_server_handled_total, and additionally in
+// grpc_server_benign_handled_total, which error ratio dashboards and SLO
+// alerts can subtract not to page on them. They are left out of the error
+// budget, error spike and failed RPC seconds metrics. The option can be given
+// several times.
+func WithBenignCodes(fullMethod string, benign ...codes.Code) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		if m.benignCodes == nil {
+			m.benignCodes = make(map[methodKey]map[codes.Code]bool)
+			m.serverBenignHandledCounter = m.newCounterVec(
+				m.counterOpts.apply(prom.CounterOpts{
+					Name: "grpc_server_benign_handled_total",
+					Help: "Total number of RPCs completed on the server with a code marked as benign for their method.",
+				}), []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"})
+		}
+		var key methodKey
+		if fullMethod != "" {
+			key.service, key.method = splitMethodName(fullMethod)
+		}
+		if m.benignCodes[key] == nil {
+			m.benignCodes[key] = make(map[codes.Code]bool, len(benign))
+		}
+		for _, code := range benign {
+			m.benignCodes[key][code] = true
+		}
+	}
+}
+
+// benignCode reports whether code is benign for the given method.
+func (m *ServerMetrics) benignCode(service, method string, code codes.Code) bool {
+	if m.benignCodes == nil {
+		return false
+	}
+	return m.benignCodes[methodKey{service, method}][code] || m.benignCodes[methodKey{}][code]
+}
@@ -0,0 +1,71 @@
+package grpc_prometheus
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// CircuitState is the state of a client-side circuit breaker, as exported by
+// the grpc_client_circuit_state gauge.
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = 0
+	CircuitHalfOpen CircuitState = 1
+	CircuitOpen     CircuitState = 2
+)
+
+// BreakerObserver represents circuit-breaker metrics for a gRPC client.
+// Circuit-breaker middlewares report state transitions and the calls they
+// shed to it. Rejected calls never reach the monitoring interceptor, use
+// ClientMetrics.RecordShortCircuit to also account them as handled RPCs.
+type BreakerObserver struct {
+	circuitState      *prom.GaugeVec
+	circuitRejections *prom.CounterVec
+}
+
+// NewBreakerObserver returns a BreakerObserver object.
+func NewBreakerObserver(counterOpts ...CounterOption) *BreakerObserver {
+	opts := counterOptions(counterOpts)
+	return &BreakerObserver{
+		circuitState: prom.NewGaugeVec(
+			prom.GaugeOpts{
+				Name:        "grpc_client_circuit_state",
+				Help:        "State of the client circuit breaker per target and service: 0 closed, 1 half-open, 2 open.",
+				ConstLabels: opts.apply(prom.CounterOpts{}).ConstLabels,
+			}, []string{"target", "grpc_service"}),
+		circuitRejections: prom.NewCounterVec(
+			opts.apply(prom.CounterOpts{
+				Name: "grpc_client_circuit_rejections_total",
+				Help: "Total number of RPCs rejected by the client circuit breaker.",
+			}), []string{"target", "grpc_service", "grpc_method"}),
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (o *BreakerObserver) Describe(ch chan<- *prom.Desc) {
+	o.circuitState.Describe(ch)
+	o.circuitRejections.Describe(ch)
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (o *BreakerObserver) Collect(ch chan<- prom.Metric) {
+	o.circuitState.Collect(ch)
+	o.circuitRejections.Collect(ch)
+}
+
+// StateChanged records that the circuit for service on target transitioned
+// to state.
+func (o *BreakerObserver) StateChanged(target string, service string, state CircuitState) {
+	o.circuitState.WithLabelValues(target, service).Set(float64(state))
+}
+
+// Rejected records that an RPC to fullMethod on target was shed by the
+// circuit breaker.
+func (o *BreakerObserver) Rejected(target string, fullMethod string) {
+	serviceName, methodName := splitMethodName(fullMethod)
+	o.circuitRejections.WithLabelValues(target, serviceName, methodName).Inc()
+}
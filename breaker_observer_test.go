@@ -0,0 +1,36 @@
+package grpc_prometheus
+
+import (
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakerObserver(t *testing.T) {
+	o := NewBreakerObserver()
+	o.StateChanged("backend:443", "mwitkow.testproto.TestService", CircuitOpen)
+	o.Rejected("backend:443", "/mwitkow.testproto.TestService/Ping")
+	o.Rejected("backend:443", "/mwitkow.testproto.TestService/Ping")
+	o.StateChanged("backend:443", "mwitkow.testproto.TestService", CircuitHalfOpen)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(o.circuitState.WithLabelValues("backend:443", "mwitkow.testproto.TestService")))
+	require.Equal(t, 2.0, testutil.ToFloat64(o.circuitRejections.WithLabelValues("backend:443", "mwitkow.testproto.TestService", "Ping")))
+}
+
+func TestBreakerObserverConstLabels(t *testing.T) {
+	o := NewBreakerObserver(WithConstLabels(prom.Labels{"app": "test"}))
+	o.StateChanged("backend:443", "mwitkow.testproto.TestService", CircuitOpen)
+	o.Rejected("backend:443", "/mwitkow.testproto.TestService/Ping")
+
+	reg := prom.NewPedanticRegistry()
+	reg.MustRegister(o)
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	require.Len(t, mfs, 2)
+	for _, mf := range mfs {
+		require.Equal(t, "app", mf.GetMetric()[0].GetLabel()[0].GetName(), mf.GetName())
+		require.Equal(t, "test", mf.GetMetric()[0].GetLabel()[0].GetValue(), mf.GetName())
+	}
+}
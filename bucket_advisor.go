@@ -0,0 +1,144 @@
+package grpc_prometheus
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// advisorQuantiles are the quantiles of the sampled durations that recommended
+// bucket boundaries are derived from.
+var advisorQuantiles = []float64{0.1, 0.25, 0.5, 0.75, 0.9, 0.95, 0.99, 0.999, 1}
+
+// BucketAdvisor is a development-mode helper recommending histogram buckets.
+// It samples raw handling times for a sample period, and then derives a
+// bucket layout from the observed distribution. The recommendation is passed
+// to an optional callback, e.g. for logging, and exported as the
+// grpc_prometheus_recommended_buckets info metric when registered.
+type BucketAdvisor struct {
+	desc             *prom.Desc
+	onRecommendation func(buckets []float64)
+
+	mu          sync.Mutex
+	deadline    time.Time
+	maxSamples  int
+	seen        int
+	samples     []float64
+	recommended []float64
+}
+
+// NewBucketAdvisor returns a BucketAdvisor sampling at most maxSamples
+// durations during samplePeriod from now. onRecommendation, if not nil, is
+// called once with the recommended buckets when the sample period is over.
+func NewBucketAdvisor(samplePeriod time.Duration, maxSamples int, onRecommendation func(buckets []float64)) *BucketAdvisor {
+	return &BucketAdvisor{
+		desc: prom.NewDesc(
+			"grpc_prometheus_recommended_buckets",
+			"Histogram buckets recommended from the handling times sampled by the bucket advisor, in the buckets label.",
+			[]string{"buckets"}, nil),
+		onRecommendation: onRecommendation,
+		deadline:         time.Now().Add(samplePeriod),
+		maxSamples:       maxSamples,
+	}
+}
+
+// WithBucketAdvisor feeds the handling times of all RPCs to the given
+// BucketAdvisor.
+func WithBucketAdvisor(a *BucketAdvisor) ServerMetricsOption {
+	return func(m *ServerMetrics) { m.bucketAdvisor = a }
+}
+
+// Observe samples a duration in seconds. Once the sample period is over, the
+// first call computes the recommendation and further calls are ignored.
+func (a *BucketAdvisor) Observe(seconds float64) {
+	a.mu.Lock()
+	if a.recommended != nil {
+		a.mu.Unlock()
+		return
+	}
+	if time.Now().After(a.deadline) {
+		a.recommended = recommendBuckets(a.samples)
+		a.samples = nil
+		recommended := a.recommended
+		a.mu.Unlock()
+		if a.onRecommendation != nil {
+			a.onRecommendation(recommended)
+		}
+		return
+	}
+	// Reservoir sampling keeps a uniform sample in bounded memory.
+	a.seen++
+	if len(a.samples) < a.maxSamples {
+		a.samples = append(a.samples, seconds)
+	} else if i := rand.Intn(a.seen); i < a.maxSamples {
+		a.samples[i] = seconds
+	}
+	a.mu.Unlock()
+}
+
+// Recommendation returns the recommended buckets, or nil while the sample
+// period isn't over yet.
+func (a *BucketAdvisor) Recommendation() []float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.recommended
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (a *BucketAdvisor) Describe(ch chan<- *prom.Desc) {
+	ch <- a.desc
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (a *BucketAdvisor) Collect(ch chan<- prom.Metric) {
+	buckets := a.Recommendation()
+	if buckets == nil {
+		return
+	}
+	values := make([]string, len(buckets))
+	for i, b := range buckets {
+		values[i] = strconv.FormatFloat(b, 'g', -1, 64)
+	}
+	ch <- prom.MustNewConstMetric(a.desc, prom.GaugeValue, 1, strings.Join(values, ","))
+}
+
+// recommendBuckets derives bucket boundaries from quantiles of the samples,
+// each rounded up to the next 1-2.5-5 value of its decade.
+func recommendBuckets(samples []float64) []float64 {
+	if len(samples) == 0 {
+		return []float64{}
+	}
+	sort.Float64s(samples)
+	var buckets []float64
+	for _, q := range advisorQuantiles {
+		b := roundUpToNice(samples[int(q*float64(len(samples)-1))])
+		if len(buckets) == 0 || b > buckets[len(buckets)-1] {
+			buckets = append(buckets, b)
+		}
+	}
+	return buckets
+}
+
+func roundUpToNice(v float64) float64 {
+	if v <= 0 {
+		return 0.001
+	}
+	decade := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, step := range []float64{1, 2.5, 5, 10} {
+		// Tolerate floating point noise so that exact nice values stay put.
+		if nice := step * decade; v <= nice*(1+1e-9) {
+			return nice
+		}
+	}
+	return 10 * decade
+}
@@ -0,0 +1,31 @@
+package grpc_prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecommendBuckets(t *testing.T) {
+	var samples []float64
+	for i := 1; i <= 1000; i++ {
+		samples = append(samples, float64(i)/1000)
+	}
+	require.Equal(t, []float64{0.1, 0.25, 0.5, 1}, recommendBuckets(samples))
+	require.Equal(t, []float64{0.005}, recommendBuckets([]float64{0.005, 0.005}))
+}
+
+func TestBucketAdvisorRecommendsAfterSamplePeriod(t *testing.T) {
+	var got []float64
+	a := NewBucketAdvisor(10*time.Millisecond, 100, func(buckets []float64) { got = buckets })
+	for i := 0; i < 200; i++ {
+		a.Observe(0.02)
+	}
+	require.Nil(t, a.Recommendation(), "no recommendation before the sample period is over")
+
+	time.Sleep(20 * time.Millisecond)
+	a.Observe(0.02)
+	require.Equal(t, []float64{0.025}, a.Recommendation())
+	require.Equal(t, a.Recommendation(), got)
+}
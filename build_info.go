@@ -0,0 +1,34 @@
+package grpc_prometheus
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+// modulePath is the import path of this module, used to find its version.
+const modulePath = "github.com/grpc-ecosystem/go-grpc-prometheus"
+
+// buildInfoCollector exports grpc_prometheus_build_info for the metrics of
+// a server or client, told apart by the component label.
+type buildInfoCollector struct {
+	desc *prom.Desc
+}
+
+func newBuildInfoCollector(component string, counterOpts counterOptions) *buildInfoCollector {
+	opts := counterOpts.apply(prom.CounterOpts{Name: "grpc_prometheus_build_info"})
+	return &buildInfoCollector{
+		desc: prom.NewDesc(
+			prom.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+			"Versions of go-grpc-prometheus and gRPC the binary was built with, in labels.",
+			[]string{"version", "grpc_version"},
+			mergeLabels(opts.ConstLabels, prom.Labels{"component": component})),
+	}
+}
+
+func (c *buildInfoCollector) Describe(ch chan<- *prom.Desc) {
+	ch <- c.desc
+}
+
+func (c *buildInfoCollector) Collect(ch chan<- prom.Metric) {
+	ch <- prom.MustNewConstMetric(c.desc, prom.GaugeValue, 1, moduleVersion(), grpc.Version)
+}
@@ -0,0 +1,10 @@
+//go:build !go1.12
+// +build !go1.12
+
+package grpc_prometheus
+
+// moduleVersion returns "unknown", build information is only available from
+// Go 1.12 on.
+func moduleVersion() string {
+	return "unknown"
+}
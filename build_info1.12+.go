@@ -0,0 +1,29 @@
+//go:build go1.12
+// +build go1.12
+
+package grpc_prometheus
+
+import (
+	"runtime/debug"
+)
+
+// moduleVersion returns the version of this module the binary was built
+// with, as recorded by the go command.
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
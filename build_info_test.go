@@ -0,0 +1,35 @@
+package grpc_prometheus
+
+import (
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestBuildInfo(t *testing.T) {
+	// Server and client metrics must be registrable side by side.
+	reg := prom.NewPedanticRegistry()
+	reg.MustRegister(NewServerMetrics(), NewClientMetrics())
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	components := map[string]bool{}
+	for _, mf := range mfs {
+		if mf.GetName() != "grpc_prometheus_build_info" {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, lp := range metric.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			require.Equal(t, grpc.Version, labels["grpc_version"])
+			require.NotEmpty(t, labels["version"])
+			require.EqualValues(t, 1, metric.GetGauge().GetValue())
+			components[labels["component"]] = true
+		}
+	}
+	require.Equal(t, map[string]bool{"server": true, "client": true}, components)
+}
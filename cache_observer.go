@@ -0,0 +1,67 @@
+package grpc_prometheus
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// CacheResult is the outcome of a response cache lookup, as exported by the
+// result label of grpc_server_cache_requests_total.
+type CacheResult string
+
+const (
+	// CacheHit is a lookup answered from a fresh cached response.
+	CacheHit CacheResult = "hit"
+	// CacheMiss is a lookup that found no cached response.
+	CacheMiss CacheResult = "miss"
+	// CacheStale is a lookup answered from an expired cached response.
+	CacheStale CacheResult = "stale"
+)
+
+// CacheObserver represents response cache metrics for a gRPC server.
+// Response-cache interceptors report the result of each lookup to it, under
+// the same grpc_service and grpc_method labels as the RPC metrics, so cache
+// metrics can be joined with them, e.g. to compute hit ratios of handled RPCs.
+type CacheObserver struct {
+	cacheRequests *prom.CounterVec
+}
+
+// NewCacheObserver returns a CacheObserver object.
+func NewCacheObserver(counterOpts ...CounterOption) *CacheObserver {
+	return &CacheObserver{
+		cacheRequests: prom.NewCounterVec(
+			counterOptions(counterOpts).apply(prom.CounterOpts{
+				Name: "grpc_server_cache_requests_total",
+				Help: "Total number of response cache lookups for RPCs on the server, by result.",
+			}), []string{"grpc_service", "grpc_method", "result"}),
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (o *CacheObserver) Describe(ch chan<- *prom.Desc) {
+	o.cacheRequests.Describe(ch)
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (o *CacheObserver) Collect(ch chan<- prom.Metric) {
+	o.cacheRequests.Collect(ch)
+}
+
+// Observe records the result of a response cache lookup for an RPC to
+// fullMethod.
+func (o *CacheObserver) Observe(fullMethod string, result CacheResult) {
+	serviceName, methodName := splitMethodName(fullMethod)
+	o.cacheRequests.WithLabelValues(serviceName, methodName, string(result)).Inc()
+}
+
+// InitializeMethod initializes the series of all results for fullMethod to
+// zero, so ratios can be computed before the first lookup of each result.
+func (o *CacheObserver) InitializeMethod(fullMethod string) {
+	serviceName, methodName := splitMethodName(fullMethod)
+	for _, result := range []CacheResult{CacheHit, CacheMiss, CacheStale} {
+		o.cacheRequests.GetMetricWithLabelValues(serviceName, methodName, string(result))
+	}
+}
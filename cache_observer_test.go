@@ -0,0 +1,20 @@
+package grpc_prometheus
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheObserver(t *testing.T) {
+	o := NewCacheObserver()
+	o.InitializeMethod("/mwitkow.testproto.TestService/Ping")
+	o.Observe("/mwitkow.testproto.TestService/Ping", CacheHit)
+	o.Observe("/mwitkow.testproto.TestService/Ping", CacheHit)
+	o.Observe("/mwitkow.testproto.TestService/Ping", CacheMiss)
+
+	require.Equal(t, 2.0, testutil.ToFloat64(o.cacheRequests.WithLabelValues("mwitkow.testproto.TestService", "Ping", "hit")))
+	require.Equal(t, 1.0, testutil.ToFloat64(o.cacheRequests.WithLabelValues("mwitkow.testproto.TestService", "Ping", "miss")))
+	require.Equal(t, 0.0, testutil.ToFloat64(o.cacheRequests.WithLabelValues("mwitkow.testproto.TestService", "Ping", "stale")))
+}
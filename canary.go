@@ -0,0 +1,97 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// CanaryRPC is a unary RPC issued by a Canary.
+type CanaryRPC struct {
+	// Method is the full method name, e.g. "/grpc.health.v1.Health/Check".
+	Method string
+	// Request is sent on every call, and Reply overwritten by the response.
+	Request, Reply interface{}
+}
+
+// HealthCheckRPC returns a CanaryRPC checking the health of service, or of
+// the server if empty, with the standard gRPC health checking protocol.
+func HealthCheckRPC(service string) CanaryRPC {
+	return CanaryRPC{
+		Method:  "/grpc.health.v1.Health/Check",
+		Request: &grpc_health_v1.HealthCheckRequest{Service: service},
+		Reply:   &grpc_health_v1.HealthCheckResponse{},
+	}
+}
+
+// Canary periodically issues no-op RPCs, such as health checks, on a
+// connection, and records them in client metrics of its own carrying the
+// grpc_canary const label, so that the latency and error baselines of the
+// target are continuous even when organic traffic is idle. A Canary is a
+// prom.Collector exporting these metrics, including the handling time
+// histogram.
+type Canary struct {
+	metrics  *ClientMetrics
+	cc       ClientConnInterface
+	interval time.Duration
+	rpcs     []CanaryRPC
+}
+
+// NewCanary returns a Canary issuing rpcs on cc every interval once Run. The
+// grpc_canary label of its metrics is set to name, e.g. the target, to tell
+// canaries apart.
+func NewCanary(name string, cc ClientConnInterface, interval time.Duration, rpcs ...CanaryRPC) *Canary {
+	labels := prom.Labels{"grpc_canary": name}
+	m := NewClientMetrics(WithConstLabels(labels))
+	m.EnableClientHandlingTimeHistogram(WithHistogramConstLabels(labels))
+	return &Canary{
+		metrics:  m,
+		cc:       WrapClientConn(cc, m),
+		interval: interval,
+		rpcs:     rpcs,
+	}
+}
+
+// Run issues the RPCs of c right away and then every interval, until ctx is
+// done. Each RPC must complete within the interval.
+func (c *Canary) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		for _, rpc := range c.rpcs {
+			if ctx.Err() != nil {
+				// Calls on a done context would only record Canceled.
+				return
+			}
+			c.call(ctx, rpc)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Canary) call(ctx context.Context, rpc CanaryRPC) {
+	ctx, cancel := context.WithTimeout(ctx, c.interval)
+	defer cancel()
+	// Failures are recorded by the metrics.
+	c.cc.Invoke(ctx, rpc.Method, rpc.Request, rpc.Reply)
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (c *Canary) Describe(ch chan<- *prom.Desc) {
+	c.metrics.Describe(ch)
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (c *Canary) Collect(ch chan<- prom.Metric) {
+	c.metrics.Collect(ch)
+}
@@ -0,0 +1,81 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var (
+	// canaries must satisfy the Collector interface
+	_ prom.Collector = &Canary{}
+)
+
+func TestCanary(t *testing.T) {
+	server := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, lis.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	c := NewCanary("backend", conn, 10*time.Millisecond, HealthCheckRPC(""), HealthCheckRPC("unknown.Service"))
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		c.Run(runCtx)
+		close(done)
+	}()
+	notFound := c.metrics.clientHandledCounter.WithLabelValues("unary", "grpc.health.v1.Health", "Check", "NotFound")
+	for testutil.ToFloat64(notFound) < 3 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("timeout while waiting for canary RPCs")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	stop()
+	<-done
+	require.True(t, testutil.ToFloat64(c.metrics.clientHandledCounter.WithLabelValues("unary", "grpc.health.v1.Health", "Check", "OK")) >= 3)
+
+	reg := prom.NewPedanticRegistry()
+	reg.MustRegister(c)
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	names := map[string]bool{}
+	for _, mf := range mfs {
+		names[mf.GetName()] = true
+		for _, metric := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, lp := range metric.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			require.Equal(t, "backend", labels["grpc_canary"], mf.GetName())
+		}
+	}
+	require.True(t, names["grpc_client_handled_total"])
+	require.True(t, names["grpc_client_handling_seconds"])
+}
+
+func TestCanaryDoneContext(t *testing.T) {
+	c := NewCanary("backend", nil, time.Second, HealthCheckRPC(""))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// A done context is checked before each call, cc is never used.
+	c.Run(ctx)
+	require.Equal(t, 0.0, testutil.ToFloat64(c.metrics.clientStartedCounter.WithLabelValues("unary", "grpc.health.v1.Health", "Check")))
+}
@@ -0,0 +1,83 @@
+package grpc_prometheus
+
+import (
+	"context"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+)
+
+// Causes of cancelled RPCs in grpc_server_rpc_cancellations_total.
+const (
+	// cancelCauseDeadlineBeforeHandler is an RPC whose deadline was
+	// exceeded before its handler was invoked.
+	cancelCauseDeadlineBeforeHandler = "deadline_exceeded_before_handler"
+	// cancelCauseClientCanceled is an RPC cancelled by the client, while
+	// its handler did not notice.
+	cancelCauseClientCanceled = "client_canceled"
+	// cancelCauseDeadlineExceeded is an RPC whose deadline was exceeded
+	// while its handler ran, without the handler noticing.
+	cancelCauseDeadlineExceeded = "deadline_exceeded"
+	// cancelCauseHandlerObserved is an RPC whose handler returned the
+	// cancellation of its context.
+	cancelCauseHandlerObserved = "handler_observed"
+)
+
+// EnableCancellationCounter turns on grpc_server_rpc_cancellations_total,
+// counting RPCs whose context was done when the handler returned, by cause:
+// the deadline exceeded before the handler was invoked, the client cancelled
+// or the deadline exceeded while the handler ran, or the handler observed
+// the cancellation and returned it. This tells apart whose fault spikes of
+// the Canceled code are.
+func (m *ServerMetrics) EnableCancellationCounter(counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnableCancellationCounter")
+	if !m.serverCancellationCounterEnabled {
+		m.serverCancellationCounter = m.newCounterVec(
+			counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_server_rpc_cancellations_total",
+				Help: "Total number of RPCs on the server whose context was cancelled, by cause.",
+			})), []string{"grpc_type", "grpc_service", "grpc_method", "cause"})
+	}
+	m.serverCancellationCounterEnabled = true
+	m.publishToggles()
+}
+
+// cancellationCause returns the cause of the cancellation of an RPC whose
+// context had errBefore before and errAfter after the handler returned code.
+func cancellationCause(errBefore, errAfter error, code codes.Code) string {
+	switch {
+	case errBefore == context.DeadlineExceeded:
+		return cancelCauseDeadlineBeforeHandler
+	case code == codes.Canceled || code == codes.DeadlineExceeded:
+		return cancelCauseHandlerObserved
+	case errAfter == context.Canceled:
+		return cancelCauseClientCanceled
+	}
+	return cancelCauseDeadlineExceeded
+}
+
+// EnableWastedWorkMetrics turns on grpc_server_wasted_work_total, counting
+// handler executions which completed successfully after the client had
+// already cancelled or the deadline was exceeded, and the
+// grpc_server_wasted_work_seconds histogram of their handling time. The
+// responses of these executions are discarded, which quantifies the value of
+// cancellation checks in handlers.
+func (m *ServerMetrics) EnableWastedWorkMetrics(opts ...HistogramOption) {
+	m.checkNotFrozen("EnableWastedWorkMetrics")
+	if m.serverWastedWorkCounter == nil {
+		histOpts := prom.HistogramOpts{
+			Name:    "grpc_server_wasted_work_seconds",
+			Help:    "Histogram of handling time (seconds) of RPCs completed successfully by the server after their context was cancelled.",
+			Buckets: prom.DefBuckets,
+		}
+		for _, o := range opts {
+			o(&histOpts)
+		}
+		m.serverWastedWorkCounter = m.newCounterVec(
+			m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_server_wasted_work_total",
+				Help: "Total number of RPCs completed successfully by the server after their context was cancelled.",
+			}), []string{"grpc_type", "grpc_service", "grpc_method"})
+		m.serverWastedWorkHistogram = m.newHistogramVec(histOpts, []string{"grpc_type", "grpc_service", "grpc_method"})
+	}
+}
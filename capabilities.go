@@ -0,0 +1,137 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"regexp"
+	"runtime"
+	"strings"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// capabilitiesKey carries the capabilities of clients, see
+// ClientMetrics.EnableCapabilityHeader.
+const capabilitiesKey = "x-grpc-prometheus-capabilities"
+
+// unknownCapability is the label value of capabilities a client didn't send.
+const unknownCapability = "unknown"
+
+// otherCapability is the label value of capabilities outside of the bounded
+// set of label values.
+const otherCapability = "other"
+
+// peerCapabilities are the label values of the capabilities of a client.
+type peerCapabilities struct {
+	goVersion, os, arch, libVersion string
+}
+
+var unknownPeerCapabilities = peerCapabilities{unknownCapability, unknownCapability, unknownCapability, unknownCapability}
+
+// knownOSes and knownArches bound the os and arch label values.
+var (
+	knownOSes = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true, "illumos": true, "ios": true,
+		"js": true, "linux": true, "netbsd": true, "openbsd": true, "plan9": true, "solaris": true, "windows": true,
+	}
+	knownArches = map[string]bool{
+		"386": true, "amd64": true, "arm": true, "arm64": true, "mips": true, "mips64": true, "mips64le": true,
+		"mipsle": true, "ppc64": true, "ppc64le": true, "riscv64": true, "s390x": true, "wasm": true,
+	}
+)
+
+var (
+	goMinorVersion  = regexp.MustCompile(`^go1\.[0-9]+`)
+	libMinorVersion = regexp.MustCompile(`^v[0-9]+\.[0-9]+`)
+)
+
+// formatCapabilities returns the capability header value: the Go version,
+// the OS and architecture, and the version of this module, e.g.
+// "go1.12.4 linux/amd64 v1.3.0".
+func formatCapabilities(goVersion, os, arch, libVersion string) string {
+	return goVersion + " " + os + "/" + arch + " " + libVersion
+}
+
+// parseCapabilities returns the bounded label values of a capability header
+// value: versions are truncated to their minor version, and values outside
+// of the known ones are reported as "other".
+func parseCapabilities(header string) peerCapabilities {
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return unknownPeerCapabilities
+	}
+	c := peerCapabilities{otherCapability, otherCapability, otherCapability, otherCapability}
+	if v := goMinorVersion.FindString(fields[0]); v != "" {
+		c.goVersion = v
+	}
+	if i := strings.IndexByte(fields[1], '/'); i >= 0 {
+		if os := fields[1][:i]; knownOSes[os] {
+			c.os = os
+		}
+		if arch := fields[1][i+1:]; knownArches[arch] {
+			c.arch = arch
+		}
+	}
+	if fields[2] == unknownCapability {
+		c.libVersion = unknownCapability
+	} else if v := libMinorVersion.FindString(fields[2]); v != "" {
+		c.libVersion = v
+	}
+	return c
+}
+
+// EnableCapabilityHeader makes the client interceptors attach the Go
+// version, OS, architecture and go-grpc-prometheus version of the client to
+// the metadata of RPCs, for servers to count with
+// ServerMetrics.EnablePeerCapabilityCounter.
+func (m *ClientMetrics) EnableCapabilityHeader() {
+	m.checkNotFrozen("EnableCapabilityHeader")
+	m.capabilities = formatCapabilities(runtime.Version(), runtime.GOOS, runtime.GOARCH, moduleVersion())
+}
+
+// withCapabilities attaches the capability header to the outgoing metadata
+// of ctx if enabled.
+func (m *ClientMetrics) withCapabilities(ctx context.Context) context.Context {
+	if m.capabilities == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, capabilitiesKey, m.capabilities)
+}
+
+// EnablePeerCapabilityCounter turns on grpc_server_peer_handled_total,
+// counting RPCs completed by the Go minor version, OS, architecture and
+// go-grpc-prometheus minor version of the client, as sent by clients with
+// ClientMetrics.EnableCapabilityHeader. This tells which client runtimes
+// generate errors across a fleet. RPCs of clients not sending the header
+// are counted as "unknown", unexpected values as "other".
+func (m *ServerMetrics) EnablePeerCapabilityCounter(counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnablePeerCapabilityCounter")
+	if m.serverPeerCapabilityCounter != nil {
+		return
+	}
+	m.serverPeerCapabilityCounter = m.newCounterVec(
+		counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+			Name: "grpc_server_peer_handled_total",
+			Help: "Total number of RPCs completed on the server by client runtime, regardless of success or failure.",
+		})), []string{"grpc_service", "grpc_code", "go_version", "os", "arch", "lib_version"})
+}
+
+// peerCapabilitiesOf returns the capabilities of the client of the RPC of
+// ctx.
+func peerCapabilitiesOf(ctx context.Context) peerCapabilities {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(capabilitiesKey)
+	if len(values) == 0 {
+		return unknownPeerCapabilities
+	}
+	return parseCapabilities(values[0])
+}
+
+func (r *serverReporter) observePeerCapabilities(code codes.Code) {
+	c := r.capabilities
+	if c == (peerCapabilities{}) {
+		c = unknownPeerCapabilities
+	}
+	r.metrics.serverPeerCapabilityCounter.WithLabelValues(r.serviceName, code.String(), c.goVersion, c.os, c.arch, c.libVersion).Inc()
+}
@@ -0,0 +1,59 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		want   peerCapabilities
+	}{
+		{"go1.12.4 linux/amd64 v1.3.0", peerCapabilities{"go1.12", "linux", "amd64", "v1.3"}},
+		{"go1.9 darwin/arm64 unknown", peerCapabilities{"go1.9", "darwin", "arm64", "unknown"}},
+		{"devel-abc plan10/quantum (devel)", peerCapabilities{"other", "other", "other", "other"}},
+		{"go1.12 linux v1.3.0", peerCapabilities{"go1.12", "other", "other", "v1.3"}},
+		{"", unknownPeerCapabilities},
+		{"go1.12 linux/amd64", unknownPeerCapabilities},
+	} {
+		require.Equal(t, tc.want, parseCapabilities(tc.header), "header %q", tc.header)
+	}
+}
+
+func TestPeerCapabilityCounter(t *testing.T) {
+	client := NewClientMetrics()
+	client.EnableCapabilityHeader()
+	server := NewServerMetrics()
+	server.EnablePeerCapabilityCounter()
+
+	// The invoker hands the outgoing metadata of the client to the server.
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		_, err := server.UnaryServerInterceptor()(metadata.NewIncomingContext(context.Background(), md), req, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, status.Error(codes.Unavailable, "down")
+			})
+		return err
+	}
+	err := client.UnaryClientInterceptor()(context.Background(), "/mwitkow.testproto.TestService/Ping", &pb_testproto.PingRequest{}, &pb_testproto.PingResponse{}, nil, invoker)
+	require.Error(t, err)
+
+	want := parseCapabilities(client.capabilities)
+	require.NotEqual(t, unknownPeerCapabilities, want)
+	requireValue(t, 1, server.serverPeerCapabilityCounter.WithLabelValues("mwitkow.testproto.TestService", "Unavailable", want.goVersion, want.os, want.arch, want.libVersion))
+
+	// Clients not sending the header are counted as unknown.
+	server.UnaryServerInterceptor()(context.Background(), &pb_testproto.PingRequest{}, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return &pb_testproto.PingResponse{}, nil
+		})
+	requireValue(t, 1, server.serverPeerCapabilityCounter.WithLabelValues("mwitkow.testproto.TestService", "OK", "unknown", "unknown", "unknown", "unknown"))
+}
@@ -0,0 +1,245 @@
+package grpc_prometheus
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// CounterSample is the value of one counter series in a checkpoint.
+type CounterSample struct {
+	Name   string            `json:"name"`
+	Help   string            `json:"help,omitempty"`
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// CheckpointStore persists counter values across restarts.
+type CheckpointStore interface {
+	// Save replaces the stored checkpoint with samples.
+	Save(samples []CounterSample) error
+	// Load returns the stored checkpoint, or no samples if there is none.
+	Load() ([]CounterSample, error)
+}
+
+// NewFileCheckpointStore returns a CheckpointStore keeping the checkpoint as
+// JSON in the file at path. The file is replaced atomically on every save.
+func NewFileCheckpointStore(path string) CheckpointStore {
+	return &fileCheckpointStore{path: path}
+}
+
+type fileCheckpointStore struct {
+	path string
+}
+
+func (s *fileCheckpointStore) Save(samples []CounterSample) error {
+	data, err := json.Marshal(samples)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *fileCheckpointStore) Load() ([]CounterSample, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var samples []CounterSample
+	if err := json.Unmarshal(data, &samples); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// checkpointedCounter is a counter covered by checkpoints.
+type checkpointedCounter struct {
+	help   string
+	labels []string
+	// vec returns the vector holding the series of service, and vecs all
+	// vectors, which are several when sharded.
+	vec  func(service string) *counterVec
+	vecs func() []*counterVec
+}
+
+// EnableCounterCheckpoints restores the started, handled and message
+// counters, and the long-term handled counter if enabled, from the checkpoint
+// in store, and then saves their values to store every interval. This avoids
+// rate() artifacts from counter resets on restarts, which dominate for
+// methods with very little traffic.
+//
+// This changes counter semantics: counters no longer start from zero on
+// process start. It must be called before any RPC is handled. The returned
+// stop function stops the periodic saving after a final save, and should be
+// called on shutdown.
+func (m *ServerMetrics) EnableCounterCheckpoints(store CheckpointStore, interval time.Duration) (stop func() error, err error) {
+	counters := m.checkpointedCounters()
+	samples, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range samples {
+		c, ok := counters[s.Name]
+		if !ok {
+			continue
+		}
+		if counter, err := c.vec(s.Labels["grpc_service"]).GetMetricWith(prom.Labels(s.Labels)); err == nil {
+			counter.Add(s.Value)
+		}
+	}
+
+	return saveCountersPeriodically(counters, store, interval, m.checkpointErrorHandler), nil
+}
+
+// WithCheckpointErrorHandler sets the function called with the errors of the
+// periodic saves of EnableCounterCheckpoints and EnableMultiProcessExport,
+// e.g. to log them. By default they are ignored, the next save being retried
+// after the interval. The error of the final save is returned by their stop
+// function instead.
+func WithCheckpointErrorHandler(f func(error)) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.checkpointErrorHandler = f
+	}
+}
+
+// saveCountersPeriodically saves the values of counters to store every
+// interval, until the returned stop function is called. Errors of the
+// periodic saves are passed to onError, if not nil.
+func saveCountersPeriodically(counters map[string]checkpointedCounter, store CheckpointStore, interval time.Duration, onError func(error)) (stop func() error) {
+	save := func() error {
+		samples, err := snapshotCounters(counters)
+		if err != nil {
+			return err
+		}
+		return store.Save(samples)
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := save(); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() error {
+		close(done)
+		wg.Wait()
+		return save()
+	}
+}
+
+func (m *ServerMetrics) checkpointedCounters() map[string]checkpointedCounter {
+	rpcLabels := []string{"grpc_type", "grpc_service", "grpc_method"}
+	counters := make(map[string]checkpointedCounter)
+	add := func(opts prom.CounterOpts, labels []string, vec func(service string) *counterVec, shardVec func(*serviceShard) *counterVec) {
+		c := checkpointedCounter{help: opts.Help, labels: labels, vec: vec}
+		if m.serverShards != nil && shardVec != nil {
+			c.vecs = func() []*counterVec {
+				var vecs []*counterVec
+				for _, shard := range m.serverShards.all() {
+					vecs = append(vecs, shardVec(shard))
+				}
+				return vecs
+			}
+		} else {
+			c.vecs = func() []*counterVec { return []*counterVec{vec("")} }
+		}
+		counters[prom.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)] = c
+	}
+	add(m.counterOpts.apply(serverHandledCounterOpts), append(rpcLabels, "grpc_code"), m.handledCounter,
+		func(s *serviceShard) *counterVec { return s.handled })
+	if !m.serverStartedCounterDisabled {
+		add(m.counterOpts.apply(serverStartedCounterOpts), rpcLabels, m.startedCounter,
+			func(s *serviceShard) *counterVec { return s.started })
+	}
+	if !m.serverMsgCountersDisabled {
+		add(m.counterOpts.apply(serverStreamMsgReceivedOpts), rpcLabels, m.msgReceivedCounter,
+			func(s *serviceShard) *counterVec { return s.msgReceived })
+		add(m.counterOpts.apply(serverStreamMsgSentOpts), rpcLabels, m.msgSentCounter,
+			func(s *serviceShard) *counterVec { return s.msgSent })
+	}
+	if m.serverLongTermHandledCounterEnabled {
+		add(m.serverLongTermHandledCounterOpts, []string{"grpc_service", "grpc_method"},
+			func(string) *counterVec { return m.serverLongTermHandledCounter }, nil)
+	}
+	return counters
+}
+
+// snapshotCounters returns the current values of counters, without their
+// constant labels.
+func snapshotCounters(counters map[string]checkpointedCounter) ([]CounterSample, error) {
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var samples []CounterSample
+	for _, name := range names {
+		c := counters[name]
+		for _, vec := range c.vecs() {
+			for _, metric := range collectMetrics(vec) {
+				var pb dto.Metric
+				if err := metric.Write(&pb); err != nil {
+					return nil, err
+				}
+				labels := make(map[string]string, len(c.labels))
+				for _, l := range pb.GetLabel() {
+					for _, labelName := range c.labels {
+						if l.GetName() == labelName {
+							labels[labelName] = l.GetValue()
+						}
+					}
+				}
+				samples = append(samples, CounterSample{Name: name, Help: c.help, Labels: labels, Value: pb.GetCounter().GetValue()})
+			}
+		}
+	}
+	return samples, nil
+}
+
+// collectMetrics returns the metrics collected from c.
+func collectMetrics(c prom.Collector) []prom.Metric {
+	ch := make(chan prom.Metric)
+	var metrics []prom.Metric
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	for metric := range ch {
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
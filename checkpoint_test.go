@@ -0,0 +1,95 @@
+package grpc_prometheus
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounterCheckpointsSurviveRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := NewFileCheckpointStore(filepath.Join(dir, "counters.json"))
+
+	before := NewServerMetrics(WithConstLabels(prometheus.Labels{"env": "test"}))
+	stop, err := before.EnableCounterCheckpoints(store, time.Hour)
+	require.NoError(t, err)
+	before.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK").Add(3)
+	require.NoError(t, stop())
+
+	after := NewServerMetrics(WithConstLabels(prometheus.Labels{"env": "test"}))
+	stop, err = after.EnableCounterCheckpoints(store, time.Hour)
+	require.NoError(t, err)
+	defer stop()
+	requireValue(t, 3, after.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+}
+
+func TestCounterCheckpointsWithUniqueNamesAndShards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := NewFileCheckpointStore(filepath.Join(dir, "counters.json"))
+	newMetrics := func() *ServerMetrics {
+		m := NewServerMetrics(WithUniqueNameSuffix())
+		m.Configure(WithShardedCollectors())
+		m.EnableLongTermHandledCounter()
+		return m
+	}
+
+	before := newMetrics()
+	stop, err := before.EnableCounterCheckpoints(store, time.Hour)
+	require.NoError(t, err)
+	before.handledCounter("mwitkow.testproto.TestService").WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK").Add(3)
+	before.handledCounter("mwitkow.testproto.OtherService").WithLabelValues("unary", "mwitkow.testproto.OtherService", "Ping", "OK").Add(2)
+	before.serverLongTermHandledCounter.WithLabelValues("mwitkow.testproto.TestService", "Ping").Add(3)
+	require.NoError(t, stop())
+	samples, err := store.Load()
+	require.NoError(t, err)
+	names := map[string]bool{}
+	for _, s := range samples {
+		names[s.Name] = true
+	}
+	require.True(t, names["grpc_server_handled_fork_total"])
+	require.True(t, names["grpc_server_handled_longterm_fork_total"])
+
+	after := newMetrics()
+	stop, err = after.EnableCounterCheckpoints(store, time.Hour)
+	require.NoError(t, err)
+	defer stop()
+	requireValue(t, 3, after.handledCounter("mwitkow.testproto.TestService").WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+	requireValue(t, 2, after.handledCounter("mwitkow.testproto.OtherService").WithLabelValues("unary", "mwitkow.testproto.OtherService", "Ping", "OK"))
+	requireValue(t, 3, after.serverLongTermHandledCounter.WithLabelValues("mwitkow.testproto.TestService", "Ping"))
+}
+
+// failingCheckpointStore is a CheckpointStore failing to save.
+type failingCheckpointStore struct{}
+
+func (failingCheckpointStore) Save(samples []CounterSample) error { return errors.New("disk full") }
+func (failingCheckpointStore) Load() ([]CounterSample, error)     { return nil, nil }
+
+func TestCounterCheckpointsReportSaveErrors(t *testing.T) {
+	errs := make(chan error, 1)
+	m := NewServerMetrics()
+	m.Configure(WithCheckpointErrorHandler(func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}))
+	stop, err := m.EnableCounterCheckpoints(failingCheckpointStore{}, time.Millisecond)
+	require.NoError(t, err)
+	select {
+	case err := <-errs:
+		require.EqualError(t, err, "disk full")
+	case <-time.After(5 * time.Second):
+		t.Fatal("periodic save error not reported")
+	}
+	require.EqualError(t, stop(), "disk full")
+}
@@ -12,7 +12,8 @@ import (
 var (
 	// DefaultClientMetrics is the default instance of ClientMetrics. It is
 	// intended to be used in conjunction the default Prometheus metrics
-	// registry.
+	// registry. Failures to register with it are reported by
+	// DefaultRegistrationErrors.
 	DefaultClientMetrics = NewClientMetrics()
 
 	// UnaryClientInterceptor is a gRPC client-side interceptor that provides Prometheus monitoring for Unary RPCs.
@@ -23,10 +24,16 @@ var (
 )
 
 func init() {
-	prom.MustRegister(DefaultClientMetrics.clientStartedCounter)
-	prom.MustRegister(DefaultClientMetrics.clientHandledCounter)
-	prom.MustRegister(DefaultClientMetrics.clientStreamMsgReceived)
-	prom.MustRegister(DefaultClientMetrics.clientStreamMsgSent)
+	registerDefault(
+		DefaultClientMetrics.buildInfo,
+		DefaultClientMetrics.clientStartedCounter,
+		DefaultClientMetrics.clientHandledCounter,
+		DefaultClientMetrics.clientStreamMsgReceived,
+		DefaultClientMetrics.clientStreamMsgSent,
+		DefaultClientMetrics.clientShortCircuitedCounter,
+		DefaultClientMetrics.clientStreamCreationFailures,
+		DefaultClientMetrics.clientWarmupHistogram,
+	)
 }
 
 // EnableClientHandlingTimeHistogram turns on recording of handling time of
@@ -35,7 +42,7 @@ func init() {
 // default Prometheus metrics registry.
 func EnableClientHandlingTimeHistogram(opts ...HistogramOption) {
 	DefaultClientMetrics.EnableClientHandlingTimeHistogram(opts...)
-	prom.Register(DefaultClientMetrics.clientHandledHistogram)
+	registerDefault(DefaultClientMetrics.clientHandledHistogram)
 }
 
 // EnableClientStreamReceiveTimeHistogram turns on recording of
@@ -44,7 +51,7 @@ func EnableClientHandlingTimeHistogram(opts ...HistogramOption) {
 // default Prometheus metrics registry.
 func EnableClientStreamReceiveTimeHistogram(opts ...HistogramOption) {
 	DefaultClientMetrics.EnableClientStreamReceiveTimeHistogram(opts...)
-	prom.Register(DefaultClientMetrics.clientStreamRecvHistogram)
+	registerDefault(DefaultClientMetrics.clientStreamRecvHistogram)
 }
 
 // EnableClientStreamSendTimeHistogram turns on recording of
@@ -53,5 +60,22 @@ func EnableClientStreamReceiveTimeHistogram(opts ...HistogramOption) {
 // default Prometheus metrics registry.
 func EnableClientStreamSendTimeHistogram(opts ...HistogramOption) {
 	DefaultClientMetrics.EnableClientStreamSendTimeHistogram(opts...)
-	prom.Register(DefaultClientMetrics.clientStreamSendHistogram)
+	registerDefault(DefaultClientMetrics.clientStreamSendHistogram)
+}
+
+// DisableClientStartedCounter turns off grpc_client_started_total. This
+// function acts on the DefaultClientMetrics variable and the default
+// Prometheus metrics registry.
+func DisableClientStartedCounter() {
+	DefaultClientMetrics.DisableClientStartedCounter()
+	prom.Unregister(DefaultClientMetrics.clientStartedCounter)
+}
+
+// DisableClientMsgCounters turns off grpc_client_msg_received_total and
+// grpc_client_msg_sent_total. This function acts on the DefaultClientMetrics
+// variable and the default Prometheus metrics registry.
+func DisableClientMsgCounters() {
+	DefaultClientMetrics.DisableClientMsgCounters()
+	prom.Unregister(DefaultClientMetrics.clientStreamMsgReceived)
+	prom.Unregister(DefaultClientMetrics.clientStreamMsgSent)
 }
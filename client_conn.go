@@ -0,0 +1,51 @@
+package grpc_prometheus
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// ClientConnInterface is implemented by connections client stubs issue RPCs
+// on, such as *grpc.ClientConn, mocks and in-process adapters. It has the
+// methods of grpc.ClientConnInterface, which newer versions of gRPC define.
+type ClientConnInterface interface {
+	Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error
+	NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error)
+}
+
+// WrapClientConn returns cc monitored by m, as if it had been dialed with
+// its interceptors, for stubs created on connections whose dial options
+// can't be changed.
+func WrapClientConn(cc ClientConnInterface, m *ClientMetrics) ClientConnInterface {
+	return &monitoredClientConn{
+		cc:     cc,
+		unary:  m.UnaryClientInterceptor(),
+		stream: m.StreamClientInterceptor(),
+	}
+}
+
+type monitoredClientConn struct {
+	cc     ClientConnInterface
+	unary  grpc.UnaryClientInterceptor
+	stream grpc.StreamClientInterceptor
+}
+
+// clientConn returns the *grpc.ClientConn passed to the interceptors, if cc
+// is one.
+func (c *monitoredClientConn) clientConn() *grpc.ClientConn {
+	cc, _ := c.cc.(*grpc.ClientConn)
+	return cc
+}
+
+func (c *monitoredClientConn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	return c.unary(ctx, method, args, reply, c.clientConn(), func(ctx context.Context, method string, req, reply interface{}, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return c.cc.Invoke(ctx, method, req, reply, opts...)
+	}, opts...)
+}
+
+func (c *monitoredClientConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return c.stream(ctx, desc, c.clientConn(), method, func(ctx context.Context, desc *grpc.StreamDesc, _ *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return c.cc.NewStream(ctx, desc, method, opts...)
+	}, opts...)
+}
@@ -0,0 +1,54 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// gRPC connections must satisfy the ClientConnInterface interface
+	_ ClientConnInterface = (*grpc.ClientConn)(nil)
+)
+
+// fakeClientConn is a ClientConnInterface failing unary RPCs with err and
+// streaming msgs messages.
+type fakeClientConn struct {
+	err  error
+	msgs int
+}
+
+func (c fakeClientConn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	return c.err
+}
+
+func (c fakeClientConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return &bidiClientStream{msgs: c.msgs}, nil
+}
+
+func TestWrapClientConn(t *testing.T) {
+	m := NewClientMetrics()
+	cc := WrapClientConn(fakeClientConn{err: status.Error(codes.NotFound, ""), msgs: 3}, m)
+
+	err := cc.Invoke(context.Background(), "/mwitkow.testproto.TestService/Ping", nil, nil)
+	require.Equal(t, codes.NotFound, status.Code(err))
+	requireValue(t, 1, m.clientStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValue(t, 1, m.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "NotFound"))
+
+	desc := &grpc.StreamDesc{StreamName: "PingList", ServerStreams: true}
+	stream, err := cc.NewStream(context.Background(), desc, "/mwitkow.testproto.TestService/PingList")
+	require.NoError(t, err)
+	for {
+		if err := stream.RecvMsg(nil); err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	requireValue(t, 3, m.clientStreamMsgReceived.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
+	requireValue(t, 1, m.clientHandledCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "OK"))
+}
@@ -3,21 +3,40 @@ package grpc_prometheus
 import (
 	"context"
 	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	prom "github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
 // ClientMetrics represents a collection of metrics to be registered on a
 // Prometheus metrics registry for a gRPC client.
 type ClientMetrics struct {
+	counterOpts counterOptions
+	buildInfo   *buildInfoCollector
+
+	// frozen holds the collectors exported since the first call to Describe.
+	freezeOnce sync.Once
+	frozen     atomic.Value
+
 	clientStartedCounter    *prom.CounterVec
 	clientHandledCounter    *prom.CounterVec
 	clientStreamMsgReceived *prom.CounterVec
 	clientStreamMsgSent     *prom.CounterVec
 
+	clientStartedCounterDisabled bool
+	clientMsgCountersDisabled    bool
+	// clientPerAttempt makes the started and handled counters count attempts.
+	clientPerAttempt bool
+
+	clientShortCircuitedCounter  *prom.CounterVec
+	clientStreamCreationFailures *prom.CounterVec
+
 	clientHandledHistogramEnabled bool
 	clientHandledHistogramOpts    prom.HistogramOpts
 	clientHandledHistogram        *prom.HistogramVec
@@ -29,8 +48,57 @@ type ClientMetrics struct {
 	clientStreamSendHistogramEnabled bool
 	clientStreamSendHistogramOpts    prom.HistogramOpts
 	clientStreamSendHistogram        *prom.HistogramVec
+
+	// runtime holds the *clientRuntimeConfig of the settings that can change
+	// after registration.
+	runtime runtimeConfigStore
+
+	clientLongTermHandledCounterEnabled bool
+	clientLongTermHandledCounter        *prom.CounterVec
+
+	clientStreamReconnectCounter      *prom.CounterVec
+	clientStreamReconnectGapHistogram *prom.HistogramVec
+
+	clientShardExtractor      ShardExtractor
+	clientKnownShards         map[string]bool
+	clientShardHandledCounter *prom.CounterVec
+
+	clientClockSkewGauge *prom.GaugeVec
+
+	clientStreamMsgErrorCounter *prom.CounterVec
+
+	// capabilities is the capability header value sent with RPCs, if enabled.
+	capabilities string
+
+	clientPrematureTimeouts *prematureTimeouts
+
+	clientUnsafeRetries *unsafeRetries
+
+	clientPhaseHistogram *prom.HistogramVec
+
+	clientWarmupHistogram *prom.HistogramVec
+
+	clientDeadlineRemainingHistogramEnabled bool
+	clientDeadlineRemainingHistogramOpts    prom.HistogramOpts
+	clientDeadlineRemainingHistogram        *prom.HistogramVec
+
+	configInfo     *ConfigInfo
+	configInfoDesc *prom.Desc
 }
 
+// Options of the client started and handled counters, shared with
+// WithPerCallAccounting.
+var (
+	clientStartedCounterOpts = prom.CounterOpts{
+		Name: "grpc_client_started_total",
+		Help: "Total number of RPCs started on the client.",
+	}
+	clientHandledCounterOpts = prom.CounterOpts{
+		Name: "grpc_client_handled_total",
+		Help: "Total number of RPCs completed by the client, regardless of success or failure.",
+	}
+)
+
 // NewClientMetrics returns a ClientMetrics object. Use a new instance of
 // ClientMetrics when not using the default Prometheus metrics registry, for
 // example when wanting to control which metrics are added to a registry as
@@ -38,17 +106,14 @@ type ClientMetrics struct {
 func NewClientMetrics(counterOpts ...CounterOption) *ClientMetrics {
 	opts := counterOptions(counterOpts)
 	return &ClientMetrics{
+		counterOpts: opts,
+		buildInfo:   newBuildInfoCollector("client", opts),
+
 		clientStartedCounter: prom.NewCounterVec(
-			opts.apply(prom.CounterOpts{
-				Name: "grpc_client_started_total",
-				Help: "Total number of RPCs started on the client.",
-			}), []string{"grpc_type", "grpc_service", "grpc_method"}),
+			opts.apply(clientStartedCounterOpts), []string{"grpc_type", "grpc_service", "grpc_method"}),
 
 		clientHandledCounter: prom.NewCounterVec(
-			opts.apply(prom.CounterOpts{
-				Name: "grpc_client_handled_total",
-				Help: "Total number of RPCs completed by the client, regardless of success or failure.",
-			}), []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"}),
+			opts.apply(clientHandledCounterOpts), []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"}),
 
 		clientStreamMsgReceived: prom.NewCounterVec(
 			opts.apply(prom.CounterOpts{
@@ -62,6 +127,26 @@ func NewClientMetrics(counterOpts ...CounterOption) *ClientMetrics {
 				Help: "Total number of gRPC stream messages sent by the client.",
 			}), []string{"grpc_type", "grpc_service", "grpc_method"}),
 
+		clientShortCircuitedCounter: prom.NewCounterVec(
+			opts.apply(prom.CounterOpts{
+				Name: "grpc_client_short_circuited_total",
+				Help: "Total number of RPCs failed by the client before being sent, e.g. by an open circuit breaker.",
+			}), []string{"grpc_service", "grpc_method", "grpc_code", "reason"}),
+
+		clientStreamCreationFailures: prom.NewCounterVec(
+			opts.apply(prom.CounterOpts{
+				Name: "grpc_client_stream_creation_failures_total",
+				Help: "Total number of streams the client failed to create, by reason: connection, context, call_option or other.",
+			}), []string{"grpc_type", "grpc_service", "grpc_method", "reason"}),
+
+		clientWarmupHistogram: prom.NewHistogramVec(
+			prom.HistogramOpts{
+				Name:        "grpc_client_warmup_seconds",
+				Help:        "Histogram of the time (seconds) taken by Warmup to establish connections to a target.",
+				Buckets:     prom.ExponentialBuckets(0.001, 2, 16),
+				ConstLabels: opts.apply(prom.CounterOpts{}).ConstLabels,
+			}, []string{"target"}),
+
 		clientHandledHistogramEnabled: false,
 		clientHandledHistogramOpts: prom.HistogramOpts{
 			Name:    "grpc_client_handling_seconds",
@@ -83,25 +168,25 @@ func NewClientMetrics(counterOpts ...CounterOption) *ClientMetrics {
 			Buckets: prom.DefBuckets,
 		},
 		clientStreamSendHistogram: nil,
+		clientDeadlineRemainingHistogramOpts: prom.HistogramOpts{
+			Name:    "grpc_client_deadline_remaining_ratio",
+			Help:    "Histogram of the fraction of the deadline of RPCs remaining when they were completed by the client.",
+			Buckets: []float64{0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1},
+		},
 	}
 }
 
 // Describe sends the super-set of all possible descriptors of metrics
 // collected by this Collector to the provided channel and returns once
 // the last descriptor has been sent.
+//
+// The first call, usually on registration, freezes the set of exported
+// metrics, so that Describe and Collect stay consistent. Enabling metrics or
+// changing options afterwards panics.
 func (m *ClientMetrics) Describe(ch chan<- *prom.Desc) {
-	m.clientStartedCounter.Describe(ch)
-	m.clientHandledCounter.Describe(ch)
-	m.clientStreamMsgReceived.Describe(ch)
-	m.clientStreamMsgSent.Describe(ch)
-	if m.clientHandledHistogramEnabled {
-		m.clientHandledHistogram.Describe(ch)
-	}
-	if m.clientStreamRecvHistogramEnabled {
-		m.clientStreamRecvHistogram.Describe(ch)
-	}
-	if m.clientStreamSendHistogramEnabled {
-		m.clientStreamSendHistogram.Describe(ch)
+	m.freezeOnce.Do(func() { m.frozen.Store(m.collectors()) })
+	for _, c := range m.exportedCollectors() {
+		c.Describe(ch)
 	}
 }
 
@@ -109,24 +194,87 @@ func (m *ClientMetrics) Describe(ch chan<- *prom.Desc) {
 // metrics. The implementation sends each collected metric via the
 // provided channel and returns once the last metric has been sent.
 func (m *ClientMetrics) Collect(ch chan<- prom.Metric) {
-	m.clientStartedCounter.Collect(ch)
-	m.clientHandledCounter.Collect(ch)
-	m.clientStreamMsgReceived.Collect(ch)
-	m.clientStreamMsgSent.Collect(ch)
+	for _, c := range m.exportedCollectors() {
+		c.Collect(ch)
+	}
+}
+
+// checkNotFrozen panics if m was already described, usually on registration:
+// the metrics enabled by method would be recorded but never exported, and
+// changing options would race with the RPCs being monitored.
+func (m *ClientMetrics) checkNotFrozen(method string) {
+	if m.frozen.Load() != nil {
+		panic("grpc_prometheus: ClientMetrics." + method + " called after the metrics were registered; configure them before registration")
+	}
+}
+
+// exportedCollectors returns the collectors frozen by Describe, or those of
+// the current options if Describe wasn't called yet.
+func (m *ClientMetrics) exportedCollectors() []prom.Collector {
+	if cs, ok := m.frozen.Load().([]prom.Collector); ok {
+		return cs
+	}
+	return m.collectors()
+}
+
+// collectors returns the collectors of all metrics enabled by the current
+// options.
+func (m *ClientMetrics) collectors() []prom.Collector {
+	var cs []prom.Collector
+	if !m.clientStartedCounterDisabled {
+		cs = append(cs, m.clientStartedCounter)
+	}
+	cs = append(cs, m.buildInfo, m.clientHandledCounter)
+	if !m.clientMsgCountersDisabled {
+		cs = append(cs, m.clientStreamMsgReceived, m.clientStreamMsgSent)
+	}
+	cs = append(cs, m.clientShortCircuitedCounter, m.clientStreamCreationFailures, m.clientWarmupHistogram)
 	if m.clientHandledHistogramEnabled {
-		m.clientHandledHistogram.Collect(ch)
+		cs = append(cs, m.clientHandledHistogram)
 	}
 	if m.clientStreamRecvHistogramEnabled {
-		m.clientStreamRecvHistogram.Collect(ch)
+		cs = append(cs, m.clientStreamRecvHistogram)
 	}
 	if m.clientStreamSendHistogramEnabled {
-		m.clientStreamSendHistogram.Collect(ch)
+		cs = append(cs, m.clientStreamSendHistogram)
+	}
+	if m.clientLongTermHandledCounterEnabled {
+		cs = append(cs, m.clientLongTermHandledCounter)
+	}
+	if m.clientStreamReconnectCounter != nil {
+		cs = append(cs, m.clientStreamReconnectCounter, m.clientStreamReconnectGapHistogram)
+	}
+	if m.clientShardHandledCounter != nil {
+		cs = append(cs, m.clientShardHandledCounter)
+	}
+	if m.clientClockSkewGauge != nil {
+		cs = append(cs, m.clientClockSkewGauge)
+	}
+	if m.clientPrematureTimeouts != nil {
+		cs = append(cs, m.clientPrematureTimeouts.counter)
+	}
+	if m.clientUnsafeRetries != nil {
+		cs = append(cs, m.clientUnsafeRetries.counter)
+	}
+	if m.clientPhaseHistogram != nil {
+		cs = append(cs, m.clientPhaseHistogram)
+	}
+	if m.clientDeadlineRemainingHistogramEnabled {
+		cs = append(cs, m.clientDeadlineRemainingHistogram)
 	}
+	if m.clientStreamMsgErrorCounter != nil {
+		cs = append(cs, m.clientStreamMsgErrorCounter)
+	}
+	if m.configInfo != nil {
+		cs = append(cs, clientConfigInfoCollector{m})
+	}
+	return cs
 }
 
 // EnableClientHandlingTimeHistogram turns on recording of handling time of RPCs.
 // Histogram metrics can be very expensive for Prometheus to retain and query.
 func (m *ClientMetrics) EnableClientHandlingTimeHistogram(opts ...HistogramOption) {
+	m.checkNotFrozen("EnableClientHandlingTimeHistogram")
 	for _, o := range opts {
 		o(&m.clientHandledHistogramOpts)
 	}
@@ -137,11 +285,13 @@ func (m *ClientMetrics) EnableClientHandlingTimeHistogram(opts ...HistogramOptio
 		)
 	}
 	m.clientHandledHistogramEnabled = true
+	m.publishToggles()
 }
 
 // EnableClientStreamReceiveTimeHistogram turns on recording of single message receive time of streaming RPCs.
 // Histogram metrics can be very expensive for Prometheus to retain and query.
 func (m *ClientMetrics) EnableClientStreamReceiveTimeHistogram(opts ...HistogramOption) {
+	m.checkNotFrozen("EnableClientStreamReceiveTimeHistogram")
 	for _, o := range opts {
 		o(&m.clientStreamRecvHistogramOpts)
 	}
@@ -154,11 +304,13 @@ func (m *ClientMetrics) EnableClientStreamReceiveTimeHistogram(opts ...Histogram
 	}
 
 	m.clientStreamRecvHistogramEnabled = true
+	m.publishToggles()
 }
 
 // EnableClientStreamSendTimeHistogram turns on recording of single message send time of streaming RPCs.
 // Histogram metrics can be very expensive for Prometheus to retain and query.
 func (m *ClientMetrics) EnableClientStreamSendTimeHistogram(opts ...HistogramOption) {
+	m.checkNotFrozen("EnableClientStreamSendTimeHistogram")
 	for _, o := range opts {
 		o(&m.clientStreamSendHistogramOpts)
 	}
@@ -171,37 +323,234 @@ func (m *ClientMetrics) EnableClientStreamSendTimeHistogram(opts ...HistogramOpt
 	}
 
 	m.clientStreamSendHistogramEnabled = true
+	m.publishToggles()
+}
+
+// EnableDeadlineRemainingHistogram turns on recording the fraction of the
+// deadline of RPCs remaining when they complete, from 0 to 1, as
+// grpc_client_deadline_remaining_ratio. Values consistently near zero point
+// to call paths starved of time budget. RPCs without deadline are not
+// recorded.
+func (m *ClientMetrics) EnableDeadlineRemainingHistogram(opts ...HistogramOption) {
+	m.checkNotFrozen("EnableDeadlineRemainingHistogram")
+	for _, o := range opts {
+		o(&m.clientDeadlineRemainingHistogramOpts)
+	}
+	if !m.clientDeadlineRemainingHistogramEnabled {
+		m.clientDeadlineRemainingHistogram = prom.NewHistogramVec(
+			m.clientDeadlineRemainingHistogramOpts,
+			[]string{"grpc_type", "grpc_service", "grpc_method"},
+		)
+	}
+	m.clientDeadlineRemainingHistogramEnabled = true
+	m.publishToggles()
+}
+
+// DisableClientStartedCounter turns off grpc_client_started_total, which is
+// redundant with grpc_client_handled_total for users not alerting on RPCs in
+// flight. It must be called before the ClientMetrics is registered.
+func (m *ClientMetrics) DisableClientStartedCounter() {
+	m.checkNotFrozen("DisableClientStartedCounter")
+	m.clientStartedCounterDisabled = true
+	m.publishToggles()
+}
+
+// DisableClientMsgCounters turns off grpc_client_msg_received_total and
+// grpc_client_msg_sent_total. It must be called before the ClientMetrics is
+// registered.
+func (m *ClientMetrics) DisableClientMsgCounters() {
+	m.checkNotFrozen("DisableClientMsgCounters")
+	m.clientMsgCountersDisabled = true
+	m.publishToggles()
+}
+
+// EnableLongTermHandledCounter turns on grpc_client_handled_longterm_total,
+// a minimal counter of completed RPCs labeled only by service and method. It
+// is emitted alongside the detailed metrics and intended for long-retention
+// or remote-write tiers, where the cardinality of grpc_code and grpc_type is
+// not worth paying for.
+func (m *ClientMetrics) EnableLongTermHandledCounter(counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnableLongTermHandledCounter")
+	if !m.clientLongTermHandledCounterEnabled {
+		m.clientLongTermHandledCounter = prom.NewCounterVec(
+			counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_client_handled_longterm_total",
+				Help: "Total number of RPCs completed by the client, regardless of type and status, for long-term retention.",
+			})), []string{"grpc_service", "grpc_method"})
+	}
+	m.clientLongTermHandledCounterEnabled = true
+	m.publishToggles()
+}
+
+// InitializeMetricsFromServiceDesc initializes all metrics, with their
+// appropriate null value, for all methods of the given services, e.g. the
+// ServiceDesc values of generated code. This is the client-side equivalent of
+// ServerMetrics.InitializeMetrics, usable without a server or reflection.
+func (m *ClientMetrics) InitializeMetricsFromServiceDesc(descs ...*grpc.ServiceDesc) {
+	for _, desc := range descs {
+		for _, method := range desc.Methods {
+			preRegisterClientMethod(m, Unary, desc.ServiceName, method.MethodName)
+		}
+		for i := range desc.Streams {
+			stream := &desc.Streams[i]
+			preRegisterClientMethod(m, clientStreamType(stream), desc.ServiceName, stream.StreamName)
+		}
+	}
+}
+
+// RecordShortCircuit accounts a unary RPC to fullMethod that failed with code
+// before reaching the monitoring interceptor, e.g. because an earlier
+// interceptor rejected it while a circuit breaker was open. It is counted as
+// started and handled under the standard metric names, and in
+// grpc_client_short_circuited_total with the given reason. Its handling time
+// is not recorded.
+func (m *ClientMetrics) RecordShortCircuit(fullMethod string, code codes.Code, reason string) {
+	serviceName, methodName := splitMethodName(fullMethod)
+	if m.clientPerAttempt {
+		a := &callAttempts{rpcType: Unary, serviceName: serviceName, methodName: methodName}
+		a.started(m, 0)
+		a.handled(m, code.String(), 0)
+	} else {
+		if !m.clientStartedCounterDisabled {
+			m.clientStartedCounter.WithLabelValues(string(Unary), serviceName, methodName).Inc()
+		}
+		m.clientHandledCounter.WithLabelValues(string(Unary), serviceName, methodName, code.String()).Inc()
+	}
+	if m.clientLongTermHandledCounterEnabled {
+		m.clientLongTermHandledCounter.WithLabelValues(serviceName, methodName).Inc()
+	}
+	m.clientShortCircuitedCounter.WithLabelValues(serviceName, methodName, code.String(), reason).Inc()
 }
 
 // UnaryClientInterceptor is a gRPC client-side interceptor that provides Prometheus monitoring for Unary RPCs.
 func (m *ClientMetrics) UnaryClientInterceptor() func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if metricsSuppressed(ctx) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
 		monitor := newClientReporter(m, Unary, method)
+		monitor.histogramOverride = histogramOverrideFrom(ctx)
+		monitor.shard = m.shardOf(ctx, method)
+		monitor.trackDeadline(ctx)
 		monitor.SentMessage()
+		ctx = m.withCapabilities(ctx)
+		ctx = m.withCallAttempts(ctx, monitor)
+		var trailer metadata.MD
+		if m.clientClockSkewGauge != nil {
+			ctx = metadata.AppendToOutgoingContext(ctx, clockSkewRequestKey, "1")
+		}
+		var callID string
+		if m.clientPrematureTimeouts != nil {
+			ctx, callID = m.clientPrematureTimeouts.start(ctx)
+		}
+		if m.clientClockSkewGauge != nil || m.clientPrematureTimeouts != nil {
+			opts = append(opts, grpc.Trailer(&trailer))
+		}
+		sent := time.Now()
 		err := invoker(ctx, method, req, reply, cc, opts...)
+		if m.clientClockSkewGauge != nil && cc != nil {
+			m.observeClockSkew(cc.Target(), sent, time.Now(), trailer)
+		}
 		if err == nil {
 			monitor.ReceivedMessage()
 		}
 		st, _ := status.FromError(err)
+		if m.clientPrematureTimeouts != nil {
+			m.clientPrematureTimeouts.handled(monitor.serviceName, monitor.methodName, callID, st.Code(), trailer)
+		}
 		monitor.Handled(st.Code())
 		return err
 	}
 }
 
 // StreamClientInterceptor is a gRPC client-side interceptor that provides Prometheus monitoring for Streaming RPCs.
+//
+// The returned streams keep the concurrency guarantees of gRPC: SendMsg and
+// RecvMsg may be called concurrently from different goroutines, e.g. for
+// bidirectional streams, but not SendMsg, or RecvMsg, from several goroutines
+// at once. The completion of a stream is counted exactly once, however often
+// RecvMsg is called after it failed.
 func (m *ClientMetrics) StreamClientInterceptor() func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
 	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if metricsSuppressed(ctx) {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
 		monitor := newClientReporter(m, clientStreamType(desc), method)
+		monitor.histogramOverride = histogramOverrideFrom(ctx)
+		monitor.shard = m.shardOf(ctx, method)
+		monitor.trackDeadline(ctx)
+		ctx = m.withCapabilities(ctx)
+		ctx = m.withCallAttempts(ctx, monitor)
 		clientStream, err := streamer(ctx, desc, cc, method, opts...)
 		if err != nil {
 			st, _ := status.FromError(err)
 			monitor.Handled(st.Code())
+			m.clientStreamCreationFailures.WithLabelValues(string(monitor.rpcType), monitor.serviceName, monitor.methodName, streamCreationFailureReason(ctx, st.Code())).Inc()
 			return nil, err
 		}
+		if m.clientStreamReconnectCounter != nil {
+			if tracker, ok := ctx.Value(streamReconnectKey{}).(*streamReconnectTracker); ok {
+				monitor.streamReconnect = tracker
+				if gap, reconnect := tracker.streamEstablished(time.Now()); reconnect {
+					m.clientStreamReconnectCounter.WithLabelValues(monitor.serviceName, monitor.methodName).Inc()
+					m.clientStreamReconnectGapHistogram.WithLabelValues(monitor.serviceName, monitor.methodName).Observe(gap.Seconds())
+				}
+			}
+		}
 		return &monitoredClientStream{clientStream, monitor}, nil
 	}
 }
 
+// preRegisterClientMethod pre-populates the labels of all client metrics for a method.
+func preRegisterClientMethod(m *ClientMetrics, rpcType grpcType, serviceName, methodName string) {
+	methodType := string(rpcType)
+	// These are just references (no increments), as just referencing will create the labels but not set values.
+	// Labels of attempts are pre-populated for the first attempt.
+	attemptLabels := func(lvs ...string) []string {
+		if m.clientPerAttempt {
+			return append(lvs, "1")
+		}
+		return lvs
+	}
+	if !m.clientStartedCounterDisabled {
+		m.clientStartedCounter.GetMetricWithLabelValues(attemptLabels(methodType, serviceName, methodName)...)
+	}
+	if !m.clientMsgCountersDisabled {
+		m.clientStreamMsgReceived.GetMetricWithLabelValues(methodType, serviceName, methodName)
+		m.clientStreamMsgSent.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if m.clientHandledHistogramEnabled {
+		m.clientHandledHistogram.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if m.clientStreamRecvHistogramEnabled {
+		m.clientStreamRecvHistogram.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if m.clientStreamSendHistogramEnabled {
+		m.clientStreamSendHistogram.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if m.clientLongTermHandledCounterEnabled {
+		m.clientLongTermHandledCounter.GetMetricWithLabelValues(serviceName, methodName)
+	}
+	for _, code := range allCodes {
+		m.clientHandledCounter.GetMetricWithLabelValues(attemptLabels(methodType, serviceName, methodName, code.String())...)
+	}
+}
+
+// streamCreationFailureReason classifies why creating a stream failed.
+func streamCreationFailureReason(ctx context.Context, code codes.Code) string {
+	switch {
+	case ctx.Err() != nil || code == codes.Canceled || code == codes.DeadlineExceeded:
+		return "context"
+	case code == codes.Unavailable:
+		return "connection"
+	case code == codes.Internal || code == codes.InvalidArgument:
+		// Failing call options, e.g. an unknown compressor, are reported
+		// with these codes.
+		return "call_option"
+	}
+	return "other"
+}
+
 func clientStreamType(desc *grpc.StreamDesc) grpcType {
 	if desc.ClientStreams && !desc.ServerStreams {
 		return ClientStream
@@ -212,6 +561,7 @@ func clientStreamType(desc *grpc.StreamDesc) grpcType {
 }
 
 // monitoredClientStream wraps grpc.ClientStream allowing each Sent/Recv of message to increment counters.
+// SendMsg and RecvMsg only share the reporter, which is safe for concurrent use.
 type monitoredClientStream struct {
 	grpc.ClientStream
 	monitor *clientReporter
@@ -223,6 +573,8 @@ func (s *monitoredClientStream) SendMsg(m interface{}) error {
 	timer.ObserveDuration()
 	if err == nil {
 		s.monitor.SentMessage()
+	} else {
+		s.monitor.messageError("sent", err)
 	}
 	return err
 }
@@ -238,7 +590,9 @@ func (s *monitoredClientStream) RecvMsg(m interface{}) error {
 		s.monitor.Handled(codes.OK)
 	} else {
 		st, _ := status.FromError(err)
-		s.monitor.Handled(st.Code())
+		if s.monitor.Handled(st.Code()) && isRecvMsgError(err) {
+			s.monitor.messageError("received", err)
+		}
 	}
 	return err
 }
@@ -0,0 +1,173 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/stats"
+)
+
+// Phases of grpc_client_phase_seconds.
+const (
+	phaseDNS        = "dns"
+	phaseConnect    = "connect"
+	phaseTLS        = "tls"
+	phaseTTFB       = "ttfb"
+	phaseProcessing = "processing"
+)
+
+// EnableClientPhaseHistogram turns on grpc_client_phase_seconds, a latency
+// waterfall per target: the time spent resolving ("dns"), connecting
+// ("connect") and in the TLS handshake ("tls") for each new connection, and
+// for each RPC the time until the first response header ("ttfb") and from
+// there until completion ("processing").
+//
+// Timings are recorded on connections dialed with the options returned by
+// PhaseDialOptions and, for TLS, with credentials wrapped by
+// PhaseTransportCredentials.
+func (m *ClientMetrics) EnableClientPhaseHistogram(opts ...HistogramOption) {
+	m.checkNotFrozen("EnableClientPhaseHistogram")
+	if m.clientPhaseHistogram != nil {
+		return
+	}
+	histOpts := prom.HistogramOpts{
+		Name:        "grpc_client_phase_seconds",
+		Help:        "Histogram of time (seconds) spent by the client in each phase of connections and RPCs to a target.",
+		Buckets:     prom.DefBuckets,
+		ConstLabels: m.counterOpts.apply(prom.CounterOpts{}).ConstLabels,
+	}
+	for _, o := range opts {
+		o(&histOpts)
+	}
+	m.clientPhaseHistogram = prom.NewHistogramVec(histOpts, []string{"target", "phase"})
+}
+
+// PhaseDialOptions returns the options to dial target with to record its
+// phases in the histogram enabled with EnableClientPhaseHistogram: a dialer
+// resolving and connecting itself, and a stats handler timing RPCs. As the
+// dialer resolves target, it must be dialed without name resolution scheme,
+// e.g. "example.com:443". As gRPC keeps a single stats handler, the others to
+// install, e.g. RetryStatsHandler, are given as statsHandlers and chained
+// after the phase one.
+func (m *ClientMetrics) PhaseDialOptions(target string, statsHandlers ...stats.Handler) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return m.dialWithPhases(target, addr, timeout)
+		}),
+		grpc.WithStatsHandler(ChainStatsHandlers(append([]stats.Handler{phaseStatsHandler{m, target}}, statsHandlers...)...)),
+	}
+}
+
+// PhaseTransportCredentials wraps creds to record the duration of TLS
+// handshakes with target in the histogram enabled with
+// EnableClientPhaseHistogram.
+func (m *ClientMetrics) PhaseTransportCredentials(target string, creds credentials.TransportCredentials) credentials.TransportCredentials {
+	return &phaseCredentials{creds, m, target}
+}
+
+func (m *ClientMetrics) observePhase(target, phase string, d time.Duration) {
+	if m.clientPhaseHistogram != nil {
+		m.clientPhaseHistogram.WithLabelValues(target, phase).Observe(d.Seconds())
+	}
+}
+
+func (m *ClientMetrics) dialWithPhases(target, addr string, timeout time.Duration) (net.Conn, error) {
+	deadline := time.Now().Add(timeout)
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return net.DialTimeout("tcp", addr, timeout)
+	}
+	addrs := []string{host}
+	if net.ParseIP(host) == nil {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		start := time.Now()
+		addrs, err = net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		m.observePhase(target, phaseDNS, time.Since(start))
+	}
+	start := time.Now()
+	for _, a := range addrs {
+		var conn net.Conn
+		conn, err = net.DialTimeout("tcp", net.JoinHostPort(a, port), time.Until(deadline))
+		if err == nil {
+			m.observePhase(target, phaseConnect, time.Since(start))
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// phaseCredentials wraps credentials.TransportCredentials to time client
+// handshakes.
+type phaseCredentials struct {
+	credentials.TransportCredentials
+	m      *ClientMetrics
+	target string
+}
+
+func (c *phaseCredentials) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	start := time.Now()
+	conn, authInfo, err := c.TransportCredentials.ClientHandshake(ctx, authority, rawConn)
+	if err == nil {
+		c.m.observePhase(c.target, phaseTLS, time.Since(start))
+	}
+	return conn, authInfo, err
+}
+
+func (c *phaseCredentials) Clone() credentials.TransportCredentials {
+	return &phaseCredentials{c.TransportCredentials.Clone(), c.m, c.target}
+}
+
+type rpcPhasesKey struct{}
+
+// rpcPhases holds the timings of a client-side RPC, in unix nanoseconds,
+// accessed atomically.
+type rpcPhases struct {
+	begin  int64
+	header int64
+}
+
+// phaseStatsHandler is a stats.Handler timing the phases of client-side RPCs
+// to a target.
+type phaseStatsHandler struct {
+	m      *ClientMetrics
+	target string
+}
+
+func (h phaseStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, rpcPhasesKey{}, &rpcPhases{})
+}
+
+func (h phaseStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	p, ok := ctx.Value(rpcPhasesKey{}).(*rpcPhases)
+	if !ok || !s.IsClient() {
+		return
+	}
+	switch s := s.(type) {
+	case *stats.Begin:
+		atomic.StoreInt64(&p.begin, s.BeginTime.UnixNano())
+	case *stats.InHeader:
+		now := time.Now().UnixNano()
+		if atomic.CompareAndSwapInt64(&p.header, 0, now) {
+			h.m.observePhase(h.target, phaseTTFB, time.Duration(now-atomic.LoadInt64(&p.begin)))
+		}
+	case *stats.End:
+		if header := atomic.LoadInt64(&p.header); header != 0 {
+			h.m.observePhase(h.target, phaseProcessing, time.Duration(s.EndTime.UnixNano()-header))
+		}
+	}
+}
+
+func (h phaseStatsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h phaseStatsHandler) HandleConn(ctx context.Context, s stats.ConnStats) {}
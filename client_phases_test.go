@@ -0,0 +1,54 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func TestClientPhaseHistogram(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	pb_testproto.RegisterTestServiceServer(s, &testService{t: t})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	m := NewClientMetrics()
+	m.EnableClientPhaseHistogram()
+	target := "localhost:" + strconv.Itoa(lis.Addr().(*net.TCPAddr).Port)
+	cc, err := grpc.Dial(target, append(m.PhaseDialOptions("test"), grpc.WithInsecure(), grpc.WithBlock())...)
+	require.NoError(t, err)
+	defer cc.Close()
+	_, err = pb_testproto.NewTestServiceClient(cc).Ping(context.Background(), &pb_testproto.PingRequest{})
+	require.NoError(t, err)
+
+	for _, phase := range []string{"dns", "connect", "ttfb", "processing"} {
+		requireValueHistCount(t, 1, m.clientPhaseHistogram.WithLabelValues("test", phase))
+	}
+	requireValueHistCount(t, 0, m.clientPhaseHistogram.WithLabelValues("test", "tls"))
+}
+
+// fakeCredentials completes client handshakes without doing anything.
+type fakeCredentials struct {
+	credentials.TransportCredentials
+}
+
+func (fakeCredentials) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return rawConn, nil, nil
+}
+
+func TestPhaseTransportCredentials(t *testing.T) {
+	m := NewClientMetrics()
+	m.EnableClientPhaseHistogram()
+	creds := m.PhaseTransportCredentials("test", fakeCredentials{})
+	_, _, err := creds.ClientHandshake(context.Background(), "test", nil)
+	require.NoError(t, err)
+	requireValueHistCount(t, 1, m.clientPhaseHistogram.WithLabelValues("test", "tls"))
+}
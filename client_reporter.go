@@ -4,33 +4,67 @@
 package grpc_prometheus
 
 import (
+	"context"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc/codes"
 )
 
+// clientReporter reports the metrics of a client RPC. Apart from the fields
+// set before the RPC starts, it holds no mutable state besides handled, so
+// that a stream may be sent on and received from by different goroutines.
 type clientReporter struct {
 	metrics     *ClientMetrics
 	rpcType     grpcType
 	serviceName string
 	methodName  string
 	startTime   time.Time
+
+	streamReconnect   *streamReconnectTracker
+	shard             string
+	histogramOverride histogramOverride
+
+	// deadlineStart and deadline are set for RPCs with a deadline if the
+	// deadline remaining histogram is enabled.
+	deadlineStart time.Time
+	deadline      time.Time
+
+	// handled is set atomically by the first call to Handled.
+	handled int32
+
+	// config is the runtime config at the start of the RPC, see
+	// serverReporter.
+	config *clientRuntimeConfig
 }
 
 func newClientReporter(m *ClientMetrics, rpcType grpcType, fullMethod string) *clientReporter {
 	r := &clientReporter{
 		metrics: m,
 		rpcType: rpcType,
+		config:  m.runtimeConfig(),
 	}
-	if r.metrics.clientHandledHistogramEnabled {
+	if r.config.handledHistogramEnabled {
 		r.startTime = time.Now()
 	}
 	r.serviceName, r.methodName = splitMethodName(fullMethod)
-	r.metrics.clientStartedCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	if !r.config.startedCounterDisabled && !r.metrics.clientPerAttempt {
+		r.metrics.clientStartedCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	}
 	return r
 }
 
+func (r *clientReporter) trackDeadline(ctx context.Context) {
+	if !r.config.deadlineRemainingHistogramEnabled {
+		return
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		r.deadlineStart = time.Now()
+		r.deadline = deadline
+	}
+}
+
 // timer is a helper interface to time functions.
 type timer interface {
 	ObserveDuration() time.Duration
@@ -46,7 +80,7 @@ func (noOpTimer) ObserveDuration() time.Duration {
 var emptyTimer = noOpTimer{}
 
 func (r *clientReporter) ReceiveMessageTimer() timer {
-	if r.metrics.clientStreamRecvHistogramEnabled {
+	if r.streamHistogramActive(r.config.streamRecvHistogramEnabled) {
 		hist := r.metrics.clientStreamRecvHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName)
 		return prometheus.NewTimer(hist)
 	}
@@ -54,12 +88,33 @@ func (r *clientReporter) ReceiveMessageTimer() timer {
 	return emptyTimer
 }
 
+// handlingTimeHistogramActive reports whether the handling time of the RPC is
+// observed in the handling time histogram, taking its override into account.
+func (r *clientReporter) handlingTimeHistogramActive() bool {
+	return r.config.handledHistogramEnabled && r.histogramOverride != histogramSkip
+}
+
+// streamHistogramActive reports whether the send or receive times of the RPC
+// are observed in a stream histogram, enabled or not, taking its override and
+// the runtime config into account.
+func (r *clientReporter) streamHistogramActive(enabled bool) bool {
+	switch r.histogramOverride {
+	case histogramForce:
+		return enabled
+	case histogramSkip:
+		return false
+	}
+	return enabled && !r.metrics.runtimeConfig().streamHistogramsPaused
+}
+
 func (r *clientReporter) ReceivedMessage() {
-	r.metrics.clientStreamMsgReceived.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	if !r.config.msgCountersDisabled {
+		r.metrics.clientStreamMsgReceived.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	}
 }
 
 func (r *clientReporter) SendMessageTimer() timer {
-	if r.metrics.clientStreamSendHistogramEnabled {
+	if r.streamHistogramActive(r.config.streamSendHistogramEnabled) {
 		hist := r.metrics.clientStreamSendHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName)
 		return prometheus.NewTimer(hist)
 	}
@@ -68,12 +123,40 @@ func (r *clientReporter) SendMessageTimer() timer {
 }
 
 func (r *clientReporter) SentMessage() {
-	r.metrics.clientStreamMsgSent.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	if !r.config.msgCountersDisabled {
+		r.metrics.clientStreamMsgSent.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	}
 }
 
-func (r *clientReporter) Handled(code codes.Code) {
-	r.metrics.clientHandledCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName, code.String()).Inc()
-	if r.metrics.clientHandledHistogramEnabled {
+// Handled reports the completion of the RPC with code. Only the first call
+// has an effect, as RecvMsg keeps returning the final error of a stream when
+// called again, and reports true.
+func (r *clientReporter) Handled(code codes.Code) bool {
+	if !atomic.CompareAndSwapInt32(&r.handled, 0, 1) {
+		return false
+	}
+	if !r.metrics.clientPerAttempt {
+		r.metrics.clientHandledCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName, code.String()).Inc()
+	}
+	if r.config.longTermHandledCounterEnabled {
+		r.metrics.clientLongTermHandledCounter.WithLabelValues(r.serviceName, r.methodName).Inc()
+	}
+	if r.handlingTimeHistogramActive() {
 		r.metrics.clientHandledHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Observe(time.Since(r.startTime).Seconds())
 	}
+	if !r.deadline.IsZero() {
+		now := time.Now()
+		ratio := 0.0
+		if total := r.deadline.Sub(r.deadlineStart); total > 0 && now.Before(r.deadline) {
+			ratio = float64(r.deadline.Sub(now)) / float64(total)
+		}
+		r.metrics.clientDeadlineRemainingHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Observe(ratio)
+	}
+	if r.shard != "" {
+		r.metrics.clientShardHandledCounter.WithLabelValues(r.serviceName, r.methodName, r.shard, code.String()).Inc()
+	}
+	if r.streamReconnect != nil {
+		r.streamReconnect.streamEnded(time.Now())
+	}
+	return true
 }
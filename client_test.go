@@ -7,15 +7,21 @@ import (
 	"context"
 	"io"
 	"net"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -150,3 +156,254 @@ func (s *ClientInterceptorTestSuite) TestStreamingIncrementsMetrics() {
 	requireValue(s.T(), 1, DefaultClientMetrics.clientHandledCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "FailedPrecondition"))
 	requireValueHistCount(s.T(), 2, DefaultClientMetrics.clientHandledHistogram.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
 }
+
+func TestInitializeMetricsFromServiceDesc(t *testing.T) {
+	m := NewClientMetrics()
+	m.InitializeMetricsFromServiceDesc(&grpc.ServiceDesc{
+		ServiceName: "mwitkow.testproto.TestService",
+		Methods:     []grpc.MethodDesc{{MethodName: "Ping"}},
+		Streams:     []grpc.StreamDesc{{StreamName: "PingList", ServerStreams: true}},
+	})
+
+	expected := `
+# HELP grpc_client_started_total Total number of RPCs started on the client.
+# TYPE grpc_client_started_total counter
+grpc_client_started_total{grpc_method="Ping",grpc_service="mwitkow.testproto.TestService",grpc_type="unary"} 0
+grpc_client_started_total{grpc_method="PingList",grpc_service="mwitkow.testproto.TestService",grpc_type="server_stream"} 0
+`
+	require.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(expected), "grpc_client_started_total"))
+}
+
+func TestRecordShortCircuit(t *testing.T) {
+	m := NewClientMetrics()
+	m.RecordShortCircuit("/mwitkow.testproto.TestService/Ping", codes.Unavailable, "circuit_open")
+
+	requireValue(t, 1, m.clientStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValue(t, 1, m.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "Unavailable"))
+	requireValue(t, 1, m.clientShortCircuitedCounter.WithLabelValues("mwitkow.testproto.TestService", "Ping", "Unavailable", "circuit_open"))
+}
+
+func TestStreamCreationFailureReasons(t *testing.T) {
+	m := NewClientMetrics()
+	interceptor := m.StreamClientInterceptor()
+	desc := &grpc.StreamDesc{StreamName: "PingList", ServerStreams: true}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, tc := range []struct {
+		ctx context.Context
+		err error
+	}{
+		{context.Background(), status.Error(codes.Unavailable, "connection refused")},
+		{canceled, status.Error(codes.Canceled, "context canceled")},
+		{context.Background(), status.Error(codes.Internal, "grpc: Compressor is not installed")},
+		{context.Background(), status.Error(codes.PermissionDenied, "denied")},
+	} {
+		_, err := interceptor(tc.ctx, desc, nil, "/mwitkow.testproto.TestService/PingList",
+			func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return nil, tc.err
+			})
+		require.Error(t, err)
+	}
+	for _, reason := range []string{"connection", "context", "call_option", "other"} {
+		requireValue(t, 1, m.clientStreamCreationFailures.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", reason))
+	}
+}
+
+// brokenClientStream is a grpc.ClientStream whose first receive fails.
+type brokenClientStream struct {
+	grpc.ClientStream
+}
+
+func (brokenClientStream) RecvMsg(m interface{}) error {
+	return status.Error(codes.Unavailable, "transport is closing")
+}
+
+// bidiClientStream is a grpc.ClientStream accepting any number of sends and
+// receiving msgs messages before io.EOF.
+type bidiClientStream struct {
+	grpc.ClientStream
+	msgs     int
+	received int
+}
+
+func (s *bidiClientStream) SendMsg(m interface{}) error {
+	return nil
+}
+
+func (s *bidiClientStream) RecvMsg(m interface{}) error {
+	if s.received == s.msgs {
+		return io.EOF
+	}
+	s.received++
+	return nil
+}
+
+func TestClientStreamConcurrentSendAndRecv(t *testing.T) {
+	m := NewClientMetrics()
+	m.EnableClientHandlingTimeHistogram()
+	m.EnableClientStreamSendTimeHistogram()
+	m.EnableClientStreamReceiveTimeHistogram()
+	interceptor := m.StreamClientInterceptor()
+	desc := &grpc.StreamDesc{StreamName: "PingStream", ClientStreams: true, ServerStreams: true}
+
+	const streams, msgs = 8, 100
+	var wg sync.WaitGroup
+	for i := 0; i < streams; i++ {
+		stream, err := interceptor(context.Background(), desc, nil, "/mwitkow.testproto.TestService/PingStream",
+			func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return &bidiClientStream{msgs: msgs}, nil
+			})
+		require.NoError(t, err)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < msgs; j++ {
+				stream.SendMsg(&pb_testproto.PingRequest{})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for stream.RecvMsg(&pb_testproto.PingResponse{}) == nil {
+			}
+			// Receiving again after the end of the stream mustn't count it twice.
+			stream.RecvMsg(&pb_testproto.PingResponse{})
+		}()
+	}
+	wg.Wait()
+
+	requireValue(t, streams*msgs, m.clientStreamMsgSent.WithLabelValues("bidi_stream", "mwitkow.testproto.TestService", "PingStream"))
+	requireValue(t, streams*msgs, m.clientStreamMsgReceived.WithLabelValues("bidi_stream", "mwitkow.testproto.TestService", "PingStream"))
+	requireValue(t, streams, m.clientHandledCounter.WithLabelValues("bidi_stream", "mwitkow.testproto.TestService", "PingStream", "OK"))
+	requireValueHistCount(t, streams, m.clientHandledHistogram.WithLabelValues("bidi_stream", "mwitkow.testproto.TestService", "PingStream"))
+}
+
+func TestClientHistogramRecordingOverride(t *testing.T) {
+	m := NewClientMetrics()
+	m.EnableClientHandlingTimeHistogram()
+	m.EnableClientStreamSendTimeHistogram()
+	m.EnableClientStreamReceiveTimeHistogram()
+	interceptor := m.StreamClientInterceptor()
+	desc := &grpc.StreamDesc{StreamName: "PingStream", ClientStreams: true, ServerStreams: true}
+	call := func(ctx context.Context) {
+		stream, err := interceptor(ctx, desc, nil, "/mwitkow.testproto.TestService/PingStream",
+			func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return &bidiClientStream{msgs: 1}, nil
+			})
+		require.NoError(t, err)
+		require.NoError(t, stream.SendMsg(&pb_testproto.PingRequest{}))
+		for stream.RecvMsg(&pb_testproto.PingResponse{}) == nil {
+		}
+	}
+	requireHistCounts := func(handled, sent, received int) {
+		requireValueHistCount(t, handled, m.clientHandledHistogram.WithLabelValues("bidi_stream", "mwitkow.testproto.TestService", "PingStream"))
+		requireValueHistCount(t, sent, m.clientStreamSendHistogram.WithLabelValues("bidi_stream", "mwitkow.testproto.TestService", "PingStream"))
+		requireValueHistCount(t, received, m.clientStreamRecvHistogram.WithLabelValues("bidi_stream", "mwitkow.testproto.TestService", "PingStream"))
+	}
+
+	call(SkipHistogramRecording(context.Background()))
+	requireHistCounts(0, 0, 0)
+
+	m.ApplyFeatureGate(FeatureGateFunc(func(feature string) bool { return feature != FeatureStreamMessageHistograms }))
+	call(context.Background())
+	requireHistCounts(1, 0, 0)
+	// Receiving io.EOF is timed too.
+	call(ForceHistogramRecording(context.Background()))
+	requireHistCounts(2, 1, 2)
+}
+
+func TestStreamReconnectMetrics(t *testing.T) {
+	m := NewClientMetrics()
+	m.EnableStreamReconnectMetrics()
+	interceptor := m.StreamClientInterceptor()
+	desc := &grpc.StreamDesc{StreamName: "PingList", ServerStreams: true}
+	ctx := WithStreamReconnectTracking(context.Background())
+
+	for i := 0; i < 3; i++ {
+		stream, err := interceptor(ctx, desc, nil, "/mwitkow.testproto.TestService/PingList",
+			func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return brokenClientStream{}, nil
+			})
+		require.NoError(t, err)
+		require.Error(t, stream.RecvMsg(&pb_testproto.PingResponse{}))
+	}
+	requireValue(t, 2, m.clientStreamReconnectCounter.WithLabelValues("mwitkow.testproto.TestService", "PingList"))
+	requireValueHistCount(t, 2, m.clientStreamReconnectGapHistogram.WithLabelValues("mwitkow.testproto.TestService", "PingList"))
+}
+
+func TestShardHandledCounter(t *testing.T) {
+	m := NewClientMetrics()
+	m.EnableShardHandledCounter(ShardFromOutgoingMetadata("x-shard"), []string{"0", "1"})
+	interceptor := m.UnaryClientInterceptor()
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	for _, shard := range []string{"1", "1", "7", ""} {
+		ctx := context.Background()
+		if shard != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-shard", shard)
+		}
+		require.NoError(t, interceptor(ctx, "/mwitkow.testproto.TestService/Ping", &pb_testproto.PingRequest{}, &pb_testproto.PingResponse{}, nil, invoker))
+	}
+	requireValue(t, 2, m.clientShardHandledCounter.WithLabelValues("mwitkow.testproto.TestService", "Ping", "1", "OK"))
+	requireValue(t, 1, m.clientShardHandledCounter.WithLabelValues("mwitkow.testproto.TestService", "Ping", "other", "OK"))
+}
+
+func TestObserveClockSkew(t *testing.T) {
+	m := NewClientMetrics()
+	m.EnableClockSkewGauge()
+	sent := time.Unix(1000, 0)
+	received := sent.Add(100 * time.Millisecond)
+	// 60ms handling leaves a 40ms round trip, so the server finished 20ms
+	// before the response was received.
+	serverTime := received.Add(-20 * time.Millisecond).Add(5 * time.Second)
+	trailer := metadata.Pairs(
+		serverTimeTrailerKey, strconv.FormatInt(serverTime.UnixNano(), 10),
+		serverHandlingTrailerKey, strconv.FormatInt(int64(60*time.Millisecond), 10),
+	)
+	m.observeClockSkew("backend:443", sent, received, trailer)
+	require.InDelta(t, 5.0, testutil.ToFloat64(m.clientClockSkewGauge.WithLabelValues("backend:443")), 1e-9)
+}
+
+func TestDeadlineRemainingHistogram(t *testing.T) {
+	m := NewClientMetrics()
+	m.EnableDeadlineRemainingHistogram()
+	interceptor := m.UnaryClientInterceptor()
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	require.NoError(t, interceptor(ctx, "/mwitkow.testproto.TestService/Ping", &pb_testproto.PingRequest{}, &pb_testproto.PingResponse{}, nil, invoker))
+	require.NoError(t, interceptor(context.Background(), "/mwitkow.testproto.TestService/Ping", &pb_testproto.PingRequest{}, &pb_testproto.PingResponse{}, nil, invoker))
+
+	h := &dto.Metric{}
+	require.NoError(t, m.clientDeadlineRemainingHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping").(prometheus.Histogram).Write(h))
+	require.EqualValues(t, 1, h.GetHistogram().GetSampleCount(), "RPCs without deadline must not be recorded")
+	require.InDelta(t, 1, h.GetHistogram().GetSampleSum(), 0.01)
+}
+
+func TestClientSuppressMetrics(t *testing.T) {
+	m := NewClientMetrics()
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	for _, ctx := range []context.Context{SuppressMetrics(context.Background()), context.Background()} {
+		require.NoError(t, m.UnaryClientInterceptor()(ctx, "/mwitkow.testproto.TestService/Ping", &pb_testproto.PingRequest{}, &pb_testproto.PingResponse{}, nil, invoker))
+	}
+	requireValue(t, 1, m.clientStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValue(t, 1, m.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+}
+
+func TestClientMetricsFrozenOnRegistration(t *testing.T) {
+	m := NewClientMetrics()
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(m))
+	require.PanicsWithValue(t,
+		"grpc_prometheus: ClientMetrics.EnableClientHandlingTimeHistogram called after the metrics were registered; configure them before registration",
+		func() { m.EnableClientHandlingTimeHistogram() })
+	require.Panics(t, func() { m.Configure(WithPerAttemptAccounting()) })
+	require.Nil(t, m.clientHandledHistogram)
+	_, err := reg.Gather()
+	require.NoError(t, err)
+}
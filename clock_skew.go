@@ -0,0 +1,91 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// clockSkewRequestKey is sent by clients asking for the server clock.
+	clockSkewRequestKey = "x-grpc-prometheus-clock-request"
+	// serverTimeTrailerKey carries the wall clock of the server, in unix
+	// nanoseconds, when it finished handling the RPC.
+	serverTimeTrailerKey = "x-grpc-prometheus-server-time"
+	// serverHandlingTrailerKey carries the handling time of the server, in
+	// nanoseconds, so clients can tell network round trip time apart.
+	serverHandlingTrailerKey = "x-grpc-prometheus-server-handling"
+)
+
+// EnableClockSkewTrailer makes the unary server interceptor attach the wall
+// clock of the server to the trailer of RPCs of clients asking for it, see
+// ClientMetrics.EnableClockSkewGauge.
+func (m *ServerMetrics) EnableClockSkewTrailer() {
+	m.checkNotFrozen("EnableClockSkewTrailer")
+	m.clockSkewTrailerEnabled = true
+	m.publishToggles()
+}
+
+// setClockSkewTrailer attaches the server clock to the trailer of the RPC of
+// ctx if the client asked for it.
+func (m *ServerMetrics) setClockSkewTrailer(ctx context.Context, start time.Time) {
+	if md, ok := metadata.FromIncomingContext(ctx); !ok || len(md.Get(clockSkewRequestKey)) == 0 {
+		return
+	}
+	now := time.Now()
+	grpc.SetTrailer(ctx, metadata.Pairs(
+		serverTimeTrailerKey, strconv.FormatInt(now.UnixNano(), 10),
+		serverHandlingTrailerKey, strconv.FormatInt(int64(now.Sub(start)), 10),
+	))
+}
+
+// EnableClockSkewGauge turns on grpc_client_clock_skew_seconds, the
+// difference between the wall clocks of each target and the client, as
+// measured on unary RPCs. Servers must enable it with
+// ServerMetrics.EnableClockSkewTrailer. The server clock is compared to the
+// client clock at the estimated time the server finished handling, i.e.
+// half the network round trip time before the response was received.
+// Skewed clocks break deadline propagation.
+func (m *ClientMetrics) EnableClockSkewGauge() {
+	m.checkNotFrozen("EnableClockSkewGauge")
+	if m.clientClockSkewGauge == nil {
+		m.clientClockSkewGauge = prom.NewGaugeVec(
+			prom.GaugeOpts{
+				Name:        "grpc_client_clock_skew_seconds",
+				Help:        "Estimated difference (seconds) between the wall clock of the target and the client, positive if the target is ahead.",
+				ConstLabels: m.counterOpts.apply(prom.CounterOpts{}).ConstLabels,
+			}, []string{"target"})
+	}
+}
+
+// observeClockSkew records the clock skew to target from the trailer of an
+// RPC sent at sent and completed at received.
+func (m *ClientMetrics) observeClockSkew(target string, sent, received time.Time, trailer metadata.MD) {
+	serverTime, err := parseTrailerInt(trailer, serverTimeTrailerKey)
+	if err != nil {
+		return
+	}
+	handling, err := parseTrailerInt(trailer, serverHandlingTrailerKey)
+	if err != nil {
+		return
+	}
+	rtt := received.Sub(sent) - time.Duration(handling)
+	if rtt < 0 {
+		rtt = 0
+	}
+	clientTime := received.Add(-rtt / 2)
+	skew := time.Unix(0, serverTime).Sub(clientTime)
+	m.clientClockSkewGauge.WithLabelValues(target).Set(skew.Seconds())
+}
+
+func parseTrailerInt(trailer metadata.MD, key string) (int64, error) {
+	values := trailer.Get(key)
+	if len(values) == 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.ParseInt(values[0], 10, 64)
+}
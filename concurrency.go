@@ -0,0 +1,111 @@
+package grpc_prometheus
+
+import (
+	"sync/atomic"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// WithConcurrencyLimits turns on the grpc_server_concurrency_utilization
+// gauge, the number of RPCs in flight of each method divided by its
+// concurrency limit. limits maps full method names, e.g.
+// "/mwitkow.testproto.TestService/Ping", to the limit enforced by the server,
+// e.g. by a concurrency limiting interceptor. Methods without a positive
+// limit are not tracked. Utilization is a much more stable autoscaling signal
+// than latency.
+func WithConcurrencyLimits(limits map[string]int) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		if m.serverInFlight == nil {
+			m.serverInFlight = &inFlightTracker{
+				desc: prom.NewDesc(
+					"grpc_server_concurrency_utilization",
+					"Number of RPCs in flight on the server divided by the concurrency limit of their method.",
+					[]string{"grpc_service", "grpc_method"}, m.counterOpts.apply(prom.CounterOpts{}).ConstLabels),
+				methods: make(map[methodKey]*methodInFlight, len(limits)),
+			}
+		}
+		for fullMethod, limit := range limits {
+			if limit <= 0 {
+				continue
+			}
+			service, method := splitMethodName(fullMethod)
+			m.serverInFlight.methods[methodKey{service, method}] = &methodInFlight{limit: limit}
+		}
+	}
+}
+
+// WithLongPollMethods marks the given methods, e.g.
+// "/mwitkow.testproto.TestService/Watch", as long-poll or long-lived
+// streaming methods. Their RPCs are excluded from
+// grpc_server_concurrency_utilization, so that parked long-polls don't mask
+// saturation of short RPCs, and are tracked in the dedicated
+// grpc_server_long_poll_in_flight gauge instead.
+func WithLongPollMethods(fullMethods ...string) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		if m.serverLongPollInFlight == nil {
+			m.serverLongPollInFlight = &inFlightTracker{
+				desc: prom.NewDesc(
+					"grpc_server_long_poll_in_flight",
+					"Number of RPCs of long-poll methods in flight on the server.",
+					[]string{"grpc_service", "grpc_method"}, m.counterOpts.apply(prom.CounterOpts{}).ConstLabels),
+				methods: make(map[methodKey]*methodInFlight, len(fullMethods)),
+			}
+		}
+		for _, fullMethod := range fullMethods {
+			service, method := splitMethodName(fullMethod)
+			// A limit of 1 exports the number of RPCs in flight as is.
+			m.serverLongPollInFlight.methods[methodKey{service, method}] = &methodInFlight{limit: 1}
+		}
+	}
+}
+
+// excludeLongPollMethods removes long-poll methods from the concurrency
+// utilization, whichever order the options were given in.
+func (m *ServerMetrics) excludeLongPollMethods() {
+	if m.serverInFlight == nil || m.serverLongPollInFlight == nil {
+		return
+	}
+	for key := range m.serverLongPollInFlight.methods {
+		delete(m.serverInFlight.methods, key)
+	}
+}
+
+// inFlightTracker counts the RPCs in flight of a set of methods, relative to
+// their limit. The set of methods is fixed before serving, so lookups need no lock.
+type inFlightTracker struct {
+	desc    *prom.Desc
+	methods map[methodKey]*methodInFlight
+}
+
+type methodInFlight struct {
+	count int64 // accessed atomically
+	limit int
+}
+
+// start counts an RPC of the given method as in flight, and returns the
+// counter to pass to finish, or nil if the method isn't tracked.
+func (t *inFlightTracker) start(service, method string) *methodInFlight {
+	mf, ok := t.methods[methodKey{service, method}]
+	if !ok {
+		return nil
+	}
+	atomic.AddInt64(&mf.count, 1)
+	return mf
+}
+
+func (t *inFlightTracker) finish(mf *methodInFlight) {
+	if mf != nil {
+		atomic.AddInt64(&mf.count, -1)
+	}
+}
+
+func (t *inFlightTracker) Describe(ch chan<- *prom.Desc) {
+	ch <- t.desc
+}
+
+func (t *inFlightTracker) Collect(ch chan<- prom.Metric) {
+	for key, mf := range t.methods {
+		utilization := float64(atomic.LoadInt64(&mf.count)) / float64(mf.limit)
+		ch <- prom.MustNewConstMetric(t.desc, prom.GaugeValue, utilization, key.service, key.method)
+	}
+}
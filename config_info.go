@@ -0,0 +1,145 @@
+package grpc_prometheus
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// ConfigInfo holds operational parameters of an instrumented gRPC server or
+// client, as passed to its grpc.ServerOption or grpc.DialOption values. Zero
+// values mean the parameter is not configured, i.e. the gRPC default applies.
+type ConfigInfo struct {
+	MaxRecvMsgSize       int
+	MaxSendMsgSize       int
+	MaxConcurrentStreams uint32
+	ConnectionTimeout    time.Duration
+	KeepaliveTime        time.Duration
+	KeepaliveTimeout     time.Duration
+}
+
+var configInfoLabels = []string{
+	"max_recv_msg_size", "max_send_msg_size", "max_concurrent_streams",
+	"connection_timeout", "keepalive_time", "keepalive_timeout", "histograms",
+}
+
+func newConfigInfoDesc(name, help string, constLabels prom.Labels) *prom.Desc {
+	return prom.NewDesc(name, help, configInfoLabels, constLabels)
+}
+
+// collectConfigInfo sends the info metric of info, with the names of the
+// enabled histograms.
+func collectConfigInfo(ch chan<- prom.Metric, desc *prom.Desc, info ConfigInfo, histograms []string) {
+	formatInt := func(v int64) string {
+		if v == 0 {
+			return ""
+		}
+		return strconv.FormatInt(v, 10)
+	}
+	formatDuration := func(d time.Duration) string {
+		if d == 0 {
+			return ""
+		}
+		return d.String()
+	}
+	ch <- prom.MustNewConstMetric(desc, prom.GaugeValue, 1,
+		formatInt(int64(info.MaxRecvMsgSize)),
+		formatInt(int64(info.MaxSendMsgSize)),
+		formatInt(int64(info.MaxConcurrentStreams)),
+		formatDuration(info.ConnectionTimeout),
+		formatDuration(info.KeepaliveTime),
+		formatDuration(info.KeepaliveTimeout),
+		strings.Join(histograms, ","),
+	)
+}
+
+// EnableConfigInfo turns on grpc_server_config_info, an info gauge exporting
+// the given server configuration and the histograms enabled on these
+// metrics, so that dashboards can annotate behavior changes with
+// configuration changes.
+func (m *ServerMetrics) EnableConfigInfo(info ConfigInfo) {
+	m.checkNotFrozen("EnableConfigInfo")
+	if info.MaxRecvMsgSize == 0 && m.serverRecvSizeRatioHistogramEnabled {
+		info.MaxRecvMsgSize = m.serverMaxRecvMsgSize
+	}
+	m.configInfo = &info
+	m.configInfoDesc = newConfigInfoDesc(
+		"grpc_server_config_info",
+		"Configuration of the gRPC server, and histograms enabled on its metrics, in labels.",
+		m.counterOpts.apply(prom.CounterOpts{}).ConstLabels)
+}
+
+// serverConfigInfoCollector exports grpc_server_config_info.
+type serverConfigInfoCollector struct {
+	m *ServerMetrics
+}
+
+func (c serverConfigInfoCollector) Describe(ch chan<- *prom.Desc) {
+	ch <- c.m.configInfoDesc
+}
+
+func (c serverConfigInfoCollector) Collect(ch chan<- prom.Metric) {
+	collectConfigInfo(ch, c.m.configInfoDesc, *c.m.configInfo, c.m.enabledHistograms())
+}
+
+func (m *ServerMetrics) enabledHistograms() []string {
+	var histograms []string
+	if m.HandlingTimeHistogramActive() {
+		histograms = append(histograms, "grpc_server_handling_seconds")
+	}
+	if m.serverRecvSizeRatioHistogramEnabled {
+		histograms = append(histograms, "grpc_server_msg_received_size_limit_ratio")
+	}
+	if m.serverHeaderProcessingHistogramEnabled {
+		histograms = append(histograms, "grpc_server_header_processing_seconds")
+	}
+	if m.serverResponseItemsHistogramEnabled {
+		histograms = append(histograms, "grpc_server_response_items")
+	}
+	return histograms
+}
+
+// EnableConfigInfo turns on grpc_client_config_info, an info gauge exporting
+// the given client configuration and the histograms enabled on these
+// metrics, so that dashboards can annotate behavior changes with
+// configuration changes.
+func (m *ClientMetrics) EnableConfigInfo(info ConfigInfo) {
+	m.checkNotFrozen("EnableConfigInfo")
+	m.configInfo = &info
+	m.configInfoDesc = newConfigInfoDesc(
+		"grpc_client_config_info",
+		"Configuration of the gRPC client, and histograms enabled on its metrics, in labels.",
+		m.counterOpts.apply(prom.CounterOpts{}).ConstLabels)
+}
+
+// clientConfigInfoCollector exports grpc_client_config_info.
+type clientConfigInfoCollector struct {
+	m *ClientMetrics
+}
+
+func (c clientConfigInfoCollector) Describe(ch chan<- *prom.Desc) {
+	ch <- c.m.configInfoDesc
+}
+
+func (c clientConfigInfoCollector) Collect(ch chan<- prom.Metric) {
+	collectConfigInfo(ch, c.m.configInfoDesc, *c.m.configInfo, c.m.enabledHistograms())
+}
+
+func (m *ClientMetrics) enabledHistograms() []string {
+	var histograms []string
+	if m.clientHandledHistogramEnabled {
+		histograms = append(histograms, "grpc_client_handling_seconds")
+	}
+	if m.clientStreamRecvHistogramEnabled {
+		histograms = append(histograms, "grpc_client_msg_recv_handling_seconds")
+	}
+	if m.clientStreamSendHistogramEnabled {
+		histograms = append(histograms, "grpc_client_msg_send_handling_seconds")
+	}
+	if m.clientDeadlineRemainingHistogramEnabled {
+		histograms = append(histograms, "grpc_client_deadline_remaining_ratio")
+	}
+	return histograms
+}
@@ -0,0 +1,23 @@
+package grpc_prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerConfigInfo(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableHandlingTimeHistogram()
+	m.EnableConfigInfo(ConfigInfo{MaxRecvMsgSize: 1 << 20, KeepaliveTime: 2 * time.Hour})
+
+	expected := `
+# HELP grpc_server_config_info Configuration of the gRPC server, and histograms enabled on its metrics, in labels.
+# TYPE grpc_server_config_info gauge
+grpc_server_config_info{connection_timeout="",histograms="grpc_server_handling_seconds",keepalive_time="2h0m0s",keepalive_timeout="",max_concurrent_streams="",max_recv_msg_size="1048576",max_send_msg_size=""} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(expected), "grpc_server_config_info"))
+}
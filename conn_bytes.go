@@ -0,0 +1,195 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/stats"
+)
+
+// connBytesTracker accumulates the bytes received and sent on each server
+// connection, and exposes their sum per listener.
+type connBytesTracker struct {
+	receivedDesc *prom.Desc
+	sentDesc     *prom.Desc
+
+	mu sync.Mutex
+	// conns are the open connections.
+	conns map[*connBytes]struct{}
+	// closed are the totals of the closed connections of each listener.
+	closed map[string]*listenerBytes
+}
+
+// connBytes are the bytes received and sent on a connection.
+type connBytes struct {
+	listener string
+	received int64 // accessed atomically
+	sent     int64 // accessed atomically
+}
+
+type listenerBytes struct {
+	received, sent float64
+}
+
+// EnableConnBytesCounters turns on grpc_server_conn_received_bytes_total and
+// grpc_server_conn_sent_bytes_total, the bytes received and sent on the wire
+// by the server, per listener, so that network saturation is visible per
+// gRPC port. The listener label is the local port of connections, e.g.
+// ":8080". Bytes are accumulated per connection by ConnBytesStatsHandler,
+// which must be installed with grpc.StatsHandler. Connections of servers
+// serving over net/http with grpc.Server.ServeHTTP aren't seen by gRPC, nor
+// by this handler.
+func (m *ServerMetrics) EnableConnBytesCounters(counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnableConnBytesCounters")
+	if m.serverConnBytes != nil {
+		return
+	}
+	received := counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+		Name: "grpc_server_conn_received_bytes_total",
+		Help: "Total number of bytes received on the wire by the server, per listener.",
+	}))
+	sent := counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+		Name: "grpc_server_conn_sent_bytes_total",
+		Help: "Total number of bytes sent on the wire by the server, per listener.",
+	}))
+	m.serverConnBytes = &connBytesTracker{
+		receivedDesc: prom.NewDesc(prom.BuildFQName(received.Namespace, received.Subsystem, received.Name), received.Help, []string{"listener"}, received.ConstLabels),
+		sentDesc:     prom.NewDesc(prom.BuildFQName(sent.Namespace, sent.Subsystem, sent.Name), sent.Help, []string{"listener"}, sent.ConstLabels),
+		conns:        make(map[*connBytes]struct{}),
+		closed:       make(map[string]*listenerBytes),
+	}
+}
+
+// ConnBytesStatsHandler returns a stats.Handler, to install with
+// grpc.StatsHandler, accumulating the bytes of each connection for
+// EnableConnBytesCounters. As gRPC keeps a single stats handler, it is
+// combined with others with ChainStatsHandlers.
+func (m *ServerMetrics) ConnBytesStatsHandler() stats.Handler {
+	return connBytesHandler{m}
+}
+
+// listenerOf returns the listener label value of a connection with the given
+// local address.
+func listenerOf(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return ":" + strconv.Itoa(a.Port)
+	case nil:
+		return "unknown"
+	}
+	return addr.String()
+}
+
+func (t *connBytesTracker) open(listener string) *connBytes {
+	c := &connBytes{listener: listener}
+	t.mu.Lock()
+	t.conns[c] = struct{}{}
+	t.mu.Unlock()
+	return c
+}
+
+// close folds the bytes of c into the totals of its listener.
+func (t *connBytesTracker) close(c *connBytes) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.conns[c]; !ok {
+		return
+	}
+	delete(t.conns, c)
+	l := t.listener(c.listener)
+	l.received += float64(atomic.LoadInt64(&c.received))
+	l.sent += float64(atomic.LoadInt64(&c.sent))
+}
+
+// listener returns the totals of the closed connections of a listener. The
+// caller must hold t.mu.
+func (t *connBytesTracker) listener(name string) *listenerBytes {
+	l, ok := t.closed[name]
+	if !ok {
+		l = &listenerBytes{}
+		t.closed[name] = l
+	}
+	return l
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (t *connBytesTracker) Describe(ch chan<- *prom.Desc) {
+	ch <- t.receivedDesc
+	ch <- t.sentDesc
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (t *connBytesTracker) Collect(ch chan<- prom.Metric) {
+	t.mu.Lock()
+	totals := make(map[string]listenerBytes, len(t.closed))
+	for name, l := range t.closed {
+		totals[name] = *l
+	}
+	for c := range t.conns {
+		l := totals[c.listener]
+		l.received += float64(atomic.LoadInt64(&c.received))
+		l.sent += float64(atomic.LoadInt64(&c.sent))
+		totals[c.listener] = l
+	}
+	t.mu.Unlock()
+	for name, l := range totals {
+		ch <- prom.MustNewConstMetric(t.receivedDesc, prom.CounterValue, l.received, name)
+		ch <- prom.MustNewConstMetric(t.sentDesc, prom.CounterValue, l.sent, name)
+	}
+}
+
+type connBytesKey struct{}
+
+// connBytesHandler is a stats.Handler accumulating the bytes of server
+// connections. RPC contexts derive from the context of their connection.
+type connBytesHandler struct {
+	m *ServerMetrics
+}
+
+func (h connBytesHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	if h.m.serverConnBytes == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, connBytesKey{}, h.m.serverConnBytes.open(listenerOf(info.LocalAddr)))
+}
+
+func (h connBytesHandler) HandleConn(ctx context.Context, s stats.ConnStats) {
+	if _, ok := s.(*stats.ConnEnd); !ok || s.IsClient() {
+		return
+	}
+	if c, ok := ctx.Value(connBytesKey{}).(*connBytes); ok {
+		h.m.serverConnBytes.close(c)
+	}
+}
+
+func (h connBytesHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h connBytesHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if s.IsClient() {
+		return
+	}
+	c, ok := ctx.Value(connBytesKey{}).(*connBytes)
+	if !ok {
+		return
+	}
+	switch s := s.(type) {
+	case *stats.InHeader:
+		atomic.AddInt64(&c.received, int64(s.WireLength))
+	case *stats.InPayload:
+		atomic.AddInt64(&c.received, int64(s.WireLength))
+	case *stats.OutPayload:
+		atomic.AddInt64(&c.sent, int64(s.WireLength))
+	case *stats.OutTrailer:
+		atomic.AddInt64(&c.sent, int64(s.WireLength))
+	}
+}
@@ -0,0 +1,66 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/stats"
+)
+
+// collectConnBytes returns the received and sent bytes per listener.
+func collectConnBytes(t *testing.T, c *connBytesTracker) (received, sent map[string]float64) {
+	ch := make(chan prom.Metric, 16)
+	c.Collect(ch)
+	close(ch)
+	received, sent = map[string]float64{}, map[string]float64{}
+	for metric := range ch {
+		var pb dto.Metric
+		require.NoError(t, metric.Write(&pb))
+		listener := pb.GetLabel()[0].GetValue()
+		if metric.Desc() == c.receivedDesc {
+			received[listener] = pb.GetCounter().GetValue()
+		} else {
+			sent[listener] = pb.GetCounter().GetValue()
+		}
+	}
+	return received, sent
+}
+
+func TestConnBytesCounters(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableConnBytesCounters()
+	h := m.ConnBytesStatsHandler()
+
+	conn := func(port int) context.Context {
+		return h.TagConn(context.Background(), &stats.ConnTagInfo{LocalAddr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: port}})
+	}
+	rpc := func(ctx context.Context, in, out int) {
+		ctx = h.TagRPC(ctx, &stats.RPCTagInfo{FullMethodName: "/mwitkow.testproto.TestService/Ping"})
+		h.HandleRPC(ctx, &stats.InHeader{WireLength: 3})
+		h.HandleRPC(ctx, &stats.InPayload{WireLength: in})
+		h.HandleRPC(ctx, &stats.OutPayload{WireLength: out})
+		h.HandleRPC(ctx, &stats.OutTrailer{WireLength: 2})
+	}
+
+	first, second, admin := conn(8080), conn(8080), conn(9090)
+	rpc(first, 10, 100)
+	rpc(first, 10, 100)
+	rpc(second, 5, 50)
+	rpc(admin, 1, 1)
+	received, sent := collectConnBytes(t, m.serverConnBytes)
+	require.Equal(t, map[string]float64{":8080": 3*3 + 25, ":9090": 3 + 1}, received)
+	require.Equal(t, map[string]float64{":8080": 2*3 + 250, ":9090": 2 + 1}, sent)
+
+	// Totals of closed connections are kept.
+	h.HandleConn(first, &stats.ConnEnd{})
+	h.HandleConn(first, &stats.ConnEnd{})
+	rpc(second, 5, 50)
+	received, sent = collectConnBytes(t, m.serverConnBytes)
+	require.Equal(t, map[string]float64{":8080": 3*4 + 30, ":9090": 3 + 1}, received)
+	require.Equal(t, map[string]float64{":8080": 2*4 + 300, ":9090": 2 + 1}, sent)
+	require.Len(t, m.serverConnBytes.conns, 2)
+}
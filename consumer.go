@@ -0,0 +1,82 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// unknownConsumer is the consumer label value of RPCs without consumer.
+const unknownConsumer = "unknown"
+
+// ConsumerNormalizer maps the raw consumer ID or API key of an RPC to its
+// consumer label value. It must return a small, bounded set of values and
+// should not return secrets such as API keys verbatim, as each value is a
+// label value exposed to anyone able to scrape the metrics.
+type ConsumerNormalizer func(raw string) string
+
+// HashConsumer returns a ConsumerNormalizer hashing consumers into the given
+// number of buckets, labeled "0" to buckets-1. Distinct consumers may share
+// a bucket, but the label set is bounded and API keys are not exposed.
+func HashConsumer(buckets int) ConsumerNormalizer {
+	return func(raw string) string {
+		h := fnv.New32a()
+		h.Write([]byte(raw))
+		return strconv.FormatUint(uint64(h.Sum32()%uint32(buckets)), 10)
+	}
+}
+
+// WithConsumerLabel turns on the grpc_server_consumer_requests_total and
+// grpc_server_consumer_errors_total counters of RPCs, and of failed RPCs,
+// completed per consumer. The consumer is read from the first value of the
+// given key in the incoming metadata, e.g. "x-api-key", and mapped to its
+// label value by normalize, e.g. HashConsumer. RPCs without consumer are
+// counted as "unknown".
+func WithConsumerLabel(key string, normalize ConsumerNormalizer, counterOpts ...CounterOption) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.consumerKey = key
+		m.consumerNormalizer = normalize
+		if m.serverConsumerRequestsCounter != nil {
+			return
+		}
+		m.serverConsumerRequestsCounter = m.newCounterVec(
+			counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_server_consumer_requests_total",
+				Help: "Total number of RPCs completed on the server per consumer, regardless of success or failure.",
+			})), []string{"grpc_service", "grpc_method", "consumer"})
+		m.serverConsumerErrorsCounter = m.newCounterVec(
+			counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_server_consumer_errors_total",
+				Help: "Total number of RPCs completed on the server per consumer with a code other than OK.",
+			})), []string{"grpc_service", "grpc_method", "consumer"})
+	}
+}
+
+// consumerOf returns the consumer label value of the RPC of ctx.
+func (m *ServerMetrics) consumerOf(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(m.consumerKey)
+	if len(values) == 0 || values[0] == "" {
+		return unknownConsumer
+	}
+	if consumer := m.consumerNormalizer(values[0]); consumer != "" {
+		return consumer
+	}
+	return unknownConsumer
+}
+
+func (r *serverReporter) observeConsumer(code codes.Code, benign bool) {
+	r.metrics.serverConsumerRequestsCounter.WithLabelValues(r.serviceName, r.methodName, r.consumer).Inc()
+	if code != codes.OK && !benign {
+		r.metrics.serverConsumerErrorsCounter.WithLabelValues(r.serviceName, r.methodName, r.consumer).Inc()
+	}
+	if s := r.metrics.serverConsumerSLO; s != nil {
+		slow := r.config.slowHandledCounterEnabled && r.elapsed > r.config.slowHandlingThreshold
+		s.observe(r.serviceName, r.methodName, r.consumer, slow, time.Now())
+	}
+}
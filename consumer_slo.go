@@ -0,0 +1,95 @@
+package grpc_prometheus
+
+import (
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// otherConsumer is the consumer label value of the consumers outside of the
+// top consumers.
+const otherConsumer = "other"
+
+// consumerSLORefreshInterval is how often the top consumers are recomputed.
+const consumerSLORefreshInterval = time.Second
+
+// WithConsumerSLOViolations turns on grpc_server_consumer_slo_violations_total,
+// counting per consumer the RPCs slower than the slow handling threshold, so
+// that the consumers affected by a latency regression can be told apart.
+// Only the n consumers with the most RPCs, counted with exponential decay of
+// the given half-life, have their own series; the violations of the others
+// are counted as "other", and the series of consumers leaving the top n are
+// deleted. It needs both WithConsumerLabel, which identifies consumers, and
+// EnableSlowHandlingCounter, which sets the threshold.
+func WithConsumerSLOViolations(n int, halfLife time.Duration, counterOpts ...CounterOption) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		if m.serverConsumerSLO != nil {
+			return
+		}
+		m.serverConsumerSLO = &consumerSLO{
+			volume: newTopKTracker(n, halfLife, nil, nil),
+			counter: m.newCounterVec(
+				counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+					Name: "grpc_server_consumer_slo_violations_total",
+					Help: "Total number of RPCs completed on the server slower than the slow handling threshold, per top consumer.",
+				})), []string{"grpc_service", "grpc_method", "consumer"}),
+			series: make(map[string]map[methodKey]bool),
+		}
+	}
+}
+
+// consumerSLO counts the SLO violations of the top consumers by volume.
+type consumerSLO struct {
+	volume  *topKTracker
+	counter *counterVec
+
+	mu          sync.Mutex
+	refreshedAt time.Time
+	top         map[string]bool
+	// series are the methods with a series of each top consumer, to delete
+	// when it leaves the top consumers.
+	series map[string]map[methodKey]bool
+}
+
+// observe accounts an RPC of consumer, violating the SLO if slow.
+func (s *consumerSLO) observe(serviceName, methodName, consumer string, slow bool, now time.Time) {
+	s.volume.observe(consumer, now)
+	if !slow {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.refreshedAt) >= consumerSLORefreshInterval {
+		s.refresh(now)
+	}
+	if !s.top[consumer] {
+		consumer = otherConsumer
+	} else {
+		if s.series[consumer] == nil {
+			s.series[consumer] = make(map[methodKey]bool)
+		}
+		s.series[consumer][methodKey{serviceName, methodName}] = true
+	}
+	s.counter.WithLabelValues(serviceName, methodName, consumer).Inc()
+}
+
+// refresh recomputes the top consumers, deleting the series of those leaving
+// them. The caller must hold s.mu.
+func (s *consumerSLO) refresh(now time.Time) {
+	s.refreshedAt = now
+	top := make(map[string]bool, s.volume.k)
+	for _, e := range s.volume.top(now) {
+		top[e.key] = true
+	}
+	for consumer, methods := range s.series {
+		if top[consumer] {
+			continue
+		}
+		for k := range methods {
+			s.counter.DeleteLabelValues(k.service, k.method, consumer)
+		}
+		delete(s.series, consumer)
+	}
+	s.top = top
+}
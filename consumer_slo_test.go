@@ -0,0 +1,58 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestConsumerSLOViolations(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableSlowHandlingCounter(0)
+	m.Configure(
+		WithConsumerLabel("x-api-key", func(raw string) string { return raw }),
+		WithConsumerSLOViolations(1, time.Minute),
+	)
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	call := func(consumer string) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", consumer))
+		m.UnaryServerInterceptor()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			time.Sleep(time.Millisecond)
+			return nil, nil
+		})
+	}
+	call("team-a")
+	call("team-a")
+	call("team-b")
+
+	violations := m.serverConsumerSLO.counter
+	require.Equal(t, 2.0, testutil.ToFloat64(violations.WithLabelValues("mwitkow.testproto.TestService", "Ping", "team-a")))
+	require.Equal(t, 1.0, testutil.ToFloat64(violations.WithLabelValues("mwitkow.testproto.TestService", "Ping", "other")))
+}
+
+func TestConsumerSLOTopEviction(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithConsumerSLOViolations(1, time.Minute))
+	s := m.serverConsumerSLO
+	now := time.Now()
+
+	s.observe("svc", "Ping", "team-a", true, now)
+	s.observe("svc", "Ping", "team-a", true, now)
+	require.Equal(t, 2.0, testutil.ToFloat64(s.counter.WithLabelValues("svc", "Ping", "team-a")))
+
+	// Once team-b overtakes team-a, the series of team-a is deleted.
+	for i := 0; i < 5; i++ {
+		s.observe("svc", "Ping", "team-b", false, now)
+	}
+	now = now.Add(consumerSLORefreshInterval)
+	s.observe("svc", "Ping", "team-a", true, now)
+	s.observe("svc", "Ping", "team-b", true, now)
+	require.Equal(t, 1.0, testutil.ToFloat64(s.counter.WithLabelValues("svc", "Ping", "other")))
+	require.Equal(t, 1.0, testutil.ToFloat64(s.counter.WithLabelValues("svc", "Ping", "team-b")))
+	require.Equal(t, 0.0, testutil.ToFloat64(s.counter.WithLabelValues("svc", "Ping", "team-a")))
+}
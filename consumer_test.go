@@ -0,0 +1,43 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestHashConsumer(t *testing.T) {
+	normalize := HashConsumer(4)
+	require.Equal(t, normalize("key-a"), normalize("key-a"))
+	seen := map[string]bool{}
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		seen[normalize(key)] = true
+	}
+	for bucket := range seen {
+		require.Contains(t, []string{"0", "1", "2", "3"}, bucket)
+	}
+}
+
+func TestConsumerLabel(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithConsumerLabel("x-api-key", func(raw string) string { return "team-" + raw[:1] }))
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	call := func(ctx context.Context, err error) {
+		m.UnaryServerInterceptor()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) { return nil, err })
+	}
+	withKey := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "a-secret"))
+	call(withKey, nil)
+	call(withKey, status.Error(codes.Internal, ""))
+	call(context.Background(), nil)
+
+	requests, errors := m.serverConsumerRequestsCounter, m.serverConsumerErrorsCounter
+	require.Equal(t, 2.0, testutil.ToFloat64(requests.WithLabelValues("mwitkow.testproto.TestService", "Ping", "team-a")))
+	require.Equal(t, 1.0, testutil.ToFloat64(errors.WithLabelValues("mwitkow.testproto.TestService", "Ping", "team-a")))
+	require.Equal(t, 1.0, testutil.ToFloat64(requests.WithLabelValues("mwitkow.testproto.TestService", "Ping", "unknown")))
+}
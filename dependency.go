@@ -0,0 +1,45 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// WithDependencyHistogram records the time each RPC spent waiting on named
+// downstream dependencies, e.g. a database, in the
+// grpc_server_dependency_seconds histogram. Handlers attribute latency with
+// AttributeLatency, and the total per dependency is recorded when the RPC
+// ends, so the share of handling times due to each dependency can be read
+// from a single metric family. Dependency names must be a small, fixed set,
+// as each is a label value.
+func WithDependencyHistogram(opts ...HistogramOption) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		if m.serverDependencyHistogram != nil {
+			return
+		}
+		histOpts := prom.HistogramOpts{
+			Name:        "grpc_server_dependency_seconds",
+			Help:        "Histogram of time (seconds) spent by gRPC handled by the server waiting on downstream dependencies.",
+			Buckets:     prom.DefBuckets,
+			ConstLabels: m.counterOpts.apply(prom.CounterOpts{}).ConstLabels,
+		}
+		for _, o := range opts {
+			o(&histOpts)
+		}
+		m.serverDependencyHistogram = m.newHistogramVec(histOpts, []string{"grpc_type", "grpc_service", "grpc_method", "dependency"})
+	}
+}
+
+// AttributeLatency attributes d of the duration of the RPC of ctx to the
+// named dependency. Several calls for the same dependency accumulate. It does
+// nothing if ctx is not the context of an RPC monitored with
+// WithDependencyHistogram.
+func AttributeLatency(ctx context.Context, dependency string, d time.Duration) {
+	if p, ok := ctx.Value(dependenciesKey{}).(*phases); ok {
+		p.add(dependency, d)
+	}
+}
+
+type dependenciesKey struct{}
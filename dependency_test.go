@@ -0,0 +1,29 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestDependencyHistogram(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithDependencyHistogram())
+	m.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			AttributeLatency(ctx, "postgres", 20*time.Millisecond)
+			AttributeLatency(ctx, "postgres", 30*time.Millisecond)
+			return nil, nil
+		})
+
+	var metric dto.Metric
+	h := m.serverDependencyHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "postgres")
+	require.NoError(t, h.(prometheus.Metric).Write(&metric))
+	require.EqualValues(t, 1, metric.GetHistogram().GetSampleCount())
+	require.InDelta(t, 0.05, metric.GetHistogram().GetSampleSum(), 1e-9)
+}
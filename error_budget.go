@@ -0,0 +1,99 @@
+package grpc_prometheus
+
+import (
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+)
+
+// errorBudgetCodes are the codes counted against an error budget, i.e. the
+// failures attributable to the server rather than to the caller.
+var errorBudgetCodes = map[codes.Code]bool{
+	codes.Unknown:          true,
+	codes.DeadlineExceeded: true,
+	codes.Unimplemented:    true,
+	codes.Internal:         true,
+	codes.Unavailable:      true,
+	codes.DataLoss:         true,
+}
+
+// defaultErrorBudgetWindows are the windows burn rates are computed over if
+// none are configured.
+var defaultErrorBudgetWindows = []time.Duration{5 * time.Minute, time.Hour}
+
+// errorBudgetBuckets is the number of buckets each sliding window is split into.
+const errorBudgetBuckets = 60
+
+type methodKey struct {
+	service, method string
+}
+
+type errorBudget struct {
+	desc    *prom.Desc
+	windows []time.Duration
+	methods map[methodKey]*methodErrorBudget
+}
+
+type methodErrorBudget struct {
+	objective float64
+	// counters holds one sliding counter per configured window.
+	counters []*slidingCounter
+}
+
+func newErrorBudget(objectives map[string]float64, windows []time.Duration, constLabels prom.Labels) *errorBudget {
+	if len(windows) == 0 {
+		windows = defaultErrorBudgetWindows
+	}
+	b := &errorBudget{
+		desc: prom.NewDesc(
+			"grpc_server_error_budget_burn",
+			"Rate at which the error budget of a method is burnt over a sliding window, where 1 means the budget lasts exactly the objective period.",
+			[]string{"grpc_service", "grpc_method", "window"}, constLabels),
+		windows: windows,
+		methods: make(map[methodKey]*methodErrorBudget, len(objectives)),
+	}
+	for fullMethod, objective := range objectives {
+		service, method := splitMethodName(fullMethod)
+		mb := &methodErrorBudget{objective: objective}
+		for _, w := range windows {
+			mb.counters = append(mb.counters, newSlidingCounter(w, errorBudgetBuckets))
+		}
+		b.methods[methodKey{service, method}] = mb
+	}
+	return b
+}
+
+func (b *errorBudget) observe(service, method string, code codes.Code, t time.Time) {
+	mb, ok := b.methods[methodKey{service, method}]
+	if !ok {
+		return
+	}
+	var errors float64
+	if errorBudgetCodes[code] {
+		errors = 1
+	}
+	for _, c := range mb.counters {
+		c.add(t, 1, errors)
+	}
+}
+
+func (b *errorBudget) collect(ch chan<- prom.Metric, t time.Time) {
+	for key, mb := range b.methods {
+		for i, c := range mb.counters {
+			var burn float64
+			if total, errors := c.sum(t); total > 0 && mb.objective < 1 {
+				burn = (errors / total) / (1 - mb.objective)
+			}
+			ch <- prom.MustNewConstMetric(b.desc, prom.GaugeValue, burn, key.service, key.method, formatWindow(b.windows[i]))
+		}
+	}
+}
+
+func (b *errorBudget) Describe(ch chan<- *prom.Desc) {
+	ch <- b.desc
+}
+
+func (b *errorBudget) Collect(ch chan<- prom.Metric) {
+	b.collect(ch, time.Now())
+}
@@ -0,0 +1,77 @@
+package grpc_prometheus
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// errorSpikeMinRequests is the number of RPCs a method must have handled
+// within the window before its error ratio is considered, so that a single
+// failure of a rarely called method isn't reported as a spike.
+const errorSpikeMinRequests = 10
+
+// errorSpikeBuckets is the number of buckets each sliding window is split into.
+const errorSpikeBuckets = 10
+
+// WithErrorSpikeCallback calls fn when the ratio of errors of a method, over
+// the sliding window, rises to threshold or above, e.g. 0.1, so that the
+// process can react immediately, e.g. by dumping logs or flushing a flight
+// recorder, before Prometheus alerting would. Errors are the codes counted by
+// EnableErrorBudgetBurnGauges, and only methods having handled at least 10
+// RPCs within the window are considered. fn is called once per spike, in its
+// own goroutine, and again only after the ratio fell below threshold.
+func WithErrorSpikeCallback(threshold float64, window time.Duration, fn func(service, method string, errorRatio float64)) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.serverErrorSpikes = &errorSpikeDetector{
+			threshold: threshold,
+			window:    window,
+			fn:        fn,
+			methods:   make(map[methodKey]*methodErrorSpike),
+		}
+	}
+}
+
+type errorSpikeDetector struct {
+	threshold float64
+	window    time.Duration
+	fn        func(service, method string, errorRatio float64)
+
+	mu      sync.Mutex
+	methods map[methodKey]*methodErrorSpike
+}
+
+type methodErrorSpike struct {
+	counter *slidingCounter
+	// spiking is set while the error ratio is above the threshold.
+	spiking bool
+}
+
+func (d *errorSpikeDetector) observe(service, method string, code codes.Code, t time.Time) {
+	key := methodKey{service, method}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ms, ok := d.methods[key]
+	if !ok {
+		ms = &methodErrorSpike{counter: newSlidingCounter(d.window, errorSpikeBuckets)}
+		d.methods[key] = ms
+	}
+	var errors float64
+	if errorBudgetCodes[code] {
+		errors = 1
+	}
+	ms.counter.add(t, 1, errors)
+	total, errors := ms.counter.sum(t)
+	if total < errorSpikeMinRequests {
+		return
+	}
+	ratio := errors / total
+	switch {
+	case ratio >= d.threshold && !ms.spiking:
+		ms.spiking = true
+		go d.fn(service, method, ratio)
+	case ratio < d.threshold:
+		ms.spiking = false
+	}
+}
@@ -0,0 +1,54 @@
+package grpc_prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestErrorSpikeCallbackFiresOncePerSpike(t *testing.T) {
+	spikes := make(chan float64, 10)
+	m := NewServerMetrics()
+	m.Configure(WithErrorSpikeCallback(0.5, time.Minute, func(service, method string, errorRatio float64) {
+		require.Equal(t, "mwitkow.testproto.TestService", service)
+		require.Equal(t, "Ping", method)
+		spikes <- errorRatio
+	}))
+	d := m.serverErrorSpikes
+	now := time.Now()
+	observe := func(n int, code codes.Code) {
+		for i := 0; i < n; i++ {
+			d.observe("mwitkow.testproto.TestService", "Ping", code, now)
+		}
+	}
+
+	observe(9, codes.Unavailable)
+	observe(1, codes.OK)
+	require.Equal(t, 0.9, <-spikes)
+	observe(5, codes.Unavailable)
+	observe(20, codes.OK)
+	observe(10, codes.Internal)
+	require.Equal(t, 0.5, <-spikes, "fires again after the ratio fell below the threshold")
+	select {
+	case r := <-spikes:
+		t.Fatalf("unexpected spike %v", r)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestErrorSpikeCallbackIgnoresFewRequestsAndCallerErrors(t *testing.T) {
+	fired := false
+	d := &errorSpikeDetector{threshold: 0.1, window: time.Minute, methods: make(map[methodKey]*methodErrorSpike),
+		fn: func(service, method string, errorRatio float64) { fired = true }}
+	now := time.Now()
+	for i := 0; i < errorSpikeMinRequests-1; i++ {
+		d.observe("svc", "Ping", codes.Internal, now)
+	}
+	for i := 0; i < 100; i++ {
+		d.observe("svc", "Other", codes.InvalidArgument, now)
+	}
+	time.Sleep(10 * time.Millisecond)
+	require.False(t, fired)
+}
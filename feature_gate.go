@@ -0,0 +1,98 @@
+package grpc_prometheus
+
+import (
+	"sync"
+	"time"
+)
+
+// Features controlled by a FeatureGate. Each covers metrics expensive enough
+// to be worth turning off at runtime, e.g. during incidents.
+const (
+	// FeatureHandlingTimeHistogram covers grpc_server_handling_seconds, see
+	// ServerMetrics.SetHandlingTimeHistogramActive.
+	FeatureHandlingTimeHistogram = "grpc_prometheus_handling_time_histogram"
+	// FeatureMessageSizeMetrics covers the server metrics observing the size
+	// of every message: grpc_server_msg_size_received_bytes,
+	// grpc_server_msg_size_sent_bytes, the size limit ratio histogram, the
+	// message size stats and the large message counter.
+	FeatureMessageSizeMetrics = "grpc_prometheus_message_size_metrics"
+	// FeatureStreamMessageHistograms covers the client histograms observing
+	// every stream message: grpc_client_msg_recv_handling_seconds and
+	// grpc_client_msg_send_handling_seconds.
+	FeatureStreamMessageHistograms = "grpc_prometheus_stream_message_histograms"
+)
+
+// FeatureGate reports whether features are enabled, e.g. from a feature
+// flag system, so that instrumentation depth can be changed fleet-wide
+// without redeploys. Metrics covered by a feature are still registered when
+// it is disabled, they only stop being observed.
+type FeatureGate interface {
+	Enabled(feature string) bool
+}
+
+// FeatureGateFunc is an adapter to use an ordinary function as a
+// FeatureGate.
+type FeatureGateFunc func(feature string) bool
+
+// Enabled calls f(feature).
+func (f FeatureGateFunc) Enabled(feature string) bool {
+	return f(feature)
+}
+
+// ApplyFeatureGate applies the current state of the features of g to m. Call
+// it whenever flags change, for flag systems pushing changes, or use
+// WatchFeatureGate to poll g.
+func (m *ServerMetrics) ApplyFeatureGate(g FeatureGate) {
+	histogram, msgSize := g.Enabled(FeatureHandlingTimeHistogram), g.Enabled(FeatureMessageSizeMetrics)
+	m.updateRuntimeConfig(func(c *serverRuntimeConfig) {
+		c.handlingTimeHistogramPaused = !histogram
+		c.msgSizeMetricsPaused = !msgSize
+	})
+}
+
+// WatchFeatureGate applies the features of g to m now and then every
+// interval, until stop is called.
+func (m *ServerMetrics) WatchFeatureGate(g FeatureGate, interval time.Duration) (stop func()) {
+	return watchFeatureGate(func() { m.ApplyFeatureGate(g) }, interval)
+}
+
+// ApplyFeatureGate applies the current state of the features of g to m. Call
+// it whenever flags change, for flag systems pushing changes, or use
+// WatchFeatureGate to poll g.
+func (m *ClientMetrics) ApplyFeatureGate(g FeatureGate) {
+	streamHistograms := g.Enabled(FeatureStreamMessageHistograms)
+	m.updateRuntimeConfig(func(c *clientRuntimeConfig) { c.streamHistogramsPaused = !streamHistograms })
+}
+
+// WatchFeatureGate applies the features of g to m now and then every
+// interval, until stop is called.
+func (m *ClientMetrics) WatchFeatureGate(g FeatureGate, interval time.Duration) (stop func()) {
+	return watchFeatureGate(func() { m.ApplyFeatureGate(g) }, interval)
+}
+
+func watchFeatureGate(apply func(), interval time.Duration) (stop func()) {
+	apply()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				apply()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
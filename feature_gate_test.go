@@ -0,0 +1,74 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestServerFeatureGate(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableHandlingTimeHistogram()
+	m.EnableMsgSizeReceivedBytesHistogram()
+	m.Configure(WithLargeMessageThreshold(0))
+	call := func() {
+		m.UnaryServerInterceptor()(context.Background(), &pb_testproto.PingRequest{Value: "ping"}, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+			func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	}
+	m.ApplyFeatureGate(FeatureGateFunc(func(feature string) bool { return feature != FeatureMessageSizeMetrics }))
+	call()
+	require.True(t, m.HandlingTimeHistogramActive())
+	requireValueHistCount(t, 1, m.serverHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValue(t, 0, m.serverLargeMessageCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "received"))
+	requireValueHistCount(t, 0, m.serverMsgSizeReceivedHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+
+	m.ApplyFeatureGate(FeatureGateFunc(func(feature string) bool { return feature != FeatureHandlingTimeHistogram }))
+	call()
+	require.False(t, m.HandlingTimeHistogramActive())
+	requireValueHistCount(t, 1, m.serverHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValue(t, 1, m.serverLargeMessageCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "received"))
+	requireValueHistCount(t, 1, m.serverMsgSizeReceivedHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+}
+
+func TestEnableWhileServing(t *testing.T) {
+	m := NewServerMetrics()
+	done := make(chan struct{})
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			m.UnaryServerInterceptor()(context.Background(), &pb_testproto.PingRequest{Value: "ping"}, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+				func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+		}
+	}()
+	m.EnableHandlingTimeHistogram()
+	m.EnableHandlingTimeSummary()
+	m.EnableMsgSizeReceivedBytesHistogram()
+	m.EnableSlowHandlingCounter(0)
+	close(done)
+	<-served
+}
+
+func TestWatchFeatureGate(t *testing.T) {
+	m := NewClientMetrics()
+	var enabled int32 = 1
+	stop := m.WatchFeatureGate(FeatureGateFunc(func(string) bool { return atomic.LoadInt32(&enabled) != 0 }), time.Millisecond)
+	defer stop()
+	require.False(t, m.runtimeConfig().streamHistogramsPaused)
+	atomic.StoreInt32(&enabled, 0)
+	for deadline := time.Now().Add(time.Second); !m.runtimeConfig().streamHistogramsPaused; {
+		require.True(t, time.Now().Before(deadline), "feature gate not polled")
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+}
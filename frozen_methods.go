@@ -0,0 +1,42 @@
+package grpc_prometheus
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// WithFrozenMethodSet restricts the metrics to the approved methods, given as
+// full method names, e.g. "/mwitkow.testproto.TestService/Ping". RPCs to any
+// other method are left out of all other metrics and counted in
+// grpc_server_unexpected_method_total instead, which staying at zero proves
+// that only approved endpoints were served. The RPCs themselves are still
+// handled; rejecting them is up to the server.
+func WithFrozenMethodSet(methods []string) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.approvedMethods = make(map[methodKey]bool, len(methods))
+		for _, fullMethod := range methods {
+			service, method := splitMethodName(fullMethod)
+			m.approvedMethods[methodKey{service, method}] = true
+		}
+		if m.serverUnexpectedMethodCounter == nil {
+			m.serverUnexpectedMethodCounter = m.newCounterVec(
+				m.counterOpts.apply(prom.CounterOpts{
+					Name: "grpc_server_unexpected_method_total",
+					Help: "Total number of RPCs started on the server for methods outside of the approved method set.",
+				}), []string{"grpc_type", "grpc_service", "grpc_method"})
+		}
+	}
+}
+
+// unexpectedMethod reports whether fullMethod is outside of the method set
+// frozen by WithFrozenMethodSet, counting the RPC if so.
+func (m *ServerMetrics) unexpectedMethod(rpcType grpcType, fullMethod string) bool {
+	if m.approvedMethods == nil {
+		return false
+	}
+	service, method := splitMethodName(fullMethod)
+	if m.approvedMethods[methodKey{service, method}] {
+		return false
+	}
+	m.serverUnexpectedMethodCounter.WithLabelValues(string(rpcType), service, method).Inc()
+	return true
+}
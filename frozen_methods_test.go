@@ -0,0 +1,27 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestFrozenMethodSetDropsUnexpectedMethods(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithFrozenMethodSet([]string{"/mwitkow.testproto.TestService/Ping"}))
+	handled := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		handled++
+		return nil, nil
+	}
+	for _, fullMethod := range []string{"/mwitkow.testproto.TestService/Ping", "/mwitkow.testproto.TestService/PingError"} {
+		m.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: fullMethod}, handler)
+	}
+
+	require.Equal(t, 2, handled, "unexpected methods are still handled")
+	requireValue(t, 1, m.serverStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValue(t, 0, m.serverStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError"))
+	requireValue(t, 1, m.serverUnexpectedMethodCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError"))
+}
@@ -0,0 +1,10 @@
+//go:build !go1.16
+// +build !go1.16
+
+package grpc_prometheus
+
+const gcOverlapSupported = false
+
+func readGCCycles() uint64 {
+	return 0
+}
@@ -0,0 +1,21 @@
+//go:build go1.16
+// +build go1.16
+
+package grpc_prometheus
+
+import (
+	"runtime/metrics"
+)
+
+const gcOverlapSupported = true
+
+// readGCCycles returns the number of completed GC cycles. Every cycle
+// includes stop-the-world phases.
+func readGCCycles() uint64 {
+	sample := []metrics.Sample{{Name: "/gc/cycles/total:gc-cycles"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
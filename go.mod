@@ -2,8 +2,10 @@ module github.com/grpc-ecosystem/go-grpc-prometheus
 
 require (
 	github.com/golang/protobuf v1.2.0
+	github.com/golang/snappy v0.0.1
 	github.com/prometheus/client_golang v0.9.2
 	github.com/prometheus/client_model v0.0.0-20190129233127-fd36f4220a90
+	github.com/prometheus/common v0.0.0-20181126121408-4724e9255275
 	github.com/stretchr/testify v1.3.0
 	golang.org/x/net v0.0.0-20190213061140-3a22650c66bd
 	google.golang.org/grpc v1.18.0
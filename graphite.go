@@ -0,0 +1,104 @@
+package grpc_prometheus
+
+import (
+	"sort"
+	"strings"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// DefaultGraphiteTemplate is the metric name template used by
+// GraphiteGatherer if none is given.
+const DefaultGraphiteTemplate = "{metric}_{service}_{method}"
+
+// graphiteLabels maps template placeholders to the labels they encode.
+var graphiteLabels = map[string]string{
+	"{type}":    "grpc_type",
+	"{service}": "grpc_service",
+	"{method}":  "grpc_method",
+}
+
+// GraphiteGatherer wraps g, encoding the labels of gRPC metrics into metric
+// names for bridges exporting to Graphite-style systems, where labels are
+// not available. The name of each series is built from template, in which
+// {metric} is replaced by the original metric name and {type}, {service} and
+// {method} by the sanitized value of the grpc_type, grpc_service and
+// grpc_method label. Labels used by the template are removed, other labels
+// are kept. Metrics without any of these labels are passed through unchanged.
+//
+// The Prometheus-native exposition stays the default; use the returned
+// Gatherer only for the bridge, e.g. in graphite.Config.
+func GraphiteGatherer(g prom.Gatherer, template string) prom.Gatherer {
+	if template == "" {
+		template = DefaultGraphiteTemplate
+	}
+	return &graphiteGatherer{gatherer: g, template: template}
+}
+
+type graphiteGatherer struct {
+	gatherer prom.Gatherer
+	template string
+}
+
+func (g *graphiteGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.gatherer.Gather()
+	byName := make(map[string]*dto.MetricFamily)
+	var out []*dto.MetricFamily
+	for _, mf := range mfs {
+		for _, metric := range mf.Metric {
+			name, labels := g.encode(mf.GetName(), metric.Label)
+			family, ok := byName[name]
+			if !ok {
+				family = &dto.MetricFamily{Name: &name, Help: mf.Help, Type: mf.Type}
+				byName[name] = family
+				out = append(out, family)
+			}
+			encoded := *metric
+			encoded.Label = labels
+			family.Metric = append(family.Metric, &encoded)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].GetName() < out[j].GetName() })
+	return out, err
+}
+
+// encode returns the name of a series and its remaining labels.
+func (g *graphiteGatherer) encode(metricName string, labels []*dto.LabelPair) (string, []*dto.LabelPair) {
+	values := make(map[string]string, len(graphiteLabels))
+	for _, l := range labels {
+		values[l.GetName()] = l.GetValue()
+	}
+	used := make(map[string]bool, len(graphiteLabels))
+	replacements := []string{"{metric}", metricName}
+	for placeholder, label := range graphiteLabels {
+		if !strings.Contains(g.template, placeholder) {
+			continue
+		}
+		if v, ok := values[label]; ok {
+			used[label] = true
+			replacements = append(replacements, placeholder, sanitizeGraphiteNode(v))
+		}
+	}
+	if len(used) == 0 {
+		return metricName, labels
+	}
+	remaining := make([]*dto.LabelPair, 0, len(labels))
+	for _, l := range labels {
+		if !used[l.GetName()] {
+			remaining = append(remaining, l)
+		}
+	}
+	return strings.NewReplacer(replacements...).Replace(g.template), remaining
+}
+
+// sanitizeGraphiteNode replaces characters not valid in metric names, such
+// as the dots of package-qualified service names, with underscores.
+func sanitizeGraphiteNode(v string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, v)
+}
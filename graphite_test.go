@@ -0,0 +1,26 @@
+package grpc_prometheus
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphiteGathererEncodesLabelsIntoNames(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewServerMetrics()
+	reg.MustRegister(m.serverHandledCounter)
+	m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK").Inc()
+
+	mfs, err := GraphiteGatherer(reg, "").Gather()
+	require.NoError(t, err)
+	require.Len(t, mfs, 1)
+	require.Equal(t, "grpc_server_handled_total_mwitkow_testproto_TestService_Ping", mfs[0].GetName())
+	require.Len(t, mfs[0].Metric, 1)
+	labels := mfs[0].Metric[0].Label
+	require.Len(t, labels, 2)
+	require.Equal(t, "grpc_code", labels[0].GetName())
+	require.Equal(t, "grpc_type", labels[1].GetName())
+	require.EqualValues(t, 1, mfs[0].Metric[0].GetCounter().GetValue())
+}
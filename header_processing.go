@@ -0,0 +1,107 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+)
+
+// EnableHeaderProcessingTimeHistogram turns on recording the time between the
+// server receiving the request headers of an RPC and invoking its handler as
+// grpc_server_header_processing_seconds. This captures the aggregate cost of
+// routing and the interceptor chain per method.
+//
+// Recording needs both HeaderProcessingStatsHandler, installed with
+// grpc.StatsHandler, and the HeaderProcessing interceptors, installed as the
+// innermost interceptors of the chain.
+func (m *ServerMetrics) EnableHeaderProcessingTimeHistogram(opts ...HistogramOption) {
+	m.checkNotFrozen("EnableHeaderProcessingTimeHistogram")
+	for _, o := range opts {
+		o(&m.serverHeaderProcessingHistogramOpts)
+	}
+	if !m.serverHeaderProcessingHistogramEnabled {
+		m.serverHeaderProcessingHistogram = m.newHistogramVec(
+			m.serverHeaderProcessingHistogramOpts,
+			[]string{"grpc_type", "grpc_service", "grpc_method"},
+		)
+	}
+	m.serverHeaderProcessingHistogramEnabled = true
+	m.publishToggles()
+}
+
+// HeaderProcessingStatsHandler returns a stats.Handler noting when the request
+// headers of each server-side RPC are received. See ChainStatsHandlers to
+// install it along with other stats handlers.
+func (m *ServerMetrics) HeaderProcessingStatsHandler() stats.Handler {
+	return headerTimingHandler{}
+}
+
+// HeaderProcessingUnaryServerInterceptor is a gRPC server-side interceptor
+// recording the header processing time of Unary RPCs. It must be the last
+// interceptor of the chain.
+func (m *ServerMetrics) HeaderProcessingUnaryServerInterceptor() func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		m.observeHeaderProcessing(ctx, Unary, info.FullMethod)
+		return handler(ctx, req)
+	}
+}
+
+// HeaderProcessingStreamServerInterceptor is a gRPC server-side interceptor
+// recording the header processing time of Streaming RPCs. It must be the last
+// interceptor of the chain.
+func (m *ServerMetrics) HeaderProcessingStreamServerInterceptor() func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		m.observeHeaderProcessing(ss.Context(), streamRPCType(info), info.FullMethod)
+		return handler(srv, ss)
+	}
+}
+
+func (m *ServerMetrics) observeHeaderProcessing(ctx context.Context, rpcType grpcType, fullMethod string) {
+	if !m.runtimeConfig().headerProcessingHistogramEnabled {
+		return
+	}
+	t, ok := ctx.Value(headerTimingKey{}).(*headerTiming)
+	if !ok {
+		return
+	}
+	received := atomic.LoadInt64(&t.received)
+	if received == 0 {
+		return
+	}
+	serviceName, methodName := splitMethodName(fullMethod)
+	elapsed := time.Since(time.Unix(0, received))
+	m.serverHeaderProcessingHistogram.WithLabelValues(string(rpcType), serviceName, methodName).Observe(elapsed.Seconds())
+}
+
+type headerTimingKey struct{}
+
+// headerTiming is attached to the context of each RPC by headerTimingHandler.
+type headerTiming struct {
+	// received is accessed atomically, in unix nanoseconds.
+	received int64
+}
+
+// headerTimingHandler is a stats.Handler recording when the request headers
+// of server-side RPCs are received.
+type headerTimingHandler struct{}
+
+func (headerTimingHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, headerTimingKey{}, &headerTiming{})
+}
+
+func (headerTimingHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if h, ok := s.(*stats.InHeader); ok && !h.Client {
+		if t, ok := ctx.Value(headerTimingKey{}).(*headerTiming); ok {
+			atomic.StoreInt64(&t.received, time.Now().UnixNano())
+		}
+	}
+}
+
+func (headerTimingHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (headerTimingHandler) HandleConn(ctx context.Context, s stats.ConnStats) {}
@@ -0,0 +1,60 @@
+package grpc_prometheus
+
+import (
+	"math"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// Default range of the heatmap histogram buckets, in seconds.
+	defaultHeatmapMin = 0.001
+	defaultHeatmapMax = 60
+)
+
+// HeatmapBuckets returns count bucket boundaries spaced evenly on a log scale
+// from min to max, both included, rounded to 2 significant figures. Evenly
+// spaced rows are what heatmap panels, e.g. Grafana's, render best.
+func HeatmapBuckets(min, max float64, count int) []float64 {
+	if min <= 0 || max <= min || count < 2 {
+		panic("HeatmapBuckets needs 0 < min < max and at least 2 buckets")
+	}
+	factor := math.Pow(max/min, 1/float64(count-1))
+	buckets := make([]float64, 0, count)
+	for i := 0; i < count; i++ {
+		b := roundSignificant(min*math.Pow(factor, float64(i)), 2)
+		if len(buckets) == 0 || b > buckets[len(buckets)-1] {
+			buckets = append(buckets, b)
+		}
+	}
+	return buckets
+}
+
+func roundSignificant(v float64, figures int) float64 {
+	scale := math.Pow(10, float64(figures-1)-math.Floor(math.Log10(v)))
+	return math.Floor(v*scale+0.5) / scale
+}
+
+// WithHeatmapHistogram records handling times in the
+// grpc_server_handling_heatmap_seconds histogram, with count log-spaced
+// buckets from 1ms to 60s unless overridden by opts. It is independent of
+// EnableHandlingTimeHistogram, so that heatmap panels can use a handful of
+// buckets while the primary histogram keeps the resolution needed for
+// quantiles, or is disabled altogether.
+func WithHeatmapHistogram(count int, opts ...HistogramOption) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		if m.serverHeatmapHistogram != nil {
+			return
+		}
+		histOpts := prom.HistogramOpts{
+			Name:        "grpc_server_handling_heatmap_seconds",
+			Help:        "Histogram of response latency (seconds) of gRPC that had been application-level handled by the server, with few buckets for heatmaps.",
+			Buckets:     HeatmapBuckets(defaultHeatmapMin, defaultHeatmapMax, count),
+			ConstLabels: m.counterOpts.apply(prom.CounterOpts{}).ConstLabels,
+		}
+		for _, o := range opts {
+			o(&histOpts)
+		}
+		m.serverHeatmapHistogram = m.newHistogramVec(histOpts, []string{"grpc_type", "grpc_service", "grpc_method"})
+	}
+}
@@ -0,0 +1,26 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestHeatmapBuckets(t *testing.T) {
+	require.Equal(t, []float64{0.001, 0.01, 0.1, 1}, HeatmapBuckets(0.001, 1, 4))
+	require.Equal(t, []float64{0.001, 0.004, 0.016, 0.062, 0.24, 0.97, 3.8, 15, 60}, HeatmapBuckets(defaultHeatmapMin, defaultHeatmapMax, 9))
+}
+
+func TestHeatmapHistogramIsIndependentOfHandlingTimeHistogram(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithHeatmapHistogram(5))
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	m.UnaryServerInterceptor()(context.Background(), nil, info, handler)
+	m.UnaryServerInterceptor()(SkipHistogramRecording(context.Background()), nil, info, handler)
+
+	require.Nil(t, m.serverHandledHistogram)
+	requireValueHistCount(t, 1, m.serverHeatmapHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+}
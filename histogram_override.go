@@ -0,0 +1,39 @@
+package grpc_prometheus
+
+import (
+	"context"
+)
+
+// histogramOverride overrides, for a single RPC, whether its latency is
+// observed in histograms.
+type histogramOverride int
+
+const (
+	histogramDefault histogramOverride = iota
+	histogramForce
+	histogramSkip
+)
+
+type histogramOverrideKey struct{}
+
+// ForceHistogramRecording returns a copy of ctx for which handling times are
+// observed in the handling time histograms even while recording is paused
+// with ServerMetrics.SetHandlingTimeHistogramActive, and the send and receive
+// times of client streams even while paused by a FeatureGate, e.g. for a
+// sampled debug call during an investigation. Histograms must still have been
+// enabled, as the set of registered metrics can't change at runtime.
+func ForceHistogramRecording(ctx context.Context) context.Context {
+	return context.WithValue(ctx, histogramOverrideKey{}, histogramForce)
+}
+
+// SkipHistogramRecording returns a copy of ctx for which neither handling
+// times nor the send and receive times of client streams are observed in
+// histograms, while counters are still incremented.
+func SkipHistogramRecording(ctx context.Context) context.Context {
+	return context.WithValue(ctx, histogramOverrideKey{}, histogramSkip)
+}
+
+func histogramOverrideFrom(ctx context.Context) histogramOverride {
+	o, _ := ctx.Value(histogramOverrideKey{}).(histogramOverride)
+	return o
+}
@@ -0,0 +1,92 @@
+package grpc_prometheus
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// Channel is the client side of a gRPC connection, as implemented by
+// *grpc.ClientConn and by in-process transports such as
+// github.com/fullstorydev/grpchan/inprocgrpc. It has the methods of
+// grpc.ClientConnInterface of newer gRPC versions.
+type Channel interface {
+	Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error
+	NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error)
+}
+
+// InstrumentChannel returns a Channel monitoring the RPCs made through ch with
+// the client interceptors of m, for in-process transports which don't take
+// grpc.DialOptions. The interceptors are passed no *grpc.ClientConn, so
+// metrics labeled by target, e.g. grpc_client_clock_skew_seconds, are not
+// recorded.
+func (m *ClientMetrics) InstrumentChannel(ch Channel) Channel {
+	return &instrumentedChannel{
+		ch:     ch,
+		unary:  m.UnaryClientInterceptor(),
+		stream: m.StreamClientInterceptor(),
+	}
+}
+
+type instrumentedChannel struct {
+	ch     Channel
+	unary  grpc.UnaryClientInterceptor
+	stream grpc.StreamClientInterceptor
+}
+
+func (c *instrumentedChannel) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	return c.unary(ctx, method, args, reply, nil, func(ctx context.Context, method string, args, reply interface{}, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return c.ch.Invoke(ctx, method, args, reply, opts...)
+	}, opts...)
+}
+
+func (c *instrumentedChannel) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return c.stream(ctx, desc, nil, method, func(ctx context.Context, desc *grpc.StreamDesc, _ *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return c.ch.NewStream(ctx, desc, method, opts...)
+	}, opts...)
+}
+
+// InstrumentServiceDesc returns a copy of desc whose handlers are monitored
+// by the server interceptors of m, running before any interceptor the server
+// passes to unary handlers. It is intended for in-process transports, and
+// other servers dispatching to generated service descriptions, which don't
+// support interceptors or stats handlers. Register the returned description
+// instead of desc, e.g. on an inprocgrpc.Channel:
+//
+//	channel.RegisterService(m.InstrumentServiceDesc(&pb.MyService_serviceDesc), srv)
+func (m *ServerMetrics) InstrumentServiceDesc(desc *grpc.ServiceDesc) *grpc.ServiceDesc {
+	unary := m.UnaryServerInterceptor()
+	stream := m.StreamServerInterceptor()
+	instrumented := *desc
+	instrumented.Methods = make([]grpc.MethodDesc, len(desc.Methods))
+	for i, md := range desc.Methods {
+		handler := md.Handler
+		instrumented.Methods[i] = grpc.MethodDesc{
+			MethodName: md.MethodName,
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				return handler(srv, ctx, dec, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+					return unary(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+						if interceptor == nil {
+							return h(ctx, req)
+						}
+						return interceptor(ctx, req, info, h)
+					})
+				})
+			},
+		}
+	}
+	instrumented.Streams = make([]grpc.StreamDesc, len(desc.Streams))
+	for i, sd := range desc.Streams {
+		handler := sd.Handler
+		info := &grpc.StreamServerInfo{
+			FullMethod:     "/" + desc.ServiceName + "/" + sd.StreamName,
+			IsClientStream: sd.ClientStreams,
+			IsServerStream: sd.ServerStreams,
+		}
+		instrumented.Streams[i] = sd
+		instrumented.Streams[i].Handler = func(srv interface{}, ss grpc.ServerStream) error {
+			return stream(srv, ss, info, handler)
+		}
+	}
+	return &instrumented
+}
@@ -0,0 +1,79 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeChannel stands in for an in-process transport.
+type fakeChannel struct {
+	err error
+}
+
+func (c *fakeChannel) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	return c.err
+}
+
+func (c *fakeChannel) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, c.err
+}
+
+func TestInstrumentChannel(t *testing.T) {
+	m := NewClientMetrics()
+	ch := m.InstrumentChannel(&fakeChannel{err: status.Error(codes.NotFound, "")})
+	err := ch.Invoke(context.Background(), "/mwitkow.testproto.TestService/Ping", &pb_testproto.PingRequest{}, &pb_testproto.PingResponse{})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = ch.NewStream(context.Background(), &grpc.StreamDesc{ServerStreams: true}, "/mwitkow.testproto.TestService/PingList")
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	requireValue(t, 1, m.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "NotFound"))
+	requireValue(t, 1, m.clientHandledCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "NotFound"))
+}
+
+func TestInstrumentServiceDesc(t *testing.T) {
+	desc := &grpc.ServiceDesc{
+		ServiceName: "mwitkow.testproto.TestService",
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Ping",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return &pb_testproto.PingResponse{}, nil
+				}
+				if interceptor == nil {
+					return handler(ctx, &pb_testproto.PingRequest{})
+				}
+				return interceptor(ctx, &pb_testproto.PingRequest{}, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/mwitkow.testproto.TestService/Ping"}, handler)
+			},
+		}},
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "PingList",
+			ServerStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return status.Error(codes.Unavailable, "")
+			},
+		}},
+	}
+	m := NewServerMetrics()
+	instrumented := m.InstrumentServiceDesc(desc)
+
+	serverInterceptorCalled := false
+	_, err := instrumented.Methods[0].Handler(nil, context.Background(), nil, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		serverInterceptorCalled = true
+		return handler(ctx, req)
+	})
+	require.NoError(t, err)
+	require.True(t, serverInterceptorCalled)
+	_, err = instrumented.Methods[0].Handler(nil, context.Background(), nil, nil)
+	require.NoError(t, err)
+	err = instrumented.Streams[0].Handler(nil, &fakeServerStream{ctx: context.Background()})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+
+	requireValue(t, 2, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "Unavailable"))
+}
@@ -0,0 +1,31 @@
+package grpc_prometheus
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// WithLargeMessageThreshold turns on the grpc_server_large_messages_total
+// counter of messages larger than bytes, per method and direction
+// ("received" or "sent"). It is a cheap, targeted alarm for unexpectedly
+// large payloads that doesn't require querying histogram buckets.
+func WithLargeMessageThreshold(bytes int, counterOpts ...CounterOption) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.largeMessageThreshold = bytes
+		if m.serverLargeMessageCounter == nil {
+			m.serverLargeMessageCounter = m.newCounterVec(
+				counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+					Name: "grpc_server_large_messages_total",
+					Help: "Total number of gRPC messages received or sent by the server larger than the configured threshold.",
+				})), []string{"grpc_type", "grpc_service", "grpc_method", "direction"})
+		}
+	}
+}
+
+func (r *serverReporter) observeMessageSize(direction string, size int) {
+	if r.metrics.serverLargeMessageCounter != nil && size > r.metrics.largeMessageThreshold {
+		r.metrics.serverLargeMessageCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName, direction).Inc()
+	}
+	if r.metrics.serverMsgSizeStats != nil {
+		r.metrics.serverMsgSizeStats.observe(r.rpcType, r.serviceName, r.methodName, direction, size)
+	}
+}
@@ -0,0 +1,22 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"google.golang.org/grpc"
+)
+
+func TestLargeMessageThreshold(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithLargeMessageThreshold(6))
+	req := &pb_testproto.PingRequest{Value: "ping"}
+	resp := &pb_testproto.PingResponse{Value: "pong", Counter: 42}
+	m.UnaryServerInterceptor()(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return resp, nil })
+
+	// The 6 bytes request is at the threshold, the 8 bytes response above.
+	requireValue(t, 0, m.serverLargeMessageCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "received"))
+	requireValue(t, 1, m.serverLargeMessageCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "sent"))
+}
@@ -0,0 +1,93 @@
+package grpc_prometheus
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LintProblem is an instrumentation hygiene issue found by Lint.
+type LintProblem struct {
+	Metric string
+	Text   string
+}
+
+func (p LintProblem) String() string {
+	return p.Metric + ": " + p.Text
+}
+
+var (
+	camelCasePattern = regexp.MustCompile(`[a-z][A-Z]`)
+
+	// nonBaseUnits maps units Prometheus discourages to their base unit.
+	nonBaseUnits = map[string]string{
+		"nanoseconds": "seconds", "microseconds": "seconds", "milliseconds": "seconds",
+		"minutes": "seconds", "hours": "seconds", "days": "seconds",
+		"bits": "bytes", "kilobytes": "bytes", "megabytes": "bytes", "gigabytes": "bytes",
+		"percent": "ratio",
+	}
+	unitAbbreviations = []string{"ns", "us", "ms", "sec", "secs", "min", "mins", "b", "kb", "mb", "gb", "pct"}
+	typeNames         = []string{"counter", "gauge", "histogram", "summary"}
+)
+
+// Lint checks the metrics the ServerMetrics emits, see Schema, against the
+// Prometheus naming conventions, the way promlint does, and returns the
+// problems found. It is intended for the tests of applications, in
+// particular when options rename metrics or change their units.
+func (m *ServerMetrics) Lint() []LintProblem {
+	var problems []LintProblem
+	for _, ms := range m.Schema().Metrics {
+		problems = append(problems, lintMetric(ms)...)
+	}
+	return problems
+}
+
+func lintMetric(ms MetricSchema) []LintProblem {
+	var problems []LintProblem
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, LintProblem{Metric: ms.Name, Text: fmt.Sprintf(format, args...)})
+	}
+	if ms.Help == "" {
+		report("no help text")
+	}
+	if strings.Contains(ms.Name, ":") {
+		report("metric names should not contain ':'")
+	}
+	if camelCasePattern.MatchString(ms.Name) {
+		report("metric names should be written in 'snake_case' not 'camelCase'")
+	}
+	for _, l := range ms.Labels {
+		if camelCasePattern.MatchString(l) {
+			report("label names should be written in 'snake_case' not 'camelCase'")
+		}
+		if (ms.Type == "histogram" && l == "le") || (ms.Type == "summary" && l == "quantile") {
+			report("label name %q is reserved for %ss", l, ms.Type)
+		}
+	}
+	isCounter := ms.Type == "counter"
+	if isCounter && !strings.HasSuffix(ms.Name, "_total") {
+		report(`counter metrics should have "_total" suffix`)
+	}
+	if !isCounter && strings.HasSuffix(ms.Name, "_total") {
+		report(`non-counter metrics should not have "_total" suffix`)
+	}
+	parts := strings.Split(ms.Name, "_")
+	for _, part := range parts {
+		if base, ok := nonBaseUnits[part]; ok {
+			report("use base unit %q instead of %q", base, part)
+		}
+		for _, abbr := range unitAbbreviations {
+			if part == abbr {
+				report("metric names should not contain abbreviated units")
+			}
+		}
+	}
+	for _, typ := range typeNames {
+		for _, part := range parts {
+			if part == typ {
+				report("metric name should not include type '%s'", typ)
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,29 @@
+package grpc_prometheus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerMetricsLintClean(t *testing.T) {
+	m := NewServerMetrics(WithUniqueNameSuffix())
+	m.EnableHandlingTimeHistogram(WithHistogramUniqueNameSuffix())
+	m.EnableHandlingTimeSummary()
+	m.EnableConfigInfo(ConfigInfo{})
+	require.Empty(t, m.Lint())
+}
+
+func TestLintMetric(t *testing.T) {
+	problems := lintMetric(MetricSchema{Name: "grpc_server_latency_milliseconds_total", Type: "histogram", Labels: []string{"le"}})
+	var texts []string
+	for _, p := range problems {
+		texts = append(texts, p.Text)
+	}
+	require.Equal(t, []string{
+		"no help text",
+		`label name "le" is reserved for histograms`,
+		`non-counter metrics should not have "_total" suffix`,
+		`use base unit "seconds" instead of "milliseconds"`,
+	}, texts)
+}
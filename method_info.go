@@ -0,0 +1,118 @@
+package grpc_prometheus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strconv"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// EnableMethodInfo turns on grpc_method_info, an info gauge exporting static
+// properties of each method from its proto options: its idempotency level
+// and whether it is deprecated. Dashboards can join it with runtime metrics,
+// e.g. to check which retried methods are safe to retry. Methods are added
+// by InitializeMetrics, from the file descriptors of the registered services;
+// services whose descriptors are not registered with the proto package are
+// not exported.
+func (m *ServerMetrics) EnableMethodInfo() {
+	m.checkNotFrozen("EnableMethodInfo")
+	if m.methodInfo != nil {
+		return
+	}
+	m.methodInfo = &methodInfoCollector{
+		desc: prom.NewDesc(
+			"grpc_method_info",
+			"Static properties of the gRPC methods registered on the server, from their proto options, in labels.",
+			[]string{"grpc_service", "grpc_method", "idempotency_level", "deprecated"},
+			m.counterOpts.apply(prom.CounterOpts{}).ConstLabels),
+		methods: make(map[methodKey]*descriptor.MethodOptions),
+	}
+}
+
+type methodInfoCollector struct {
+	desc *prom.Desc
+
+	mu      sync.Mutex
+	methods map[methodKey]*descriptor.MethodOptions
+}
+
+// addService adds the methods of serviceName, defined in the proto file of
+// the given name.
+func (c *methodInfoCollector) addService(serviceName string, file interface{}) {
+	name, ok := file.(string)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, opts := range fileMethodOptions(name) {
+		if key.service == serviceName {
+			c.methods[key] = opts
+		}
+	}
+}
+
+// fileMethodOptions returns the options of the methods of all services
+// defined in the registered proto file of the given name.
+func fileMethodOptions(name string) map[methodKey]*descriptor.MethodOptions {
+	fd, err := decodeFileDescriptor(proto.FileDescriptor(name))
+	if err != nil {
+		return nil
+	}
+	methods := make(map[methodKey]*descriptor.MethodOptions)
+	for _, sd := range fd.GetService() {
+		serviceName := sd.GetName()
+		if fd.GetPackage() != "" {
+			serviceName = fd.GetPackage() + "." + serviceName
+		}
+		for _, md := range sd.GetMethod() {
+			methods[methodKey{serviceName, md.GetName()}] = md.GetOptions()
+		}
+	}
+	return methods
+}
+
+// decodeFileDescriptor decodes a gzipped FileDescriptorProto, as registered
+// by generated code.
+func decodeFileDescriptor(gz []byte) (*descriptor.FileDescriptorProto, error) {
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	fd := &descriptor.FileDescriptorProto{}
+	if err := proto.Unmarshal(b, fd); err != nil {
+		return nil, err
+	}
+	return fd, nil
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (c *methodInfoCollector) Describe(ch chan<- *prom.Desc) {
+	ch <- c.desc
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (c *methodInfoCollector) Collect(ch chan<- prom.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, opts := range c.methods {
+		ch <- prom.MustNewConstMetric(c.desc, prom.GaugeValue, 1,
+			key.service, key.method,
+			opts.GetIdempotencyLevel().String(),
+			strconv.FormatBool(opts.GetDeprecated()),
+		)
+	}
+}
@@ -0,0 +1,81 @@
+package grpc_prometheus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func init() {
+	fd := &descriptor.FileDescriptorProto{
+		Name:    proto.String("grpc_prometheus_method_info_test.proto"),
+		Package: proto.String("grpc_prometheus.test"),
+		Service: []*descriptor.ServiceDescriptorProto{{
+			Name: proto.String("InfoService"),
+			Method: []*descriptor.MethodDescriptorProto{
+				{
+					Name:    proto.String("Get"),
+					Options: &descriptor.MethodOptions{IdempotencyLevel: descriptor.MethodOptions_NO_SIDE_EFFECTS.Enum()},
+				},
+				{
+					Name:    proto.String("OldPut"),
+					Options: &descriptor.MethodOptions{Deprecated: proto.Bool(true)},
+				},
+			},
+		}},
+	}
+	b, err := proto.Marshal(fd)
+	if err != nil {
+		panic(err)
+	}
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(b)
+	w.Close()
+	proto.RegisterFile(fd.GetName(), gz.Bytes())
+}
+
+func TestMethodInfo(t *testing.T) {
+	s := grpc.NewServer()
+	pb_testproto.RegisterTestServiceServer(s, &testService{t: t})
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "grpc_prometheus.test.InfoService",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{
+			{MethodName: "Get"},
+			{MethodName: "OldPut"},
+		},
+		Metadata: "grpc_prometheus_method_info_test.proto",
+	}, struct{}{})
+
+	m := NewServerMetrics()
+	m.EnableMethodInfo()
+	m.InitializeMetrics(s)
+	reg := prom.NewPedanticRegistry()
+	reg.MustRegister(m)
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	infos := map[string]string{}
+	for _, mf := range mfs {
+		if mf.GetName() != "grpc_method_info" {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, lp := range metric.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			infos[labels["grpc_service"]+"/"+labels["grpc_method"]] = labels["idempotency_level"] + "," + labels["deprecated"]
+		}
+	}
+	require.Equal(t, "NO_SIDE_EFFECTS,false", infos["grpc_prometheus.test.InfoService/Get"])
+	require.Equal(t, "IDEMPOTENCY_UNKNOWN,true", infos["grpc_prometheus.test.InfoService/OldPut"])
+	require.Equal(t, "IDEMPOTENCY_UNKNOWN,false", infos["mwitkow.testproto.TestService/Ping"])
+}
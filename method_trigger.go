@@ -0,0 +1,187 @@
+package grpc_prometheus
+
+import (
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+)
+
+// methodTriggerMinRequests is the number of RPCs a method must have handled
+// within the window before its bounds are checked, so that a single slow or
+// failed RPC of a rarely called method doesn't trigger it.
+const methodTriggerMinRequests = 10
+
+// methodTriggerBuckets is the number of buckets each sliding window is split
+// into.
+const methodTriggerBuckets = 10
+
+// TriggerBounds are the bounds of WithMethodTrigger. Zero values disable the
+// corresponding bound.
+type TriggerBounds struct {
+	// ErrorRatio is the ratio of errors, counted as by
+	// EnableErrorBudgetBurnGauges, e.g. 0.05.
+	ErrorRatio float64
+	// Latency is the mean handling time.
+	Latency time.Duration
+}
+
+// WithMethodTrigger calls fn with triggered set when the error ratio or the
+// mean handling time of a method, over the sliding window, reaches one of
+// bounds, and again with triggered unset once both are back within bounds.
+// This allows reacting on the affected method only, e.g. by raising its log
+// verbosity or sampling its traces while it misbehaves. The state of each
+// method is exported in the grpc_server_method_triggered gauge. Only methods
+// having handled at least 10 RPCs within the window are triggered, methods
+// falling below, e.g. going idle, are untriggered.
+//
+// fn is called at the end of the RPC crossing the bound, or by a timer once
+// the RPCs of a triggered method left the window. Calls for a method are made
+// one at a time and in order, and delay its RPCs changing its state: fn must
+// not block.
+func WithMethodTrigger(bounds TriggerBounds, window time.Duration, fn func(service, method string, triggered bool)) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.serverMethodTrigger = &methodTrigger{
+			desc: prom.NewDesc(
+				"grpc_server_method_triggered",
+				"Whether the error ratio or mean handling time of a method over a sliding window is beyond the trigger bounds.",
+				[]string{"grpc_service", "grpc_method"},
+				m.counterOpts.apply(prom.CounterOpts{}).ConstLabels),
+			bounds:  bounds,
+			window:  window,
+			fn:      fn,
+			methods: make(map[methodKey]*methodTriggerState),
+		}
+	}
+}
+
+type methodTrigger struct {
+	desc   *prom.Desc
+	bounds TriggerBounds
+	window time.Duration
+	fn     func(service, method string, triggered bool)
+
+	mu      sync.RWMutex
+	methods map[methodKey]*methodTriggerState
+}
+
+type methodTriggerState struct {
+	service, method string
+
+	mu     sync.Mutex
+	errors *slidingCounter
+	// latency sums the handling times of RPCs, in seconds, as errors.
+	latency   *slidingCounter
+	triggered bool
+
+	// notifyMu serializes the calls to fn, notified being the state fn was
+	// last called with.
+	notifyMu sync.Mutex
+	notified bool
+}
+
+func (d *methodTrigger) state(service, method string) *methodTriggerState {
+	key := methodKey{service, method}
+	d.mu.RLock()
+	ms, ok := d.methods[key]
+	d.mu.RUnlock()
+	if ok {
+		return ms
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if ms, ok := d.methods[key]; ok {
+		return ms
+	}
+	ms = &methodTriggerState{
+		service: service,
+		method:  method,
+		errors:  newSlidingCounter(d.window, methodTriggerBuckets),
+		latency: newSlidingCounter(d.window, methodTriggerBuckets),
+	}
+	d.methods[key] = ms
+	return ms
+}
+
+func (d *methodTrigger) observe(service, method string, code codes.Code, elapsed time.Duration, t time.Time) {
+	ms := d.state(service, method)
+	var errors float64
+	if errorBudgetCodes[code] {
+		errors = 1
+	}
+	ms.mu.Lock()
+	ms.errors.add(t, 1, errors)
+	ms.latency.add(t, 1, elapsed.Seconds())
+	ms.mu.Unlock()
+	d.evaluate(ms, t)
+}
+
+// evaluate updates the state of ms at t, notifying fn of changes. While
+// triggered, ms is evaluated again periodically, so that it is untriggered
+// once its RPCs leave the window even if the method went idle.
+func (d *methodTrigger) evaluate(ms *methodTriggerState, t time.Time) {
+	ms.mu.Lock()
+	total, errors := ms.errors.sum(t)
+	_, seconds := ms.latency.sum(t)
+	triggered := total >= methodTriggerMinRequests &&
+		(d.bounds.ErrorRatio > 0 && errors/total >= d.bounds.ErrorRatio ||
+			d.bounds.Latency > 0 && seconds/total >= d.bounds.Latency.Seconds())
+	changed := triggered != ms.triggered
+	ms.triggered = triggered
+	ms.mu.Unlock()
+	if !changed {
+		return
+	}
+	if triggered {
+		d.scheduleEvaluation(ms)
+	}
+
+	// Notify the latest state, so that concurrent changes are delivered in
+	// order.
+	ms.notifyMu.Lock()
+	defer ms.notifyMu.Unlock()
+	ms.mu.Lock()
+	triggered = ms.triggered
+	ms.mu.Unlock()
+	if triggered != ms.notified {
+		ms.notified = triggered
+		d.fn(ms.service, ms.method, triggered)
+	}
+}
+
+func (d *methodTrigger) scheduleEvaluation(ms *methodTriggerState) {
+	time.AfterFunc(d.window/methodTriggerBuckets, func() {
+		d.evaluate(ms, time.Now())
+		ms.mu.Lock()
+		triggered := ms.triggered
+		ms.mu.Unlock()
+		if triggered {
+			d.scheduleEvaluation(ms)
+		}
+	})
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (d *methodTrigger) Describe(ch chan<- *prom.Desc) {
+	ch <- d.desc
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (d *methodTrigger) Collect(ch chan<- prom.Metric) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for key, ms := range d.methods {
+		var v float64
+		ms.mu.Lock()
+		if ms.triggered {
+			v = 1
+		}
+		ms.mu.Unlock()
+		ch <- prom.MustNewConstMetric(d.desc, prom.GaugeValue, v, key.service, key.method)
+	}
+}
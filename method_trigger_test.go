@@ -0,0 +1,68 @@
+package grpc_prometheus
+
+import (
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+// collectTriggered returns the state of each method of d.
+func collectTriggered(t *testing.T, d *methodTrigger) map[string]float64 {
+	ch := make(chan prom.Metric, 16)
+	d.Collect(ch)
+	close(ch)
+	values := map[string]float64{}
+	for metric := range ch {
+		var pb dto.Metric
+		require.NoError(t, metric.Write(&pb))
+		values[pb.GetLabel()[0].GetValue()] = pb.GetGauge().GetValue()
+	}
+	return values
+}
+
+type triggerCall struct {
+	service, method string
+	triggered       bool
+}
+
+func TestMethodTrigger(t *testing.T) {
+	var calls []triggerCall
+	m := NewServerMetrics()
+	m.Configure(WithMethodTrigger(TriggerBounds{ErrorRatio: 0.5, Latency: 100 * time.Millisecond}, time.Minute, func(service, method string, triggered bool) {
+		calls = append(calls, triggerCall{service, method, triggered})
+	}))
+	d := m.serverMethodTrigger
+	now := time.Now()
+
+	// Too few RPCs.
+	for i := 0; i < methodTriggerMinRequests-1; i++ {
+		d.observe("svc", "Ping", codes.Internal, 0, now)
+	}
+	require.Empty(t, calls)
+	d.observe("svc", "Ping", codes.Internal, 0, now)
+	require.Equal(t, []triggerCall{{"svc", "Ping", true}}, calls)
+
+	// Recovered once the errors leave the window.
+	now = now.Add(time.Minute)
+	for i := 0; i < methodTriggerMinRequests; i++ {
+		d.observe("svc", "Ping", codes.OK, 0, now)
+	}
+	require.Equal(t, []triggerCall{{"svc", "Ping", true}, {"svc", "Ping", false}}, calls)
+
+	// Slow methods are triggered too.
+	calls = nil
+	for i := 0; i < methodTriggerMinRequests; i++ {
+		d.observe("svc", "Slow", codes.OK, time.Second, now)
+	}
+	require.Equal(t, []triggerCall{{"svc", "Slow", true}}, calls)
+	require.Equal(t, map[string]float64{"Ping": 0, "Slow": 1}, collectTriggered(t, d))
+
+	// Idle methods are untriggered.
+	d.evaluate(d.state("svc", "Slow"), now.Add(time.Minute))
+	require.Equal(t, []triggerCall{{"svc", "Slow", true}, {"svc", "Slow", false}}, calls)
+	require.Equal(t, map[string]float64{"Ping": 0, "Slow": 0}, collectTriggered(t, d))
+}
@@ -1,6 +1,10 @@
 package grpc_prometheus
 
 import (
+	"context"
+	"math"
+	"time"
+
 	prom "github.com/prometheus/client_golang/prometheus"
 )
 
@@ -23,6 +27,64 @@ func WithConstLabels(labels prom.Labels) CounterOption {
 	}
 }
 
+// A ServerMetricsOption lets you configure optional behaviour of
+// ServerMetrics using With* funcs. See ServerMetrics.Configure.
+type ServerMetricsOption func(*ServerMetrics)
+
+// WithWarmupExclusion excludes the handling time of RPCs started during the
+// first d after process start from the handling time histogram, summary and
+// counters derived from it. Connection establishment and cold caches pollute
+// latency SLOs right after every deploy. Excluded handling times are recorded
+// in the grpc_server_warmup_handling_seconds histogram instead, unless
+// WithWarmupDiscard is also given.
+func WithWarmupExclusion(d time.Duration) ServerMetricsOption {
+	return func(m *ServerMetrics) { m.warmupPeriod = d }
+}
+
+// WithWarmupDiscard drops handling times excluded by WithWarmupExclusion
+// instead of recording them in a separate histogram.
+func WithWarmupDiscard() ServerMetricsOption {
+	return func(m *ServerMetrics) { m.warmupDiscard = true }
+}
+
+// WithRequestCostFunc records the logical cost of each received request, as
+// returned by f, e.g. rows requested or items in a batch, in the
+// grpc_server_request_cost_total counter. For streaming RPCs f is called for
+// every received message. Negative costs are skipped.
+func WithRequestCostFunc(f func(ctx context.Context, req interface{}) float64) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.serverRequestCostFunc = f
+		if m.serverRequestCostCounter == nil {
+			m.serverRequestCostCounter = m.newCounterVec(
+				m.counterOpts.apply(prom.CounterOpts{
+					Name: "grpc_server_request_cost_total",
+					Help: "Total logical cost of the requests received by the server.",
+				}), []string{"grpc_type", "grpc_service", "grpc_method"})
+		}
+	}
+}
+
+// WithBatchSizeFunc records the number of items in each received request, as
+// returned by f, in the grpc_server_request_batch_size histogram. Buckets are
+// powers of two from 1 to 4096 unless overridden by opts. For streaming RPCs
+// f is called for every received message.
+func WithBatchSizeFunc(f func(req interface{}) int, opts ...HistogramOption) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.serverBatchSizeFunc = f
+		if m.serverBatchSizeHistogram == nil {
+			histOpts := prom.HistogramOpts{
+				Name:    "grpc_server_request_batch_size",
+				Help:    "Histogram of the number of items in requests received by the server.",
+				Buckets: prom.ExponentialBuckets(1, 2, 13),
+			}
+			for _, o := range opts {
+				o(&histOpts)
+			}
+			m.serverBatchSizeHistogram = m.newHistogramVec(histOpts, []string{"grpc_type", "grpc_service", "grpc_method"})
+		}
+	}
+}
+
 // A HistogramOption lets you add options to Histogram metrics using With*
 // funcs.
 type HistogramOption func(*prom.HistogramOpts)
@@ -32,6 +94,45 @@ func WithHistogramBuckets(buckets []float64) HistogramOption {
 	return func(o *prom.HistogramOpts) { o.Buckets = buckets }
 }
 
+// WithHighResolutionLatency configures histograms with 40 exponential buckets
+// growing by 40% from 0.5ms up to roughly 250s, instead of prom.DefBuckets
+// which tops out at 10s and hides the long tail. This multiplies the number of
+// series per histogram, so it is best reserved for latency-critical services.
+func WithHighResolutionLatency() HistogramOption {
+	return WithHistogramBuckets(prom.ExponentialBuckets(0.0005, 1.4, 40))
+}
+
+// HDRBuckets returns the bucket boundaries of an HDR histogram tracking values
+// from lowest to highest with the given number of significant decimal figures.
+// Values are in the unit of the histogram, e.g. seconds. The first lowest*2^k
+// range is split linearly, each subsequent power of two into the same number
+// of linear sub-buckets at doubled width, as done by HdrHistogram. This allows
+// bucket layouts to line up with HDR-based tooling, at the price of many
+// buckets: for 1 significant figure that is 16 per power of two.
+func HDRBuckets(lowest, highest float64, significantFigures int) []float64 {
+	if lowest <= 0 || highest <= lowest || significantFigures < 1 || significantFigures > 5 {
+		panic("HDRBuckets needs 0 < lowest < highest and 1 to 5 significant figures")
+	}
+	subBucketCount := 1 << uint(math.Ceil(math.Log2(2*math.Pow10(significantFigures))))
+	subBucketHalfCount := subBucketCount / 2
+
+	var buckets []float64
+	for j := 1; j <= subBucketCount; j++ {
+		buckets = append(buckets, float64(j)*lowest)
+		if buckets[len(buckets)-1] >= highest {
+			return buckets
+		}
+	}
+	for width := 2 * lowest; ; width *= 2 {
+		for j := subBucketHalfCount + 1; j <= subBucketCount; j++ {
+			buckets = append(buckets, float64(j)*width)
+			if buckets[len(buckets)-1] >= highest {
+				return buckets
+			}
+		}
+	}
+}
+
 // WithHistogramConstLabels allows you to add custom ConstLabels to
 // histograms metrics.
 func WithHistogramConstLabels(labels prom.Labels) HistogramOption {
@@ -39,3 +140,26 @@ func WithHistogramConstLabels(labels prom.Labels) HistogramOption {
 		o.ConstLabels = labels
 	}
 }
+
+// A SummaryOption lets you add options to Summary metrics using With* funcs.
+type SummaryOption func(*prom.SummaryOpts)
+
+// WithSummaryObjectives allows you to specify the quantiles, and their
+// allowed absolute errors, tracked by summaries.
+func WithSummaryObjectives(objectives map[float64]float64) SummaryOption {
+	return func(o *prom.SummaryOpts) { o.Objectives = objectives }
+}
+
+// WithSummaryMaxAge allows you to specify the duration of the sliding window
+// quantiles of summaries are computed over.
+func WithSummaryMaxAge(maxAge time.Duration) SummaryOption {
+	return func(o *prom.SummaryOpts) { o.MaxAge = maxAge }
+}
+
+// WithSummaryConstLabels allows you to add custom ConstLabels to
+// summary metrics.
+func WithSummaryConstLabels(labels prom.Labels) SummaryOption {
+	return func(o *prom.SummaryOpts) {
+		o.ConstLabels = labels
+	}
+}
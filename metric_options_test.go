@@ -0,0 +1,93 @@
+package grpc_prometheus
+
+import (
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHDRBuckets(t *testing.T) {
+	for _, tc := range []struct {
+		name                      string
+		lowest, highest           float64
+		significantFigures        int
+		wantLen                   int
+		wantFirst                 []float64
+		wantSubBucketsPerDoubling int
+	}{
+		{
+			name:   "within the linear range",
+			lowest: 1, highest: 4, significantFigures: 1,
+			wantLen:   4,
+			wantFirst: []float64{1, 2, 3, 4},
+		},
+		{
+			name:   "one doubling past the linear range",
+			lowest: 1, highest: 40, significantFigures: 1,
+			wantLen:                   32 + 4,
+			wantFirst:                 []float64{1, 2, 3},
+			wantSubBucketsPerDoubling: 16,
+		},
+		{
+			name:   "seconds with 2 significant figures",
+			lowest: 0.001, highest: 10, significantFigures: 2,
+			wantFirst:                 []float64{0.001, 0.002, 0.003},
+			wantSubBucketsPerDoubling: 128,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buckets := HDRBuckets(tc.lowest, tc.highest, tc.significantFigures)
+			if tc.wantLen > 0 {
+				require.Len(t, buckets, tc.wantLen)
+			}
+			require.InDeltaSlice(t, tc.wantFirst, buckets[:len(tc.wantFirst)], 1e-12)
+			require.True(t, buckets[len(buckets)-1] >= tc.highest, "last bucket must reach highest")
+			require.True(t, buckets[len(buckets)-2] < tc.highest, "buckets must stop once highest is reached")
+			for i := 1; i < len(buckets); i++ {
+				require.True(t, buckets[i] > buckets[i-1], "buckets must increase")
+			}
+			if tc.wantSubBucketsPerDoubling > 0 {
+				// The bucket ending the linear range is followed by
+				// sub-buckets of doubled width, as many per power of two.
+				linear := 2 * tc.wantSubBucketsPerDoubling
+				require.InDelta(t, float64(linear)*tc.lowest, buckets[linear-1], 1e-12)
+				require.InDelta(t, 2*tc.lowest, buckets[linear]-buckets[linear-1], 1e-12)
+				if len(buckets) > linear+tc.wantSubBucketsPerDoubling {
+					require.InDelta(t, float64(2*linear)*tc.lowest, buckets[linear+tc.wantSubBucketsPerDoubling-1], 1e-12)
+				}
+			}
+		})
+	}
+}
+
+func TestHDRBucketsPanics(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		lowest, highest    float64
+		significantFigures int
+	}{
+		{"zero lowest", 0, 1, 1},
+		{"negative lowest", -1, 1, 1},
+		{"highest equal to lowest", 1, 1, 1},
+		{"highest below lowest", 2, 1, 1},
+		{"no significant figures", 1, 2, 0},
+		{"too many significant figures", 1, 2, 6},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Panics(t, func() { HDRBuckets(tc.lowest, tc.highest, tc.significantFigures) })
+		})
+	}
+}
+
+func TestWithHighResolutionLatency(t *testing.T) {
+	opts := prom.HistogramOpts{Buckets: prom.DefBuckets}
+	WithHighResolutionLatency()(&opts)
+	require.Len(t, opts.Buckets, 40)
+	require.InDelta(t, 0.0005, opts.Buckets[0], 1e-12)
+	for i := 1; i < len(opts.Buckets); i++ {
+		require.InDelta(t, 1.4, opts.Buckets[i]/opts.Buckets[i-1], 1e-9)
+	}
+	last := opts.Buckets[len(opts.Buckets)-1]
+	require.True(t, last > 200 && last < 300, "last bucket must be roughly 250s, got %v", last)
+}
@@ -0,0 +1,145 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-prometheus/packages/grpcstatus"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+)
+
+// defaultServerMaxSendMsgSize mirrors the default of grpc.MaxSendMsgSize.
+const defaultServerMaxSendMsgSize = math.MaxInt32
+
+// msgSizeLimits are the metrics of EnableMsgSizeLimitMetrics.
+type msgSizeLimits struct {
+	maxRecvMsgSize int
+	maxSendMsgSize int
+	limits         *gaugeVec
+	exceeded       *counterVec
+}
+
+// EnableMsgSizeLimitMetrics turns on grpc_server_msg_size_limit_bytes, the
+// effective message size limits by direction ("received" or "sent"), and
+// grpc_server_msg_size_limit_exceeded_total, counting the messages rejected
+// for exceeding them, so that tuning the limits is backed by data. The limits
+// should match the grpc.MaxRecvMsgSize and grpc.MaxSendMsgSize options the
+// server was created with (gRPC doesn't expose them). Values <= 0 assume the
+// gRPC defaults.
+//
+// Unary requests exceeding the limit are rejected by gRPC before reaching
+// the interceptors: MsgSizeLimitStatsHandler, installed with
+// grpc.StatsHandler, counts them.
+func (m *ServerMetrics) EnableMsgSizeLimitMetrics(maxRecvMsgSize, maxSendMsgSize int, counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnableMsgSizeLimitMetrics")
+	if maxRecvMsgSize <= 0 {
+		maxRecvMsgSize = defaultServerMaxRecvMsgSize
+	}
+	if maxSendMsgSize <= 0 {
+		maxSendMsgSize = defaultServerMaxSendMsgSize
+	}
+	if m.serverMsgSizeLimits == nil {
+		m.serverMsgSizeLimits = &msgSizeLimits{
+			limits: m.newGaugeVec(prom.GaugeOpts{
+				Name:        "grpc_server_msg_size_limit_bytes",
+				Help:        "Maximum size (bytes) of messages the server receives or sends.",
+				ConstLabels: m.counterOpts.apply(prom.CounterOpts{}).ConstLabels,
+			}, []string{"direction"}),
+			exceeded: m.newCounterVec(
+				counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+					Name: "grpc_server_msg_size_limit_exceeded_total",
+					Help: "Total number of messages rejected by the server for exceeding the message size limits.",
+				})), []string{"grpc_type", "grpc_service", "grpc_method", "direction"}),
+		}
+	}
+	l := m.serverMsgSizeLimits
+	l.maxRecvMsgSize, l.maxSendMsgSize = maxRecvMsgSize, maxSendMsgSize
+	l.limits.WithLabelValues("received").Set(float64(maxRecvMsgSize))
+	l.limits.WithLabelValues("sent").Set(float64(maxSendMsgSize))
+}
+
+func (l *msgSizeLimits) exceed(rpcType grpcType, serviceName, methodName, direction string) {
+	l.exceeded.WithLabelValues(string(rpcType), serviceName, methodName, direction).Inc()
+}
+
+// isMsgSizeLimitError reports whether err is gRPC rejecting a message for
+// exceeding a size limit.
+func isMsgSizeLimitError(err error) bool {
+	st, _ := grpcstatus.FromError(err)
+	return st.Code() == codes.ResourceExhausted && strings.Contains(st.Message(), "message larger than max")
+}
+
+// MsgSizeLimitStatsHandler returns a stats.Handler counting, for
+// EnableMsgSizeLimitMetrics, the unary requests gRPC rejects for exceeding
+// the receive size limit before invoking the interceptors. See
+// ChainStatsHandlers to install it along with other stats handlers.
+func (m *ServerMetrics) MsgSizeLimitStatsHandler() stats.Handler {
+	return msgSizeLimitHandler{m}
+}
+
+type interceptedKey struct{}
+
+// intercepted is attached to the context of each RPC by msgSizeLimitHandler,
+// and marked by the interceptors of RPCs reaching them. gRPC invokes the
+// interceptors and reports the end of unary RPCs from the same goroutine.
+type intercepted struct {
+	fullMethod string
+	reached    bool
+}
+
+// markIntercepted notes that the RPC of ctx reached the interceptors.
+func markIntercepted(ctx context.Context) {
+	if i, ok := ctx.Value(interceptedKey{}).(*intercepted); ok {
+		i.reached = true
+	}
+}
+
+// msgSizeLimitHandler is a stats.Handler counting the unary requests
+// rejected for their size before reaching the interceptors.
+type msgSizeLimitHandler struct {
+	m *ServerMetrics
+}
+
+func (h msgSizeLimitHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, interceptedKey{}, &intercepted{fullMethod: info.FullMethodName})
+}
+
+func (h msgSizeLimitHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	end, ok := s.(*stats.End)
+	if !ok || end.Client || h.m.serverMsgSizeLimits == nil || !isMsgSizeLimitError(end.Error) {
+		return
+	}
+	i, ok := ctx.Value(interceptedKey{}).(*intercepted)
+	if !ok || i.reached {
+		return
+	}
+	// Only the single request of unary RPCs is received before invoking the
+	// interceptors.
+	serviceName, methodName := splitMethodName(i.fullMethod)
+	h.m.serverMsgSizeLimits.exceed(Unary, serviceName, methodName, "received")
+}
+
+func (h msgSizeLimitHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h msgSizeLimitHandler) HandleConn(ctx context.Context, s stats.ConnStats) {}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (l *msgSizeLimits) Describe(ch chan<- *prom.Desc) {
+	l.limits.Describe(ch)
+	l.exceeded.Describe(ch)
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (l *msgSizeLimits) Collect(ch chan<- prom.Metric) {
+	l.limits.Collect(ch)
+	l.exceeded.Collect(ch)
+}
@@ -0,0 +1,64 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMsgSizeLimitMetrics(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableMsgSizeLimitMetrics(100, 50)
+	server := grpc.NewServer(
+		grpc.MaxRecvMsgSize(100),
+		grpc.MaxSendMsgSize(50),
+		grpc.StatsHandler(m.MsgSizeLimitStatsHandler()),
+		grpc.UnaryInterceptor(m.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(m.StreamServerInterceptor()),
+	)
+	pb_testproto.RegisterTestServiceServer(server, &testService{t: t})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, lis.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb_testproto.NewTestServiceClient(conn)
+
+	requireValue(t, 100, m.serverMsgSizeLimits.limits.WithLabelValues("received"))
+	requireValue(t, 50, m.serverMsgSizeLimits.limits.WithLabelValues("sent"))
+
+	// The request exceeds the receive limit.
+	_, err = client.Ping(ctx, &pb_testproto.PingRequest{Value: strings.Repeat("x", 200)})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	requireValue(t, 1, m.serverMsgSizeLimits.exceeded.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "received"))
+
+	// The request fits, its echo in the response exceeds the send limit.
+	_, err = client.Ping(ctx, &pb_testproto.PingRequest{Value: strings.Repeat("x", 80)})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	requireValue(t, 1, m.serverMsgSizeLimits.exceeded.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "sent"))
+
+	// The first failed send ends the stream, the handler keeps on sending.
+	stream, err := client.PingList(ctx, &pb_testproto.PingRequest{Value: strings.Repeat("x", 80)})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	requireValueWithRetry(ctx, t, countListResponses, m.serverMsgSizeLimits.exceeded.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "sent"))
+
+	// Errors returned by handlers aren't rejections.
+	_, err = client.PingError(ctx, &pb_testproto.PingRequest{ErrorCodeReturned: uint32(codes.ResourceExhausted)})
+	require.Error(t, err)
+	requireValue(t, 0, m.serverMsgSizeLimits.exceeded.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "received"))
+}
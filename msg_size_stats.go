@@ -0,0 +1,107 @@
+package grpc_prometheus
+
+import (
+	"sync"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// WithMsgSizeStats turns on a low-cost alternative to message size
+// histograms: for each method and direction ("received" or "sent"), the
+// grpc_server_msg_size_bytes_total and grpc_server_msg_size_messages_total
+// counters sum the sizes and count the messages, and the
+// grpc_server_msg_size_max_bytes and grpc_server_msg_size_min_bytes gauges
+// hold the largest and smallest message seen. This gives average and maximum
+// sizes without the cost of histogram buckets.
+func WithMsgSizeStats() ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		if m.serverMsgSizeStats != nil {
+			return
+		}
+		constLabels := m.counterOpts.apply(prom.CounterOpts{}).ConstLabels
+		labels := []string{"grpc_type", "grpc_service", "grpc_method", "direction"}
+		m.serverMsgSizeStats = &msgSizeStats{
+			sumDesc: prom.NewDesc(
+				"grpc_server_msg_size_bytes_total",
+				"Total size (bytes) of gRPC messages received or sent by the server.",
+				labels, constLabels),
+			countDesc: prom.NewDesc(
+				"grpc_server_msg_size_messages_total",
+				"Total number of gRPC messages received or sent by the server whose size was recorded.",
+				labels, constLabels),
+			maxDesc: prom.NewDesc(
+				"grpc_server_msg_size_max_bytes",
+				"Size (bytes) of the largest gRPC message received or sent by the server.",
+				labels, constLabels),
+			minDesc: prom.NewDesc(
+				"grpc_server_msg_size_min_bytes",
+				"Size (bytes) of the smallest gRPC message received or sent by the server.",
+				labels, constLabels),
+			stats: make(map[msgSizeKey]*msgSizeStat),
+		}
+	}
+}
+
+type msgSizeKey struct {
+	rpcType   grpcType
+	service   string
+	method    string
+	direction string
+}
+
+type msgSizeStat struct {
+	count    uint64
+	sum      float64
+	min, max int
+}
+
+type msgSizeStats struct {
+	sumDesc, countDesc, maxDesc, minDesc *prom.Desc
+
+	mu    sync.Mutex
+	stats map[msgSizeKey]*msgSizeStat
+}
+
+func (s *msgSizeStats) observe(rpcType grpcType, service, method, direction string, size int) {
+	key := msgSizeKey{rpcType, service, method, direction}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.stats[key]
+	if !ok {
+		st = &msgSizeStat{min: size, max: size}
+		s.stats[key] = st
+	}
+	st.count++
+	st.sum += float64(size)
+	if size < st.min {
+		st.min = size
+	}
+	if size > st.max {
+		st.max = size
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (s *msgSizeStats) Describe(ch chan<- *prom.Desc) {
+	ch <- s.sumDesc
+	ch <- s.countDesc
+	ch <- s.maxDesc
+	ch <- s.minDesc
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (s *msgSizeStats) Collect(ch chan<- prom.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, st := range s.stats {
+		labels := []string{string(key.rpcType), key.service, key.method, key.direction}
+		ch <- prom.MustNewConstMetric(s.sumDesc, prom.CounterValue, st.sum, labels...)
+		ch <- prom.MustNewConstMetric(s.countDesc, prom.CounterValue, float64(st.count), labels...)
+		ch <- prom.MustNewConstMetric(s.maxDesc, prom.GaugeValue, float64(st.max), labels...)
+		ch <- prom.MustNewConstMetric(s.minDesc, prom.GaugeValue, float64(st.min), labels...)
+	}
+}
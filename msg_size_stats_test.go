@@ -0,0 +1,66 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestMsgSizeStats(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithMsgSizeStats())
+	info := &grpc.StreamServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingList", IsServerStream: true}
+	m.StreamServerInterceptor()(nil, &fakeServerStream{ctx: context.Background()}, info, func(srv interface{}, ss grpc.ServerStream) error {
+		require.NoError(t, ss.SendMsg(&pb_testproto.PingResponse{Value: "pong"}))
+		require.NoError(t, ss.SendMsg(&pb_testproto.PingResponse{Value: "pong", Counter: 42}))
+		return nil
+	})
+
+	reg := prom.NewPedanticRegistry()
+	reg.MustRegister(m)
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	values := map[string]float64{}
+	for _, mf := range mfs {
+		for _, metric := range mf.GetMetric() {
+			for _, lp := range metric.GetLabel() {
+				if lp.GetName() == "direction" && lp.GetValue() == "sent" {
+					values[mf.GetName()] = metric.GetCounter().GetValue() + metric.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	require.Equal(t, map[string]float64{
+		"grpc_server_msg_size_bytes_total":    14,
+		"grpc_server_msg_size_messages_total": 2,
+		"grpc_server_msg_size_max_bytes":      8,
+		"grpc_server_msg_size_min_bytes":      6,
+	}, values)
+}
+
+func TestMsgSizeBytesHistograms(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableMsgSizeReceivedBytesHistogram()
+	m.EnableMsgSizeSentBytesHistogram()
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	for i := 0; i < 2; i++ {
+		m.UnaryServerInterceptor()(context.Background(), &pb_testproto.PingRequest{Value: "ping"}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return &pb_testproto.PingResponse{Value: "pong", Counter: 42}, nil
+		})
+	}
+
+	sum := func(h *histogramVec) float64 {
+		var pb dto.Metric
+		require.NoError(t, h.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping").(prom.Metric).Write(&pb))
+		return pb.GetHistogram().GetSampleSum()
+	}
+	requireValueHistCount(t, 2, m.serverMsgSizeReceivedHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValueHistCount(t, 2, m.serverMsgSizeSentHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	require.Equal(t, 2*6.0, sum(m.serverMsgSizeReceivedHistogram))
+	require.Equal(t, 2*8.0, sum(m.serverMsgSizeSentHistogram))
+}
@@ -0,0 +1,118 @@
+package grpc_prometheus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+const multiProcessFileSuffix = ".counters.json"
+
+// EnableMultiProcessExport saves the counters covered by
+// EnableCounterCheckpoints to a file named after the process ID in dir every
+// interval, for servers run as several worker processes under a pre-fork
+// supervisor. The process serving /metrics aggregates the files of all
+// workers with NewMultiProcessCollector. The returned stop function stops
+// the export after a final save, and should be called on shutdown.
+func (m *ServerMetrics) EnableMultiProcessExport(dir string, interval time.Duration) (stop func() error, err error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	store := NewFileCheckpointStore(filepath.Join(dir, strconv.Itoa(os.Getpid())+multiProcessFileSuffix))
+	return saveCountersPeriodically(m.checkpointedCounters(), store, interval, m.checkpointErrorHandler), nil
+}
+
+// NewMultiProcessCollector returns a collector summing the counters exported
+// by all worker processes with EnableMultiProcessExport into dir. Files of
+// exited workers keep counting, so that counters do not go backwards; the
+// supervisor should empty dir when starting a new generation of workers.
+// Constant labels are not exported by the workers, add them when registering
+// this collector, e.g. with prometheus.WrapRegistererWith. Invalid samples,
+// e.g. of corrupted files, are reported as collection errors.
+//
+// The collected metric names depend on the files found, so the collector is
+// unchecked and describes no metrics. Do not also register the ServerMetrics
+// of the collecting process, its counters would be counted twice.
+func NewMultiProcessCollector(dir string) prom.Collector {
+	return &multiProcessCollector{dir: dir}
+}
+
+type multiProcessCollector struct {
+	dir string
+}
+
+// Describe sends no descriptors, making this an unchecked Collector.
+func (c *multiProcessCollector) Describe(ch chan<- *prom.Desc) {}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (c *multiProcessCollector) Collect(ch chan<- prom.Metric) {
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	type series struct {
+		sample CounterSample
+		value  float64
+	}
+	aggregated := make(map[string]*series)
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), multiProcessFileSuffix) {
+			continue
+		}
+		// Files are replaced atomically, a failed load is a worker which
+		// has not saved yet.
+		samples, err := NewFileCheckpointStore(filepath.Join(c.dir, f.Name())).Load()
+		if err != nil {
+			continue
+		}
+		for _, s := range samples {
+			key := seriesKey(s)
+			if a, ok := aggregated[key]; ok {
+				a.value += s.Value
+			} else {
+				aggregated[key] = &series{s, s.Value}
+			}
+		}
+	}
+	for _, a := range aggregated {
+		names := make([]string, 0, len(a.sample.Labels))
+		for name := range a.sample.Labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		values := make([]string, len(names))
+		for i, name := range names {
+			values[i] = a.sample.Labels[name]
+		}
+		desc := prom.NewDesc(a.sample.Name, a.sample.Help, names, nil)
+		// The files are written by other processes, report invalid samples
+		// rather than panicking on them.
+		metric, err := prom.NewConstMetric(desc, prom.CounterValue, a.value, values...)
+		if err != nil {
+			metric = prom.NewInvalidMetric(desc, err)
+		}
+		ch <- metric
+	}
+}
+
+// seriesKey identifies the series of a sample.
+func seriesKey(s CounterSample) string {
+	names := make([]string, 0, len(s.Labels))
+	for name := range s.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	key := s.Name
+	for _, name := range names {
+		key += "\xff" + name + "\xff" + s.Labels[name]
+	}
+	return key
+}
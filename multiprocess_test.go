@@ -0,0 +1,88 @@
+package grpc_prometheus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiProcessCollectorAggregatesWorkers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multiprocess")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	worker := NewServerMetrics()
+	stop, err := worker.EnableMultiProcessExport(dir, time.Hour)
+	require.NoError(t, err)
+	worker.serverStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping").Add(2)
+	require.NoError(t, stop())
+
+	// Another worker, which has exited already.
+	other := NewFileCheckpointStore(filepath.Join(dir, "1"+multiProcessFileSuffix))
+	require.NoError(t, other.Save([]CounterSample{{
+		Name:   "grpc_server_started_total",
+		Help:   "Total number of RPCs started on the server.",
+		Labels: map[string]string{"grpc_type": "unary", "grpc_service": "mwitkow.testproto.TestService", "grpc_method": "Ping"},
+		Value:  3,
+	}}))
+
+	expected := `
+# HELP grpc_server_started_total Total number of RPCs started on the server.
+# TYPE grpc_server_started_total counter
+grpc_server_started_total{grpc_method="Ping",grpc_service="mwitkow.testproto.TestService",grpc_type="unary"} 5
+`
+	require.NoError(t, testutil.CollectAndCompare(NewMultiProcessCollector(dir), strings.NewReader(expected), "grpc_server_started_total"))
+}
+
+func TestMultiProcessExportWithUniqueNamesAndShards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multiprocess")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	worker := NewServerMetrics(WithUniqueNameSuffix())
+	worker.Configure(WithShardedCollectors())
+	stop, err := worker.EnableMultiProcessExport(dir, time.Hour)
+	require.NoError(t, err)
+	worker.startedCounter("mwitkow.testproto.TestService").WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping").Add(2)
+	require.NoError(t, stop())
+
+	expected := `
+# HELP grpc_server_started_fork_total Total number of RPCs started on the server.
+# TYPE grpc_server_started_fork_total counter
+grpc_server_started_fork_total{grpc_method="Ping",grpc_service="mwitkow.testproto.TestService",grpc_type="unary"} 2
+`
+	require.NoError(t, testutil.CollectAndCompare(NewMultiProcessCollector(dir), strings.NewReader(expected), "grpc_server_started_fork_total"))
+}
+
+func TestMultiProcessCollectorReportsCorruptFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multiprocess")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := NewFileCheckpointStore(filepath.Join(dir, "1"+multiProcessFileSuffix))
+	require.NoError(t, store.Save([]CounterSample{
+		{
+			Name:   "grpc_server_started_total",
+			Help:   "Total number of RPCs started on the server.",
+			Labels: map[string]string{"grpc_type": "unary", "grpc_service": "mwitkow.testproto.TestService", "grpc_method": "Ping"},
+			Value:  3,
+		},
+		{Name: "not a metric name", Value: 1},
+		{Name: "grpc_server_handled_total", Labels: map[string]string{"grpc code": "OK"}, Value: 1},
+	}))
+
+	reg := prom.NewRegistry()
+	reg.MustRegister(NewMultiProcessCollector(dir))
+	mfs, err := reg.Gather()
+	require.Error(t, err)
+	require.Len(t, mfs, 1)
+	require.Equal(t, "grpc_server_started_total", mfs[0].GetName())
+	require.Equal(t, 3.0, mfs[0].GetMetric()[0].GetCounter().GetValue())
+}
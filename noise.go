@@ -0,0 +1,175 @@
+package grpc_prometheus
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// NoisyCollector wraps c, e.g. a ServerMetrics registered on a separate
+// registry shared with partners, so that the values it collects don't reveal
+// exact traffic volumes. At every collection, Laplace noise of the given scale
+// is added to counter, gauge and untyped values, to the counts of histograms
+// and summaries and to their sums, and the results are rounded to multiples
+// of granularity. Quantiles are left untouched.
+//
+// Noise is drawn once per value: a value which didn't change since the
+// previous collection is reported with the same noise, so that it can't be
+// recovered by averaging many scrapes. To keep rate() and increase() usable,
+// noisy counts never decrease: each is reported as at least its previous
+// value, which biases them upwards by a few times scale. Keep the registries
+// used internally exact by registering c on them directly.
+func NoisyCollector(c prom.Collector, scale, granularity float64) prom.Collector {
+	return &noisyCollector{
+		collector:   c,
+		scale:       scale,
+		granularity: granularity,
+		rand:        rand.New(rand.NewSource(noiseSeed())),
+		values:      make(map[string]noisyValue),
+	}
+}
+
+// noiseSeed returns a seed read from crypto/rand, as the math/rand global
+// source isn't seeded before Go 1.20, giving all processes the same noise.
+func noiseSeed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+type noisyCollector struct {
+	collector   prom.Collector
+	scale       float64
+	granularity float64
+
+	mu   sync.Mutex
+	rand *rand.Rand
+	// values holds the last value of every series, by key.
+	values map[string]noisyValue
+}
+
+// noisyValue is an exact value and its reported noisy value.
+type noisyValue struct {
+	exact, noisy float64
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (c *noisyCollector) Describe(ch chan<- *prom.Desc) {
+	c.collector.Describe(ch)
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (c *noisyCollector) Collect(ch chan<- prom.Metric) {
+	in := make(chan prom.Metric)
+	go func() {
+		c.collector.Collect(in)
+		close(in)
+	}()
+	for m := range in {
+		ch <- &noisyMetric{m, c}
+	}
+}
+
+// noisyMetric wraps a prom.Metric, adding noise to its values on Write.
+type noisyMetric struct {
+	prom.Metric
+	collector *noisyCollector
+}
+
+func (m *noisyMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	c := m.collector
+	key := noisySeriesKey(m.Desc(), out.Label)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case out.Counter != nil:
+		out.Counter = &dto.Counter{Value: proto.Float64(c.noisyCount(key, out.Counter.GetValue()))}
+	case out.Gauge != nil:
+		out.Gauge = &dto.Gauge{Value: proto.Float64(c.noisy(key, out.Gauge.GetValue()))}
+	case out.Untyped != nil:
+		out.Untyped = &dto.Untyped{Value: proto.Float64(c.noisy(key, out.Untyped.GetValue()))}
+	case out.Histogram != nil:
+		h := out.Histogram
+		noisy := &dto.Histogram{SampleSum: proto.Float64(c.noisy(key+"\xffsum", h.GetSampleSum()))}
+		var previous float64
+		for _, b := range h.Bucket {
+			le := strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64)
+			count := math.Max(previous, c.noisyCount(key+"\xffle="+le, float64(b.GetCumulativeCount())))
+			noisy.Bucket = append(noisy.Bucket, &dto.Bucket{UpperBound: b.UpperBound, CumulativeCount: proto.Uint64(uint64(count))})
+			previous = count
+		}
+		noisy.SampleCount = proto.Uint64(uint64(math.Max(previous, c.noisyCount(key, float64(h.GetSampleCount())))))
+		out.Histogram = noisy
+	case out.Summary != nil:
+		s := out.Summary
+		out.Summary = &dto.Summary{
+			SampleCount: proto.Uint64(uint64(c.noisyCount(key, float64(s.GetSampleCount())))),
+			SampleSum:   proto.Float64(c.noisy(key+"\xffsum", s.GetSampleSum())),
+			Quantile:    s.Quantile,
+		}
+	}
+	return nil
+}
+
+// noisy returns the value v of the series key with Laplace noise added,
+// rounded to the granularity. The noise is drawn again only if v changed.
+// The caller must hold c.mu.
+func (c *noisyCollector) noisy(key string, v float64) float64 {
+	return c.noisyValue(key, v, false)
+}
+
+// noisyCount returns the noisy value of a non-negative count which must not
+// decrease across collections.
+func (c *noisyCollector) noisyCount(key string, v float64) float64 {
+	return c.noisyValue(key, v, true)
+}
+
+func (c *noisyCollector) noisyValue(key string, v float64, count bool) float64 {
+	last, ok := c.values[key]
+	if ok && last.exact == v {
+		return last.noisy
+	}
+	// Inverse transform sampling of the Laplace distribution.
+	u := c.rand.Float64() - 0.5
+	n := v - c.scale*math.Copysign(math.Log(1-2*math.Abs(u)), u)
+	if c.granularity > 0 {
+		n = math.Floor(n/c.granularity+0.5) * c.granularity
+	}
+	if count {
+		n = math.Max(0, n)
+		if ok && n < last.noisy {
+			n = last.noisy
+		}
+	}
+	c.values[key] = noisyValue{exact: v, noisy: n}
+	return n
+}
+
+// noisySeriesKey identifies a series by its descriptor and label pairs.
+func noisySeriesKey(desc *prom.Desc, labels []*dto.LabelPair) string {
+	pairs := make([]string, 0, len(labels))
+	for _, l := range labels {
+		pairs = append(pairs, l.GetName()+"="+l.GetValue())
+	}
+	sort.Strings(pairs)
+	return desc.String() + "\xff" + strings.Join(pairs, "\xff")
+}
@@ -0,0 +1,74 @@
+package grpc_prometheus
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func gatherNoisy(t *testing.T, reg *prometheus.Registry) map[string]*dto.Metric {
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	metrics := make(map[string]*dto.Metric)
+	for _, mf := range mfs {
+		require.Len(t, mf.Metric, 1)
+		metrics[mf.GetName()] = mf.Metric[0]
+	}
+	return metrics
+}
+
+func TestNoisyCollectorRoundsToGranularity(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableHandlingTimeHistogram(WithHistogramBuckets([]float64{0.1}))
+	for i := 0; i < 149; i++ {
+		m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK").Inc()
+		m.serverHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping").Observe(0.05)
+	}
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(NoisyCollector(m, 0, 100)))
+
+	metrics := gatherNoisy(t, reg)
+	require.Equal(t, 100.0, metrics["grpc_server_handled_total"].GetCounter().GetValue())
+	h := metrics["grpc_server_handling_seconds"].GetHistogram()
+	require.Equal(t, uint64(100), h.GetSampleCount())
+	require.Equal(t, uint64(100), h.Bucket[0].GetCumulativeCount())
+	require.Equal(t, 0.0, h.GetSampleSum())
+}
+
+func TestNoisyCollectorCountsNeverDecrease(t *testing.T) {
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "requests_total", Help: "h"})
+	c.Add(1000)
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(NoisyCollector(c, 50, 1)))
+
+	var previous float64
+	exact := 0
+	for i := 0; i < 100; i++ {
+		c.Inc()
+		v := gatherNoisy(t, reg)["requests_total"].GetCounter().GetValue()
+		require.True(t, v >= previous, "noisy counter decreased from %v to %v", previous, v)
+		if v == float64(1001+i) {
+			exact++
+		}
+		previous = v
+	}
+	require.True(t, exact < 100, "values must be noisy")
+}
+
+func TestNoisyCollectorKeepsNoiseOfUnchangedValues(t *testing.T) {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "queue_length", Help: "h"})
+	g.Set(1000)
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(NoisyCollector(g, 50, 0)))
+
+	// Averaging scrapes of an unchanged value doesn't reveal it.
+	first := gatherNoisy(t, reg)["queue_length"].GetGauge().GetValue()
+	require.NotEqual(t, 1000.0, first)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, first, gatherNoisy(t, reg)["queue_length"].GetGauge().GetValue())
+	}
+	g.Set(1001)
+	require.NotEqual(t, first, gatherNoisy(t, reg)["queue_length"].GetGauge().GetValue())
+}
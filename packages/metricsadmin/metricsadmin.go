@@ -0,0 +1,62 @@
+// Package metricsadmin provides a gRPC service for inspecting and tuning
+// grpc_prometheus.ServerMetrics of a running process, for fleets that are
+// managed over gRPC rather than over HTTP admin ports.
+package metricsadmin
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/prometheus/common/expfmt"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxSlowHandlingThreshold is the largest threshold SetSlowHandlingThreshold
+// accepts, far above any sensible one, keeping the conversion to a
+// time.Duration from overflowing.
+const maxSlowHandlingThreshold = 24 * time.Hour
+
+type server struct {
+	metrics *grpc_prometheus.ServerMetrics
+}
+
+// NewServer returns a MetricsAdminServer acting on the given ServerMetrics.
+func NewServer(m *grpc_prometheus.ServerMetrics) MetricsAdminServer {
+	return &server{metrics: m}
+}
+
+// Register registers a MetricsAdmin service acting on the given ServerMetrics
+// on a gRPC server.
+func Register(s *grpc.Server, m *grpc_prometheus.ServerMetrics) {
+	RegisterMetricsAdminServer(s, NewServer(m))
+}
+
+func (s *server) GetMetrics(ctx context.Context, req *GetMetricsRequest) (*GetMetricsResponse, error) {
+	format := expfmt.Format(req.Format)
+	if format == "" {
+		format = expfmt.FmtText
+	}
+	var buf bytes.Buffer
+	if err := s.metrics.Dump(&buf, format); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &GetMetricsResponse{Format: string(format), Payload: buf.Bytes()}, nil
+}
+
+func (s *server) SetHandlingTimeHistogram(ctx context.Context, req *SetHandlingTimeHistogramRequest) (*SetHandlingTimeHistogramResponse, error) {
+	s.metrics.SetHandlingTimeHistogramActive(req.Enabled)
+	return &SetHandlingTimeHistogramResponse{Enabled: s.metrics.HandlingTimeHistogramActive()}, nil
+}
+
+func (s *server) SetSlowHandlingThreshold(ctx context.Context, req *SetSlowHandlingThresholdRequest) (*SetSlowHandlingThresholdResponse, error) {
+	// The comparisons are false for NaN.
+	if !(req.ThresholdSeconds >= 0 && req.ThresholdSeconds <= maxSlowHandlingThreshold.Seconds()) {
+		return nil, status.Errorf(codes.InvalidArgument, "threshold_seconds must be between 0 and %v", maxSlowHandlingThreshold.Seconds())
+	}
+	s.metrics.SetSlowHandlingThreshold(time.Duration(req.ThresholdSeconds * float64(time.Second)))
+	return &SetSlowHandlingThresholdResponse{ThresholdSeconds: s.metrics.SlowHandlingThreshold().Seconds()}, nil
+}
@@ -0,0 +1,433 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: metricsadmin.proto
+
+package metricsadmin
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type GetMetricsRequest struct {
+	Format               string   `protobuf:"bytes,1,opt,name=format,proto3" json:"format,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetMetricsRequest) Reset()         { *m = GetMetricsRequest{} }
+func (m *GetMetricsRequest) String() string { return proto.CompactTextString(m) }
+func (*GetMetricsRequest) ProtoMessage()    {}
+func (*GetMetricsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_metricsadmin_1512f5378a91c2c5, []int{0}
+}
+func (m *GetMetricsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMetricsRequest.Unmarshal(m, b)
+}
+func (m *GetMetricsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMetricsRequest.Marshal(b, m, deterministic)
+}
+func (dst *GetMetricsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMetricsRequest.Merge(dst, src)
+}
+func (m *GetMetricsRequest) XXX_Size() int {
+	return xxx_messageInfo_GetMetricsRequest.Size(m)
+}
+func (m *GetMetricsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMetricsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetMetricsRequest proto.InternalMessageInfo
+
+func (m *GetMetricsRequest) GetFormat() string {
+	if m != nil {
+		return m.Format
+	}
+	return ""
+}
+
+type GetMetricsResponse struct {
+	Format               string   `protobuf:"bytes,1,opt,name=format,proto3" json:"format,omitempty"`
+	Payload              []byte   `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetMetricsResponse) Reset()         { *m = GetMetricsResponse{} }
+func (m *GetMetricsResponse) String() string { return proto.CompactTextString(m) }
+func (*GetMetricsResponse) ProtoMessage()    {}
+func (*GetMetricsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_metricsadmin_1512f5378a91c2c5, []int{1}
+}
+func (m *GetMetricsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMetricsResponse.Unmarshal(m, b)
+}
+func (m *GetMetricsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMetricsResponse.Marshal(b, m, deterministic)
+}
+func (dst *GetMetricsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMetricsResponse.Merge(dst, src)
+}
+func (m *GetMetricsResponse) XXX_Size() int {
+	return xxx_messageInfo_GetMetricsResponse.Size(m)
+}
+func (m *GetMetricsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMetricsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetMetricsResponse proto.InternalMessageInfo
+
+func (m *GetMetricsResponse) GetFormat() string {
+	if m != nil {
+		return m.Format
+	}
+	return ""
+}
+
+func (m *GetMetricsResponse) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+type SetHandlingTimeHistogramRequest struct {
+	Enabled              bool     `protobuf:"varint,1,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetHandlingTimeHistogramRequest) Reset()         { *m = SetHandlingTimeHistogramRequest{} }
+func (m *SetHandlingTimeHistogramRequest) String() string { return proto.CompactTextString(m) }
+func (*SetHandlingTimeHistogramRequest) ProtoMessage()    {}
+func (*SetHandlingTimeHistogramRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_metricsadmin_1512f5378a91c2c5, []int{2}
+}
+func (m *SetHandlingTimeHistogramRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetHandlingTimeHistogramRequest.Unmarshal(m, b)
+}
+func (m *SetHandlingTimeHistogramRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetHandlingTimeHistogramRequest.Marshal(b, m, deterministic)
+}
+func (dst *SetHandlingTimeHistogramRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetHandlingTimeHistogramRequest.Merge(dst, src)
+}
+func (m *SetHandlingTimeHistogramRequest) XXX_Size() int {
+	return xxx_messageInfo_SetHandlingTimeHistogramRequest.Size(m)
+}
+func (m *SetHandlingTimeHistogramRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetHandlingTimeHistogramRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetHandlingTimeHistogramRequest proto.InternalMessageInfo
+
+func (m *SetHandlingTimeHistogramRequest) GetEnabled() bool {
+	if m != nil {
+		return m.Enabled
+	}
+	return false
+}
+
+type SetHandlingTimeHistogramResponse struct {
+	Enabled              bool     `protobuf:"varint,1,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetHandlingTimeHistogramResponse) Reset()         { *m = SetHandlingTimeHistogramResponse{} }
+func (m *SetHandlingTimeHistogramResponse) String() string { return proto.CompactTextString(m) }
+func (*SetHandlingTimeHistogramResponse) ProtoMessage()    {}
+func (*SetHandlingTimeHistogramResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_metricsadmin_1512f5378a91c2c5, []int{3}
+}
+func (m *SetHandlingTimeHistogramResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetHandlingTimeHistogramResponse.Unmarshal(m, b)
+}
+func (m *SetHandlingTimeHistogramResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetHandlingTimeHistogramResponse.Marshal(b, m, deterministic)
+}
+func (dst *SetHandlingTimeHistogramResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetHandlingTimeHistogramResponse.Merge(dst, src)
+}
+func (m *SetHandlingTimeHistogramResponse) XXX_Size() int {
+	return xxx_messageInfo_SetHandlingTimeHistogramResponse.Size(m)
+}
+func (m *SetHandlingTimeHistogramResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetHandlingTimeHistogramResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetHandlingTimeHistogramResponse proto.InternalMessageInfo
+
+func (m *SetHandlingTimeHistogramResponse) GetEnabled() bool {
+	if m != nil {
+		return m.Enabled
+	}
+	return false
+}
+
+type SetSlowHandlingThresholdRequest struct {
+	ThresholdSeconds     float64  `protobuf:"fixed64,1,opt,name=threshold_seconds,json=thresholdSeconds,proto3" json:"threshold_seconds,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetSlowHandlingThresholdRequest) Reset()         { *m = SetSlowHandlingThresholdRequest{} }
+func (m *SetSlowHandlingThresholdRequest) String() string { return proto.CompactTextString(m) }
+func (*SetSlowHandlingThresholdRequest) ProtoMessage()    {}
+func (*SetSlowHandlingThresholdRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_metricsadmin_1512f5378a91c2c5, []int{4}
+}
+func (m *SetSlowHandlingThresholdRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetSlowHandlingThresholdRequest.Unmarshal(m, b)
+}
+func (m *SetSlowHandlingThresholdRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetSlowHandlingThresholdRequest.Marshal(b, m, deterministic)
+}
+func (dst *SetSlowHandlingThresholdRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetSlowHandlingThresholdRequest.Merge(dst, src)
+}
+func (m *SetSlowHandlingThresholdRequest) XXX_Size() int {
+	return xxx_messageInfo_SetSlowHandlingThresholdRequest.Size(m)
+}
+func (m *SetSlowHandlingThresholdRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetSlowHandlingThresholdRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetSlowHandlingThresholdRequest proto.InternalMessageInfo
+
+func (m *SetSlowHandlingThresholdRequest) GetThresholdSeconds() float64 {
+	if m != nil {
+		return m.ThresholdSeconds
+	}
+	return 0
+}
+
+type SetSlowHandlingThresholdResponse struct {
+	ThresholdSeconds     float64  `protobuf:"fixed64,1,opt,name=threshold_seconds,json=thresholdSeconds,proto3" json:"threshold_seconds,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetSlowHandlingThresholdResponse) Reset()         { *m = SetSlowHandlingThresholdResponse{} }
+func (m *SetSlowHandlingThresholdResponse) String() string { return proto.CompactTextString(m) }
+func (*SetSlowHandlingThresholdResponse) ProtoMessage()    {}
+func (*SetSlowHandlingThresholdResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_metricsadmin_1512f5378a91c2c5, []int{5}
+}
+func (m *SetSlowHandlingThresholdResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetSlowHandlingThresholdResponse.Unmarshal(m, b)
+}
+func (m *SetSlowHandlingThresholdResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetSlowHandlingThresholdResponse.Marshal(b, m, deterministic)
+}
+func (dst *SetSlowHandlingThresholdResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetSlowHandlingThresholdResponse.Merge(dst, src)
+}
+func (m *SetSlowHandlingThresholdResponse) XXX_Size() int {
+	return xxx_messageInfo_SetSlowHandlingThresholdResponse.Size(m)
+}
+func (m *SetSlowHandlingThresholdResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetSlowHandlingThresholdResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetSlowHandlingThresholdResponse proto.InternalMessageInfo
+
+func (m *SetSlowHandlingThresholdResponse) GetThresholdSeconds() float64 {
+	if m != nil {
+		return m.ThresholdSeconds
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*GetMetricsRequest)(nil), "grpc_prometheus.metricsadmin.GetMetricsRequest")
+	proto.RegisterType((*GetMetricsResponse)(nil), "grpc_prometheus.metricsadmin.GetMetricsResponse")
+	proto.RegisterType((*SetHandlingTimeHistogramRequest)(nil), "grpc_prometheus.metricsadmin.SetHandlingTimeHistogramRequest")
+	proto.RegisterType((*SetHandlingTimeHistogramResponse)(nil), "grpc_prometheus.metricsadmin.SetHandlingTimeHistogramResponse")
+	proto.RegisterType((*SetSlowHandlingThresholdRequest)(nil), "grpc_prometheus.metricsadmin.SetSlowHandlingThresholdRequest")
+	proto.RegisterType((*SetSlowHandlingThresholdResponse)(nil), "grpc_prometheus.metricsadmin.SetSlowHandlingThresholdResponse")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// MetricsAdminClient is the client API for MetricsAdmin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type MetricsAdminClient interface {
+	GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*GetMetricsResponse, error)
+	SetHandlingTimeHistogram(ctx context.Context, in *SetHandlingTimeHistogramRequest, opts ...grpc.CallOption) (*SetHandlingTimeHistogramResponse, error)
+	SetSlowHandlingThreshold(ctx context.Context, in *SetSlowHandlingThresholdRequest, opts ...grpc.CallOption) (*SetSlowHandlingThresholdResponse, error)
+}
+
+type metricsAdminClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewMetricsAdminClient(cc *grpc.ClientConn) MetricsAdminClient {
+	return &metricsAdminClient{cc}
+}
+
+func (c *metricsAdminClient) GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*GetMetricsResponse, error) {
+	out := new(GetMetricsResponse)
+	err := c.cc.Invoke(ctx, "/grpc_prometheus.metricsadmin.MetricsAdmin/GetMetrics", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *metricsAdminClient) SetHandlingTimeHistogram(ctx context.Context, in *SetHandlingTimeHistogramRequest, opts ...grpc.CallOption) (*SetHandlingTimeHistogramResponse, error) {
+	out := new(SetHandlingTimeHistogramResponse)
+	err := c.cc.Invoke(ctx, "/grpc_prometheus.metricsadmin.MetricsAdmin/SetHandlingTimeHistogram", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *metricsAdminClient) SetSlowHandlingThreshold(ctx context.Context, in *SetSlowHandlingThresholdRequest, opts ...grpc.CallOption) (*SetSlowHandlingThresholdResponse, error) {
+	out := new(SetSlowHandlingThresholdResponse)
+	err := c.cc.Invoke(ctx, "/grpc_prometheus.metricsadmin.MetricsAdmin/SetSlowHandlingThreshold", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MetricsAdminServer is the server API for MetricsAdmin service.
+type MetricsAdminServer interface {
+	GetMetrics(context.Context, *GetMetricsRequest) (*GetMetricsResponse, error)
+	SetHandlingTimeHistogram(context.Context, *SetHandlingTimeHistogramRequest) (*SetHandlingTimeHistogramResponse, error)
+	SetSlowHandlingThreshold(context.Context, *SetSlowHandlingThresholdRequest) (*SetSlowHandlingThresholdResponse, error)
+}
+
+func RegisterMetricsAdminServer(s *grpc.Server, srv MetricsAdminServer) {
+	s.RegisterService(&_MetricsAdmin_serviceDesc, srv)
+}
+
+func _MetricsAdmin_GetMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMetricsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricsAdminServer).GetMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpc_prometheus.metricsadmin.MetricsAdmin/GetMetrics",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricsAdminServer).GetMetrics(ctx, req.(*GetMetricsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MetricsAdmin_SetHandlingTimeHistogram_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetHandlingTimeHistogramRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricsAdminServer).SetHandlingTimeHistogram(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpc_prometheus.metricsadmin.MetricsAdmin/SetHandlingTimeHistogram",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricsAdminServer).SetHandlingTimeHistogram(ctx, req.(*SetHandlingTimeHistogramRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MetricsAdmin_SetSlowHandlingThreshold_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetSlowHandlingThresholdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricsAdminServer).SetSlowHandlingThreshold(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpc_prometheus.metricsadmin.MetricsAdmin/SetSlowHandlingThreshold",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricsAdminServer).SetSlowHandlingThreshold(ctx, req.(*SetSlowHandlingThresholdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _MetricsAdmin_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "grpc_prometheus.metricsadmin.MetricsAdmin",
+	HandlerType: (*MetricsAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetMetrics",
+			Handler:    _MetricsAdmin_GetMetrics_Handler,
+		},
+		{
+			MethodName: "SetHandlingTimeHistogram",
+			Handler:    _MetricsAdmin_SetHandlingTimeHistogram_Handler,
+		},
+		{
+			MethodName: "SetSlowHandlingThreshold",
+			Handler:    _MetricsAdmin_SetSlowHandlingThreshold_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "metricsadmin.proto",
+}
+
+func init() { proto.RegisterFile("metricsadmin.proto", fileDescriptor_metricsadmin_1512f5378a91c2c5) }
+
+var fileDescriptor_metricsadmin_1512f5378a91c2c5 = []byte{
+	// 319 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x93, 0xc1, 0x4a, 0xf3, 0x40,
+	0x14, 0x85, 0xff, 0xfc, 0x42, 0xab, 0x97, 0x22, 0x76, 0x16, 0x12, 0x8a, 0x60, 0x98, 0x55, 0xa1,
+	0x10, 0x45, 0x97, 0x6a, 0x41, 0x17, 0xda, 0x8d, 0x0a, 0xa9, 0x2b, 0x37, 0x65, 0xda, 0xb9, 0x36,
+	0x81, 0xcc, 0x4c, 0x3a, 0x33, 0x45, 0x7c, 0x16, 0x9f, 0xc5, 0x77, 0x13, 0x93, 0x4c, 0x0d, 0x6a,
+	0x62, 0xed, 0xf2, 0xde, 0xdc, 0x73, 0xe6, 0xe3, 0x1c, 0x02, 0x44, 0xa0, 0xd5, 0xc9, 0xcc, 0x30,
+	0x2e, 0x12, 0x19, 0x66, 0x5a, 0x59, 0x45, 0x0e, 0xe6, 0x3a, 0x9b, 0x4d, 0x32, 0xad, 0x04, 0xda,
+	0x18, 0x97, 0x26, 0xac, 0xde, 0xd0, 0x01, 0x74, 0x6f, 0xd0, 0xde, 0x16, 0xab, 0x08, 0x17, 0x4b,
+	0x34, 0x96, 0xec, 0x43, 0xeb, 0x49, 0x69, 0xc1, 0xac, 0xef, 0x05, 0x5e, 0x7f, 0x27, 0x2a, 0x27,
+	0x7a, 0x0d, 0xa4, 0x7a, 0x6c, 0x32, 0x25, 0x0d, 0xd6, 0x5d, 0x13, 0x1f, 0xda, 0x19, 0x7b, 0x49,
+	0x15, 0xe3, 0xfe, 0xff, 0xc0, 0xeb, 0x77, 0x22, 0x37, 0xd2, 0x33, 0x38, 0x1c, 0xa3, 0x1d, 0x31,
+	0xc9, 0xd3, 0x44, 0xce, 0x1f, 0x12, 0x81, 0xa3, 0xc4, 0x58, 0x35, 0xd7, 0x4c, 0x38, 0x04, 0x1f,
+	0xda, 0x28, 0xd9, 0x34, 0x45, 0x9e, 0xbb, 0x6e, 0x47, 0x6e, 0xa4, 0xe7, 0x10, 0xd4, 0x8b, 0x4b,
+	0xa4, 0x7a, 0xf5, 0x5d, 0xfe, 0xf4, 0x38, 0x55, 0xcf, 0x2b, 0x87, 0x58, 0xa3, 0x89, 0x55, 0xca,
+	0xdd, 0xd3, 0x03, 0xe8, 0x5a, 0xb7, 0x9b, 0x18, 0x9c, 0x29, 0xc9, 0x4d, 0x6e, 0xe3, 0x45, 0x7b,
+	0xab, 0x0f, 0xe3, 0x62, 0x4f, 0xef, 0x21, 0xa8, 0xf7, 0x2b, 0x69, 0xfe, 0x62, 0x78, 0xf2, 0xb6,
+	0x05, 0x9d, 0x32, 0xe1, 0xcb, 0x8f, 0x86, 0xc8, 0x02, 0xe0, 0x33, 0x74, 0x72, 0x14, 0x36, 0xd5,
+	0x19, 0x7e, 0xeb, 0xb2, 0x77, 0xbc, 0xbe, 0xa0, 0xc0, 0xa5, 0xff, 0xc8, 0xab, 0x07, 0x7e, 0x5d,
+	0xc6, 0xe4, 0xa2, 0xd9, 0xf0, 0x97, 0x62, 0x7b, 0xc3, 0x4d, 0xe5, 0x5f, 0xe9, 0x7e, 0xcc, 0x7c,
+	0x0d, 0xba, 0xa6, 0xee, 0x7b, 0xc3, 0x4d, 0xe5, 0x8e, 0xee, 0x6a, 0xf7, 0xb1, 0x53, 0x95, 0x4c,
+	0x5b, 0xf9, 0x5f, 0x78, 0xfa, 0x3e, 0x00, 0xeb, 0x8b, 0x92, 0x46, 0x9b, 0x03, 0x00, 0x00,
+}
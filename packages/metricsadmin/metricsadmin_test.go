@@ -0,0 +1,52 @@
+package metricsadmin
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGetMetrics(t *testing.T) {
+	m := grpc_prometheus.NewServerMetrics()
+	m.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	s := NewServer(m)
+
+	resp, err := s.GetMetrics(context.Background(), &GetMetricsRequest{})
+	require.NoError(t, err)
+	require.True(t, strings.Contains(string(resp.Payload), `grpc_server_started_total{grpc_method="PingEmpty",grpc_service="mwitkow.testproto.TestService",grpc_type="unary"} 1`))
+
+	_, err = s.GetMetrics(context.Background(), &GetMetricsRequest{Format: "application/json"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestRuntimeToggles(t *testing.T) {
+	m := grpc_prometheus.NewServerMetrics()
+	m.EnableHandlingTimeHistogram()
+	m.EnableSlowHandlingCounter(time.Second)
+	s := NewServer(m)
+
+	hist, err := s.SetHandlingTimeHistogram(context.Background(), &SetHandlingTimeHistogramRequest{Enabled: false})
+	require.NoError(t, err)
+	require.False(t, hist.Enabled)
+	require.False(t, m.HandlingTimeHistogramActive())
+
+	slow, err := s.SetSlowHandlingThreshold(context.Background(), &SetSlowHandlingThresholdRequest{ThresholdSeconds: 0.25})
+	require.NoError(t, err)
+	require.Equal(t, 0.25, slow.ThresholdSeconds)
+	require.Equal(t, 250*time.Millisecond, m.SlowHandlingThreshold())
+
+	for _, threshold := range []float64{-1, math.NaN(), math.Inf(1), math.Inf(-1), 1e12} {
+		_, err = s.SetSlowHandlingThreshold(context.Background(), &SetSlowHandlingThresholdRequest{ThresholdSeconds: threshold})
+		require.Equal(t, codes.InvalidArgument, status.Code(err), "threshold %v", threshold)
+	}
+	require.Equal(t, 250*time.Millisecond, m.SlowHandlingThreshold())
+}
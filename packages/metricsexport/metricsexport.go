@@ -0,0 +1,109 @@
+// Package metricsexport provides a gRPC service exposing Prometheus metrics
+// in the exposition format, and a client helper to scrape it, for
+// environments which have no HTTP port for Prometheus to scrape. An agent
+// next to the process scrapes it over gRPC and forwards the metrics.
+package metricsexport
+
+import (
+	"io"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// chunkSize is the size above which the payload is split into several
+// messages, well below the default maximum gRPC message size.
+const chunkSize = 64 * 1024
+
+type server struct {
+	gatherer prom.Gatherer
+}
+
+// NewServer returns a MetricsExportServer exporting the metrics of g, e.g.
+// prometheus.DefaultGatherer or a registry holding a ServerMetrics.
+func NewServer(g prom.Gatherer) MetricsExportServer {
+	return &server{gatherer: g}
+}
+
+// Register registers a MetricsExport service exporting the metrics of g on a
+// gRPC server.
+func Register(s *grpc.Server, g prom.Gatherer) {
+	RegisterMetricsExportServer(s, NewServer(g))
+}
+
+func (s *server) Scrape(req *ScrapeRequest, stream MetricsExport_ScrapeServer) error {
+	format := expfmt.Format(req.Format)
+	if format == "" {
+		format = expfmt.FmtText
+	}
+	switch format {
+	case expfmt.FmtText, expfmt.FmtProtoDelim, expfmt.FmtProtoText, expfmt.FmtProtoCompact:
+	default:
+		return status.Errorf(codes.InvalidArgument, "unsupported exposition format %q", format)
+	}
+	mfs, err := s.gatherer.Gather()
+	if err != nil && len(mfs) == 0 {
+		return status.Error(codes.Internal, err.Error())
+	}
+	w := &chunkWriter{stream: stream, format: string(format)}
+	enc := expfmt.NewEncoder(w, format)
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			return err
+		}
+	}
+	return w.flush()
+}
+
+// chunkWriter buffers the encoded payload and sends it in chunks.
+type chunkWriter struct {
+	stream MetricsExport_ScrapeServer
+	format string
+	buf    []byte
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= chunkSize {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *chunkWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.stream.Send(&ScrapeResponse{Format: w.format, Chunk: w.buf})
+	w.buf = nil
+	return err
+}
+
+// Fetch scrapes the MetricsExport service on cc and writes the payload to w.
+// format is the requested exposition format, the text format if empty. It
+// returns the format of the payload.
+func Fetch(ctx context.Context, cc *grpc.ClientConn, w io.Writer, format expfmt.Format) (expfmt.Format, error) {
+	stream, err := NewMetricsExportClient(cc).Scrape(ctx, &ScrapeRequest{Format: string(format)})
+	if err != nil {
+		return "", err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return format, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		format = expfmt.Format(resp.Format)
+		if _, err := w.Write(resp.Chunk); err != nil {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: metricsexport.proto
+
+package metricsexport
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type ScrapeRequest struct {
+	Format               string   `protobuf:"bytes,1,opt,name=format,proto3" json:"format,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ScrapeRequest) Reset()         { *m = ScrapeRequest{} }
+func (m *ScrapeRequest) String() string { return proto.CompactTextString(m) }
+func (*ScrapeRequest) ProtoMessage()    {}
+func (*ScrapeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_metricsexport_21cfabd2012513de, []int{0}
+}
+func (m *ScrapeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ScrapeRequest.Unmarshal(m, b)
+}
+func (m *ScrapeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ScrapeRequest.Marshal(b, m, deterministic)
+}
+func (dst *ScrapeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ScrapeRequest.Merge(dst, src)
+}
+func (m *ScrapeRequest) XXX_Size() int {
+	return xxx_messageInfo_ScrapeRequest.Size(m)
+}
+func (m *ScrapeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ScrapeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ScrapeRequest proto.InternalMessageInfo
+
+func (m *ScrapeRequest) GetFormat() string {
+	if m != nil {
+		return m.Format
+	}
+	return ""
+}
+
+type ScrapeResponse struct {
+	Format               string   `protobuf:"bytes,1,opt,name=format,proto3" json:"format,omitempty"`
+	Chunk                []byte   `protobuf:"bytes,2,opt,name=chunk,proto3" json:"chunk,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ScrapeResponse) Reset()         { *m = ScrapeResponse{} }
+func (m *ScrapeResponse) String() string { return proto.CompactTextString(m) }
+func (*ScrapeResponse) ProtoMessage()    {}
+func (*ScrapeResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_metricsexport_21cfabd2012513de, []int{1}
+}
+func (m *ScrapeResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ScrapeResponse.Unmarshal(m, b)
+}
+func (m *ScrapeResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ScrapeResponse.Marshal(b, m, deterministic)
+}
+func (dst *ScrapeResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ScrapeResponse.Merge(dst, src)
+}
+func (m *ScrapeResponse) XXX_Size() int {
+	return xxx_messageInfo_ScrapeResponse.Size(m)
+}
+func (m *ScrapeResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ScrapeResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ScrapeResponse proto.InternalMessageInfo
+
+func (m *ScrapeResponse) GetFormat() string {
+	if m != nil {
+		return m.Format
+	}
+	return ""
+}
+
+func (m *ScrapeResponse) GetChunk() []byte {
+	if m != nil {
+		return m.Chunk
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*ScrapeRequest)(nil), "grpc_prometheus.metricsexport.ScrapeRequest")
+	proto.RegisterType((*ScrapeResponse)(nil), "grpc_prometheus.metricsexport.ScrapeResponse")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// MetricsExportClient is the client API for MetricsExport service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type MetricsExportClient interface {
+	Scrape(ctx context.Context, in *ScrapeRequest, opts ...grpc.CallOption) (MetricsExport_ScrapeClient, error)
+}
+
+type metricsExportClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewMetricsExportClient(cc *grpc.ClientConn) MetricsExportClient {
+	return &metricsExportClient{cc}
+}
+
+func (c *metricsExportClient) Scrape(ctx context.Context, in *ScrapeRequest, opts ...grpc.CallOption) (MetricsExport_ScrapeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_MetricsExport_serviceDesc.Streams[0], "/grpc_prometheus.metricsexport.MetricsExport/Scrape", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &metricsExportScrapeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MetricsExport_ScrapeClient interface {
+	Recv() (*ScrapeResponse, error)
+	grpc.ClientStream
+}
+
+type metricsExportScrapeClient struct {
+	grpc.ClientStream
+}
+
+func (x *metricsExportScrapeClient) Recv() (*ScrapeResponse, error) {
+	m := new(ScrapeResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MetricsExportServer is the server API for MetricsExport service.
+type MetricsExportServer interface {
+	Scrape(*ScrapeRequest, MetricsExport_ScrapeServer) error
+}
+
+func RegisterMetricsExportServer(s *grpc.Server, srv MetricsExportServer) {
+	s.RegisterService(&_MetricsExport_serviceDesc, srv)
+}
+
+func _MetricsExport_Scrape_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScrapeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MetricsExportServer).Scrape(m, &metricsExportScrapeServer{stream})
+}
+
+type MetricsExport_ScrapeServer interface {
+	Send(*ScrapeResponse) error
+	grpc.ServerStream
+}
+
+type metricsExportScrapeServer struct {
+	grpc.ServerStream
+}
+
+func (x *metricsExportScrapeServer) Send(m *ScrapeResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _MetricsExport_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "grpc_prometheus.metricsexport.MetricsExport",
+	HandlerType: (*MetricsExportServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Scrape",
+			Handler:       _MetricsExport_Scrape_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "metricsexport.proto",
+}
+
+func init() { proto.RegisterFile("metricsexport.proto", fileDescriptor_metricsexport_21cfabd2012513de) }
+
+var fileDescriptor_metricsexport_21cfabd2012513de = []byte{
+	// 177 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0xce, 0x4d, 0x2d, 0x29,
+	0xca, 0x4c, 0x2e, 0x4e, 0xad, 0x28, 0xc8, 0x2f, 0x2a, 0xd1, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17,
+	0x92, 0x4d, 0x2f, 0x2a, 0x48, 0x8e, 0x2f, 0x28, 0xca, 0xcf, 0x4d, 0x2d, 0xc9, 0x48, 0x2d, 0x2d,
+	0xd6, 0x43, 0x51, 0xa4, 0xa4, 0xce, 0xc5, 0x1b, 0x9c, 0x5c, 0x94, 0x58, 0x90, 0x1a, 0x94, 0x5a,
+	0x58, 0x9a, 0x5a, 0x5c, 0x22, 0x24, 0xc6, 0xc5, 0x96, 0x96, 0x5f, 0x94, 0x9b, 0x58, 0x22, 0xc1,
+	0xa8, 0xc0, 0xa8, 0xc1, 0x19, 0x04, 0xe5, 0x29, 0xd9, 0x71, 0xf1, 0xc1, 0x14, 0x16, 0x17, 0xe4,
+	0xe7, 0x15, 0xa7, 0xe2, 0x52, 0x29, 0x24, 0xc2, 0xc5, 0x9a, 0x9c, 0x51, 0x9a, 0x97, 0x2d, 0xc1,
+	0xa4, 0xc0, 0xa8, 0xc1, 0x13, 0x04, 0xe1, 0x18, 0x55, 0x71, 0xf1, 0xfa, 0x42, 0x6c, 0x76, 0x05,
+	0xdb, 0x2c, 0x94, 0xc9, 0xc5, 0x06, 0x31, 0x50, 0x48, 0x47, 0x0f, 0xaf, 0x1b, 0xf5, 0x50, 0x1c,
+	0x28, 0xa5, 0x4b, 0xa4, 0x6a, 0x88, 0x2b, 0x95, 0x18, 0x0c, 0x18, 0x9d, 0xf8, 0xa3, 0x78, 0x51,
+	0xd4, 0x24, 0xb1, 0x81, 0xc3, 0xc6, 0x18, 0x30, 0x00, 0x8e, 0x1d, 0x0d, 0xa1, 0x32, 0x01, 0x00,
+	0x00,
+}
@@ -0,0 +1,51 @@
+package metricsexport
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestScrapeOverGRPC(t *testing.T) {
+	m := grpc_prometheus.NewServerMetrics()
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(m))
+	// Enough methods for the payload to span several chunks.
+	for i := 0; i < 1000; i++ {
+		m.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping" + strings.Repeat("x", i%50) + string(rune('a'+i%26)) + string(rune('a'+i/26))},
+			func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	Register(s, reg)
+	go s.Serve(lis)
+	defer s.Stop()
+	cc, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer cc.Close()
+
+	var buf bytes.Buffer
+	format, err := Fetch(context.Background(), cc, &buf, "")
+	require.NoError(t, err)
+	require.Equal(t, expfmt.FmtText, format)
+	require.True(t, buf.Len() > chunkSize, "payload must span several chunks")
+
+	var want bytes.Buffer
+	require.NoError(t, m.Dump(&want, expfmt.FmtText))
+	require.Equal(t, want.String(), buf.String())
+
+	_, err = Fetch(context.Background(), cc, &buf, "application/json")
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,247 @@
+// Package prometheus mirrors the API of the
+// github.com/grpc-ecosystem/go-grpc-middleware/providers/prometheus package
+// on top of grpc_prometheus, so that code wired against that provider can
+// switch to this package by changing the import path only:
+//
+//	srvMetrics := prometheus.NewServerMetrics(
+//		prometheus.WithServerHandlingTimeHistogram(prometheus.WithHistogramBuckets(buckets)),
+//	)
+//	grpc.NewServer(grpc.UnaryInterceptor(srvMetrics.UnaryServerInterceptor()))
+//
+// The returned metrics embed grpc_prometheus.ServerMetrics and ClientMetrics,
+// so all metrics specific to this module remain available through their
+// Enable* methods. Exemplars are not supported by the Prometheus client this
+// module builds on, so WithExemplarFromContext is accepted and ignored.
+// Context labels are not supported either: WithContextLabels and
+// WithLabelsFromContext are accepted and ignored, the metrics are exported
+// without these labels.
+package prometheus
+
+import (
+	"context"
+
+	"github.com/grpc-ecosystem/go-grpc-prometheus"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+// A CounterOption lets you add options to Counter metrics using With* funcs.
+type CounterOption = grpc_prometheus.CounterOption
+
+// A HistogramOption lets you add options to Histogram metrics using With*
+// funcs.
+type HistogramOption = grpc_prometheus.HistogramOption
+
+// WithConstLabels allows you to add ConstLabels to Counter metrics.
+func WithConstLabels(labels prom.Labels) CounterOption {
+	return grpc_prometheus.WithConstLabels(labels)
+}
+
+// WithSubsystem allows you to add a Subsystem to Counter metrics.
+func WithSubsystem(subsystem string) CounterOption {
+	return func(o *prom.CounterOpts) { o.Subsystem = subsystem }
+}
+
+// WithNamespace allows you to add a Namespace to Counter metrics.
+func WithNamespace(namespace string) CounterOption {
+	return func(o *prom.CounterOpts) { o.Namespace = namespace }
+}
+
+// WithHistogramBuckets allows you to specify custom bucket ranges for
+// histograms.
+func WithHistogramBuckets(buckets []float64) HistogramOption {
+	return grpc_prometheus.WithHistogramBuckets(buckets)
+}
+
+// WithHistogramOpts allows you to specify the full HistogramOpts of
+// histograms. Name and Help are kept if left empty.
+func WithHistogramOpts(opts *prom.HistogramOpts) HistogramOption {
+	return func(o *prom.HistogramOpts) {
+		name, help := o.Name, o.Help
+		*o = *opts
+		if o.Name == "" {
+			o.Name = name
+		}
+		if o.Help == "" {
+			o.Help = help
+		}
+	}
+}
+
+// WithHistogramConstLabels allows you to add custom ConstLabels to
+// histograms metrics.
+func WithHistogramConstLabels(labels prom.Labels) HistogramOption {
+	return grpc_prometheus.WithHistogramConstLabels(labels)
+}
+
+// WithHistogramSubsystem allows you to add a Subsystem to histograms metrics.
+func WithHistogramSubsystem(subsystem string) HistogramOption {
+	return func(o *prom.HistogramOpts) { o.Subsystem = subsystem }
+}
+
+// WithHistogramNamespace allows you to add a Namespace to histograms metrics.
+func WithHistogramNamespace(namespace string) HistogramOption {
+	return func(o *prom.HistogramOpts) { o.Namespace = namespace }
+}
+
+// An Option lets you configure interceptors using With* funcs.
+type Option func(*config)
+
+type config struct{}
+
+// WithExemplarFromContext is accepted for compatibility and ignored, as
+// exemplars are not supported.
+func WithExemplarFromContext(exemplarFn func(ctx context.Context) prom.Labels) Option {
+	return func(*config) {}
+}
+
+// WithLabelsFromContext is accepted for compatibility and ignored, as context
+// labels are not supported.
+func WithLabelsFromContext(labelsFn func(ctx context.Context) prom.Labels) Option {
+	return func(*config) {}
+}
+
+// A ServerMetricsOption lets you configure ServerMetrics using With* funcs.
+type ServerMetricsOption func(*serverMetricsConfig)
+
+type serverMetricsConfig struct {
+	counterOpts     []CounterOption
+	histogramOpts   []HistogramOption
+	histogramEnable bool
+}
+
+// WithServerCounterOptions sets counter options of all server metrics.
+func WithServerCounterOptions(opts ...CounterOption) ServerMetricsOption {
+	return func(c *serverMetricsConfig) { c.counterOpts = append(c.counterOpts, opts...) }
+}
+
+// WithContextLabels is accepted for compatibility and ignored, as context
+// labels are not supported: the metrics don't have the labelNames labels.
+func WithContextLabels(labelNames ...string) ServerMetricsOption {
+	return func(*serverMetricsConfig) {}
+}
+
+// WithServerHandlingTimeHistogram turns on recording of the handling time of
+// RPCs.
+func WithServerHandlingTimeHistogram(opts ...HistogramOption) ServerMetricsOption {
+	return func(c *serverMetricsConfig) {
+		c.histogramEnable = true
+		c.histogramOpts = append(c.histogramOpts, opts...)
+	}
+}
+
+// ServerMetrics represents a collection of metrics to be registered on a
+// Prometheus metrics registry for a gRPC server.
+type ServerMetrics struct {
+	*grpc_prometheus.ServerMetrics
+}
+
+// NewServerMetrics returns a new ServerMetrics object that has server
+// interceptor methods.
+func NewServerMetrics(opts ...ServerMetricsOption) *ServerMetrics {
+	var c serverMetricsConfig
+	for _, o := range opts {
+		o(&c)
+	}
+	m := grpc_prometheus.NewServerMetrics(c.counterOpts...)
+	if c.histogramEnable {
+		m.EnableHandlingTimeHistogram(c.histogramOpts...)
+	}
+	return &ServerMetrics{m}
+}
+
+// UnaryServerInterceptor is a gRPC server-side interceptor that provides
+// Prometheus monitoring for Unary RPCs.
+func (m *ServerMetrics) UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
+	return m.ServerMetrics.UnaryServerInterceptor()
+}
+
+// StreamServerInterceptor is a gRPC server-side interceptor that provides
+// Prometheus monitoring for Streaming RPCs.
+func (m *ServerMetrics) StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
+	return m.ServerMetrics.StreamServerInterceptor()
+}
+
+// A ClientMetricsOption lets you configure ClientMetrics using With* funcs.
+type ClientMetricsOption func(*clientMetricsConfig)
+
+type clientMetricsConfig struct {
+	counterOpts []CounterOption
+
+	handlingTimeEnable bool
+	handlingTimeOpts   []HistogramOption
+	streamRecvEnable   bool
+	streamRecvOpts     []HistogramOption
+	streamSendEnable   bool
+	streamSendOpts     []HistogramOption
+}
+
+// WithClientCounterOptions sets counter options of all client metrics.
+func WithClientCounterOptions(opts ...CounterOption) ClientMetricsOption {
+	return func(c *clientMetricsConfig) { c.counterOpts = append(c.counterOpts, opts...) }
+}
+
+// WithClientHandlingTimeHistogram turns on recording of the handling time of
+// RPCs.
+func WithClientHandlingTimeHistogram(opts ...HistogramOption) ClientMetricsOption {
+	return func(c *clientMetricsConfig) {
+		c.handlingTimeEnable = true
+		c.handlingTimeOpts = append(c.handlingTimeOpts, opts...)
+	}
+}
+
+// WithClientStreamRecvHistogram turns on recording of the time waiting for
+// stream messages to be received.
+func WithClientStreamRecvHistogram(opts ...HistogramOption) ClientMetricsOption {
+	return func(c *clientMetricsConfig) {
+		c.streamRecvEnable = true
+		c.streamRecvOpts = append(c.streamRecvOpts, opts...)
+	}
+}
+
+// WithClientStreamSendHistogram turns on recording of the time taken to send
+// stream messages.
+func WithClientStreamSendHistogram(opts ...HistogramOption) ClientMetricsOption {
+	return func(c *clientMetricsConfig) {
+		c.streamSendEnable = true
+		c.streamSendOpts = append(c.streamSendOpts, opts...)
+	}
+}
+
+// ClientMetrics represents a collection of metrics to be registered on a
+// Prometheus metrics registry for a gRPC client.
+type ClientMetrics struct {
+	*grpc_prometheus.ClientMetrics
+}
+
+// NewClientMetrics returns a new ClientMetrics object that has client
+// interceptor methods.
+func NewClientMetrics(opts ...ClientMetricsOption) *ClientMetrics {
+	var c clientMetricsConfig
+	for _, o := range opts {
+		o(&c)
+	}
+	m := grpc_prometheus.NewClientMetrics(c.counterOpts...)
+	if c.handlingTimeEnable {
+		m.EnableClientHandlingTimeHistogram(c.handlingTimeOpts...)
+	}
+	if c.streamRecvEnable {
+		m.EnableClientStreamReceiveTimeHistogram(c.streamRecvOpts...)
+	}
+	if c.streamSendEnable {
+		m.EnableClientStreamSendTimeHistogram(c.streamSendOpts...)
+	}
+	return &ClientMetrics{m}
+}
+
+// UnaryClientInterceptor is a gRPC client-side interceptor that provides
+// Prometheus monitoring for Unary RPCs.
+func (m *ClientMetrics) UnaryClientInterceptor(opts ...Option) grpc.UnaryClientInterceptor {
+	return m.ClientMetrics.UnaryClientInterceptor()
+}
+
+// StreamClientInterceptor is a gRPC client-side interceptor that provides
+// Prometheus monitoring for Streaming RPCs.
+func (m *ClientMetrics) StreamClientInterceptor(opts ...Option) grpc.StreamClientInterceptor {
+	return m.ClientMetrics.StreamClientInterceptor()
+}
@@ -0,0 +1,68 @@
+package prometheus
+
+import (
+	"context"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func gather(t *testing.T, c prom.Collector) map[string]*dto.MetricFamily {
+	reg := prom.NewPedanticRegistry()
+	require.NoError(t, reg.Register(c))
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	byName := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		byName[mf.GetName()] = mf
+	}
+	return byName
+}
+
+func TestServerMetricsOptions(t *testing.T) {
+	m := NewServerMetrics(
+		WithServerCounterOptions(WithConstLabels(prom.Labels{"app": "test"})),
+		WithServerHandlingTimeHistogram(WithHistogramBuckets([]float64{1})),
+		WithContextLabels("tenant"),
+	)
+	interceptor := m.UnaryServerInterceptor(
+		WithExemplarFromContext(func(ctx context.Context) prom.Labels { return nil }),
+		WithLabelsFromContext(func(ctx context.Context) prom.Labels { return prom.Labels{"tenant": "a"} }),
+	)
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	require.NoError(t, err)
+
+	mfs := gather(t, m)
+	started := mfs["grpc_server_started_total"].GetMetric()[0]
+	require.Contains(t, started.GetLabel(), &dto.LabelPair{Name: proto("app"), Value: proto("test")})
+	histogram := mfs["grpc_server_handling_seconds"].GetMetric()[0].GetHistogram()
+	require.EqualValues(t, 1, histogram.GetSampleCount())
+	require.Len(t, histogram.GetBucket(), 1)
+	require.EqualValues(t, 1, histogram.GetBucket()[0].GetUpperBound())
+}
+
+func TestClientMetricsOptions(t *testing.T) {
+	m := NewClientMetrics(
+		WithClientCounterOptions(WithNamespace("app")),
+		WithClientHandlingTimeHistogram(WithHistogramNamespace("app")),
+		WithClientStreamRecvHistogram(),
+		WithClientStreamSendHistogram(),
+	)
+	err := m.UnaryClientInterceptor()(context.Background(), "/mwitkow.testproto.TestService/Ping", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return nil
+		})
+	require.NoError(t, err)
+
+	mfs := gather(t, m)
+	require.Contains(t, mfs, "app_grpc_client_started_total")
+	require.EqualValues(t, 1, mfs["app_grpc_client_handling_seconds"].GetMetric()[0].GetHistogram().GetSampleCount())
+}
+
+func proto(s string) *string {
+	return &s
+}
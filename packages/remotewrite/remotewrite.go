@@ -0,0 +1,237 @@
+// Package remotewrite provides an Exporter periodically pushing the metrics
+// of a prometheus.Gatherer, e.g. a registry holding a ServerMetrics, to a
+// Prometheus remote-write endpoint. It suits serverless and other
+// environments which can neither be scraped nor reach a Pushgateway.
+//
+// Each push sends a snapshot of all gathered series with the push time as
+// timestamp, unless the metric carries its own. Counters keep their
+// cumulative values, so rates are computed by the receiving end as usual.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	defaultInterval = 15 * time.Second
+	defaultTimeout  = 10 * time.Second
+	// protocolVersion is the version of the remote-write protocol spoken.
+	protocolVersion = "0.1.0"
+)
+
+// Exporter pushes gathered metrics to a remote-write endpoint.
+type Exporter struct {
+	url      string
+	gatherer prom.Gatherer
+	client   *http.Client
+	interval time.Duration
+	timeout  time.Duration
+	labels   map[string]string
+	onError  func(error)
+
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// An Option lets you configure an Exporter using With* funcs.
+type Option func(*Exporter)
+
+// WithInterval sets the interval between pushes, 15 seconds by default.
+func WithInterval(d time.Duration) Option {
+	return func(e *Exporter) { e.interval = d }
+}
+
+// WithTimeout sets the timeout of each push, 10 seconds by default.
+func WithTimeout(d time.Duration) Option {
+	return func(e *Exporter) { e.timeout = d }
+}
+
+// WithHTTPClient sets the client used to push, e.g. to configure TLS or
+// authentication. http.DefaultClient is used by default.
+func WithHTTPClient(c *http.Client) Option {
+	return func(e *Exporter) { e.client = c }
+}
+
+// WithLabels adds labels to all pushed series, typically job and instance,
+// which are otherwise attached by Prometheus when scraping. Labels of the
+// gathered metrics take precedence.
+func WithLabels(labels map[string]string) Option {
+	return func(e *Exporter) { e.labels = labels }
+}
+
+// WithErrorHandler sets a function called with the error of every failed
+// periodic push, e.g. for logging. Errors are dropped by default.
+func WithErrorHandler(f func(error)) Option {
+	return func(e *Exporter) { e.onError = f }
+}
+
+// New returns an Exporter pushing the metrics of g to the remote-write
+// endpoint at url, e.g. "http://prometheus:9090/api/v1/write". It doesn't
+// push until Start is called.
+func New(url string, g prom.Gatherer, opts ...Option) *Exporter {
+	e := &Exporter{
+		url:      url,
+		gatherer: g,
+		client:   http.DefaultClient,
+		interval: defaultInterval,
+		timeout:  defaultTimeout,
+		done:     make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(e)
+	}
+	return e
+}
+
+// Start starts pushing every interval in the background, until Close.
+func (e *Exporter) Start() {
+	e.wg.Add(1)
+	go e.pushLoop()
+}
+
+// Close stops the periodic pushes, and pushes a last snapshot so that the
+// final values of the counters aren't lost.
+func (e *Exporter) Close() error {
+	e.once.Do(func() { close(e.done) })
+	e.wg.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	return e.Push(ctx)
+}
+
+func (e *Exporter) pushLoop() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+			if err := e.Push(ctx); err != nil && e.onError != nil {
+				e.onError(err)
+			}
+			cancel()
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// Push gathers the metrics and pushes them once.
+func (e *Exporter) Push(ctx context.Context) error {
+	mfs, err := e.gatherer.Gather()
+	if err != nil && len(mfs) == 0 {
+		return err
+	}
+	data, err := proto.Marshal(&WriteRequest{Timeseries: toTimeSeries(mfs, e.labels, time.Now())})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", e.url, bytes.NewReader(snappy.Encode(nil, data)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", protocolVersion)
+	resp, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remotewrite: server returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// toTimeSeries flattens metric families into time series, expanding
+// histograms and summaries into their _bucket, _sum and _count series as in
+// the exposition format.
+func toTimeSeries(mfs []*dto.MetricFamily, extra map[string]string, now time.Time) []*TimeSeries {
+	nowMs := now.UnixNano() / int64(time.Millisecond)
+	var series []*TimeSeries
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.Metric {
+			ts := nowMs
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+			add := func(suffix string, value float64, extraName, extraValue string) {
+				labels := seriesLabels(name+suffix, m.Label, extra, extraName, extraValue)
+				series = append(series, &TimeSeries{Labels: labels, Samples: []*Sample{{Value: value, Timestamp: ts}}})
+			}
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue(), "", "")
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue(), "", "")
+			case dto.MetricType_UNTYPED:
+				add("", m.GetUntyped().GetValue(), "", "")
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.Bucket {
+					add("_bucket", float64(b.GetCumulativeCount()), "le", formatFloat(b.GetUpperBound()))
+				}
+				add("_bucket", float64(h.GetSampleCount()), "le", "+Inf")
+				add("_sum", h.GetSampleSum(), "", "")
+				add("_count", float64(h.GetSampleCount()), "", "")
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.Quantile {
+					add("", q.GetValue(), "quantile", formatFloat(q.GetQuantile()))
+				}
+				add("_sum", s.GetSampleSum(), "", "")
+				add("_count", float64(s.GetSampleCount()), "", "")
+			}
+		}
+	}
+	return series
+}
+
+// seriesLabels returns the sorted labels of a series, as required by the
+// remote-write protocol.
+func seriesLabels(name string, pairs []*dto.LabelPair, extra map[string]string, extraName, extraValue string) []*Label {
+	byName := make(map[string]string, len(extra)+len(pairs)+2)
+	for k, v := range extra {
+		byName[k] = v
+	}
+	for _, p := range pairs {
+		byName[p.GetName()] = p.GetValue()
+	}
+	if extraName != "" {
+		byName[extraName] = extraValue
+	}
+	byName["__name__"] = name
+	labels := make([]*Label, 0, len(byName))
+	for k, v := range byName {
+		labels = append(labels, &Label{Name: k, Value: v})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
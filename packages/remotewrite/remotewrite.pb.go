@@ -0,0 +1,223 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: remotewrite.proto
+
+package remotewrite
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type WriteRequest struct {
+	Timeseries           []*TimeSeries `protobuf:"bytes,1,rep,name=timeseries,proto3" json:"timeseries,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *WriteRequest) Reset()         { *m = WriteRequest{} }
+func (m *WriteRequest) String() string { return proto.CompactTextString(m) }
+func (*WriteRequest) ProtoMessage()    {}
+func (*WriteRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remotewrite_6bada810eeed02fe, []int{0}
+}
+func (m *WriteRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WriteRequest.Unmarshal(m, b)
+}
+func (m *WriteRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WriteRequest.Marshal(b, m, deterministic)
+}
+func (dst *WriteRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WriteRequest.Merge(dst, src)
+}
+func (m *WriteRequest) XXX_Size() int {
+	return xxx_messageInfo_WriteRequest.Size(m)
+}
+func (m *WriteRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WriteRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WriteRequest proto.InternalMessageInfo
+
+func (m *WriteRequest) GetTimeseries() []*TimeSeries {
+	if m != nil {
+		return m.Timeseries
+	}
+	return nil
+}
+
+type TimeSeries struct {
+	Labels               []*Label  `protobuf:"bytes,1,rep,name=labels,proto3" json:"labels,omitempty"`
+	Samples              []*Sample `protobuf:"bytes,2,rep,name=samples,proto3" json:"samples,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *TimeSeries) Reset()         { *m = TimeSeries{} }
+func (m *TimeSeries) String() string { return proto.CompactTextString(m) }
+func (*TimeSeries) ProtoMessage()    {}
+func (*TimeSeries) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remotewrite_6bada810eeed02fe, []int{1}
+}
+func (m *TimeSeries) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TimeSeries.Unmarshal(m, b)
+}
+func (m *TimeSeries) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TimeSeries.Marshal(b, m, deterministic)
+}
+func (dst *TimeSeries) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TimeSeries.Merge(dst, src)
+}
+func (m *TimeSeries) XXX_Size() int {
+	return xxx_messageInfo_TimeSeries.Size(m)
+}
+func (m *TimeSeries) XXX_DiscardUnknown() {
+	xxx_messageInfo_TimeSeries.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TimeSeries proto.InternalMessageInfo
+
+func (m *TimeSeries) GetLabels() []*Label {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *TimeSeries) GetSamples() []*Sample {
+	if m != nil {
+		return m.Samples
+	}
+	return nil
+}
+
+type Label struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value                string   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Label) Reset()         { *m = Label{} }
+func (m *Label) String() string { return proto.CompactTextString(m) }
+func (*Label) ProtoMessage()    {}
+func (*Label) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remotewrite_6bada810eeed02fe, []int{2}
+}
+func (m *Label) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Label.Unmarshal(m, b)
+}
+func (m *Label) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Label.Marshal(b, m, deterministic)
+}
+func (dst *Label) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Label.Merge(dst, src)
+}
+func (m *Label) XXX_Size() int {
+	return xxx_messageInfo_Label.Size(m)
+}
+func (m *Label) XXX_DiscardUnknown() {
+	xxx_messageInfo_Label.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Label proto.InternalMessageInfo
+
+func (m *Label) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Label) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+type Sample struct {
+	Value                float64  `protobuf:"fixed64,1,opt,name=value,proto3" json:"value,omitempty"`
+	Timestamp            int64    `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Sample) Reset()         { *m = Sample{} }
+func (m *Sample) String() string { return proto.CompactTextString(m) }
+func (*Sample) ProtoMessage()    {}
+func (*Sample) Descriptor() ([]byte, []int) {
+	return fileDescriptor_remotewrite_6bada810eeed02fe, []int{3}
+}
+func (m *Sample) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Sample.Unmarshal(m, b)
+}
+func (m *Sample) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Sample.Marshal(b, m, deterministic)
+}
+func (dst *Sample) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Sample.Merge(dst, src)
+}
+func (m *Sample) XXX_Size() int {
+	return xxx_messageInfo_Sample.Size(m)
+}
+func (m *Sample) XXX_DiscardUnknown() {
+	xxx_messageInfo_Sample.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Sample proto.InternalMessageInfo
+
+func (m *Sample) GetValue() float64 {
+	if m != nil {
+		return m.Value
+	}
+	return 0
+}
+
+func (m *Sample) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*WriteRequest)(nil), "grpc_prometheus.remotewrite.WriteRequest")
+	proto.RegisterType((*TimeSeries)(nil), "grpc_prometheus.remotewrite.TimeSeries")
+	proto.RegisterType((*Label)(nil), "grpc_prometheus.remotewrite.Label")
+	proto.RegisterType((*Sample)(nil), "grpc_prometheus.remotewrite.Sample")
+}
+
+func init() { proto.RegisterFile("remotewrite.proto", fileDescriptor_remotewrite_6bada810eeed02fe) }
+
+var fileDescriptor_remotewrite_6bada810eeed02fe = []byte{
+	// 236 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x91, 0x31, 0x4b, 0x03, 0x31,
+	0x14, 0xc7, 0x49, 0x6b, 0x4f, 0xfa, 0xaa, 0x83, 0x0f, 0x87, 0x03, 0x1d, 0x4a, 0x1c, 0xec, 0x14,
+	0x50, 0x37, 0xd1, 0xc5, 0xc5, 0xc5, 0x29, 0x15, 0x0a, 0x2e, 0x92, 0xca, 0x43, 0x03, 0x89, 0x89,
+	0x49, 0x4e, 0x3f, 0x82, 0x5f, 0xbb, 0xdc, 0x6b, 0x8f, 0xbb, 0xe9, 0xb6, 0x97, 0xf7, 0xff, 0xff,
+	0x7e, 0x24, 0x04, 0xce, 0x12, 0xf9, 0x50, 0xe8, 0x2f, 0xd9, 0x42, 0x2a, 0xa6, 0x50, 0x02, 0x5e,
+	0x7c, 0xa6, 0xf8, 0xf1, 0x1e, 0x53, 0xf0, 0x54, 0xbe, 0xa8, 0xc9, 0x6a, 0x50, 0x91, 0x1b, 0x38,
+	0xd9, 0xb4, 0x83, 0xa6, 0x9f, 0x86, 0x72, 0xc1, 0x67, 0x80, 0x62, 0x3d, 0x65, 0x4a, 0x96, 0x72,
+	0x2d, 0x96, 0xd3, 0xd5, 0xe2, 0xf6, 0x5a, 0x8d, 0x18, 0xd4, 0xab, 0xf5, 0xb4, 0xe6, 0xba, 0x1e,
+	0xa0, 0xf2, 0x5f, 0x00, 0xf4, 0x11, 0xde, 0x43, 0xe5, 0xcc, 0x96, 0x5c, 0xe7, 0x94, 0xa3, 0xce,
+	0x97, 0xb6, 0xaa, 0x0f, 0x04, 0x3e, 0xc2, 0x71, 0x36, 0x3e, 0x3a, 0xca, 0xf5, 0x84, 0xe1, 0xab,
+	0x51, 0x78, 0xcd, 0x5d, 0xdd, 0x31, 0xf2, 0x06, 0x66, 0xec, 0x43, 0x84, 0xa3, 0x6f, 0xe3, 0xa9,
+	0x16, 0x4b, 0xb1, 0x9a, 0x6b, 0x9e, 0xf1, 0x1c, 0x66, 0xbf, 0xc6, 0x35, 0x54, 0x4f, 0x78, 0xb9,
+	0x3f, 0xc8, 0x07, 0xa8, 0xf6, 0x96, 0x3e, 0x6f, 0x21, 0x71, 0xc8, 0xf1, 0x12, 0xe6, 0xfc, 0xd4,
+	0x62, 0x7c, 0x64, 0x72, 0xaa, 0xfb, 0xc5, 0xd3, 0xe9, 0xdb, 0x62, 0x70, 0x9f, 0x6d, 0xc5, 0xdf,
+	0x70, 0xb7, 0x1b, 0x00, 0x28, 0xb3, 0xc5, 0x8e, 0x9b, 0x01, 0x00, 0x00,
+}
@@ -0,0 +1,91 @@
+package remotewrite
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func receiver(t *testing.T, requests chan<- *WriteRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		require.Equal(t, protocolVersion, r.Header.Get("X-Prometheus-Remote-Write-Version"))
+		compressed, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		data, err := snappy.Decode(nil, compressed)
+		require.NoError(t, err)
+		req := &WriteRequest{}
+		require.NoError(t, proto.Unmarshal(data, req))
+		requests <- req
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func labelMap(ts *TimeSeries) map[string]string {
+	m := make(map[string]string)
+	for _, l := range ts.Labels {
+		m[l.Name] = l.Value
+	}
+	return m
+}
+
+func TestPushSendsSnapshot(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "grpc_server_handled_total", Help: "h"}, []string{"grpc_code"})
+	hist := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "grpc_server_handling_seconds", Help: "h", Buckets: []float64{0.1}})
+	reg.MustRegister(counter, hist)
+	counter.WithLabelValues("OK").Add(3)
+	hist.Observe(0.05)
+
+	requests := make(chan *WriteRequest, 1)
+	srv := receiver(t, requests)
+	defer srv.Close()
+	e := New(srv.URL, reg, WithLabels(map[string]string{"job": "fn"}))
+	require.NoError(t, e.Push(context.Background()))
+
+	got := make(map[string]float64)
+	for _, ts := range (<-requests).Timeseries {
+		l := labelMap(ts)
+		require.Equal(t, "fn", l["job"])
+		require.Len(t, ts.Samples, 1)
+		got[l["__name__"]+"{"+l["le"]+l["grpc_code"]+"}"] = ts.Samples[0].Value
+	}
+	require.Equal(t, map[string]float64{
+		"grpc_server_handled_total{OK}":             3,
+		"grpc_server_handling_seconds_bucket{0.1}":  1,
+		"grpc_server_handling_seconds_bucket{+Inf}": 1,
+		"grpc_server_handling_seconds_sum{}":        0.05,
+		"grpc_server_handling_seconds_count{}":      1,
+	}, got)
+}
+
+func TestPushReportsServerErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	err := New(srv.URL, prometheus.NewRegistry()).Push(context.Background())
+	require.EqualError(t, err, "remotewrite: server returned 400 Bad Request: out of order sample")
+}
+
+func TestStartPushesPeriodicallyAndOnClose(t *testing.T) {
+	requests := make(chan *WriteRequest, 100)
+	srv := receiver(t, requests)
+	defer srv.Close()
+	e := New(srv.URL, prometheus.NewRegistry(), WithInterval(10*time.Millisecond))
+	e.Start()
+	select {
+	case <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no periodic push")
+	}
+	require.NoError(t, e.Close())
+}
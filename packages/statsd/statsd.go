@@ -0,0 +1,177 @@
+// Package statsd provides a grpc_prometheus.Recorder sending the RPC metrics
+// of the server interceptors to a statsd agent over UDP, in the tagged
+// DogStatsD format, for pipelines that are not scraped by Prometheus.
+//
+// Lines are buffered and sent when a packet is full or the flush interval has
+// passed, whichever comes first.
+package statsd
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-prometheus"
+)
+
+const (
+	defaultPrefix        = "grpc.server."
+	defaultFlushInterval = time.Second
+	// maxPacketSize keeps packets below the common Ethernet MTU.
+	maxPacketSize = 1432
+	// otherTagValue replaces the service and method of RPCs beyond the
+	// tag set limit.
+	otherTagValue = "other"
+)
+
+// Recorder is a grpc_prometheus.Recorder sending metrics to statsd.
+type Recorder struct {
+	conn          net.Conn
+	prefix        string
+	flushInterval time.Duration
+	maxTagSets    int
+
+	mu      sync.Mutex
+	buf     []byte
+	tagSets map[string]bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+var _ grpc_prometheus.Recorder = &Recorder{}
+
+// An Option lets you configure a Recorder using With* funcs.
+type Option func(*Recorder)
+
+// WithPrefix sets the prefix of all metric names, "grpc.server." by default.
+func WithPrefix(prefix string) Option {
+	return func(r *Recorder) { r.prefix = prefix }
+}
+
+// WithFlushInterval sets the maximum time metrics are buffered before being
+// sent, one second by default.
+func WithFlushInterval(d time.Duration) Option {
+	return func(r *Recorder) { r.flushInterval = d }
+}
+
+// WithMaxTagSets bounds the number of distinct grpc_type, grpc_service and
+// grpc_method tag combinations sent. RPCs of further combinations are sent
+// with service and method tagged as "other". Zero, the default, means no
+// bound.
+func WithMaxTagSets(n int) Option {
+	return func(r *Recorder) { r.maxTagSets = n }
+}
+
+// New returns a Recorder sending metrics to the statsd agent at addr, e.g.
+// "localhost:8125". It must be closed with Close to release its resources.
+func New(addr string, opts ...Option) (*Recorder, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{
+		conn:          conn,
+		prefix:        defaultPrefix,
+		flushInterval: defaultFlushInterval,
+		buf:           make([]byte, 0, maxPacketSize),
+		tagSets:       make(map[string]bool),
+		done:          make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	r.wg.Add(1)
+	go r.flushLoop()
+	return r, nil
+}
+
+// RPCStarted implements grpc_prometheus.Recorder.
+func (r *Recorder) RPCStarted(rpcType, service, method string) {
+	r.send("started", "1|c", rpcType, service, method, "")
+}
+
+// RPCHandled implements grpc_prometheus.Recorder.
+func (r *Recorder) RPCHandled(rpcType, service, method, code string, handlingTime time.Duration) {
+	r.send("handled", "1|c", rpcType, service, method, code)
+	ms := strconv.FormatFloat(handlingTime.Seconds()*1000, 'f', -1, 64)
+	r.send("handling_time", ms+"|ms", rpcType, service, method, "")
+}
+
+// MsgReceived implements grpc_prometheus.Recorder.
+func (r *Recorder) MsgReceived(rpcType, service, method string) {
+	r.send("msg_received", "1|c", rpcType, service, method, "")
+}
+
+// MsgSent implements grpc_prometheus.Recorder.
+func (r *Recorder) MsgSent(rpcType, service, method string) {
+	r.send("msg_sent", "1|c", rpcType, service, method, "")
+}
+
+// Flush sends all buffered metrics.
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.flushLocked()
+}
+
+// Close flushes buffered metrics and closes the connection to the agent.
+func (r *Recorder) Close() error {
+	close(r.done)
+	r.wg.Wait()
+	err := r.Flush()
+	if cerr := r.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (r *Recorder) flushLoop() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.Flush()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *Recorder) send(name, value, rpcType, service, method, code string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxTagSets > 0 {
+		key := rpcType + "/" + service + "/" + method
+		if !r.tagSets[key] {
+			if len(r.tagSets) < r.maxTagSets {
+				r.tagSets[key] = true
+			} else {
+				service, method = otherTagValue, otherTagValue
+			}
+		}
+	}
+	line := r.prefix + name + ":" + value + "|#grpc_type:" + rpcType + ",grpc_service:" + service + ",grpc_method:" + method
+	if code != "" {
+		line += ",grpc_code:" + code
+	}
+	if len(r.buf) > 0 && len(r.buf)+1+len(line) > maxPacketSize {
+		r.flushLocked()
+	}
+	if len(r.buf) > 0 {
+		r.buf = append(r.buf, '\n')
+	}
+	r.buf = append(r.buf, line...)
+}
+
+func (r *Recorder) flushLocked() error {
+	if len(r.buf) == 0 {
+		return nil
+	}
+	_, err := r.conn.Write(r.buf)
+	r.buf = r.buf[:0]
+	return err
+}
@@ -0,0 +1,59 @@
+package statsd
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func listen(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	return conn
+}
+
+func readLines(t *testing.T, conn *net.UDPConn) []string {
+	buf := make([]byte, maxPacketSize)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	return strings.Split(string(buf[:n]), "\n")
+}
+
+func TestRecorderSendsTaggedLines(t *testing.T) {
+	agent := listen(t)
+	defer agent.Close()
+	r, err := New(agent.LocalAddr().String(), WithFlushInterval(time.Hour))
+	require.NoError(t, err)
+	defer r.Close()
+
+	r.RPCStarted("unary", "mwitkow.testproto.TestService", "Ping")
+	r.RPCHandled("unary", "mwitkow.testproto.TestService", "Ping", "OK", 1500*time.Microsecond)
+	require.NoError(t, r.Flush())
+
+	require.Equal(t, []string{
+		"grpc.server.started:1|c|#grpc_type:unary,grpc_service:mwitkow.testproto.TestService,grpc_method:Ping",
+		"grpc.server.handled:1|c|#grpc_type:unary,grpc_service:mwitkow.testproto.TestService,grpc_method:Ping,grpc_code:OK",
+		"grpc.server.handling_time:1.5|ms|#grpc_type:unary,grpc_service:mwitkow.testproto.TestService,grpc_method:Ping",
+	}, readLines(t, agent))
+}
+
+func TestRecorderBoundsTagSets(t *testing.T) {
+	agent := listen(t)
+	defer agent.Close()
+	r, err := New(agent.LocalAddr().String(), WithFlushInterval(time.Hour), WithMaxTagSets(1), WithPrefix("svc."))
+	require.NoError(t, err)
+	defer r.Close()
+
+	r.MsgSent("unary", "mwitkow.testproto.TestService", "Ping")
+	r.MsgSent("unary", "mwitkow.testproto.TestService", "PingError")
+	require.NoError(t, r.Flush())
+
+	require.Equal(t, []string{
+		"svc.msg_sent:1|c|#grpc_type:unary,grpc_service:mwitkow.testproto.TestService,grpc_method:Ping",
+		"svc.msg_sent:1|c|#grpc_type:unary,grpc_service:other,grpc_method:other",
+	}, readLines(t, agent))
+}
@@ -0,0 +1,109 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// WithPhaseHistogram records the time spent in named phases of each RPC, e.g.
+// authentication or request validation, in the grpc_server_phase_seconds
+// histogram. Phases are delimited with MarkPhaseStart and MarkPhaseEnd, from
+// interceptors or handlers running inside the interceptors of m, and their
+// durations are recorded when the RPC ends. This decomposes handling times
+// without wrapping every middleware individually. Phase names must be a small,
+// fixed set, as each is a label value.
+func WithPhaseHistogram(opts ...HistogramOption) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		if m.serverPhaseHistogram != nil {
+			return
+		}
+		histOpts := prom.HistogramOpts{
+			Name:        "grpc_server_phase_seconds",
+			Help:        "Histogram of time (seconds) spent in named phases of gRPC handled by the server.",
+			Buckets:     prom.DefBuckets,
+			ConstLabels: m.counterOpts.apply(prom.CounterOpts{}).ConstLabels,
+		}
+		for _, o := range opts {
+			o(&histOpts)
+		}
+		m.serverPhaseHistogram = m.newHistogramVec(histOpts, []string{"grpc_type", "grpc_service", "grpc_method", "phase"})
+	}
+}
+
+// MarkPhaseStart marks the start of the phase name of the RPC of ctx. It does
+// nothing if ctx is not the context of an RPC monitored with
+// WithPhaseHistogram.
+func MarkPhaseStart(ctx context.Context, name string) {
+	if p, ok := ctx.Value(phasesKey{}).(*phases); ok {
+		p.start(name, time.Now())
+	}
+}
+
+// MarkPhaseEnd marks the end of the phase name of the RPC of ctx, started with
+// MarkPhaseStart. A phase entered several times accumulates its durations.
+// It does nothing if the phase was not started, or if ctx is not the context
+// of an RPC monitored with WithPhaseHistogram.
+func MarkPhaseEnd(ctx context.Context, name string) {
+	if p, ok := ctx.Value(phasesKey{}).(*phases); ok {
+		p.end(name, time.Now())
+	}
+}
+
+type phasesKey struct{}
+
+// phases accumulates the durations of the named phases, or dependencies, of
+// an RPC. Phases still running when the RPC ends are not recorded.
+type phases struct {
+	mu      sync.Mutex
+	started map[string]time.Time
+	elapsed map[string]time.Duration
+	order   []string
+}
+
+func (p *phases) start(name string, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.started == nil {
+		p.started = make(map[string]time.Time)
+	}
+	p.started[name] = now
+}
+
+func (p *phases) end(name string, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	start, ok := p.started[name]
+	if !ok {
+		return
+	}
+	delete(p.started, name)
+	p.addLocked(name, now.Sub(start))
+}
+
+func (p *phases) add(name string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.addLocked(name, d)
+}
+
+func (p *phases) addLocked(name string, d time.Duration) {
+	if p.elapsed == nil {
+		p.elapsed = make(map[string]time.Duration)
+	}
+	if _, ok := p.elapsed[name]; !ok {
+		p.order = append(p.order, name)
+	}
+	p.elapsed[name] += d
+}
+
+// flush records the accumulated phase durations in h.
+func (p *phases) flush(h *histogramVec, rpcType grpcType, serviceName, methodName string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, name := range p.order {
+		h.WithLabelValues(string(rpcType), serviceName, methodName, name).Observe(p.elapsed[name].Seconds())
+	}
+}
@@ -0,0 +1,42 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestPhaseHistogram(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithPhaseHistogram())
+	auth := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		MarkPhaseStart(ctx, "auth")
+		MarkPhaseEnd(ctx, "auth")
+		MarkPhaseStart(ctx, "validation")
+		return handler(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	m.UnaryServerInterceptor()(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return auth(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	})
+	requireValueHistCount(t, 1, m.serverPhaseHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "auth"))
+	// Phases still running at the end of the RPC are not recorded.
+	requireValueHistCount(t, 0, m.serverPhaseHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "validation"))
+
+	streamInfo := &grpc.StreamServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingList", IsServerStream: true}
+	m.StreamServerInterceptor()(nil, &fakeServerStream{ctx: context.Background()}, streamInfo, func(srv interface{}, ss grpc.ServerStream) error {
+		for i := 0; i < 2; i++ {
+			MarkPhaseStart(ss.Context(), "auth")
+			MarkPhaseEnd(ss.Context(), "auth")
+		}
+		return nil
+	})
+	// Repeated phases are recorded once, with their accumulated duration.
+	requireValueHistCount(t, 1, m.serverPhaseHistogram.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "auth"))
+}
+
+func TestMarkPhaseWithoutHistogram(t *testing.T) {
+	MarkPhaseStart(context.Background(), "auth")
+	MarkPhaseEnd(context.Background(), "auth")
+}
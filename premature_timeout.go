@@ -0,0 +1,159 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// clientIDKey identifies the ClientMetrics sending an RPC, so that the
+	// server echoes late completions to the right client.
+	clientIDKey = "x-grpc-prometheus-client-id"
+	// callIDKey identifies an RPC among those of its client.
+	callIDKey = "x-grpc-prometheus-call-id"
+	// lateCompletionTrailerKey carries the call IDs of earlier RPCs of the
+	// client which the server completed successfully after their deadline.
+	lateCompletionTrailerKey = "x-grpc-prometheus-late-ok"
+
+	// maxLateCompletions bounds the late completions remembered per client
+	// by the server, and maxLateClients the number of such clients.
+	maxLateCompletions = 64
+	maxLateClients     = 1024
+	// maxTimedOutCalls bounds the timed out RPCs remembered by a client.
+	maxTimedOutCalls = 1024
+)
+
+// EnableLateCompletionTrailer makes the unary server interceptor remember
+// RPCs which it completed successfully after their deadline was exceeded,
+// and echo them to their client in the trailer of its next RPC, see
+// ClientMetrics.EnablePrematureTimeoutCounter.
+func (m *ServerMetrics) EnableLateCompletionTrailer() {
+	m.checkNotFrozen("EnableLateCompletionTrailer")
+	if m.lateCompletions == nil {
+		m.lateCompletions = &lateCompletions{byClient: make(map[string][]string)}
+	}
+}
+
+// lateCompletions holds, per client ID, the call IDs of RPCs completed
+// successfully after their deadline which weren't echoed yet.
+type lateCompletions struct {
+	mu       sync.Mutex
+	byClient map[string][]string
+}
+
+// handled records the outcome of a unary RPC and attaches the pending late
+// completions of its client to its trailer.
+func (l *lateCompletions) handled(ctx context.Context, code codes.Code) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return
+	}
+	clientIDs, callIDs := md.Get(clientIDKey), md.Get(callIDKey)
+	if len(clientIDs) == 0 || len(callIDs) == 0 {
+		return
+	}
+	clientID := clientIDs[0]
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// The client may have canceled the RPC before the server noticed the
+	// deadline, so the deadline is checked rather than the context error.
+	if deadline, ok := ctx.Deadline(); ok && code == codes.OK && !time.Now().Before(deadline) {
+		pending, known := l.byClient[clientID]
+		if !known && len(l.byClient) >= maxLateClients {
+			return
+		}
+		if len(pending) >= maxLateCompletions {
+			pending = pending[1:]
+		}
+		l.byClient[clientID] = append(pending, callIDs[0])
+		return
+	}
+	if pending := l.byClient[clientID]; len(pending) > 0 {
+		grpc.SetTrailer(ctx, metadata.MD{lateCompletionTrailerKey: pending})
+		delete(l.byClient, clientID)
+	}
+}
+
+// EnablePrematureTimeoutCounter turns on grpc_client_premature_timeouts_total,
+// counting unary RPCs which failed with DeadlineExceeded on the client while
+// the server went on to complete them successfully, pinpointing methods whose
+// client timeouts are too tight. Servers must enable it with
+// ServerMetrics.EnableLateCompletionTrailer. Late completions are echoed in
+// the trailer of the next RPC to the same server, so they are counted with a
+// delay, and lost if no further RPC is made.
+func (m *ClientMetrics) EnablePrematureTimeoutCounter(counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnablePrematureTimeoutCounter")
+	if m.clientPrematureTimeouts != nil {
+		return
+	}
+	m.clientPrematureTimeouts = &prematureTimeouts{
+		counter: prom.NewCounterVec(
+			counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_client_premature_timeouts_total",
+				Help: "Total number of RPCs which exceeded their deadline on the client but were completed successfully by the server.",
+			})), []string{"grpc_service", "grpc_method"}),
+		clientID: newClientID(),
+		timedOut: make(map[string]methodKey),
+	}
+}
+
+// newClientID returns a random ID telling client processes apart. It is read
+// from crypto/rand, as the math/rand global source isn't seeded before Go
+// 1.20, giving all processes the same IDs.
+func newClientID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return strconv.FormatUint(binary.LittleEndian.Uint64(b[:]), 36)
+}
+
+// prematureTimeouts tracks the timed out RPCs of a client until the server
+// reports having completed them.
+type prematureTimeouts struct {
+	counter  *prom.CounterVec
+	clientID string
+	lastID   uint64
+
+	mu       sync.Mutex
+	timedOut map[string]methodKey
+	// order holds the keys of timedOut, oldest first, for eviction.
+	order []string
+}
+
+// start tags the outgoing context of a unary RPC and returns its call ID.
+func (p *prematureTimeouts) start(ctx context.Context) (context.Context, string) {
+	callID := strconv.FormatUint(atomic.AddUint64(&p.lastID, 1), 36)
+	return metadata.AppendToOutgoingContext(ctx, clientIDKey, p.clientID, callIDKey, callID), callID
+}
+
+// handled records the outcome of a unary RPC and counts the late
+// completions echoed in its trailer.
+func (p *prematureTimeouts) handled(service, method, callID string, code codes.Code, trailer metadata.MD) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if code == codes.DeadlineExceeded {
+		if len(p.order) >= maxTimedOutCalls {
+			delete(p.timedOut, p.order[0])
+			p.order = p.order[1:]
+		}
+		p.timedOut[callID] = methodKey{service, method}
+		p.order = append(p.order, callID)
+	}
+	for _, id := range trailer.Get(lateCompletionTrailerKey) {
+		if key, ok := p.timedOut[id]; ok {
+			p.counter.WithLabelValues(key.service, key.method).Inc()
+			delete(p.timedOut, id)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// sleepingTestService honors the sleep time of pings, ignoring cancellation.
+type sleepingTestService struct {
+	testService
+	done chan struct{}
+}
+
+func (s *sleepingTestService) Ping(ctx context.Context, ping *pb_testproto.PingRequest) (*pb_testproto.PingResponse, error) {
+	time.Sleep(time.Duration(ping.SleepTimeMs) * time.Millisecond)
+	if ping.SleepTimeMs > 0 {
+		defer close(s.done)
+	}
+	return s.testService.Ping(ctx, ping)
+}
+
+func TestPrematureTimeoutCounter(t *testing.T) {
+	serverMetrics := NewServerMetrics()
+	serverMetrics.EnableLateCompletionTrailer()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	service := &sleepingTestService{testService: testService{t: t}, done: make(chan struct{})}
+	s := grpc.NewServer(grpc.UnaryInterceptor(serverMetrics.UnaryServerInterceptor()))
+	pb_testproto.RegisterTestServiceServer(s, service)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	clientMetrics := NewClientMetrics()
+	clientMetrics.EnablePrematureTimeoutCounter()
+	cc, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(), grpc.WithUnaryInterceptor(clientMetrics.UnaryClientInterceptor()))
+	require.NoError(t, err)
+	defer cc.Close()
+	client := pb_testproto.NewTestServiceClient(cc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Ping(ctx, &pb_testproto.PingRequest{SleepTimeMs: 200})
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	requireValue(t, 0, clientMetrics.clientPrematureTimeouts.counter.WithLabelValues("mwitkow.testproto.TestService", "Ping"))
+
+	<-service.done
+	// Give the server interceptor time to record the late completion.
+	time.Sleep(50 * time.Millisecond)
+	_, err = client.Ping(context.Background(), &pb_testproto.PingRequest{})
+	require.NoError(t, err)
+	requireValue(t, 1, clientMetrics.clientPrematureTimeouts.counter.WithLabelValues("mwitkow.testproto.TestService", "Ping"))
+
+	_, err = client.Ping(context.Background(), &pb_testproto.PingRequest{})
+	require.NoError(t, err)
+	requireValue(t, 1, clientMetrics.clientPrematureTimeouts.counter.WithLabelValues("mwitkow.testproto.TestService", "Ping"))
+}
+
+func TestPrematureTimeoutClientIDsDiffer(t *testing.T) {
+	a, b := NewClientMetrics(), NewClientMetrics()
+	a.EnablePrematureTimeoutCounter()
+	b.EnablePrematureTimeoutCounter()
+	require.NotEqual(t, a.clientPrematureTimeouts.clientID, b.clientPrematureTimeouts.clientID)
+}
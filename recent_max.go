@@ -0,0 +1,73 @@
+package grpc_prometheus
+
+import (
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// recentMaxBuckets is the number of buckets each sliding window is split into.
+const recentMaxBuckets = 12
+
+// WithRecentMaxHandlingTime turns on the
+// grpc_server_recent_max_handling_seconds gauge, the maximum handling time of
+// each method observed over the sliding window, e.g. 5m. It is intended for
+// systems tuning client timeouts automatically from server-side signals.
+// Methods without RPCs completed within the window are not exported.
+func WithRecentMaxHandlingTime(window time.Duration) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.serverRecentMax = &recentMaxTracker{
+			desc: prom.NewDesc(
+				"grpc_server_recent_max_handling_seconds",
+				"Maximum handling time (seconds) of RPCs completed on the server over a sliding window.",
+				[]string{"grpc_service", "grpc_method"}, m.counterOpts.apply(prom.CounterOpts{}).ConstLabels),
+			window:  window,
+			methods: make(map[methodKey]*slidingMax),
+		}
+	}
+}
+
+type recentMaxTracker struct {
+	desc   *prom.Desc
+	window time.Duration
+
+	mu      sync.Mutex
+	methods map[methodKey]*slidingMax
+}
+
+func (t *recentMaxTracker) observe(service, method string, elapsed time.Duration, now time.Time) {
+	key := methodKey{service, method}
+	t.mu.Lock()
+	s, ok := t.methods[key]
+	if !ok {
+		s = newSlidingMax(t.window, recentMaxBuckets)
+		t.methods[key] = s
+	}
+	t.mu.Unlock()
+	s.observe(now, elapsed.Seconds())
+}
+
+func (t *recentMaxTracker) collect(ch chan<- prom.Metric, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, s := range t.methods {
+		if max, ok := s.max(now); ok {
+			ch <- prom.MustNewConstMetric(t.desc, prom.GaugeValue, max, key.service, key.method)
+		}
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (t *recentMaxTracker) Describe(ch chan<- *prom.Desc) {
+	ch <- t.desc
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (t *recentMaxTracker) Collect(ch chan<- prom.Metric) {
+	t.collect(ch, time.Now())
+}
@@ -0,0 +1,28 @@
+package grpc_prometheus
+
+import (
+	"time"
+)
+
+// Recorder receives the core RPC events observed by the server interceptors,
+// in addition to the Prometheus metrics. It allows bridging the interceptors
+// to other monitoring systems, e.g. statsd, see packages/statsd. Calls happen
+// on the RPC's goroutine, so implementations must be cheap and safe for
+// concurrent use.
+type Recorder interface {
+	// RPCStarted is called when an RPC is started.
+	RPCStarted(rpcType, service, method string)
+	// RPCHandled is called when an RPC completed with the given code after
+	// the given handling time.
+	RPCHandled(rpcType, service, method, code string, handlingTime time.Duration)
+	// MsgReceived is called for each message received by an RPC.
+	MsgReceived(rpcType, service, method string)
+	// MsgSent is called for each message sent by an RPC.
+	MsgSent(rpcType, service, method string)
+}
+
+// WithRecorder additionally reports the RPCs observed by the interceptors of
+// ServerMetrics to r.
+func WithRecorder(r Recorder) ServerMetricsOption {
+	return func(m *ServerMetrics) { m.recorder = r }
+}
@@ -0,0 +1,174 @@
+package grpc_prometheus
+
+import (
+	"sync"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// REDCollector returns a prom.Collector exporting the RPCs handled by m as
+// the three families of RED dashboards, labeled only by grpc_service and
+// grpc_method:
+//
+//	grpc_server_red_requests_total   RPCs completed (rate)
+//	grpc_server_red_errors_total     RPCs completed with a code other than OK
+//	grpc_server_red_duration_seconds handling time of RPCs
+//
+// The families are computed at collection time from grpc_server_handled_total
+// and grpc_server_handling_seconds, nothing is instrumented twice. The
+// duration histogram requires EnableHandlingTimeHistogram. The collector is
+// registered in addition to, or instead of, m.
+func REDCollector(m *ServerMetrics) prom.Collector {
+	constLabels := m.counterOpts.apply(prom.CounterOpts{}).ConstLabels
+	labels := []string{"grpc_service", "grpc_method"}
+	return &redCollector{
+		metrics:      m,
+		requestsDesc: prom.NewDesc("grpc_server_red_requests_total", "Total number of RPCs completed on the server.", labels, constLabels),
+		errorsDesc:   prom.NewDesc("grpc_server_red_errors_total", "Total number of RPCs completed on the server with a code other than OK.", labels, constLabels),
+		durationDesc: prom.NewDesc("grpc_server_red_duration_seconds", "Histogram of response latency (seconds) of gRPC that had been application-level handled by the server.", labels, constLabels),
+		sources:      make(map[*prom.Desc]redSource),
+	}
+}
+
+// redSource is the source family of a collected metric.
+type redSource int
+
+const (
+	redOther redSource = iota
+	redHandled
+	redHistogram
+)
+
+// redCollector derives the RED families from the metrics of a ServerMetrics.
+type redCollector struct {
+	metrics      *ServerMetrics
+	requestsDesc *prom.Desc
+	errorsDesc   *prom.Desc
+	durationDesc *prom.Desc
+
+	mu sync.Mutex
+	// sources are the source families of the descriptors collected so far.
+	// Sharded metrics are held by distinct vectors, with distinct but equal
+	// descriptors.
+	sources map[*prom.Desc]redSource
+}
+
+// redMethod accumulates the source series of a method.
+type redMethod struct {
+	requests, errors float64
+	count            uint64
+	sum              float64
+	buckets          map[float64]uint64
+}
+
+// describe returns the descriptor of a single-family collector.
+func describe(c prom.Collector) *prom.Desc {
+	ch := make(chan *prom.Desc, 1)
+	c.Describe(ch)
+	return <-ch
+}
+
+// source returns the source family of metrics with desc.
+func (c *redCollector) source(desc *prom.Desc) redSource {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.sources[desc]
+	if !ok {
+		switch desc.String() {
+		case describe(c.metrics.serverHandledCounter).String():
+			s = redHandled
+		case describe(prom.NewHistogramVec(c.metrics.serverHandledHistogramOpts, []string{"grpc_type", "grpc_service", "grpc_method"})).String():
+			s = redHistogram
+		}
+		c.sources[desc] = s
+	}
+	return s
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (c *redCollector) Describe(ch chan<- *prom.Desc) {
+	ch <- c.requestsDesc
+	ch <- c.errorsDesc
+	ch <- c.durationDesc
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (c *redCollector) Collect(ch chan<- prom.Metric) {
+	m := c.metrics
+	var cs []prom.Collector
+	if m.serverShards != nil {
+		cs = append(cs, m.serverShards)
+	} else {
+		cs = append(cs, m.serverHandledCounter)
+		if m.serverHandledHistogramEnabled {
+			cs = append(cs, m.serverHandledHistogram)
+		}
+	}
+	metrics := make(chan prom.Metric)
+	go func() {
+		for _, c := range cs {
+			c.Collect(metrics)
+		}
+		close(metrics)
+	}()
+
+	methods := make(map[methodKey]*redMethod)
+	histograms := false
+	for metric := range metrics {
+		s := c.source(metric.Desc())
+		if s == redOther {
+			continue
+		}
+		var pb dto.Metric
+		if err := metric.Write(&pb); err != nil {
+			continue
+		}
+		var key methodKey
+		code := ""
+		for _, lp := range pb.GetLabel() {
+			switch lp.GetName() {
+			case "grpc_service":
+				key.service = lp.GetValue()
+			case "grpc_method":
+				key.method = lp.GetValue()
+			case "grpc_code":
+				code = lp.GetValue()
+			}
+		}
+		r, ok := methods[key]
+		if !ok {
+			r = &redMethod{}
+			methods[key] = r
+		}
+		if s == redHandled {
+			r.requests += pb.GetCounter().GetValue()
+			if code != "OK" {
+				r.errors += pb.GetCounter().GetValue()
+			}
+			continue
+		}
+		histograms = true
+		h := pb.GetHistogram()
+		r.count += h.GetSampleCount()
+		r.sum += h.GetSampleSum()
+		if r.buckets == nil {
+			r.buckets = make(map[float64]uint64, len(h.GetBucket()))
+		}
+		for _, b := range h.GetBucket() {
+			r.buckets[b.GetUpperBound()] += b.GetCumulativeCount()
+		}
+	}
+
+	for key, r := range methods {
+		ch <- prom.MustNewConstMetric(c.requestsDesc, prom.CounterValue, r.requests, key.service, key.method)
+		ch <- prom.MustNewConstMetric(c.errorsDesc, prom.CounterValue, r.errors, key.service, key.method)
+		if histograms {
+			ch <- prom.MustNewConstHistogram(c.durationDesc, r.count, r.sum, r.buckets, key.service, key.method)
+		}
+	}
+}
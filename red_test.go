@@ -0,0 +1,64 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gatherRED returns the metrics of REDCollector(m) by family and method,
+// labels being sorted by name.
+func gatherRED(t *testing.T, m *ServerMetrics) map[string]map[string]*dto.Metric {
+	reg := prom.NewRegistry()
+	require.NoError(t, reg.Register(REDCollector(m)))
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	families := map[string]map[string]*dto.Metric{}
+	for _, mf := range mfs {
+		families[mf.GetName()] = map[string]*dto.Metric{}
+		for _, metric := range mf.GetMetric() {
+			require.Len(t, metric.GetLabel(), 2)
+			families[mf.GetName()][metric.GetLabel()[0].GetValue()] = metric
+		}
+	}
+	return families
+}
+
+func TestREDCollector(t *testing.T) {
+	for _, opts := range [][]ServerMetricsOption{nil, {WithShardedCollectors()}} {
+		m := NewServerMetrics()
+		m.Configure(opts...)
+		m.EnableHandlingTimeHistogram()
+		call := func(method string, err error) {
+			info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/" + method}
+			m.UnaryServerInterceptor()(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) { return nil, err })
+		}
+		call("Ping", nil)
+		call("Ping", nil)
+		call("Ping", status.Error(codes.Internal, ""))
+		call("PingEmpty", nil)
+
+		families := gatherRED(t, m)
+		require.Equal(t, 3.0, families["grpc_server_red_requests_total"]["Ping"].GetCounter().GetValue())
+		require.Equal(t, 1.0, families["grpc_server_red_errors_total"]["Ping"].GetCounter().GetValue())
+		require.Equal(t, uint64(3), families["grpc_server_red_duration_seconds"]["Ping"].GetHistogram().GetSampleCount())
+		require.Equal(t, 1.0, families["grpc_server_red_requests_total"]["PingEmpty"].GetCounter().GetValue())
+		require.Equal(t, 0.0, families["grpc_server_red_errors_total"]["PingEmpty"].GetCounter().GetValue())
+	}
+}
+
+func TestREDCollectorWithoutHistogram(t *testing.T) {
+	m := NewServerMetrics()
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	m.UnaryServerInterceptor()(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+
+	families := gatherRED(t, m)
+	require.Equal(t, 1.0, families["grpc_server_red_requests_total"]["Ping"].GetCounter().GetValue())
+	require.NotContains(t, families, "grpc_server_red_duration_seconds")
+}
@@ -0,0 +1,69 @@
+package grpc_prometheus
+
+import (
+	"google.golang.org/grpc"
+)
+
+// ServiceInfoProvider is implemented by servers exposing the services
+// registered on them, such as *grpc.Server.
+type ServiceInfoProvider interface {
+	GetServiceInfo() map[string]grpc.ServiceInfo
+}
+
+// ServiceRegistrar is implemented by servers services are registered on,
+// such as *grpc.Server, and facades around them.
+type ServiceRegistrar interface {
+	RegisterService(desc *grpc.ServiceDesc, impl interface{})
+}
+
+// ServiceDescs returns a ServiceInfoProvider exposing the services of descs,
+// to initialize metrics with InitializeMetrics when the server they are
+// registered on is not available.
+func ServiceDescs(descs ...*grpc.ServiceDesc) ServiceInfoProvider {
+	info := make(serviceInfoMap, len(descs))
+	for _, desc := range descs {
+		info[desc.ServiceName] = serviceInfoFromDesc(desc)
+	}
+	return info
+}
+
+type serviceInfoMap map[string]grpc.ServiceInfo
+
+func (m serviceInfoMap) GetServiceInfo() map[string]grpc.ServiceInfo {
+	return m
+}
+
+// serviceInfoFromDesc returns the grpc.ServiceInfo of desc, as
+// grpc.Server.GetServiceInfo does.
+func serviceInfoFromDesc(desc *grpc.ServiceDesc) grpc.ServiceInfo {
+	methods := make([]grpc.MethodInfo, 0, len(desc.Methods)+len(desc.Streams))
+	for _, method := range desc.Methods {
+		methods = append(methods, grpc.MethodInfo{Name: method.MethodName})
+	}
+	for _, stream := range desc.Streams {
+		methods = append(methods, grpc.MethodInfo{
+			Name:           stream.StreamName,
+			IsClientStream: stream.ClientStreams,
+			IsServerStream: stream.ServerStreams,
+		})
+	}
+	return grpc.ServiceInfo{Methods: methods, Metadata: desc.Metadata}
+}
+
+// WrapRegistrar returns a ServiceRegistrar forwarding registrations to r and
+// initializing the metrics of each service as it is registered, as
+// InitializeMetrics does. Register services on it when the server is hidden
+// behind a facade InitializeMetrics can't be called with.
+func (m *ServerMetrics) WrapRegistrar(r ServiceRegistrar) ServiceRegistrar {
+	return &initializingRegistrar{r, m}
+}
+
+type initializingRegistrar struct {
+	ServiceRegistrar
+	metrics *ServerMetrics
+}
+
+func (r *initializingRegistrar) RegisterService(desc *grpc.ServiceDesc, impl interface{}) {
+	r.ServiceRegistrar.RegisterService(desc, impl)
+	r.metrics.initializeService(desc.ServiceName, serviceInfoFromDesc(desc))
+}
@@ -0,0 +1,52 @@
+package grpc_prometheus
+
+import (
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+var registrarTestDesc = &grpc.ServiceDesc{
+	ServiceName: "grpc_prometheus.test.RegistrarService",
+	HandlerType: (*interface{})(nil),
+	Methods:     []grpc.MethodDesc{{MethodName: "Get"}},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Watch", ServerStreams: true},
+		{StreamName: "Sync", ClientStreams: true, ServerStreams: true},
+	},
+	Metadata: "registrar.proto",
+}
+
+func TestServiceDescsMatchesServer(t *testing.T) {
+	s := grpc.NewServer()
+	s.RegisterService(registrarTestDesc, struct{}{})
+	want, got := s.GetServiceInfo(), ServiceDescs(registrarTestDesc).GetServiceInfo()
+	require.Len(t, got, len(want))
+	for name, info := range want {
+		// grpc.Server lists streams in no particular order.
+		require.ElementsMatch(t, info.Methods, got[name].Methods)
+		require.Equal(t, info.Metadata, got[name].Metadata)
+	}
+}
+
+type fakeRegistrar struct {
+	descs []*grpc.ServiceDesc
+}
+
+func (r *fakeRegistrar) RegisterService(desc *grpc.ServiceDesc, impl interface{}) {
+	r.descs = append(r.descs, desc)
+}
+
+func TestWrapRegistrar(t *testing.T) {
+	m := NewServerMetrics()
+	inner := &fakeRegistrar{}
+	m.WrapRegistrar(inner).RegisterService(registrarTestDesc, struct{}{})
+
+	require.Equal(t, []*grpc.ServiceDesc{registrarTestDesc}, inner.descs)
+	reg := prom.NewPedanticRegistry()
+	reg.MustRegister(m)
+	// One series per method and code.
+	require.Equal(t, map[string]int{"grpc_prometheus.test.RegistrarService": 3 * len(allCodes)}, handledSeries(t, reg))
+}
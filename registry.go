@@ -0,0 +1,115 @@
+package grpc_prometheus
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// UniqueNameSuffix is inserted into metric names by WithUniqueNameSuffix and
+// WithHistogramUniqueNameSuffix.
+const UniqueNameSuffix = "fork"
+
+// unitSuffixes are kept at the end of metric names made unique.
+var unitSuffixes = []string{"_total", "_seconds", "_bytes", "_ratio"}
+
+// WithUniqueNameSuffix inserts UniqueNameSuffix into the names of Counter
+// metrics, before their unit, e.g. grpc_server_started_fork_total. This
+// allows registering the metrics of this package on a registry which already
+// holds metrics of the same name, typically because another copy of
+// go-grpc-prometheus is linked into the binary through a transitive
+// dependency.
+func WithUniqueNameSuffix() CounterOption {
+	return func(o *prom.CounterOpts) { o.Name = uniqueName(o.Name) }
+}
+
+// WithHistogramUniqueNameSuffix is the WithUniqueNameSuffix of histograms,
+// e.g. grpc_server_handling_fork_seconds.
+func WithHistogramUniqueNameSuffix() HistogramOption {
+	return func(o *prom.HistogramOpts) { o.Name = uniqueName(o.Name) }
+}
+
+func uniqueName(name string) string {
+	for _, unit := range unitSuffixes {
+		if strings.HasSuffix(name, unit) {
+			return strings.TrimSuffix(name, unit) + "_" + UniqueNameSuffix + unit
+		}
+	}
+	return name + "_" + UniqueNameSuffix
+}
+
+var (
+	defaultRegistrationMu     sync.Mutex
+	defaultRegistrationErrors []error
+)
+
+// registerDefault registers the collectors of the default metrics with the
+// default registry. Failures are recorded for DefaultRegistrationErrors
+// rather than panicking at init, as they are typically caused by another copy
+// of go-grpc-prometheus linked into the binary.
+func registerDefault(cs ...prom.Collector) {
+	defaultRegistrationMu.Lock()
+	defer defaultRegistrationMu.Unlock()
+	for _, c := range cs {
+		err := prom.Register(c)
+		if are, ok := err.(prom.AlreadyRegisteredError); ok && are.ExistingCollector == c {
+			continue
+		}
+		if err != nil {
+			defaultRegistrationErrors = append(defaultRegistrationErrors, fmt.Errorf(
+				"grpc_prometheus: registering default metrics failed, most likely because another copy of go-grpc-prometheus "+
+					"registered metrics of the same name; register metrics created with WithUniqueNameSuffix instead of "+
+					"using the default metrics: %v", err))
+		}
+	}
+}
+
+// DefaultRegistrationErrors returns the errors of registering the metrics of
+// DefaultServerMetrics and DefaultClientMetrics with the default Prometheus
+// registry, e.g. at init. Metrics which failed to register are not exported.
+// Applications linking several copies of go-grpc-prometheus should check it
+// at startup.
+func DefaultRegistrationErrors() []error {
+	defaultRegistrationMu.Lock()
+	defer defaultRegistrationMu.Unlock()
+	return append([]error(nil), defaultRegistrationErrors...)
+}
+
+// RegisterAll registers c, e.g. a ServerMetrics or ClientMetrics, with each of
+// the given registerers, such as a per-tenant registry and a global one. All
+// collectors of this package are safe to be collected by several registries
+// concurrently. If c is already registered with one of the registerers, that
+// registration is kept. If registering fails otherwise, c is unregistered
+// again from the registerers it was newly registered with, so that either
+// all or none of the registrations take place.
+func RegisterAll(c prom.Collector, registerers ...prom.Registerer) error {
+	var registered []prom.Registerer
+	for _, r := range registerers {
+		err := r.Register(c)
+		if are, ok := err.(prom.AlreadyRegisteredError); ok && are.ExistingCollector == c {
+			continue
+		}
+		if err != nil {
+			for _, rr := range registered {
+				rr.Unregister(c)
+			}
+			return err
+		}
+		registered = append(registered, r)
+	}
+	return nil
+}
+
+// UnregisterAll unregisters c from each of the given registerers. It returns
+// whether c was unregistered from all of them.
+func UnregisterAll(c prom.Collector, registerers ...prom.Registerer) bool {
+	all := true
+	for _, r := range registerers {
+		if !r.Unregister(c) {
+			all = false
+		}
+	}
+	return all
+}
@@ -0,0 +1,66 @@
+package grpc_prometheus
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterAll(t *testing.T) {
+	global, tenant := prometheus.NewRegistry(), prometheus.NewRegistry()
+	m := NewServerMetrics()
+	require.NoError(t, global.Register(m))
+	require.NoError(t, RegisterAll(m, global, tenant), "registration with global must be kept")
+
+	m.serverStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping").Inc()
+	for _, g := range []prometheus.Gatherer{global, tenant} {
+		mfs, err := g.Gather()
+		require.NoError(t, err)
+		require.NotEmpty(t, mfs)
+	}
+	require.True(t, UnregisterAll(m, global, tenant))
+}
+
+func TestRegisterAllRollsBack(t *testing.T) {
+	first, conflicting := prometheus.NewRegistry(), prometheus.NewRegistry()
+	require.NoError(t, conflicting.Register(NewServerMetrics()))
+	m := NewServerMetrics()
+	require.Error(t, RegisterAll(m, first, conflicting))
+	require.False(t, first.Unregister(m), "failed RegisterAll must not leave partial registrations")
+}
+
+func TestWithUniqueNameSuffix(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(NewServerMetrics()))
+	m := NewServerMetrics(WithUniqueNameSuffix())
+	m.EnableHandlingTimeHistogram(WithHistogramUniqueNameSuffix())
+	require.NoError(t, reg.Register(m), "metrics with unique names must not conflict")
+
+	m.serverStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping").Inc()
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	var names []string
+	for _, mf := range mfs {
+		names = append(names, mf.GetName())
+	}
+	require.Contains(t, names, "grpc_server_started_fork_total")
+	require.Contains(t, names, "grpc_prometheus_build_info_fork")
+	require.Equal(t, "grpc_server_handling_fork_seconds", uniqueName("grpc_server_handling_seconds"))
+}
+
+func TestRegisterDefaultRecordsConflicts(t *testing.T) {
+	conflicting := prometheus.NewCounter(prometheus.CounterOpts{Name: "grpc_server_started_fork_total", Help: "Registered by another copy."})
+	require.NoError(t, prometheus.Register(conflicting))
+	defer prometheus.Unregister(conflicting)
+	before := len(DefaultRegistrationErrors())
+
+	registerDefault(NewServerMetrics(WithUniqueNameSuffix()).serverStartedCounter)
+	errs := DefaultRegistrationErrors()
+	require.Len(t, errs, before+1)
+	require.Contains(t, errs[before].Error(), "WithUniqueNameSuffix")
+
+	defaultRegistrationMu.Lock()
+	defaultRegistrationErrors = defaultRegistrationErrors[:before]
+	defaultRegistrationMu.Unlock()
+}
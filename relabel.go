@@ -0,0 +1,102 @@
+package grpc_prometheus
+
+import (
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// RelabelRule rewrites one label of the metrics collected by a
+// RelabeledCollector. Values are mapped first, then the label is renamed or
+// dropped.
+type RelabelRule struct {
+	// Label is the name of the label the rule applies to.
+	Label string
+	// MapValues replaces label values found in it. Other values are kept.
+	MapValues map[string]string
+	// RenameTo, if not empty, is the new name of the label.
+	RenameTo string
+	// Drop removes the label. Only drop labels which do not distinguish
+	// series of a metric, e.g. constant labels, as the collection fails on
+	// duplicate series otherwise.
+	Drop bool
+}
+
+// RelabeledCollector wraps c, e.g. a ServerMetrics or ClientMetrics, applying
+// rules in order to the labels of every metric it collects. This adapts the
+// metrics of this package to organization-wide naming policies without
+// touching application code or Prometheus relabel configs.
+//
+// The descriptors of the wrapped collector no longer match the relabeled
+// metrics, so the returned collector is unchecked: it describes no metrics
+// and the registry skips its registration-time consistency checks.
+func RelabeledCollector(c prom.Collector, rules []RelabelRule) prom.Collector {
+	return &relabeledCollector{c, rules}
+}
+
+type relabeledCollector struct {
+	collector prom.Collector
+	rules     []RelabelRule
+}
+
+// Describe sends no descriptors, making this an unchecked Collector.
+func (c *relabeledCollector) Describe(ch chan<- *prom.Desc) {}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (c *relabeledCollector) Collect(ch chan<- prom.Metric) {
+	in := make(chan prom.Metric)
+	go func() {
+		c.collector.Collect(in)
+		close(in)
+	}()
+	for m := range in {
+		ch <- &relabeledMetric{m, c.rules}
+	}
+}
+
+// relabeledMetric wraps a prom.Metric, rewriting its labels on Write.
+type relabeledMetric struct {
+	prom.Metric
+	rules []RelabelRule
+}
+
+func (m *relabeledMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	out.Label = relabel(out.Label, m.rules)
+	return nil
+}
+
+// relabel returns labels rewritten by rules. The label pairs written by
+// client_golang metrics are shared with the metric, so they are copied rather
+// than modified.
+func relabel(labels []*dto.LabelPair, rules []RelabelRule) []*dto.LabelPair {
+	for _, rule := range rules {
+		relabeled := make([]*dto.LabelPair, 0, len(labels))
+		for _, l := range labels {
+			if l.GetName() != rule.Label {
+				relabeled = append(relabeled, l)
+				continue
+			}
+			if rule.Drop {
+				continue
+			}
+			name, value := l.GetName(), l.GetValue()
+			if v, ok := rule.MapValues[value]; ok {
+				value = v
+			}
+			if rule.RenameTo != "" {
+				name = rule.RenameTo
+			}
+			relabeled = append(relabeled, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+		}
+		labels = relabeled
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+	return labels
+}
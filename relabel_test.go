@@ -0,0 +1,28 @@
+package grpc_prometheus
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelabeledCollector(t *testing.T) {
+	m := NewServerMetrics()
+	m.serverStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping").Inc()
+	c := RelabeledCollector(m, []RelabelRule{
+		{Label: "grpc_service", RenameTo: "service"},
+		{Label: "grpc_type", Drop: true},
+		{Label: "grpc_method", MapValues: map[string]string{"Ping": "ping"}},
+	})
+
+	expected := `
+# HELP grpc_server_started_total Total number of RPCs started on the server.
+# TYPE grpc_server_started_total counter
+grpc_server_started_total{grpc_method="ping",service="mwitkow.testproto.TestService"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected), "grpc_server_started_total"))
+	// The wrapped metrics themselves must be left untouched.
+	requireValue(t, 1, m.serverStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+}
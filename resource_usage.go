@@ -0,0 +1,37 @@
+package grpc_prometheus
+
+import "sync/atomic"
+
+// resourceUsage is a snapshot of process-wide cumulative resource usage.
+type resourceUsage struct {
+	cpuSeconds float64
+	allocBytes float64
+}
+
+// resourceAccounting attributes process-wide CPU time and heap allocations to
+// RPCs. Runtime metrics are not per goroutine, so each RPC is attributed the
+// process-wide usage during its handling divided by the number of RPCs in
+// flight when it finished. This is a coarse approximation, and reading
+// runtime metrics twice per RPC is expensive.
+type resourceAccounting struct {
+	cpuCounter   *counterVec
+	allocCounter *counterVec
+	inFlight     int64 // accessed atomically
+}
+
+func (a *resourceAccounting) start() resourceUsage {
+	atomic.AddInt64(&a.inFlight, 1)
+	return readResourceUsage()
+}
+
+func (a *resourceAccounting) finish(start resourceUsage, labelValues ...string) {
+	end := readResourceUsage()
+	inFlight := atomic.AddInt64(&a.inFlight, -1) + 1
+	share := 1 / float64(inFlight)
+	if d := end.cpuSeconds - start.cpuSeconds; d > 0 {
+		a.cpuCounter.WithLabelValues(labelValues...).Add(d * share)
+	}
+	if d := end.allocBytes - start.allocBytes; d > 0 {
+		a.allocCounter.WithLabelValues(labelValues...).Add(d * share)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !go1.20
+// +build !go1.20
+
+package grpc_prometheus
+
+const resourceAccountingSupported = false
+
+func readResourceUsage() resourceUsage {
+	return resourceUsage{}
+}
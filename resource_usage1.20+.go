@@ -0,0 +1,34 @@
+//go:build go1.20
+// +build go1.20
+
+package grpc_prometheus
+
+import (
+	"runtime/metrics"
+)
+
+const resourceAccountingSupported = true
+
+var resourceUsageMetrics = []string{
+	"/cpu/classes/user:cpu-seconds",
+	"/gc/heap/allocs:bytes",
+}
+
+// readResourceUsage returns the process-wide CPU time spent running Go code
+// and the cumulative bytes allocated on the heap. The runtime only refreshes
+// the CPU time at each GC cycle.
+func readResourceUsage() resourceUsage {
+	samples := make([]metrics.Sample, len(resourceUsageMetrics))
+	for i, name := range resourceUsageMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	var u resourceUsage
+	if samples[0].Value.Kind() == metrics.KindFloat64 {
+		u.cpuSeconds = samples[0].Value.Float64()
+	}
+	if samples[1].Value.Kind() == metrics.KindUint64 {
+		u.allocBytes = float64(samples[1].Value.Uint64())
+	}
+	return u
+}
@@ -0,0 +1,35 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+var allocSink []byte
+
+func TestExpensiveResourceAccounting(t *testing.T) {
+	m := NewServerMetrics()
+	if !m.EnableExpensiveResourceAccounting() {
+		require.Nil(t, m.serverResourceAccounting)
+		t.Skip("resource accounting needs Go 1.20")
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	m.UnaryServerInterceptor()(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		for i := 0; i < 100; i++ {
+			allocSink = make([]byte, 1<<20)
+		}
+		// The runtime refreshes CPU time at GC.
+		runtime.GC()
+		return nil, nil
+	})
+
+	a := m.serverResourceAccounting
+	require.True(t, testutil.ToFloat64(a.allocCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping")) >= 100<<20)
+	require.True(t, testutil.ToFloat64(a.cpuCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping")) > 0)
+	require.Equal(t, int64(0), a.inFlight)
+}
@@ -0,0 +1,65 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+)
+
+// EnableResponseItemsHistogram turns on recording the number of items sent in
+// each streaming RPC as the grpc_server_response_items histogram. Handlers
+// count items with AddItems. For streams where AddItems is never called, the
+// number of sent messages is recorded instead, which differs from the number
+// of items when messages carry batches of items.
+func (m *ServerMetrics) EnableResponseItemsHistogram(opts ...HistogramOption) {
+	m.checkNotFrozen("EnableResponseItemsHistogram")
+	for _, o := range opts {
+		o(&m.serverResponseItemsHistogramOpts)
+	}
+	if !m.serverResponseItemsHistogramEnabled {
+		m.serverResponseItemsHistogram = m.newHistogramVec(
+			m.serverResponseItemsHistogramOpts,
+			[]string{"grpc_type", "grpc_service", "grpc_method"},
+		)
+	}
+	m.serverResponseItemsHistogramEnabled = true
+	m.publishToggles()
+}
+
+// AddItems counts n items sent in the streaming RPC of ctx, for the
+// histogram enabled with EnableResponseItemsHistogram. It does nothing if ctx
+// is not the context of a stream monitored with that histogram enabled.
+func AddItems(ctx context.Context, n int) {
+	if c, ok := ctx.Value(responseItemsKey{}).(*responseItems); ok {
+		atomic.StoreInt32(&c.explicit, 1)
+		atomic.AddInt64(&c.items, int64(n))
+	}
+}
+
+type responseItemsKey struct{}
+
+// responseItems counts the items of a stream, accessed atomically.
+type responseItems struct {
+	items    int64
+	messages int64
+	explicit int32
+}
+
+func (c *responseItems) count() int64 {
+	if atomic.LoadInt32(&c.explicit) != 0 {
+		return atomic.LoadInt64(&c.items)
+	}
+	return atomic.LoadInt64(&c.messages)
+}
+
+// responseItemsServerStream wraps grpc.ServerStream to expose the item count
+// of the stream through its context.
+type responseItemsServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *responseItemsServerStream) Context() context.Context {
+	return s.ctx
+}
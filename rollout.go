@@ -0,0 +1,68 @@
+package grpc_prometheus
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// RolloutLabel is the name of the constant label added by WithRolloutLabel.
+const RolloutLabel = "rollout"
+
+// rolloutValueRegexp restricts rollout values to short identifiers such as
+// "canary", "stable", "blue" or a release name.
+var rolloutValueRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,62}$`)
+
+// WithRolloutLabel adds the constant label rollout="<value>" to Counter
+// metrics, so that error rates of canary and stable deployments can be
+// compared. It is merged with the labels of WithConstLabels, which must come
+// first. An empty value adds no label, so RolloutFromEnv can be passed as is.
+// It panics if value is not a short identifier.
+func WithRolloutLabel(value string) CounterOption {
+	labels := rolloutLabels(value)
+	return func(o *prom.CounterOpts) {
+		o.ConstLabels = mergeLabels(o.ConstLabels, labels)
+	}
+}
+
+// WithHistogramRolloutLabel is the equivalent of WithRolloutLabel for
+// histograms, to compare latencies of canary and stable deployments.
+func WithHistogramRolloutLabel(value string) HistogramOption {
+	labels := rolloutLabels(value)
+	return func(o *prom.HistogramOpts) {
+		o.ConstLabels = mergeLabels(o.ConstLabels, labels)
+	}
+}
+
+// RolloutFromEnv returns the rollout of this process from the environment
+// variable key, e.g. set by the deployment to "canary" or "stable".
+func RolloutFromEnv(key string) string {
+	return os.Getenv(key)
+}
+
+func rolloutLabels(value string) prom.Labels {
+	if value == "" {
+		return nil
+	}
+	if !rolloutValueRegexp.MatchString(value) {
+		panic(fmt.Sprintf("grpc_prometheus: invalid rollout label value %q", value))
+	}
+	return prom.Labels{RolloutLabel: value}
+}
+
+// mergeLabels returns the union of a and b, without modifying either.
+func mergeLabels(a, b prom.Labels) prom.Labels {
+	if len(b) == 0 {
+		return a
+	}
+	merged := make(prom.Labels, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
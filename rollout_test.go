@@ -0,0 +1,22 @@
+package grpc_prometheus
+
+import (
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRolloutLabel(t *testing.T) {
+	os.Setenv("TEST_GRPC_ROLLOUT", "canary")
+	defer os.Unsetenv("TEST_GRPC_ROLLOUT")
+
+	opts := counterOptions{WithConstLabels(prometheus.Labels{"env": "prod"}), WithRolloutLabel(RolloutFromEnv("TEST_GRPC_ROLLOUT"))}.apply(prometheus.CounterOpts{})
+	require.Equal(t, prometheus.Labels{"env": "prod", "rollout": "canary"}, opts.ConstLabels)
+
+	opts = counterOptions{WithRolloutLabel("")}.apply(prometheus.CounterOpts{})
+	require.Empty(t, opts.ConstLabels)
+
+	require.Panics(t, func() { WithRolloutLabel("canary v2") })
+}
@@ -0,0 +1,169 @@
+package grpc_prometheus
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// serverRuntimeConfig holds the settings of ServerMetrics that can change
+// after registration, and the toggles of the metrics enabled before it. A
+// serverRuntimeConfig is never modified once stored: updates store a modified
+// copy, so that an RPC loads a single pointer and sees a consistent set of
+// settings for its whole duration.
+type serverRuntimeConfig struct {
+	serverToggles
+
+	handlingTimeHistogramPaused bool
+	msgSizeMetricsPaused        bool
+	slowHandlingThreshold       time.Duration
+}
+
+// serverToggles are the switches of the metrics of ServerMetrics read by its
+// interceptors. Enable* and Disable* publish them in the runtime config after
+// creating the metrics they switch on, so that RPCs handled concurrently,
+// e.g. by a server started before the metrics are registered, see either
+// none or both.
+type serverToggles struct {
+	startedCounterDisabled           bool
+	msgCountersDisabled              bool
+	handledHistogramEnabled          bool
+	slowHandledCounterEnabled        bool
+	recvSizeRatioHistogramEnabled    bool
+	handledSummaryEnabled            bool
+	handledOverflowCounterEnabled    bool
+	longTermHandledCounterEnabled    bool
+	clockSkewTrailerEnabled          bool
+	cancellationCounterEnabled       bool
+	gcOverlapCounterEnabled          bool
+	failedSecondsCounterEnabled      bool
+	responseItemsHistogramEnabled    bool
+	headerProcessingHistogramEnabled bool
+	msgSizeReceivedHistogramEnabled  bool
+	msgSizeSentHistogramEnabled      bool
+	interceptorHistogramEnabled      bool
+}
+
+// publishToggles stores the current toggles of m in its runtime config.
+func (m *ServerMetrics) publishToggles() {
+	t := serverToggles{
+		startedCounterDisabled:           m.serverStartedCounterDisabled,
+		msgCountersDisabled:              m.serverMsgCountersDisabled,
+		handledHistogramEnabled:          m.serverHandledHistogramEnabled,
+		slowHandledCounterEnabled:        m.serverSlowHandledCounterEnabled,
+		recvSizeRatioHistogramEnabled:    m.serverRecvSizeRatioHistogramEnabled,
+		handledSummaryEnabled:            m.serverHandledSummaryEnabled,
+		handledOverflowCounterEnabled:    m.serverHandledOverflowCounterEnabled,
+		longTermHandledCounterEnabled:    m.serverLongTermHandledCounterEnabled,
+		clockSkewTrailerEnabled:          m.clockSkewTrailerEnabled,
+		cancellationCounterEnabled:       m.serverCancellationCounterEnabled,
+		gcOverlapCounterEnabled:          m.serverGCOverlapCounterEnabled,
+		failedSecondsCounterEnabled:      m.serverFailedSecondsCounterEnabled,
+		responseItemsHistogramEnabled:    m.serverResponseItemsHistogramEnabled,
+		headerProcessingHistogramEnabled: m.serverHeaderProcessingHistogramEnabled,
+		msgSizeReceivedHistogramEnabled:  m.serverMsgSizeReceivedHistogramEnabled,
+		msgSizeSentHistogramEnabled:      m.serverMsgSizeSentHistogramEnabled,
+		interceptorHistogramEnabled:      m.serverInterceptorHistogramEnabled,
+	}
+	m.updateRuntimeConfig(func(c *serverRuntimeConfig) { c.serverToggles = t })
+}
+
+var defaultServerRuntimeConfig = &serverRuntimeConfig{}
+
+// runtimeConfigStore is a copy-on-write store of a runtime config. Reads are
+// lock-free, updates are serialized so none of them is lost.
+type runtimeConfigStore struct {
+	mu sync.Mutex
+	v  atomic.Value
+}
+
+// load returns the current config, or nil if none was stored yet.
+func (s *runtimeConfigStore) load() interface{} {
+	return s.v.Load()
+}
+
+// update stores the config returned by f for the current one.
+func (s *runtimeConfigStore) update(f func(cur interface{}) interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.v.Store(f(s.v.Load()))
+}
+
+// runtimeConfig returns the current runtime config of m.
+func (m *ServerMetrics) runtimeConfig() *serverRuntimeConfig {
+	if c, ok := m.runtime.load().(*serverRuntimeConfig); ok {
+		return c
+	}
+	return defaultServerRuntimeConfig
+}
+
+// updateRuntimeConfig atomically replaces the runtime config of m with a copy
+// modified by f.
+func (m *ServerMetrics) updateRuntimeConfig(f func(c *serverRuntimeConfig)) {
+	m.runtime.update(func(cur interface{}) interface{} {
+		c := *defaultServerRuntimeConfig
+		if cur != nil {
+			c = *cur.(*serverRuntimeConfig)
+		}
+		f(&c)
+		return &c
+	})
+}
+
+// clientRuntimeConfig holds the settings of ClientMetrics that can change
+// after registration, and the toggles of the metrics enabled before it,
+// following the same rules as serverRuntimeConfig.
+type clientRuntimeConfig struct {
+	clientToggles
+
+	streamHistogramsPaused bool
+}
+
+// clientToggles are the switches of the metrics of ClientMetrics read by its
+// interceptors, published like serverToggles.
+type clientToggles struct {
+	startedCounterDisabled            bool
+	msgCountersDisabled               bool
+	handledHistogramEnabled           bool
+	streamRecvHistogramEnabled        bool
+	streamSendHistogramEnabled        bool
+	longTermHandledCounterEnabled     bool
+	deadlineRemainingHistogramEnabled bool
+}
+
+// publishToggles stores the current toggles of m in its runtime config.
+func (m *ClientMetrics) publishToggles() {
+	t := clientToggles{
+		startedCounterDisabled:            m.clientStartedCounterDisabled,
+		msgCountersDisabled:               m.clientMsgCountersDisabled,
+		handledHistogramEnabled:           m.clientHandledHistogramEnabled,
+		streamRecvHistogramEnabled:        m.clientStreamRecvHistogramEnabled,
+		streamSendHistogramEnabled:        m.clientStreamSendHistogramEnabled,
+		longTermHandledCounterEnabled:     m.clientLongTermHandledCounterEnabled,
+		deadlineRemainingHistogramEnabled: m.clientDeadlineRemainingHistogramEnabled,
+	}
+	m.updateRuntimeConfig(func(c *clientRuntimeConfig) { c.clientToggles = t })
+}
+
+var defaultClientRuntimeConfig = &clientRuntimeConfig{}
+
+// runtimeConfig returns the current runtime config of m.
+func (m *ClientMetrics) runtimeConfig() *clientRuntimeConfig {
+	if c, ok := m.runtime.load().(*clientRuntimeConfig); ok {
+		return c
+	}
+	return defaultClientRuntimeConfig
+}
+
+// updateRuntimeConfig atomically replaces the runtime config of m with a copy
+// modified by f.
+func (m *ClientMetrics) updateRuntimeConfig(f func(c *clientRuntimeConfig)) {
+	m.runtime.update(func(cur interface{}) interface{} {
+		c := *defaultClientRuntimeConfig
+		if cur != nil {
+			c = *cur.(*clientRuntimeConfig)
+		}
+		f(&c)
+		return &c
+	})
+}
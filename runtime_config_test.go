@@ -0,0 +1,117 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func runtimeConfigTestCall(m *ServerMetrics) {
+	m.UnaryServerInterceptor()(context.Background(), &pb_testproto.PingRequest{Value: "ping"}, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return &pb_testproto.PingResponse{}, nil
+		})
+}
+
+func TestRuntimeConfigConcurrentUpdates(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableHandlingTimeHistogram()
+	m.EnableSlowHandlingCounter(time.Second)
+	m.Configure(WithLargeMessageThreshold(0))
+
+	const iterations = 200
+	var wg sync.WaitGroup
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			m.SetHandlingTimeHistogramActive(i%2 == 1)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= iterations; i++ {
+			m.SetSlowHandlingThreshold(time.Duration(i) * time.Millisecond)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			runtimeConfigTestCall(m)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			m.HandlingTimeHistogramActive()
+			m.SlowHandlingThreshold()
+		}
+	}()
+	wg.Wait()
+
+	// Updates of different settings are serialized, so none of them is lost.
+	require.True(t, m.HandlingTimeHistogramActive())
+	require.Equal(t, iterations*time.Millisecond, m.SlowHandlingThreshold())
+	requireValue(t, iterations, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+}
+
+func TestRuntimeConfigSnapshotPerRPC(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableHandlingTimeHistogram()
+	m.UnaryServerInterceptor()(context.Background(), &pb_testproto.PingRequest{Value: "ping"}, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			// Pausing while the RPC runs doesn't affect it.
+			m.SetHandlingTimeHistogramActive(false)
+			return &pb_testproto.PingResponse{}, nil
+		})
+	requireValueHistCount(t, 1, m.serverHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+
+	runtimeConfigTestCall(m)
+	requireValueHistCount(t, 1, m.serverHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+}
+
+func benchmarkRuntimeConfig(b *testing.B, toggle bool) {
+	m := NewServerMetrics()
+	m.EnableHandlingTimeHistogram()
+	m.EnableSlowHandlingCounter(time.Second)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	if toggle {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for active := false; ; active = !active {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				m.SetHandlingTimeHistogramActive(active)
+				time.Sleep(time.Microsecond)
+			}
+		}()
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			runtimeConfigTestCall(m)
+		}
+	})
+	b.StopTimer()
+	close(done)
+	wg.Wait()
+}
+
+func BenchmarkRuntimeConfig(b *testing.B) {
+	benchmarkRuntimeConfig(b, false)
+}
+
+func BenchmarkRuntimeConfigToggling(b *testing.B) {
+	benchmarkRuntimeConfig(b, true)
+}
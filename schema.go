@@ -0,0 +1,96 @@
+package grpc_prometheus
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// SchemaVersion is the version of the Schema format. It is incremented on
+// incompatible changes of the format, not of the metrics it describes.
+const SchemaVersion = 1
+
+// Schema is a machine-readable description of the metrics emitted by a
+// ServerMetrics, e.g. for validating dashboards and recording rules in the
+// CI of an application against its actual configuration.
+type Schema struct {
+	Version int            `json:"version"`
+	Metrics []MetricSchema `json:"metrics"`
+}
+
+// MetricSchema describes a metric family.
+type MetricSchema struct {
+	Name string `json:"name"`
+	Help string `json:"help"`
+	// Type is one of "counter", "gauge", "histogram" and "summary".
+	Type        string            `json:"type"`
+	Labels      []string          `json:"labels"`
+	ConstLabels map[string]string `json:"const_labels,omitempty"`
+}
+
+// Schema describes all metric families the ServerMetrics emits, sorted by
+// name. That is those of its current options until it is registered, and
+// those frozen on registration afterwards.
+func (m *ServerMetrics) Schema() Schema {
+	s := Schema{Version: SchemaVersion}
+	for _, c := range m.exportedCollectors() {
+		describeCollector(c, func(desc *prom.Desc, typ dto.MetricType) {
+			s.Metrics = append(s.Metrics, metricSchema(desc, typ))
+		})
+	}
+	sort.Slice(s.Metrics, func(i, j int) bool { return s.Metrics[i].Name < s.Metrics[j].Name })
+	return s
+}
+
+// describeCollector calls f with each descriptor of c and the type of its
+// metrics. Collectors other than metric vectors are assumed to export gauges.
+func describeCollector(c prom.Collector, f func(*prom.Desc, dto.MetricType)) {
+	typ := dto.MetricType_GAUGE
+	switch c.(type) {
+	case *prom.CounterVec, *counterVec:
+		typ = dto.MetricType_COUNTER
+	case *prom.HistogramVec, *histogramVec:
+		typ = dto.MetricType_HISTOGRAM
+	case *prom.SummaryVec, *summaryVec:
+		typ = dto.MetricType_SUMMARY
+	}
+	ch := make(chan *prom.Desc, 8)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+	for desc := range ch {
+		f(desc, typ)
+	}
+}
+
+var (
+	// descPattern matches the output of prom.Desc.String, which is the only
+	// way to access the fields of a Desc.
+	descPattern       = regexp.MustCompile(`^Desc\{fqName: ("(?:[^"\\]|\\.)*"), help: ("(?:[^"\\]|\\.)*"), constLabels: \{(.*)\}, variableLabels: \[(.*)\]\}$`)
+	constLabelPattern = regexp.MustCompile(`(\w+)=("(?:[^"\\]|\\.)*")`)
+)
+
+func metricSchema(desc *prom.Desc, typ dto.MetricType) MetricSchema {
+	s := MetricSchema{Type: strings.ToLower(typ.String()), Labels: []string{}}
+	match := descPattern.FindStringSubmatch(desc.String())
+	if match == nil {
+		return s
+	}
+	s.Name, _ = strconv.Unquote(match[1])
+	s.Help, _ = strconv.Unquote(match[2])
+	for _, lp := range constLabelPattern.FindAllStringSubmatch(match[3], -1) {
+		if s.ConstLabels == nil {
+			s.ConstLabels = map[string]string{}
+		}
+		s.ConstLabels[lp[1]], _ = strconv.Unquote(lp[2])
+	}
+	if match[4] != "" {
+		s.Labels = strings.Fields(match[4])
+	}
+	return s
+}
@@ -0,0 +1,71 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestServerMetricsSchema(t *testing.T) {
+	m := NewServerMetrics(WithConstLabels(prom.Labels{"app": "test"}))
+	m.EnableHandlingTimeHistogram()
+	m.DisableMsgCounters()
+	s := m.Schema()
+
+	require.Equal(t, SchemaVersion, s.Version)
+	byName := map[string]MetricSchema{}
+	for _, ms := range s.Metrics {
+		byName[ms.Name] = ms
+	}
+	require.Equal(t, MetricSchema{
+		Name:        "grpc_server_handled_total",
+		Help:        "Total number of RPCs completed on the server, regardless of success or failure.",
+		Type:        "counter",
+		Labels:      []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"},
+		ConstLabels: map[string]string{"app": "test"},
+	}, byName["grpc_server_handled_total"])
+	require.Equal(t, "histogram", byName["grpc_server_handling_seconds"].Type)
+	require.Equal(t, "gauge", byName["grpc_prometheus_build_info"].Type)
+	require.NotContains(t, byName, "grpc_server_msg_sent_total")
+
+	// The schema must match what is actually described.
+	ch := make(chan *prom.Desc, 100)
+	m.Describe(ch)
+	close(ch)
+	require.Len(t, s.Metrics, len(ch))
+}
+
+func TestDescribeAndCollectStayConsistentAfterRegistration(t *testing.T) {
+	m := NewServerMetrics()
+	reg := prom.NewPedanticRegistry()
+	require.NoError(t, reg.Register(m))
+
+	// Enabling metrics after registration is rejected, rather than recording
+	// metrics which are never exported.
+	require.Panics(t, func() { m.EnableHandlingTimeHistogram() })
+	require.Panics(t, func() { m.EnableSlowHandlingCounter(0) })
+	require.Panics(t, func() { m.EnableConfigInfo(ConfigInfo{}) })
+	require.Panics(t, func() { m.Configure(WithSeriesBudget(1)) })
+	_, err := m.UnaryServerInterceptor()(context.Background(), &pb_testproto.PingRequest{},
+		&grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return &pb_testproto.PingResponse{}, nil
+		})
+	require.NoError(t, err)
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range mfs {
+		require.NotEqual(t, "grpc_server_handling_seconds", mf.GetName())
+	}
+	for _, ms := range m.Schema().Metrics {
+		require.NotEqual(t, "grpc_server_handling_seconds", ms.Name, "schema must reflect the frozen metrics")
+	}
+
+	// A second registry sees the same frozen metrics.
+	require.NoError(t, prom.NewPedanticRegistry().Register(m))
+}
@@ -0,0 +1,102 @@
+package grpc_prometheus
+
+import (
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// WithScrapeMinMaxHandlingTime turns on the
+// grpc_server_scrape_min_handling_seconds and
+// grpc_server_scrape_max_handling_seconds gauges, the minimum and maximum
+// handling times of each method since the previous scrape. They give
+// visibility into outliers without the storage cost of histograms. Methods
+// without RPCs completed since the previous scrape are not exported.
+//
+// Every collection resets the gauges, so with several scrapers each would
+// only see part of the RPCs. To guard against this, collections less than
+// minInterval after the last reset return the same values again instead.
+// Set minInterval slightly below the scrape interval.
+func WithScrapeMinMaxHandlingTime(minInterval time.Duration) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		constLabels := m.counterOpts.apply(prom.CounterOpts{}).ConstLabels
+		labels := []string{"grpc_type", "grpc_service", "grpc_method"}
+		m.serverScrapeMinMax = &scrapeMinMax{
+			minDesc: prom.NewDesc(
+				"grpc_server_scrape_min_handling_seconds",
+				"Minimum handling time (seconds) of RPCs completed on the server since the previous scrape.",
+				labels, constLabels),
+			maxDesc: prom.NewDesc(
+				"grpc_server_scrape_max_handling_seconds",
+				"Maximum handling time (seconds) of RPCs completed on the server since the previous scrape.",
+				labels, constLabels),
+			minInterval: minInterval,
+			current:     make(map[rpcKey]minMax),
+		}
+	}
+}
+
+type rpcKey struct {
+	rpcType grpcType
+	service string
+	method  string
+}
+
+type minMax struct {
+	min, max time.Duration
+}
+
+type scrapeMinMax struct {
+	minDesc, maxDesc *prom.Desc
+	minInterval      time.Duration
+
+	mu        sync.Mutex
+	current   map[rpcKey]minMax
+	snapshot  map[rpcKey]minMax
+	lastReset time.Time
+}
+
+func (s *scrapeMinMax) observe(key rpcKey, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mm, ok := s.current[key]
+	if !ok || elapsed < mm.min {
+		mm.min = elapsed
+	}
+	if !ok || elapsed > mm.max {
+		mm.max = elapsed
+	}
+	s.current[key] = mm
+}
+
+// collect exports the values since the previous reset, resetting them unless
+// the last reset was less than minInterval before now.
+func (s *scrapeMinMax) collect(ch chan<- prom.Metric, now time.Time) {
+	s.mu.Lock()
+	if s.snapshot == nil || now.Sub(s.lastReset) >= s.minInterval {
+		s.snapshot, s.current = s.current, make(map[rpcKey]minMax, len(s.current))
+		s.lastReset = now
+	}
+	snapshot := s.snapshot
+	s.mu.Unlock()
+	for key, mm := range snapshot {
+		ch <- prom.MustNewConstMetric(s.minDesc, prom.GaugeValue, mm.min.Seconds(), string(key.rpcType), key.service, key.method)
+		ch <- prom.MustNewConstMetric(s.maxDesc, prom.GaugeValue, mm.max.Seconds(), string(key.rpcType), key.service, key.method)
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (s *scrapeMinMax) Describe(ch chan<- *prom.Desc) {
+	ch <- s.minDesc
+	ch <- s.maxDesc
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (s *scrapeMinMax) Collect(ch chan<- prom.Metric) {
+	s.collect(ch, time.Now())
+}
@@ -0,0 +1,46 @@
+package grpc_prometheus
+
+import (
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+// collectScrapeMinMax returns the values collected from s at now by
+// descriptor.
+func collectScrapeMinMax(t *testing.T, s *scrapeMinMax, now time.Time) map[*prom.Desc]float64 {
+	ch := make(chan prom.Metric, 10)
+	s.collect(ch, now)
+	close(ch)
+	values := map[*prom.Desc]float64{}
+	for metric := range ch {
+		var m dto.Metric
+		require.NoError(t, metric.Write(&m))
+		values[metric.Desc()] = m.GetGauge().GetValue()
+	}
+	return values
+}
+
+func TestScrapeMinMaxHandlingTime(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithScrapeMinMaxHandlingTime(10 * time.Second))
+	s := m.serverScrapeMinMax
+	key := rpcKey{Unary, "mwitkow.testproto.TestService", "Ping"}
+	now := time.Now()
+
+	s.observe(key, 3*time.Second)
+	s.observe(key, time.Second)
+	s.observe(key, 2*time.Second)
+	require.Equal(t, map[*prom.Desc]float64{s.minDesc: 1, s.maxDesc: 3}, collectScrapeMinMax(t, s, now))
+
+	// A second scraper within the minimum interval sees the same values.
+	s.observe(key, 5*time.Second)
+	require.Equal(t, map[*prom.Desc]float64{s.minDesc: 1, s.maxDesc: 3}, collectScrapeMinMax(t, s, now.Add(time.Second)))
+
+	// The next scrape only sees the RPCs completed since the reset.
+	require.Equal(t, map[*prom.Desc]float64{s.minDesc: 5, s.maxDesc: 5}, collectScrapeMinMax(t, s, now.Add(15*time.Second)))
+	require.Empty(t, collectScrapeMinMax(t, s, now.Add(30*time.Second)))
+}
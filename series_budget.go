@@ -0,0 +1,421 @@
+package grpc_prometheus
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// WithSeriesBudget caps the total number of series of the metric vectors of m,
+// across all of them, at n. Series are admitted in the order they are created,
+// and stay admitted until they are deleted, for example by
+// RemoveServiceMetrics. Once the budget is exhausted, observations of new label
+// combinations are dropped and counted in the
+// grpc_prometheus_dropped_observations_total counter by metric name. This
+// protects the scrape pipeline and the process from misconfigured label
+// extractors.
+func WithSeriesBudget(n int) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.seriesBudget.mu.Lock()
+		defer m.seriesBudget.mu.Unlock()
+		m.seriesBudget.limit = n
+		m.seriesBudget.dropped = prom.NewCounterVec(
+			m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_prometheus_dropped_observations_total",
+				Help: "Total number of observations dropped because their series would have exceeded the series budget, by metric.",
+			}), []string{"metric"})
+		if m.seriesBudget.admitted == nil {
+			m.seriesBudget.admitted = make(map[string]admittedSeries)
+		}
+	}
+}
+
+// errSeriesBudgetExhausted is returned when getting a series that doesn't fit
+// in the series budget.
+var errSeriesBudgetExhausted = errors.New("grpc_prometheus: series budget exhausted")
+
+// seriesBudget admits at most limit series across the metric vectors created
+// with it, or any number if limit is 0.
+type seriesBudget struct {
+	limit   int
+	dropped *prom.CounterVec
+
+	mu sync.Mutex
+	// admitted holds the admitted series by key. Dropped series aren't
+	// remembered, so that unbounded label values don't grow it.
+	admitted map[string]admittedSeries
+}
+
+// admittedSeries identifies the metric and service of an admitted series.
+type admittedSeries struct {
+	name    string
+	service string
+}
+
+// enabled reports whether b limits the number of series.
+func (b *seriesBudget) enabled() bool {
+	return b.limit > 0
+}
+
+// admit returns whether the series of v with label values lvs is within
+// budget, counting it as dropped if it isn't.
+func (b *seriesBudget) admit(v *budgetedVec, lvs []string) bool {
+	key := v.key(lvs)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.admitted[key]; ok {
+		return true
+	}
+	if len(b.admitted) >= b.limit {
+		b.dropped.WithLabelValues(v.name).Inc()
+		return false
+	}
+	b.admitted[key] = admittedSeries{name: v.name, service: v.service(lvs)}
+	return true
+}
+
+// release frees the slot of the series of v with label values lvs.
+func (b *seriesBudget) release(v *budgetedVec, lvs []string) {
+	key := v.key(lvs)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.admitted, key)
+}
+
+// releaseMatching frees the slots of the series of v matching match.
+func (b *seriesBudget) releaseMatching(v *budgetedVec, match func(admittedSeries) bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key, series := range b.admitted {
+		if series.name == v.name && match(series) {
+			delete(b.admitted, key)
+		}
+	}
+}
+
+// budgetedVec holds what the metric vectors of ServerMetrics need to enforce
+// its series budget.
+type budgetedVec struct {
+	budget       *seriesBudget
+	name         string
+	labelNames   []string
+	serviceIndex int
+}
+
+func newBudgetedVec(budget *seriesBudget, namespace, subsystem, name string, labelNames []string) budgetedVec {
+	v := budgetedVec{
+		budget:       budget,
+		name:         prom.BuildFQName(namespace, subsystem, name),
+		labelNames:   labelNames,
+		serviceIndex: -1,
+	}
+	for i, l := range labelNames {
+		if l == "grpc_service" {
+			v.serviceIndex = i
+		}
+	}
+	return v
+}
+
+func (v *budgetedVec) key(lvs []string) string {
+	return v.name + "\xff" + strings.Join(lvs, "\xff")
+}
+
+func (v *budgetedVec) service(lvs []string) string {
+	if v.serviceIndex < 0 || v.serviceIndex >= len(lvs) {
+		return ""
+	}
+	return lvs[v.serviceIndex]
+}
+
+// admit returns whether the series with label values lvs is within budget.
+// Label values of the wrong cardinality are admitted, for the vector to
+// report the error.
+func (v *budgetedVec) admit(lvs []string) bool {
+	if !v.budget.enabled() || len(lvs) != len(v.labelNames) {
+		return true
+	}
+	return v.budget.admit(v, lvs)
+}
+
+// admitLabels is admit for labels given by name.
+func (v *budgetedVec) admitLabels(labels prom.Labels) bool {
+	lvs, ok := v.labelValues(labels)
+	return !ok || v.admit(lvs)
+}
+
+func (v *budgetedVec) release(lvs []string) {
+	if v.budget.enabled() && len(lvs) == len(v.labelNames) {
+		v.budget.release(v, lvs)
+	}
+}
+
+func (v *budgetedVec) releaseLabels(labels prom.Labels) {
+	if lvs, ok := v.labelValues(labels); ok {
+		v.release(lvs)
+	}
+}
+
+// releaseService frees the slots of the series of service.
+func (v *budgetedVec) releaseService(service string) {
+	if v.budget.enabled() {
+		v.budget.releaseMatching(v, func(s admittedSeries) bool { return s.service == service })
+	}
+}
+
+func (v *budgetedVec) releaseAll() {
+	if v.budget.enabled() {
+		v.budget.releaseMatching(v, func(admittedSeries) bool { return true })
+	}
+}
+
+// labelValues returns the values of labels in the order of the label names of
+// v, and whether labels has exactly these names.
+func (v *budgetedVec) labelValues(labels prom.Labels) ([]string, bool) {
+	if len(labels) != len(v.labelNames) {
+		return nil, false
+	}
+	lvs := make([]string, len(v.labelNames))
+	for i, l := range v.labelNames {
+		value, ok := labels[l]
+		if !ok {
+			return nil, false
+		}
+		lvs[i] = value
+	}
+	return lvs, true
+}
+
+// Metrics observations over the series budget are recorded in.
+var (
+	discardedCounter  = prom.NewCounter(prom.CounterOpts{Name: "discarded", Help: "Discarded observations."})
+	discardedGauge    = prom.NewGauge(prom.GaugeOpts{Name: "discarded", Help: "Discarded observations."})
+	discardedObserver = prom.ObserverFunc(func(float64) {})
+)
+
+// counterVec is a prom.CounterVec whose series are limited by a series budget.
+type counterVec struct {
+	*prom.CounterVec
+	budgetedVec
+}
+
+func (m *ServerMetrics) newCounterVec(opts prom.CounterOpts, labelNames []string) *counterVec {
+	return &counterVec{
+		CounterVec:  prom.NewCounterVec(opts, labelNames),
+		budgetedVec: newBudgetedVec(m.seriesBudget, opts.Namespace, opts.Subsystem, opts.Name, labelNames),
+	}
+}
+
+func (v *counterVec) WithLabelValues(lvs ...string) prom.Counter {
+	if !v.admit(lvs) {
+		return discardedCounter
+	}
+	return v.CounterVec.WithLabelValues(lvs...)
+}
+
+func (v *counterVec) With(labels prom.Labels) prom.Counter {
+	if !v.admitLabels(labels) {
+		return discardedCounter
+	}
+	return v.CounterVec.With(labels)
+}
+
+func (v *counterVec) GetMetricWithLabelValues(lvs ...string) (prom.Counter, error) {
+	if !v.admit(lvs) {
+		return nil, errSeriesBudgetExhausted
+	}
+	return v.CounterVec.GetMetricWithLabelValues(lvs...)
+}
+
+func (v *counterVec) GetMetricWith(labels prom.Labels) (prom.Counter, error) {
+	if !v.admitLabels(labels) {
+		return nil, errSeriesBudgetExhausted
+	}
+	return v.CounterVec.GetMetricWith(labels)
+}
+
+func (v *counterVec) DeleteLabelValues(lvs ...string) bool {
+	v.release(lvs)
+	return v.CounterVec.DeleteLabelValues(lvs...)
+}
+
+func (v *counterVec) Delete(labels prom.Labels) bool {
+	v.releaseLabels(labels)
+	return v.CounterVec.Delete(labels)
+}
+
+func (v *counterVec) Reset() {
+	v.releaseAll()
+	v.CounterVec.Reset()
+}
+
+// gaugeVec is a prom.GaugeVec whose series are limited by a series budget.
+type gaugeVec struct {
+	*prom.GaugeVec
+	budgetedVec
+}
+
+func (m *ServerMetrics) newGaugeVec(opts prom.GaugeOpts, labelNames []string) *gaugeVec {
+	return &gaugeVec{
+		GaugeVec:    prom.NewGaugeVec(opts, labelNames),
+		budgetedVec: newBudgetedVec(m.seriesBudget, opts.Namespace, opts.Subsystem, opts.Name, labelNames),
+	}
+}
+
+func (v *gaugeVec) WithLabelValues(lvs ...string) prom.Gauge {
+	if !v.admit(lvs) {
+		return discardedGauge
+	}
+	return v.GaugeVec.WithLabelValues(lvs...)
+}
+
+func (v *gaugeVec) With(labels prom.Labels) prom.Gauge {
+	if !v.admitLabels(labels) {
+		return discardedGauge
+	}
+	return v.GaugeVec.With(labels)
+}
+
+func (v *gaugeVec) GetMetricWithLabelValues(lvs ...string) (prom.Gauge, error) {
+	if !v.admit(lvs) {
+		return nil, errSeriesBudgetExhausted
+	}
+	return v.GaugeVec.GetMetricWithLabelValues(lvs...)
+}
+
+func (v *gaugeVec) GetMetricWith(labels prom.Labels) (prom.Gauge, error) {
+	if !v.admitLabels(labels) {
+		return nil, errSeriesBudgetExhausted
+	}
+	return v.GaugeVec.GetMetricWith(labels)
+}
+
+func (v *gaugeVec) DeleteLabelValues(lvs ...string) bool {
+	v.release(lvs)
+	return v.GaugeVec.DeleteLabelValues(lvs...)
+}
+
+func (v *gaugeVec) Delete(labels prom.Labels) bool {
+	v.releaseLabels(labels)
+	return v.GaugeVec.Delete(labels)
+}
+
+func (v *gaugeVec) Reset() {
+	v.releaseAll()
+	v.GaugeVec.Reset()
+}
+
+// histogramVec is a prom.HistogramVec whose series are limited by a series
+// budget.
+type histogramVec struct {
+	*prom.HistogramVec
+	budgetedVec
+}
+
+func (m *ServerMetrics) newHistogramVec(opts prom.HistogramOpts, labelNames []string) *histogramVec {
+	return &histogramVec{
+		HistogramVec: prom.NewHistogramVec(opts, labelNames),
+		budgetedVec:  newBudgetedVec(m.seriesBudget, opts.Namespace, opts.Subsystem, opts.Name, labelNames),
+	}
+}
+
+func (v *histogramVec) WithLabelValues(lvs ...string) prom.Observer {
+	if !v.admit(lvs) {
+		return discardedObserver
+	}
+	return v.HistogramVec.WithLabelValues(lvs...)
+}
+
+func (v *histogramVec) With(labels prom.Labels) prom.Observer {
+	if !v.admitLabels(labels) {
+		return discardedObserver
+	}
+	return v.HistogramVec.With(labels)
+}
+
+func (v *histogramVec) GetMetricWithLabelValues(lvs ...string) (prom.Observer, error) {
+	if !v.admit(lvs) {
+		return nil, errSeriesBudgetExhausted
+	}
+	return v.HistogramVec.GetMetricWithLabelValues(lvs...)
+}
+
+func (v *histogramVec) GetMetricWith(labels prom.Labels) (prom.Observer, error) {
+	if !v.admitLabels(labels) {
+		return nil, errSeriesBudgetExhausted
+	}
+	return v.HistogramVec.GetMetricWith(labels)
+}
+
+func (v *histogramVec) DeleteLabelValues(lvs ...string) bool {
+	v.release(lvs)
+	return v.HistogramVec.DeleteLabelValues(lvs...)
+}
+
+func (v *histogramVec) Delete(labels prom.Labels) bool {
+	v.releaseLabels(labels)
+	return v.HistogramVec.Delete(labels)
+}
+
+func (v *histogramVec) Reset() {
+	v.releaseAll()
+	v.HistogramVec.Reset()
+}
+
+// summaryVec is a prom.SummaryVec whose series are limited by a series budget.
+type summaryVec struct {
+	*prom.SummaryVec
+	budgetedVec
+}
+
+func (m *ServerMetrics) newSummaryVec(opts prom.SummaryOpts, labelNames []string) *summaryVec {
+	return &summaryVec{
+		SummaryVec:  prom.NewSummaryVec(opts, labelNames),
+		budgetedVec: newBudgetedVec(m.seriesBudget, opts.Namespace, opts.Subsystem, opts.Name, labelNames),
+	}
+}
+
+func (v *summaryVec) WithLabelValues(lvs ...string) prom.Observer {
+	if !v.admit(lvs) {
+		return discardedObserver
+	}
+	return v.SummaryVec.WithLabelValues(lvs...)
+}
+
+func (v *summaryVec) With(labels prom.Labels) prom.Observer {
+	if !v.admitLabels(labels) {
+		return discardedObserver
+	}
+	return v.SummaryVec.With(labels)
+}
+
+func (v *summaryVec) GetMetricWithLabelValues(lvs ...string) (prom.Observer, error) {
+	if !v.admit(lvs) {
+		return nil, errSeriesBudgetExhausted
+	}
+	return v.SummaryVec.GetMetricWithLabelValues(lvs...)
+}
+
+func (v *summaryVec) GetMetricWith(labels prom.Labels) (prom.Observer, error) {
+	if !v.admitLabels(labels) {
+		return nil, errSeriesBudgetExhausted
+	}
+	return v.SummaryVec.GetMetricWith(labels)
+}
+
+func (v *summaryVec) DeleteLabelValues(lvs ...string) bool {
+	v.release(lvs)
+	return v.SummaryVec.DeleteLabelValues(lvs...)
+}
+
+func (v *summaryVec) Delete(labels prom.Labels) bool {
+	v.releaseLabels(labels)
+	return v.SummaryVec.Delete(labels)
+}
+
+func (v *summaryVec) Reset() {
+	v.releaseAll()
+	v.SummaryVec.Reset()
+}
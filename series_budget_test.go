@@ -0,0 +1,91 @@
+package grpc_prometheus
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// budgetedSeries returns the number of grpc_server_handled_total series
+// gathered from reg, and the number of its observations dropped by the series
+// budget.
+func budgetedSeries(t *testing.T, reg *prom.Registry) (n int, dropped float64) {
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range mfs {
+		switch mf.GetName() {
+		case "grpc_server_handled_total":
+			n += len(mf.GetMetric())
+		case "grpc_prometheus_dropped_observations_total":
+			for _, metric := range mf.GetMetric() {
+				require.Equal(t, "grpc_server_handled_total", metric.GetLabel()[0].GetValue())
+				dropped = metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return n, dropped
+}
+
+func TestSeriesBudget(t *testing.T) {
+	m := NewServerMetrics()
+	m.DisableStartedCounter()
+	m.DisableMsgCounters()
+	m.Configure(WithSeriesBudget(2))
+	reg := prom.NewPedanticRegistry()
+	reg.MustRegister(m)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	call := func(method string) {
+		m.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	}
+	requireSeries := func(n int, dropped float64) {
+		actualN, actualDropped := budgetedSeries(t, reg)
+		require.Equal(t, n, actualN)
+		require.Equal(t, dropped, actualDropped)
+	}
+
+	call("/mwitkow.testproto.TestService/Ping")
+	call("/mwitkow.testproto.TestService/PingEmpty")
+	requireSeries(2, 0)
+	call("/mwitkow.testproto.TestService/PingError")
+	requireSeries(2, 1)
+	call("/mwitkow.testproto.TestService/PingError")
+	requireSeries(2, 2)
+	var dump bytes.Buffer
+	require.NoError(t, m.Dump(&dump, expfmt.FmtText))
+	require.Contains(t, dump.String(), `grpc_prometheus_dropped_observations_total{metric="grpc_server_handled_total"} 2`)
+	require.NotContains(t, dump.String(), `grpc_method="PingError"`)
+	// Dropped series aren't remembered.
+	require.Len(t, m.seriesBudget.admitted, 2)
+	// Admitted series keep being updated.
+	call("/mwitkow.testproto.TestService/Ping")
+	requireValue(t, 2, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+	// Deleting a series frees its slot.
+	m.serverHandledCounter.DeleteLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "OK")
+	call("/mwitkow.testproto.TestService/PingError")
+	requireSeries(2, 2)
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "OK"))
+}
+
+func TestSeriesBudgetFreedByRemoveServiceMetrics(t *testing.T) {
+	m := NewServerMetrics()
+	m.DisableStartedCounter()
+	m.DisableMsgCounters()
+	m.Configure(WithShardedCollectors(), WithSeriesBudget(1))
+	reg := prom.NewPedanticRegistry()
+	reg.MustRegister(m)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	for _, service := range []string{"a.Service", "b.Service"} {
+		info := &grpc.UnaryServerInfo{FullMethod: "/" + service + "/Ping"}
+		m.UnaryServerInterceptor()(context.Background(), nil, info, handler)
+	}
+	require.Equal(t, map[string]int{"a.Service": 1}, handledSeries(t, reg))
+
+	m.RemoveServiceMetrics("a.Service")
+	m.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/b.Service/Ping"}, handler)
+	require.Equal(t, map[string]int{"b.Service": 1}, handledSeries(t, reg))
+}
@@ -0,0 +1,157 @@
+package grpc_prometheus
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// serveHTTPMetrics are the metrics of WithServeHTTPMetrics.
+type serveHTTPMetrics struct {
+	connectionsOpen     prom.Gauge
+	connectionsAccepted prom.Counter
+	rejected            *counterVec
+}
+
+// WithServeHTTPMetrics turns on the metrics of servers serving gRPC over
+// net/http with grpc.Server.ServeHTTP, e.g. single-port h2c servers.
+//
+// In that mode the interceptors and the RPC events of stats handlers work as
+// usual, but gRPC sees neither connections, which are managed by
+// http.Server, nor requests it rejects before any RPC starts. Install
+// WrapListener around the listener passed to http.Server.Serve to record
+// grpc_server_connections_open and grpc_server_connections_accepted_total,
+// and HTTPMiddleware around the grpc.Server handler to count requests gRPC
+// rejects in grpc_server_http_rejected_total, by reason: "http_version" for
+// requests not using HTTP/2, "method" for requests other than POST and
+// "content_type" for requests without a gRPC content type. counterOpts apply
+// to all three metrics.
+func WithServeHTTPMetrics(counterOpts ...CounterOption) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		if m.serveHTTP != nil {
+			return
+		}
+		m.serveHTTP = &serveHTTPMetrics{
+			connectionsOpen: prom.NewGauge(prom.GaugeOpts(
+				counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+					Name: "grpc_server_connections_open",
+					Help: "Number of connections currently open on the server.",
+				})))),
+			connectionsAccepted: prom.NewCounter(
+				counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+					Name: "grpc_server_connections_accepted_total",
+					Help: "Total number of connections accepted by the server.",
+				}))),
+			rejected: m.newCounterVec(
+				counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+					Name: "grpc_server_http_rejected_total",
+					Help: "Total number of HTTP requests rejected by the server before starting an RPC.",
+				})), []string{"reason"}),
+		}
+	}
+}
+
+// WrapListener returns l recording the connections it accepts, see
+// WithServeHTTPMetrics. It returns l itself if those metrics are disabled.
+func (m *ServerMetrics) WrapListener(l net.Listener) net.Listener {
+	if m.serveHTTP == nil {
+		return l
+	}
+	return &metricsListener{Listener: l, metrics: m.serveHTTP}
+}
+
+// HTTPMiddleware returns next, typically a grpc.Server, counting the
+// requests it rejects before starting an RPC, see WithServeHTTPMetrics. It
+// returns next itself if those metrics are disabled. The http.ResponseWriter
+// is passed on unwrapped, as grpc.Server.ServeHTTP needs its optional
+// interfaces.
+func (m *ServerMetrics) HTTPMiddleware(next http.Handler) http.Handler {
+	if m.serveHTTP == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reason := rejectReason(r); reason != "" {
+			m.serveHTTP.rejected.WithLabelValues(reason).Inc()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rejectReason returns why grpc.Server.ServeHTTP rejects r, or "" if it
+// doesn't.
+func rejectReason(r *http.Request) string {
+	if r.ProtoMajor != 2 {
+		return "http_version"
+	}
+	if r.Method != http.MethodPost {
+		return "method"
+	}
+	if !isGRPCContentType(r.Header.Get("Content-Type")) {
+		return "content_type"
+	}
+	return ""
+}
+
+// isGRPCContentType reports whether ct is a content type of gRPC, e.g.
+// "application/grpc" or "application/grpc+proto".
+func isGRPCContentType(ct string) bool {
+	const base = "application/grpc"
+	if !strings.HasPrefix(ct, base) {
+		return false
+	}
+	if len(ct) == len(base) {
+		return true
+	}
+	return ct[len(base)] == '+' || ct[len(base)] == ';'
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (c *serveHTTPMetrics) Describe(ch chan<- *prom.Desc) {
+	c.connectionsOpen.Describe(ch)
+	c.connectionsAccepted.Describe(ch)
+	c.rejected.Describe(ch)
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (c *serveHTTPMetrics) Collect(ch chan<- prom.Metric) {
+	c.connectionsOpen.Collect(ch)
+	c.connectionsAccepted.Collect(ch)
+	c.rejected.Collect(ch)
+}
+
+// metricsListener records the connections accepted by a net.Listener.
+type metricsListener struct {
+	net.Listener
+	metrics *serveHTTPMetrics
+}
+
+func (l *metricsListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.metrics.connectionsAccepted.Inc()
+	l.metrics.connectionsOpen.Inc()
+	return &metricsConn{Conn: conn, metrics: l.metrics}, nil
+}
+
+// metricsConn records when a connection accepted by a metricsListener is
+// closed. It is closed by http.Server, or by the handler having hijacked it,
+// as h2c handlers do.
+type metricsConn struct {
+	net.Conn
+	metrics   *serveHTTPMetrics
+	closeOnce sync.Once
+}
+
+func (c *metricsConn) Close() error {
+	c.closeOnce.Do(c.metrics.connectionsOpen.Dec)
+	return c.Conn.Close()
+}
@@ -0,0 +1,94 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/testdata"
+)
+
+func TestIsGRPCContentType(t *testing.T) {
+	require.True(t, isGRPCContentType("application/grpc"))
+	require.True(t, isGRPCContentType("application/grpc+proto"))
+	require.True(t, isGRPCContentType("application/grpc;charset=utf-8"))
+	require.False(t, isGRPCContentType("application/grpc-web"))
+	require.False(t, isGRPCContentType("application/json"))
+	require.False(t, isGRPCContentType(""))
+}
+
+func TestServeHTTPMetrics(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithServeHTTPMetrics())
+	m.EnableHandlingTimeHistogram()
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(m.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(m.StreamServerInterceptor()),
+	)
+	pb_testproto.RegisterTestServiceServer(server, &testService{t: t})
+	m.InitializeMetrics(server)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	httpServer := &http.Server{Handler: m.HTTPMiddleware(server)}
+	go httpServer.ServeTLS(m.WrapListener(lis), testdata.Path("server1.pem"), testdata.Path("server1.key"))
+	defer httpServer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	creds := credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})
+	conn, err := grpc.DialContext(ctx, lis.Addr().String(), grpc.WithTransportCredentials(creds), grpc.WithBlock())
+	require.NoError(t, err)
+	_, err = pb_testproto.NewTestServiceClient(conn).Ping(ctx, &pb_testproto.PingRequest{Value: "ping"})
+	require.NoError(t, err)
+
+	// The interceptors record RPCs as when serving with grpc.Server.Serve.
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+	requireValueHistCount(t, 1, m.serverHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValue(t, 1, m.serveHTTP.connectionsAccepted)
+	requireValue(t, 1, m.serveHTTP.connectionsOpen)
+
+	// HTTP/1.1 requests are rejected by gRPC.
+	httpClient := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		TLSNextProto:    map[string]func(string, *tls.Conn) http.RoundTripper{},
+	}}
+	resp, err := httpClient.Get("https://" + lis.Addr().String() + "/mwitkow.testproto.TestService/Ping")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.NotEqual(t, http.StatusOK, resp.StatusCode)
+	requireValue(t, 1, m.serveHTTP.rejected.WithLabelValues("http_version"))
+	requireValue(t, 2, m.serveHTTP.connectionsAccepted)
+
+	conn.Close()
+	httpClient.Transport.(*http.Transport).CloseIdleConnections()
+	requireValueWithRetry(ctx, t, 0, m.serveHTTP.connectionsOpen)
+}
+
+func TestServeHTTPMetricsOptions(t *testing.T) {
+	m := NewServerMetrics(WithConstLabels(prom.Labels{"server": "a"}))
+	m.Configure(WithServeHTTPMetrics(WithConstLabels(prom.Labels{"listener": "h2c"})))
+	for _, c := range []prom.Collector{m.serveHTTP.connectionsOpen, m.serveHTTP.connectionsAccepted, m.serveHTTP.rejected} {
+		ch := make(chan *prom.Desc, 1)
+		c.Describe(ch)
+		require.Contains(t, (<-ch).String(), `constLabels: {listener="h2c"}`)
+	}
+}
+
+func TestServeHTTPMetricsDisabled(t *testing.T) {
+	m := NewServerMetrics()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	require.Equal(t, lis, m.WrapListener(lis))
+	handler := http.NewServeMux()
+	require.Equal(t, handler, m.HTTPMiddleware(handler))
+}
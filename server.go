@@ -13,7 +13,8 @@ import (
 var (
 	// DefaultServerMetrics is the default instance of ServerMetrics. It is
 	// intended to be used in conjunction the default Prometheus metrics
-	// registry.
+	// registry. Failures to register with it are reported by
+	// DefaultRegistrationErrors.
 	DefaultServerMetrics = NewServerMetrics()
 
 	// UnaryServerInterceptor is a gRPC server-side interceptor that provides Prometheus monitoring for Unary RPCs.
@@ -24,17 +25,20 @@ var (
 )
 
 func init() {
-	prom.MustRegister(DefaultServerMetrics.serverStartedCounter)
-	prom.MustRegister(DefaultServerMetrics.serverHandledCounter)
-	prom.MustRegister(DefaultServerMetrics.serverStreamMsgReceived)
-	prom.MustRegister(DefaultServerMetrics.serverStreamMsgSent)
+	registerDefault(
+		DefaultServerMetrics.buildInfo,
+		DefaultServerMetrics.serverStartedCounter,
+		DefaultServerMetrics.serverHandledCounter,
+		DefaultServerMetrics.serverStreamMsgReceived,
+		DefaultServerMetrics.serverStreamMsgSent,
+	)
 }
 
 // Register takes a gRPC server and pre-initializes all counters to 0. This
 // allows for easier monitoring in Prometheus (no missing metrics), and should
 // be called *after* all services have been registered with the server. This
 // function acts on the DefaultServerMetrics variable.
-func Register(server *grpc.Server) {
+func Register(server ServiceInfoProvider) {
 	DefaultServerMetrics.InitializeMetrics(server)
 }
 
@@ -44,5 +48,45 @@ func Register(server *grpc.Server) {
 // variable and the default Prometheus metrics registry.
 func EnableHandlingTimeHistogram(opts ...HistogramOption) {
 	DefaultServerMetrics.EnableHandlingTimeHistogram(opts...)
-	prom.Register(DefaultServerMetrics.serverHandledHistogram)
+	registerDefault(DefaultServerMetrics.serverHandledHistogram)
+}
+
+// DisableStartedCounter turns off grpc_server_started_total. This function
+// acts on the DefaultServerMetrics variable and the default Prometheus
+// metrics registry.
+func DisableStartedCounter() {
+	DefaultServerMetrics.DisableStartedCounter()
+	prom.Unregister(DefaultServerMetrics.serverStartedCounter)
+}
+
+// DisableMsgCounters turns off grpc_server_msg_received_total and
+// grpc_server_msg_sent_total. This function acts on the DefaultServerMetrics
+// variable and the default Prometheus metrics registry.
+func DisableMsgCounters() {
+	DefaultServerMetrics.DisableMsgCounters()
+	prom.Unregister(DefaultServerMetrics.serverStreamMsgReceived)
+	prom.Unregister(DefaultServerMetrics.serverStreamMsgSent)
+}
+
+// EnableInterceptorTimingHistogram turns on recording of the time spent in
+// the interceptors wrapped with TimedInterceptor. This function acts on the
+// DefaultServerMetrics variable and the default Prometheus metrics registry.
+func EnableInterceptorTimingHistogram(opts ...HistogramOption) {
+	DefaultServerMetrics.EnableInterceptorTimingHistogram(opts...)
+	registerDefault(DefaultServerMetrics.serverInterceptorHistogram)
+}
+
+// EnableCoalescedCounter turns on grpc_server_coalesced_requests_total, see
+// RecordCoalesced. This function acts on the DefaultServerMetrics variable and
+// the default Prometheus metrics registry.
+func EnableCoalescedCounter(counterOpts ...CounterOption) {
+	DefaultServerMetrics.EnableCoalescedCounter(counterOpts...)
+	registerDefault(DefaultServerMetrics.serverCoalescedCounter)
+}
+
+// TimedInterceptor wraps next, recording the time spent in it under the given
+// name once EnableInterceptorTimingHistogram was called. This function acts
+// on the DefaultServerMetrics variable.
+func TimedInterceptor(name string, next grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return DefaultServerMetrics.TimedInterceptor(name, next)
 }
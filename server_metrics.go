@@ -2,51 +2,230 @@ package grpc_prometheus
 
 import (
 	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/grpc-ecosystem/go-grpc-prometheus/packages/grpcstatus"
 	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 // ServerMetrics represents a collection of metrics to be registered on a
 // Prometheus metrics registry for a gRPC server.
 type ServerMetrics struct {
-	serverStartedCounter          *prom.CounterVec
-	serverHandledCounter          *prom.CounterVec
-	serverStreamMsgReceived       *prom.CounterVec
-	serverStreamMsgSent           *prom.CounterVec
+	counterOpts counterOptions
+	buildInfo   *buildInfoCollector
+
+	// frozen holds the collectors exported since the first call to Describe.
+	freezeOnce sync.Once
+	frozen     atomic.Value
+
+	// runtime holds the *serverRuntimeConfig of the settings that can change
+	// after registration.
+	runtime runtimeConfigStore
+
+	serverStartedCounter          *counterVec
+	serverHandledCounter          *counterVec
+	serverStreamMsgReceived       *counterVec
+	serverStreamMsgSent           *counterVec
+	serverCoalescedCounter        *counterVec
+	serverStartedCounterDisabled  bool
+	serverMsgCountersDisabled     bool
 	serverHandledHistogramEnabled bool
 	serverHandledHistogramOpts    prom.HistogramOpts
-	serverHandledHistogram        *prom.HistogramVec
+	serverHandledHistogram        *histogramVec
+
+	serverSlowHandledCounterEnabled bool
+	serverSlowHandledCounter        *counterVec
+
+	serverRecvSizeRatioHistogramEnabled bool
+	serverRecvSizeRatioHistogramOpts    prom.HistogramOpts
+	serverRecvSizeRatioHistogram        *histogramVec
+	serverMaxRecvMsgSize                int
+
+	serverErrorBudget *errorBudget
+	serverErrorSpikes *errorSpikeDetector
+	serverRecentMax   *recentMaxTracker
+
+	serverBackpressure *backpressureTracker
+
+	serverHandledSummaryEnabled bool
+	serverHandledSummaryOpts    prom.SummaryOpts
+	serverHandledSummary        *summaryVec
+
+	serverHandledOverflowCounterEnabled bool
+	serverHandledOverflowCounter        *counterVec
+	serverHandledOverflowBound          float64
+
+	serverLongTermHandledCounterEnabled bool
+	serverLongTermHandledCounterOpts    prom.CounterOpts
+	serverLongTermHandledCounter        *counterVec
+
+	bucketAdvisor *BucketAdvisor
+
+	recorder Recorder
+
+	accessLogger  AccessLogger
+	rpcSampleRate float64
+	rpcSampler    func(ctx context.Context, entry AccessLogEntry)
+
+	serverAPIVersions *apiVersions
+
+	clockSkewTrailerEnabled bool
+	lateCompletions         *lateCompletions
+
+	serverCancellationCounterEnabled bool
+	serverCancellationCounter        *counterVec
+
+	serverWastedWorkCounter   *counterVec
+	serverWastedWorkHistogram *histogramVec
+
+	configInfo     *ConfigInfo
+	configInfoDesc *prom.Desc
+
+	deprecatedMethods           map[methodKey]bool
+	serverDeprecatedCallCounter *counterVec
+
+	serverResourceAccounting *resourceAccounting
+
+	serverInFlight         *inFlightTracker
+	serverLongPollInFlight *inFlightTracker
+
+	serverGCOverlapCounterEnabled bool
+	serverGCOverlapCounter        *counterVec
+
+	serverFailedSecondsCounterEnabled bool
+	serverFailedSecondsCounter        *counterVec
+
+	serverRequestCostFunc    func(ctx context.Context, req interface{}) float64
+	serverRequestCostCounter *counterVec
+
+	serverBatchSizeFunc      func(req interface{}) int
+	serverBatchSizeHistogram *histogramVec
+
+	serverResponseItemsHistogramEnabled bool
+	serverResponseItemsHistogramOpts    prom.HistogramOpts
+	serverResponseItemsHistogram        *histogramVec
+
+	serverHeaderProcessingHistogramEnabled bool
+	serverHeaderProcessingHistogramOpts    prom.HistogramOpts
+	serverHeaderProcessingHistogram        *histogramVec
+
+	warmupPeriod                 time.Duration
+	warmupDiscard                bool
+	serverWarmupHandledHistogram *histogramVec
+
+	serverHeatmapHistogram *histogramVec
+
+	transportFunc            TransportFunc
+	serverTransportHistogram *histogramVec
+
+	serverPhaseHistogram      *histogramVec
+	serverDependencyHistogram *histogramVec
+
+	serverShards *serviceShards
+
+	serverMsgSizeStats *msgSizeStats
+
+	largeMessageThreshold     int
+	serverLargeMessageCounter *counterVec
+
+	serverStreamCreditsGauge         *gaugeVec
+	serverStreamCreditGrantHistogram *histogramVec
+
+	serverOutOfOrderCounter *counterVec
+	serverDuplicateCounter  *counterVec
+
+	methodInfo *methodInfoCollector
+
+	consumerKey                   string
+	consumerNormalizer            ConsumerNormalizer
+	serverConsumerRequestsCounter *counterVec
+	serverConsumerErrorsCounter   *counterVec
+
+	serverTopK *topKTracker
+
+	serverScrapeMinMax *scrapeMinMax
+
+	seriesBudget *seriesBudget
+
+	spanStatusFunc                  SpanStatusFunc
+	serverSpanStatusMismatchCounter *counterVec
+
+	approvedMethods               map[methodKey]bool
+	serverUnexpectedMethodCounter *counterVec
+
+	benignCodes                map[methodKey]map[codes.Code]bool
+	serverBenignHandledCounter *counterVec
+
+	serverPeerCapabilityCounter *counterVec
+
+	serveHTTP *serveHTTPMetrics
+
+	serverStreamMsgErrorCounter *counterVec
+
+	serverMsgSizeLimits *msgSizeLimits
+
+	serverConnBytes *connBytesTracker
+
+	serverConsumerSLO *consumerSLO
+
+	serverMsgSizeReceivedHistogramEnabled bool
+	serverMsgSizeReceivedHistogramOpts    prom.HistogramOpts
+	serverMsgSizeReceivedHistogram        *histogramVec
+	serverMsgSizeSentHistogramEnabled     bool
+	serverMsgSizeSentHistogramOpts        prom.HistogramOpts
+	serverMsgSizeSentHistogram            *histogramVec
+
+	// methodTypes are the types of the methods given to InitializeMetrics,
+	// by full method name, for NewServerStatsHandler.
+	methodTypes sync.Map
+
+	serverMethodTrigger *methodTrigger
+
+	serverInterceptorHistogramEnabled bool
+	serverInterceptorHistogramOpts    prom.HistogramOpts
+	serverInterceptorHistogram        *histogramVec
+
+	checkpointErrorHandler func(error)
 }
 
+// Options of the core server counters, shared with the per-service shards of
+// WithShardedCollectors.
+var (
+	serverStartedCounterOpts = prom.CounterOpts{
+		Name: "grpc_server_started_total",
+		Help: "Total number of RPCs started on the server.",
+	}
+	serverHandledCounterOpts = prom.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "Total number of RPCs completed on the server, regardless of success or failure.",
+	}
+	serverStreamMsgReceivedOpts = prom.CounterOpts{
+		Name: "grpc_server_msg_received_total",
+		Help: "Total number of RPC stream messages received on the server.",
+	}
+	serverStreamMsgSentOpts = prom.CounterOpts{
+		Name: "grpc_server_msg_sent_total",
+		Help: "Total number of gRPC stream messages sent by the server.",
+	}
+)
+
 // NewServerMetrics returns a ServerMetrics object. Use a new instance of
 // ServerMetrics when not using the default Prometheus metrics registry, for
 // example when wanting to control which metrics are added to a registry as
 // opposed to automatically adding metrics via init functions.
 func NewServerMetrics(counterOpts ...CounterOption) *ServerMetrics {
 	opts := counterOptions(counterOpts)
-	return &ServerMetrics{
-		serverStartedCounter: prom.NewCounterVec(
-			opts.apply(prom.CounterOpts{
-				Name: "grpc_server_started_total",
-				Help: "Total number of RPCs started on the server.",
-			}), []string{"grpc_type", "grpc_service", "grpc_method"}),
-		serverHandledCounter: prom.NewCounterVec(
-			opts.apply(prom.CounterOpts{
-				Name: "grpc_server_handled_total",
-				Help: "Total number of RPCs completed on the server, regardless of success or failure.",
-			}), []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"}),
-		serverStreamMsgReceived: prom.NewCounterVec(
-			opts.apply(prom.CounterOpts{
-				Name: "grpc_server_msg_received_total",
-				Help: "Total number of RPC stream messages received on the server.",
-			}), []string{"grpc_type", "grpc_service", "grpc_method"}),
-		serverStreamMsgSent: prom.NewCounterVec(
-			opts.apply(prom.CounterOpts{
-				Name: "grpc_server_msg_sent_total",
-				Help: "Total number of gRPC stream messages sent by the server.",
-			}), []string{"grpc_type", "grpc_service", "grpc_method"}),
+	m := &ServerMetrics{
+		counterOpts:                   opts,
+		buildInfo:                     newBuildInfoCollector("server", opts),
+		seriesBudget:                  &seriesBudget{},
 		serverHandledHistogramEnabled: false,
 		serverHandledHistogramOpts: prom.HistogramOpts{
 			Name:    "grpc_server_handling_seconds",
@@ -54,7 +233,64 @@ func NewServerMetrics(counterOpts ...CounterOption) *ServerMetrics {
 			Buckets: prom.DefBuckets,
 		},
 		serverHandledHistogram: nil,
+		serverRecvSizeRatioHistogramOpts: prom.HistogramOpts{
+			Name:    "grpc_server_msg_received_size_limit_ratio",
+			Help:    "Histogram of the size of messages received by the server as a fraction of the configured maximum receive message size.",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 0.75, 0.9, 1},
+		},
+		serverInterceptorHistogramOpts: prom.HistogramOpts{
+			Name:    "grpc_server_interceptor_seconds",
+			Help:    "Histogram of time (seconds) spent in server-side interceptors, excluding the handlers they call.",
+			Buckets: prom.ExponentialBuckets(0.00001, 2, 16),
+		},
+		serverMsgSizeReceivedHistogramOpts: prom.HistogramOpts{
+			Name:    "grpc_server_msg_size_received_bytes",
+			Help:    "Histogram of the size (bytes) of messages received by the server.",
+			Buckets: prom.ExponentialBuckets(32, 4, 10),
+		},
+		serverMsgSizeSentHistogramOpts: prom.HistogramOpts{
+			Name:    "grpc_server_msg_size_sent_bytes",
+			Help:    "Histogram of the size (bytes) of messages sent by the server.",
+			Buckets: prom.ExponentialBuckets(32, 4, 10),
+		},
+		serverHandledSummaryOpts: prom.SummaryOpts{
+			Name:       "grpc_server_handling_quantile_seconds",
+			Help:       "Approximate quantiles of response latency (seconds) of gRPC that had been application-level handled by the server, over a sliding window.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.95: 0.01, 0.99: 0.001},
+			MaxAge:     prom.DefMaxAge,
+		},
+		serverHeaderProcessingHistogramOpts: prom.HistogramOpts{
+			Name:    "grpc_server_header_processing_seconds",
+			Help:    "Histogram of time (seconds) between the server receiving the request headers of an RPC and invoking its handler.",
+			Buckets: prom.ExponentialBuckets(0.00005, 2, 16),
+		},
+		serverResponseItemsHistogramOpts: prom.HistogramOpts{
+			Name:    "grpc_server_response_items",
+			Help:    "Histogram of the number of items sent by the server per streaming RPC.",
+			Buckets: prom.ExponentialBuckets(1, 4, 10),
+		},
 	}
+	m.serverStartedCounter = m.newCounterVec(
+		opts.apply(serverStartedCounterOpts), []string{"grpc_type", "grpc_service", "grpc_method"})
+	m.serverHandledCounter = m.newCounterVec(
+		opts.apply(serverHandledCounterOpts), []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"})
+	m.serverStreamMsgReceived = m.newCounterVec(
+		opts.apply(serverStreamMsgReceivedOpts), []string{"grpc_type", "grpc_service", "grpc_method"})
+	m.serverStreamMsgSent = m.newCounterVec(
+		opts.apply(serverStreamMsgSentOpts), []string{"grpc_type", "grpc_service", "grpc_method"})
+	return m
+}
+
+// Configure applies optional behaviour to the ServerMetrics. It must be called
+// before the ServerMetrics is registered and its interceptors handle RPCs.
+func (m *ServerMetrics) Configure(opts ...ServerMetricsOption) {
+	m.checkNotFrozen("Configure")
+	for _, o := range opts {
+		o(m)
+	}
+	m.ensureWarmupHistogram()
+	m.excludeLongPollMethods()
+	m.publishToggles()
 }
 
 // EnableHandlingTimeHistogram enables histograms being registered when
@@ -62,28 +298,373 @@ func NewServerMetrics(counterOpts ...CounterOption) *ServerMetrics {
 // expensive on Prometheus servers. It takes options to configure histogram
 // options such as the defined buckets.
 func (m *ServerMetrics) EnableHandlingTimeHistogram(opts ...HistogramOption) {
+	m.checkNotFrozen("EnableHandlingTimeHistogram")
 	for _, o := range opts {
 		o(&m.serverHandledHistogramOpts)
 	}
 	if !m.serverHandledHistogramEnabled {
-		m.serverHandledHistogram = prom.NewHistogramVec(
+		m.serverHandledHistogram = m.newHistogramVec(
 			m.serverHandledHistogramOpts,
 			[]string{"grpc_type", "grpc_service", "grpc_method"},
 		)
 	}
 	m.serverHandledHistogramEnabled = true
+	m.ensureWarmupHistogram()
+	m.publishToggles()
+}
+
+// ensureWarmupHistogram creates the histogram handling times excluded by
+// WithWarmupExclusion are recorded in, if it is needed.
+func (m *ServerMetrics) ensureWarmupHistogram() {
+	if !m.serverHandledHistogramEnabled || m.warmupPeriod <= 0 || m.warmupDiscard || m.serverWarmupHandledHistogram != nil {
+		return
+	}
+	opts := m.serverHandledHistogramOpts
+	opts.Name = "grpc_server_warmup_handling_seconds"
+	opts.Help = "Histogram of response latency (seconds) of gRPC started during the warm-up period after process start."
+	m.serverWarmupHandledHistogram = m.newHistogramVec(opts, []string{"grpc_type", "grpc_service", "grpc_method"})
+}
+
+// inWarmup reports whether an RPC started at t is excluded by WithWarmupExclusion.
+func (m *ServerMetrics) inWarmup(t time.Time) bool {
+	return m.warmupPeriod > 0 && t.Sub(processStartTime) < m.warmupPeriod
+}
+
+// DisableStartedCounter turns off grpc_server_started_total, which is
+// redundant with grpc_server_handled_total for users not alerting on RPCs in
+// flight. It must be called before the ServerMetrics is registered.
+func (m *ServerMetrics) DisableStartedCounter() {
+	m.checkNotFrozen("DisableStartedCounter")
+	m.serverStartedCounterDisabled = true
+	m.publishToggles()
+}
+
+// DisableMsgCounters turns off grpc_server_msg_received_total and
+// grpc_server_msg_sent_total, halving the default number of series together
+// with DisableStartedCounter. It must be called before the ServerMetrics is
+// registered.
+func (m *ServerMetrics) DisableMsgCounters() {
+	m.checkNotFrozen("DisableMsgCounters")
+	m.serverMsgCountersDisabled = true
+	m.publishToggles()
+}
+
+// EnableHandlingTimeOverflowCounter turns on the
+// grpc_server_handling_seconds_overflow_total counter, incremented whenever a
+// handling time exceeds the largest bucket of the handling time histogram.
+// Growth of the +Inf bucket is easy to miss, and usually means the bucket
+// layout is wrong. It should be called after EnableHandlingTimeHistogram, so
+// that custom buckets are taken into account.
+func (m *ServerMetrics) EnableHandlingTimeOverflowCounter(counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnableHandlingTimeOverflowCounter")
+	buckets := m.serverHandledHistogramOpts.Buckets
+	if len(buckets) == 0 {
+		buckets = prom.DefBuckets
+	}
+	m.serverHandledOverflowBound = buckets[len(buckets)-1]
+	if !m.serverHandledOverflowCounterEnabled {
+		m.serverHandledOverflowCounter = m.newCounterVec(
+			counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_server_handling_seconds_overflow_total",
+				Help: "Total number of RPCs completed on the server whose handling time exceeded the largest handling time histogram bucket.",
+			})), []string{"grpc_type", "grpc_service", "grpc_method"})
+	}
+	m.serverHandledOverflowCounterEnabled = true
+	m.publishToggles()
+}
+
+// EnableLongTermHandledCounter turns on grpc_server_handled_longterm_total,
+// a minimal counter of completed RPCs labeled only by service and method. It
+// is emitted alongside the detailed metrics and intended for long-retention
+// or remote-write tiers, where the cardinality of grpc_code and grpc_type is
+// not worth paying for.
+func (m *ServerMetrics) EnableLongTermHandledCounter(counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnableLongTermHandledCounter")
+	if !m.serverLongTermHandledCounterEnabled {
+		m.serverLongTermHandledCounterOpts = counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+			Name: "grpc_server_handled_longterm_total",
+			Help: "Total number of RPCs completed on the server, regardless of type and status, for long-term retention.",
+		}))
+		m.serverLongTermHandledCounter = m.newCounterVec(m.serverLongTermHandledCounterOpts, []string{"grpc_service", "grpc_method"})
+	}
+	m.serverLongTermHandledCounterEnabled = true
+	m.publishToggles()
+}
+
+// EnableExpensiveResourceAccounting turns on the experimental
+// grpc_server_cpu_seconds_total and grpc_server_allocated_bytes_total
+// counters, attributing approximate CPU time and heap allocations to methods.
+// The Go runtime only exposes process-wide usage, so each RPC is attributed
+// the usage during its handling divided by the number of RPCs in flight when
+// it finished. CPU time is the user CPU time of Go code estimated by the
+// runtime, which only refreshes it at each GC cycle: it is attributed to the
+// RPCs in flight when a cycle completes, and meaningful only in aggregate.
+// Runtime metrics are read twice per RPC, which is expensive.
+// It returns false, and has no effect, when built with Go older than 1.20.
+func (m *ServerMetrics) EnableExpensiveResourceAccounting(counterOpts ...CounterOption) bool {
+	m.checkNotFrozen("EnableExpensiveResourceAccounting")
+	if !resourceAccountingSupported {
+		return false
+	}
+	if m.serverResourceAccounting == nil {
+		opts := counterOptions(counterOpts)
+		m.serverResourceAccounting = &resourceAccounting{
+			cpuCounter: m.newCounterVec(
+				opts.apply(m.counterOpts.apply(prom.CounterOpts{
+					Name: "grpc_server_cpu_seconds_total",
+					Help: "Approximate CPU time (seconds) spent by the process while handling RPCs, attributed to methods.",
+				})), []string{"grpc_type", "grpc_service", "grpc_method"}),
+			allocCounter: m.newCounterVec(
+				opts.apply(m.counterOpts.apply(prom.CounterOpts{
+					Name: "grpc_server_allocated_bytes_total",
+					Help: "Approximate heap bytes allocated by the process while handling RPCs, attributed to methods.",
+				})), []string{"grpc_type", "grpc_service", "grpc_method"}),
+		}
+	}
+	return true
+}
+
+// EnableGCOverlapCounter turns on grpc_server_gc_overlapped_total, counting
+// RPCs during whose handling at least one GC cycle, and thus a stop-the-world
+// pause, completed. Comparing it to grpc_server_handled_total tells whether
+// latency spikes coincide with GC. It returns false, and has no effect, when
+// built with Go older than 1.16.
+func (m *ServerMetrics) EnableGCOverlapCounter(counterOpts ...CounterOption) bool {
+	m.checkNotFrozen("EnableGCOverlapCounter")
+	if !gcOverlapSupported {
+		return false
+	}
+	if !m.serverGCOverlapCounterEnabled {
+		m.serverGCOverlapCounter = m.newCounterVec(
+			counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_server_gc_overlapped_total",
+				Help: "Total number of RPCs completed on the server whose handling overlapped a GC cycle.",
+			})), []string{"grpc_type", "grpc_service", "grpc_method"})
+	}
+	m.serverGCOverlapCounterEnabled = true
+	m.publishToggles()
+	return true
+}
+
+// EnableFailedRPCSecondsCounter turns on grpc_server_failed_rpc_seconds_total,
+// accumulating the handling time of RPCs which completed with a code other
+// than OK. Counting failures alone understates the cost of slow failures,
+// e.g. timeouts, which waste far more resources than fast rejections.
+func (m *ServerMetrics) EnableFailedRPCSecondsCounter(counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnableFailedRPCSecondsCounter")
+	if !m.serverFailedSecondsCounterEnabled {
+		m.serverFailedSecondsCounter = m.newCounterVec(
+			counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_server_failed_rpc_seconds_total",
+				Help: "Total handling time (seconds) of RPCs completed on the server with a code other than OK.",
+			})), []string{"grpc_type", "grpc_service", "grpc_method"})
+	}
+	m.serverFailedSecondsCounterEnabled = true
+	m.publishToggles()
+}
+
+// MarkDeprecated counts calls to the given methods, e.g.
+// "/mwitkow.testproto.TestService/Ping", in grpc_server_deprecated_calls_total,
+// so that owners can watch the usage of endpoints scheduled for removal
+// decline to zero before deleting them. It must be called before serving.
+func (m *ServerMetrics) MarkDeprecated(fullMethods ...string) {
+	m.checkNotFrozen("MarkDeprecated")
+	if m.deprecatedMethods == nil {
+		m.deprecatedMethods = make(map[methodKey]bool, len(fullMethods))
+		m.serverDeprecatedCallCounter = m.newCounterVec(
+			m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_server_deprecated_calls_total",
+				Help: "Total number of RPCs started on the server for methods marked as deprecated.",
+			}), []string{"grpc_type", "grpc_service", "grpc_method"})
+	}
+	for _, fullMethod := range fullMethods {
+		service, method := splitMethodName(fullMethod)
+		m.deprecatedMethods[methodKey{service, method}] = true
+	}
+}
+
+// EnableCoalescedCounter turns on grpc_server_coalesced_requests_total,
+// counting the RPCs reported with RecordCoalesced.
+func (m *ServerMetrics) EnableCoalescedCounter(counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnableCoalescedCounter")
+	if m.serverCoalescedCounter != nil {
+		return
+	}
+	m.serverCoalescedCounter = m.newCounterVec(
+		counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+			Name: "grpc_server_coalesced_requests_total",
+			Help: "Total number of RPCs answered by the server from the result of another identical in-flight RPC.",
+		})), []string{"grpc_type", "grpc_service", "grpc_method"})
+}
+
+// RecordCoalesced counts a unary RPC to fullMethod which was answered from
+// the result of another identical in-flight RPC, e.g. by a singleflight
+// interceptor, in grpc_server_coalesced_requests_total once
+// EnableCoalescedCounter was called. The RPC itself is still counted as
+// started and handled by the interceptors of ServerMetrics.
+func (m *ServerMetrics) RecordCoalesced(fullMethod string) {
+	if m.serverCoalescedCounter == nil {
+		return
+	}
+	serviceName, methodName := splitMethodName(fullMethod)
+	m.serverCoalescedCounter.WithLabelValues(string(Unary), serviceName, methodName).Inc()
+}
+
+// SetHandlingTimeHistogramActive pauses or resumes recording of handling time
+// observations at runtime. It has no effect unless EnableHandlingTimeHistogram
+// was called, as the set of described metrics can't change after registration.
+func (m *ServerMetrics) SetHandlingTimeHistogramActive(active bool) {
+	m.updateRuntimeConfig(func(c *serverRuntimeConfig) { c.handlingTimeHistogramPaused = !active })
+}
+
+// HandlingTimeHistogramActive reports whether handling time observations are
+// currently being recorded.
+func (m *ServerMetrics) HandlingTimeHistogramActive() bool {
+	return m.serverHandledHistogramEnabled && !m.runtimeConfig().handlingTimeHistogramPaused
+}
+
+// EnableSlowHandlingCounter enables counting RPCs whose handling time exceeded
+// threshold in the grpc_server_slow_handled_total counter. The threshold can be
+// adjusted at runtime using SetSlowHandlingThreshold.
+func (m *ServerMetrics) EnableSlowHandlingCounter(threshold time.Duration, counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnableSlowHandlingCounter")
+	if !m.serverSlowHandledCounterEnabled {
+		m.serverSlowHandledCounter = m.newCounterVec(
+			counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_server_slow_handled_total",
+				Help: "Total number of RPCs completed on the server that took longer than the slow handling threshold.",
+			})), []string{"grpc_type", "grpc_service", "grpc_method"})
+	}
+	m.SetSlowHandlingThreshold(threshold)
+	m.serverSlowHandledCounterEnabled = true
+	m.publishToggles()
+}
+
+// SetSlowHandlingThreshold changes, at runtime, the handling time above which
+// RPCs are counted as slow.
+func (m *ServerMetrics) SetSlowHandlingThreshold(threshold time.Duration) {
+	m.updateRuntimeConfig(func(c *serverRuntimeConfig) { c.slowHandlingThreshold = threshold })
+}
+
+// SlowHandlingThreshold returns the current slow handling threshold.
+func (m *ServerMetrics) SlowHandlingThreshold() time.Duration {
+	return m.runtimeConfig().slowHandlingThreshold
+}
+
+// EnableReceivedSizeLimitRatioHistogram turns on recording of the size of
+// received messages as a fraction of maxRecvMsgSize, which should match the
+// grpc.MaxRecvMsgSize option the server was created with (gRPC doesn't expose
+// it). A value <= 0 assumes the gRPC default of 4MB. This allows alerting on
+// clients approaching the limit before requests start failing. Computing
+// message sizes has a cost, hence this is off by default.
+func (m *ServerMetrics) EnableReceivedSizeLimitRatioHistogram(maxRecvMsgSize int, opts ...HistogramOption) {
+	m.checkNotFrozen("EnableReceivedSizeLimitRatioHistogram")
+	for _, o := range opts {
+		o(&m.serverRecvSizeRatioHistogramOpts)
+	}
+	if maxRecvMsgSize <= 0 {
+		maxRecvMsgSize = defaultServerMaxRecvMsgSize
+	}
+	m.serverMaxRecvMsgSize = maxRecvMsgSize
+	if !m.serverRecvSizeRatioHistogramEnabled {
+		m.serverRecvSizeRatioHistogram = m.newHistogramVec(
+			m.serverRecvSizeRatioHistogramOpts,
+			[]string{"grpc_type", "grpc_service", "grpc_method"},
+		)
+	}
+	m.serverRecvSizeRatioHistogramEnabled = true
+	m.publishToggles()
+}
+
+// EnableMsgSizeReceivedBytesHistogram turns on recording of the size of
+// messages received by the server in the grpc_server_msg_size_received_bytes
+// histogram. Computing message sizes has a cost, hence this is off by
+// default.
+func (m *ServerMetrics) EnableMsgSizeReceivedBytesHistogram(opts ...HistogramOption) {
+	m.checkNotFrozen("EnableMsgSizeReceivedBytesHistogram")
+	for _, o := range opts {
+		o(&m.serverMsgSizeReceivedHistogramOpts)
+	}
+	if !m.serverMsgSizeReceivedHistogramEnabled {
+		m.serverMsgSizeReceivedHistogram = m.newHistogramVec(
+			m.serverMsgSizeReceivedHistogramOpts,
+			[]string{"grpc_type", "grpc_service", "grpc_method"},
+		)
+	}
+	m.serverMsgSizeReceivedHistogramEnabled = true
+	m.publishToggles()
+}
+
+// EnableMsgSizeSentBytesHistogram turns on recording of the size of messages
+// sent by the server in the grpc_server_msg_size_sent_bytes histogram.
+// Computing message sizes has a cost, hence this is off by default.
+func (m *ServerMetrics) EnableMsgSizeSentBytesHistogram(opts ...HistogramOption) {
+	m.checkNotFrozen("EnableMsgSizeSentBytesHistogram")
+	for _, o := range opts {
+		o(&m.serverMsgSizeSentHistogramOpts)
+	}
+	if !m.serverMsgSizeSentHistogramEnabled {
+		m.serverMsgSizeSentHistogram = m.newHistogramVec(
+			m.serverMsgSizeSentHistogramOpts,
+			[]string{"grpc_type", "grpc_service", "grpc_method"},
+		)
+	}
+	m.serverMsgSizeSentHistogramEnabled = true
+	m.publishToggles()
+}
+
+// EnableErrorBudgetBurnGauges turns on the grpc_server_error_budget_burn
+// gauges, computed in-process for every method with an objective over each of
+// the given sliding windows (5m and 1h if none are given). objectives maps full
+// method names, e.g. "/pkg.Service/Method", to their success ratio objective,
+// e.g. 0.999. A burn rate of 1 means the error budget would be spent exactly at
+// the end of the objective's period. Only codes attributable to the server
+// (Unknown, DeadlineExceeded, Unimplemented, Internal, Unavailable and DataLoss)
+// count as errors.
+func (m *ServerMetrics) EnableErrorBudgetBurnGauges(objectives map[string]float64, windows ...time.Duration) {
+	m.checkNotFrozen("EnableErrorBudgetBurnGauges")
+	m.serverErrorBudget = newErrorBudget(objectives, windows, m.counterOpts.apply(prom.CounterOpts{}).ConstLabels)
+}
+
+// EnableHandlingTimeSummary turns on recording of approximate handling time
+// quantiles (by default p50, p95 and p99) per method, computed in-process over
+// a sliding window. It is intended for consumers that can't compute
+// histogram_quantile, e.g. autoscalers reading the metrics directly. The
+// quantiles are estimates, can't be aggregated across instances and keep
+// per-method sample streams in memory, hence this is off by default.
+func (m *ServerMetrics) EnableHandlingTimeSummary(opts ...SummaryOption) {
+	m.checkNotFrozen("EnableHandlingTimeSummary")
+	for _, o := range opts {
+		o(&m.serverHandledSummaryOpts)
+	}
+	if !m.serverHandledSummaryEnabled {
+		m.serverHandledSummary = m.newSummaryVec(
+			m.serverHandledSummaryOpts,
+			[]string{"grpc_type", "grpc_service", "grpc_method"},
+		)
+	}
+	m.serverHandledSummaryEnabled = true
+	m.publishToggles()
 }
 
 // Describe sends the super-set of all possible descriptors of metrics
 // collected by this Collector to the provided channel and returns once
 // the last descriptor has been sent.
+//
+// The first call, usually on registration, freezes the set of exported
+// metrics, so that Describe and Collect stay consistent. Enabling metrics or
+// changing options afterwards panics; use runtime switches such as
+// SetHandlingTimeHistogramActive to change what is recorded after
+// registration.
 func (m *ServerMetrics) Describe(ch chan<- *prom.Desc) {
-	m.serverStartedCounter.Describe(ch)
-	m.serverHandledCounter.Describe(ch)
-	m.serverStreamMsgReceived.Describe(ch)
-	m.serverStreamMsgSent.Describe(ch)
-	if m.serverHandledHistogramEnabled {
-		m.serverHandledHistogram.Describe(ch)
+	m.freezeOnce.Do(func() { m.frozen.Store(m.collectors()) })
+	m.describe(ch)
+}
+
+// describe sends the descriptors of the exported metrics of m.
+func (m *ServerMetrics) describe(ch chan<- *prom.Desc) {
+	for _, c := range m.exportedCollectors() {
+		c.Describe(ch)
 	}
 }
 
@@ -91,26 +672,262 @@ func (m *ServerMetrics) Describe(ch chan<- *prom.Desc) {
 // metrics. The implementation sends each collected metric via the
 // provided channel and returns once the last metric has been sent.
 func (m *ServerMetrics) Collect(ch chan<- prom.Metric) {
-	m.serverStartedCounter.Collect(ch)
-	m.serverHandledCounter.Collect(ch)
-	m.serverStreamMsgReceived.Collect(ch)
-	m.serverStreamMsgSent.Collect(ch)
-	if m.serverHandledHistogramEnabled {
-		m.serverHandledHistogram.Collect(ch)
+	for _, c := range m.exportedCollectors() {
+		c.Collect(ch)
+	}
+}
+
+// checkNotFrozen panics if m was already described, usually on registration:
+// the metrics enabled by method would be recorded but never exported, and
+// changing options would race with the RPCs being monitored.
+func (m *ServerMetrics) checkNotFrozen(method string) {
+	if m.frozen.Load() != nil {
+		panic("grpc_prometheus: ServerMetrics." + method + " called after the metrics were registered; configure them before registration, or use runtime switches such as SetHandlingTimeHistogramActive")
+	}
+}
+
+// exportedCollectors returns the collectors frozen by Describe, or those of
+// the current options if Describe wasn't called yet.
+func (m *ServerMetrics) exportedCollectors() []prom.Collector {
+	if cs, ok := m.frozen.Load().([]prom.Collector); ok {
+		return cs
+	}
+	return m.collectors()
+}
+
+// collectors returns the collectors of all metrics enabled by the current
+// options.
+func (m *ServerMetrics) collectors() []prom.Collector {
+	var cs []prom.Collector
+	if m.serverShards != nil {
+		cs = append(cs, m.buildInfo, m.serverShards)
+	} else {
+		if !m.serverStartedCounterDisabled {
+			cs = append(cs, m.serverStartedCounter)
+		}
+		cs = append(cs, m.buildInfo, m.serverHandledCounter)
+		if !m.serverMsgCountersDisabled {
+			cs = append(cs, m.serverStreamMsgReceived, m.serverStreamMsgSent)
+		}
+	}
+	if m.serverCoalescedCounter != nil {
+		cs = append(cs, m.serverCoalescedCounter)
+	}
+	if m.serverHandledHistogramEnabled && m.serverShards == nil {
+		cs = append(cs, m.serverHandledHistogram)
+	}
+	if m.serverSlowHandledCounterEnabled {
+		cs = append(cs, m.serverSlowHandledCounter)
+	}
+	if m.serverRecvSizeRatioHistogramEnabled {
+		cs = append(cs, m.serverRecvSizeRatioHistogram)
+	}
+	if m.serverErrorBudget != nil {
+		cs = append(cs, m.serverErrorBudget)
+	}
+	if m.serverHandledSummaryEnabled {
+		cs = append(cs, m.serverHandledSummary)
+	}
+	if m.serverHandledOverflowCounterEnabled {
+		cs = append(cs, m.serverHandledOverflowCounter)
+	}
+	if m.serverWarmupHandledHistogram != nil {
+		cs = append(cs, m.serverWarmupHandledHistogram)
+	}
+	if m.serverHeatmapHistogram != nil {
+		cs = append(cs, m.serverHeatmapHistogram)
+	}
+	if m.serverTransportHistogram != nil {
+		cs = append(cs, m.serverTransportHistogram)
+	}
+	if m.serverLongTermHandledCounterEnabled {
+		cs = append(cs, m.serverLongTermHandledCounter)
+	}
+	if m.serverResourceAccounting != nil {
+		cs = append(cs, m.serverResourceAccounting.cpuCounter, m.serverResourceAccounting.allocCounter)
+	}
+	if m.serverGCOverlapCounterEnabled {
+		cs = append(cs, m.serverGCOverlapCounter)
+	}
+	if m.serverFailedSecondsCounterEnabled {
+		cs = append(cs, m.serverFailedSecondsCounter)
+	}
+	if m.serverHeaderProcessingHistogramEnabled {
+		cs = append(cs, m.serverHeaderProcessingHistogram)
+	}
+	if m.serverRequestCostCounter != nil {
+		cs = append(cs, m.serverRequestCostCounter)
+	}
+	if m.serverBatchSizeHistogram != nil {
+		cs = append(cs, m.serverBatchSizeHistogram)
+	}
+	if m.serverResponseItemsHistogramEnabled {
+		cs = append(cs, m.serverResponseItemsHistogram)
+	}
+	if m.serverPhaseHistogram != nil {
+		cs = append(cs, m.serverPhaseHistogram)
+	}
+	if m.serverDependencyHistogram != nil {
+		cs = append(cs, m.serverDependencyHistogram)
+	}
+	if m.serverMsgSizeStats != nil {
+		cs = append(cs, m.serverMsgSizeStats)
+	}
+	if m.serverLargeMessageCounter != nil {
+		cs = append(cs, m.serverLargeMessageCounter)
+	}
+	if m.serverStreamCreditsGauge != nil {
+		cs = append(cs, m.serverStreamCreditsGauge, m.serverStreamCreditGrantHistogram)
+	}
+	if m.serverOutOfOrderCounter != nil {
+		cs = append(cs, m.serverOutOfOrderCounter, m.serverDuplicateCounter)
+	}
+	if m.methodInfo != nil {
+		cs = append(cs, m.methodInfo)
+	}
+	if m.serverConsumerRequestsCounter != nil {
+		cs = append(cs, m.serverConsumerRequestsCounter, m.serverConsumerErrorsCounter)
+	}
+	if m.serverTopK != nil {
+		cs = append(cs, m.serverTopK)
+	}
+	if m.serverScrapeMinMax != nil {
+		cs = append(cs, m.serverScrapeMinMax)
+	}
+	if m.serverSpanStatusMismatchCounter != nil {
+		cs = append(cs, m.serverSpanStatusMismatchCounter)
+	}
+	if m.serverDeprecatedCallCounter != nil {
+		cs = append(cs, m.serverDeprecatedCallCounter)
+	}
+	if m.serverAPIVersions != nil {
+		cs = append(cs, m.serverAPIVersions.counter)
+	}
+	if m.serverCancellationCounterEnabled {
+		cs = append(cs, m.serverCancellationCounter)
+	}
+	if m.serverWastedWorkCounter != nil {
+		cs = append(cs, m.serverWastedWorkCounter, m.serverWastedWorkHistogram)
 	}
+	if m.configInfo != nil {
+		cs = append(cs, serverConfigInfoCollector{m})
+	}
+	if m.serverInFlight != nil {
+		cs = append(cs, m.serverInFlight)
+	}
+	if m.serverLongPollInFlight != nil {
+		cs = append(cs, m.serverLongPollInFlight)
+	}
+	if m.serverUnexpectedMethodCounter != nil {
+		cs = append(cs, m.serverUnexpectedMethodCounter)
+	}
+	if m.serverRecentMax != nil {
+		cs = append(cs, m.serverRecentMax)
+	}
+	if m.serverBackpressure != nil {
+		cs = append(cs, m.serverBackpressure)
+	}
+	if m.serverBenignHandledCounter != nil {
+		cs = append(cs, m.serverBenignHandledCounter)
+	}
+	if m.serverPeerCapabilityCounter != nil {
+		cs = append(cs, m.serverPeerCapabilityCounter)
+	}
+	if m.serveHTTP != nil {
+		cs = append(cs, m.serveHTTP)
+	}
+	if m.serverStreamMsgErrorCounter != nil {
+		cs = append(cs, m.serverStreamMsgErrorCounter)
+	}
+	if m.serverMsgSizeLimits != nil {
+		cs = append(cs, m.serverMsgSizeLimits)
+	}
+	if m.serverConnBytes != nil {
+		cs = append(cs, m.serverConnBytes)
+	}
+	if m.serverConsumerSLO != nil {
+		cs = append(cs, m.serverConsumerSLO.counter)
+	}
+	if m.serverMsgSizeReceivedHistogramEnabled {
+		cs = append(cs, m.serverMsgSizeReceivedHistogram)
+	}
+	if m.serverMsgSizeSentHistogramEnabled {
+		cs = append(cs, m.serverMsgSizeSentHistogram)
+	}
+	if m.serverMethodTrigger != nil {
+		cs = append(cs, m.serverMethodTrigger)
+	}
+	if m.serverInterceptorHistogramEnabled {
+		cs = append(cs, m.serverInterceptorHistogram)
+	}
+	if m.seriesBudget.dropped != nil {
+		cs = append(cs, m.seriesBudget.dropped)
+	}
+	return cs
+}
+
+// Dump gathers only the metrics of this ServerMetrics and writes them to w in
+// the given exposition format. It is intended for debugging, e.g. serving
+// "just the gRPC metrics" over an admin endpoint without exposing the full
+// registry.
+func (m *ServerMetrics) Dump(w io.Writer, format expfmt.Format) error {
+	// Dumping must not freeze the exported metrics like registering m would.
+	return dumpCollector(unfrozenServerMetrics{m}, w, format)
+}
+
+// unfrozenServerMetrics collects the metrics of ServerMetrics like its Collect
+// does, but doesn't freeze them when described.
+type unfrozenServerMetrics struct {
+	*ServerMetrics
+}
+
+func (m unfrozenServerMetrics) Describe(ch chan<- *prom.Desc) {
+	m.describe(ch)
 }
 
 // UnaryServerInterceptor is a gRPC server-side interceptor that provides Prometheus monitoring for Unary RPCs.
 func (m *ServerMetrics) UnaryServerInterceptor() func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if m.serverMsgSizeLimits != nil {
+			markIntercepted(ctx)
+		}
+		if metricsSuppressed(ctx) || m.unexpectedMethod(Unary, info.FullMethod) {
+			return handler(ctx, req)
+		}
 		monitor := newServerReporter(m, Unary, info.FullMethod)
+		monitor.histogramOverride = histogramOverrideFrom(ctx)
 		monitor.ReceivedMessage()
+		monitor.ReceivedMessageSize(req)
+		monitor.ReceivedRequestCost(ctx, req)
+		monitor.ReceivedRequestBatchSize(req)
+		if m.serverPhaseHistogram != nil {
+			monitor.phases = &phases{}
+			ctx = context.WithValue(ctx, phasesKey{}, monitor.phases)
+		}
+		if m.serverDependencyHistogram != nil {
+			monitor.dependencies = &phases{}
+			ctx = context.WithValue(ctx, dependenciesKey{}, monitor.dependencies)
+		}
+		var start time.Time
+		if monitor.config.clockSkewTrailerEnabled {
+			start = time.Now()
+		}
+		monitor.HandlerStarting(ctx)
 		resp, err := handler(ctx, req)
+		if monitor.config.clockSkewTrailerEnabled {
+			m.setClockSkewTrailer(ctx, start)
+		}
 		st, _ := grpcstatus.FromError(err)
+		if m.lateCompletions != nil {
+			m.lateCompletions.handled(ctx, st.Code())
+		}
+		monitor.HandlerReturned(ctx, st.Code())
 		monitor.Handled(st.Code())
 		if err == nil {
 			monitor.SentMessage()
+			monitor.SentMessageSize(resp)
 		}
+		monitor.logAccess(ctx, st.Code())
 		return resp, err
 	}
 }
@@ -118,23 +935,62 @@ func (m *ServerMetrics) UnaryServerInterceptor() func(ctx context.Context, req i
 // StreamServerInterceptor is a gRPC server-side interceptor that provides Prometheus monitoring for Streaming RPCs.
 func (m *ServerMetrics) StreamServerInterceptor() func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if metricsSuppressed(ss.Context()) || m.unexpectedMethod(streamRPCType(info), info.FullMethod) {
+			return handler(srv, ss)
+		}
 		monitor := newServerReporter(m, streamRPCType(info), info.FullMethod)
+		monitor.histogramOverride = histogramOverrideFrom(ss.Context())
+		if monitor.config.responseItemsHistogramEnabled {
+			monitor.responseItems = &responseItems{}
+			ss = &responseItemsServerStream{ss, context.WithValue(ss.Context(), responseItemsKey{}, monitor.responseItems)}
+		}
+		if m.serverPhaseHistogram != nil {
+			monitor.phases = &phases{}
+			ss = &contextServerStream{ss, context.WithValue(ss.Context(), phasesKey{}, monitor.phases)}
+		}
+		if m.serverDependencyHistogram != nil {
+			monitor.dependencies = &phases{}
+			ss = &contextServerStream{ss, context.WithValue(ss.Context(), dependenciesKey{}, monitor.dependencies)}
+		}
+		if m.serverStreamCreditsGauge != nil {
+			monitor.credits = newStreamCredits(monitor)
+			ss = &contextServerStream{ss, context.WithValue(ss.Context(), streamCreditsKey{}, monitor.credits)}
+		}
+		if m.serverOutOfOrderCounter != nil {
+			ss = &contextServerStream{ss, context.WithValue(ss.Context(), streamSequenceKey{}, newStreamSequence(monitor))}
+		}
+		monitor.HandlerStarting(ss.Context())
 		err := handler(srv, &monitoredServerStream{ss, monitor})
 		st, _ := grpcstatus.FromError(err)
+		monitor.HandlerReturned(ss.Context(), st.Code())
 		monitor.Handled(st.Code())
+		monitor.logAccess(ss.Context(), st.Code())
 		return err
 	}
 }
 
 // InitializeMetrics initializes all metrics, with their appropriate null
 // value, for all gRPC methods registered on a gRPC server. This is useful, to
-// ensure that all metrics exist when collecting and querying.
-func (m *ServerMetrics) InitializeMetrics(server *grpc.Server) {
-	serviceInfo := server.GetServiceInfo()
-	for serviceName, info := range serviceInfo {
-		for _, mInfo := range info.Methods {
-			preRegisterMethod(m, serviceName, &mInfo)
+// ensure that all metrics exist when collecting and querying. The server is
+// usually a *grpc.Server, see ServiceDescs and WrapRegistrar for servers
+// hidden behind other types.
+func (m *ServerMetrics) InitializeMetrics(server ServiceInfoProvider) {
+	for serviceName, info := range server.GetServiceInfo() {
+		m.initializeService(serviceName, info)
+	}
+}
+
+func (m *ServerMetrics) initializeService(serviceName string, info grpc.ServiceInfo) {
+	if m.methodInfo != nil {
+		m.methodInfo.addService(serviceName, info.Metadata)
+	}
+	for _, mInfo := range info.Methods {
+		if m.approvedMethods != nil && !m.approvedMethods[methodKey{serviceName, mInfo.Name}] {
+			m.serverUnexpectedMethodCounter.GetMetricWithLabelValues(string(typeFromMethodInfo(&mInfo)), serviceName, mInfo.Name)
+			continue
 		}
+		m.methodTypes.Store("/"+serviceName+"/"+mInfo.Name, typeFromMethodInfo(&mInfo))
+		preRegisterMethod(m, serviceName, &mInfo)
 	}
 }
 
@@ -154,9 +1010,20 @@ type monitoredServerStream struct {
 }
 
 func (s *monitoredServerStream) SendMsg(m interface{}) error {
+	var start time.Time
+	if s.monitor.metrics.serverBackpressure != nil {
+		start = time.Now()
+	}
 	err := s.ServerStream.SendMsg(m)
+	if !start.IsZero() {
+		now := time.Now()
+		s.monitor.metrics.serverBackpressure.sent(s.monitor, now.Sub(start), now)
+	}
 	if err == nil {
 		s.monitor.SentMessage()
+		s.monitor.SentMessageSize(m)
+	} else {
+		s.monitor.messageError("sent", err)
 	}
 	return err
 }
@@ -165,22 +1032,108 @@ func (s *monitoredServerStream) RecvMsg(m interface{}) error {
 	err := s.ServerStream.RecvMsg(m)
 	if err == nil {
 		s.monitor.ReceivedMessage()
+		s.monitor.ReceivedMessageSize(m)
+		s.monitor.ReceivedRequestCost(s.Context(), m)
+		s.monitor.ReceivedRequestBatchSize(m)
+	} else {
+		s.monitor.messageError("received", err)
 	}
 	return err
 }
 
+// needsHandlingTime reports whether any metric enabled in config depends on
+// the handling time of RPCs, which is only measured if needed.
+func (m *ServerMetrics) needsHandlingTime(config *serverRuntimeConfig) bool {
+	return config.handledHistogramEnabled ||
+		config.handledSummaryEnabled ||
+		config.slowHandledCounterEnabled ||
+		config.handledOverflowCounterEnabled ||
+		config.failedSecondsCounterEnabled ||
+		m.serverRecentMax != nil ||
+		m.bucketAdvisor != nil ||
+		m.serverHeatmapHistogram != nil ||
+		m.serverTransportHistogram != nil ||
+		m.recorder != nil ||
+		m.accessLogger != nil ||
+		m.rpcSampler != nil ||
+		m.serverScrapeMinMax != nil ||
+		m.serverMethodTrigger != nil
+}
+
 // preRegisterMethod is invoked on Register of a Server, allowing all gRPC services labels to be pre-populated.
 func preRegisterMethod(metrics *ServerMetrics, serviceName string, mInfo *grpc.MethodInfo) {
 	methodName := mInfo.Name
 	methodType := string(typeFromMethodInfo(mInfo))
 	// These are just references (no increments), as just referencing will create the labels but not set values.
-	metrics.serverStartedCounter.GetMetricWithLabelValues(methodType, serviceName, methodName)
-	metrics.serverStreamMsgReceived.GetMetricWithLabelValues(methodType, serviceName, methodName)
-	metrics.serverStreamMsgSent.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	if !metrics.serverStartedCounterDisabled {
+		metrics.startedCounter(serviceName).GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if !metrics.serverMsgCountersDisabled {
+		metrics.msgReceivedCounter(serviceName).GetMetricWithLabelValues(methodType, serviceName, methodName)
+		metrics.msgSentCounter(serviceName).GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
 	if metrics.serverHandledHistogramEnabled {
-		metrics.serverHandledHistogram.GetMetricWithLabelValues(methodType, serviceName, methodName)
+		metrics.handledHistogram(serviceName).GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverSlowHandledCounterEnabled {
+		metrics.serverSlowHandledCounter.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverRecvSizeRatioHistogramEnabled {
+		metrics.serverRecvSizeRatioHistogram.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverMsgSizeReceivedHistogramEnabled {
+		metrics.serverMsgSizeReceivedHistogram.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverMsgSizeSentHistogramEnabled {
+		metrics.serverMsgSizeSentHistogram.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverHandledSummaryEnabled {
+		metrics.serverHandledSummary.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverHandledOverflowCounterEnabled {
+		metrics.serverHandledOverflowCounter.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverLongTermHandledCounterEnabled {
+		metrics.serverLongTermHandledCounter.GetMetricWithLabelValues(serviceName, methodName)
+	}
+	if metrics.serverGCOverlapCounterEnabled {
+		metrics.serverGCOverlapCounter.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverFailedSecondsCounterEnabled {
+		metrics.serverFailedSecondsCounter.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverHeaderProcessingHistogramEnabled {
+		metrics.serverHeaderProcessingHistogram.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverRequestCostCounter != nil {
+		metrics.serverRequestCostCounter.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverBatchSizeHistogram != nil {
+		metrics.serverBatchSizeHistogram.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverResponseItemsHistogramEnabled && (mInfo.IsClientStream || mInfo.IsServerStream) {
+		metrics.serverResponseItemsHistogram.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverCancellationCounterEnabled {
+		for _, cause := range []string{cancelCauseDeadlineBeforeHandler, cancelCauseClientCanceled, cancelCauseDeadlineExceeded, cancelCauseHandlerObserved} {
+			metrics.serverCancellationCounter.GetMetricWithLabelValues(methodType, serviceName, methodName, cause)
+		}
+	}
+	if metrics.serverWastedWorkCounter != nil {
+		metrics.serverWastedWorkCounter.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverOutOfOrderCounter != nil && (mInfo.IsClientStream || mInfo.IsServerStream) {
+		metrics.serverOutOfOrderCounter.GetMetricWithLabelValues(methodType, serviceName, methodName)
+		metrics.serverDuplicateCounter.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if metrics.serverLargeMessageCounter != nil {
+		metrics.serverLargeMessageCounter.GetMetricWithLabelValues(methodType, serviceName, methodName, "received")
+		metrics.serverLargeMessageCounter.GetMetricWithLabelValues(methodType, serviceName, methodName, "sent")
+	}
+	if metrics.deprecatedMethods[methodKey{serviceName, methodName}] {
+		metrics.serverDeprecatedCallCounter.GetMetricWithLabelValues(methodType, serviceName, methodName)
 	}
 	for _, code := range allCodes {
-		metrics.serverHandledCounter.GetMetricWithLabelValues(methodType, serviceName, methodName, code.String())
+		metrics.handledCounter(serviceName).GetMetricWithLabelValues(methodType, serviceName, methodName, code.String())
 	}
 }
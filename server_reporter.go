@@ -4,6 +4,8 @@
 package grpc_prometheus
 
 import (
+	"context"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc/codes"
@@ -15,32 +17,315 @@ type serverReporter struct {
 	serviceName string
 	methodName  string
 	startTime   time.Time
+
+	resourceUsageStart resourceUsage
+	gcCyclesStart      uint64
+	responseItems      *responseItems
+	ctxErrAtStart      error
+	handlerStart       time.Time
+	inFlight           *methodInFlight
+	longPollInFlight   *methodInFlight
+	histogramOverride  histogramOverride
+	accessLog          *accessLogStats
+	sampled            bool
+	transport          string
+	phases             *phases
+	dependencies       *phases
+	credits            *streamCredits
+	consumer           string
+	capabilities       peerCapabilities
+	elapsed            time.Duration
+
+	// config is the runtime config at the start of the RPC, so that its
+	// handling time is observed consistently. Per message settings are
+	// loaded again for every message to apply to long-lived streams.
+	config *serverRuntimeConfig
 }
 
 func newServerReporter(m *ServerMetrics, rpcType grpcType, fullMethod string) *serverReporter {
 	r := &serverReporter{
 		metrics: m,
+		config:  m.runtimeConfig(),
 		rpcType: rpcType,
 	}
-	if r.metrics.serverHandledHistogramEnabled {
+	if r.metrics.needsHandlingTime(r.config) {
 		r.startTime = time.Now()
 	}
 	r.serviceName, r.methodName = splitMethodName(fullMethod)
-	r.metrics.serverStartedCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	r.sampled = r.metrics.sampleRPC()
+	if r.metrics.accessLogger != nil || r.sampled {
+		r.accessLog = &accessLogStats{}
+	}
+	if !r.config.startedCounterDisabled {
+		r.metrics.startedCounter(r.serviceName).WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	}
+	if r.metrics.serverAPIVersions != nil {
+		r.metrics.serverAPIVersions.started(r.serviceName)
+	}
+	if r.metrics.deprecatedMethods[methodKey{r.serviceName, r.methodName}] {
+		r.metrics.serverDeprecatedCallCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	}
+	if r.metrics.recorder != nil {
+		r.metrics.recorder.RPCStarted(string(r.rpcType), r.serviceName, r.methodName)
+	}
+	if r.metrics.serverResourceAccounting != nil {
+		r.resourceUsageStart = r.metrics.serverResourceAccounting.start()
+	}
+	if r.config.gcOverlapCounterEnabled {
+		r.gcCyclesStart = readGCCycles()
+	}
+	if r.metrics.serverInFlight != nil {
+		r.inFlight = r.metrics.serverInFlight.start(r.serviceName, r.methodName)
+	}
+	if r.metrics.serverLongPollInFlight != nil {
+		r.longPollInFlight = r.metrics.serverLongPollInFlight.start(r.serviceName, r.methodName)
+	}
 	return r
 }
 
 func (r *serverReporter) ReceivedMessage() {
-	r.metrics.serverStreamMsgReceived.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	if !r.config.msgCountersDisabled {
+		r.metrics.msgReceivedCounter(r.serviceName).WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	}
+	if r.metrics.recorder != nil {
+		r.metrics.recorder.MsgReceived(string(r.rpcType), r.serviceName, r.methodName)
+	}
+	if r.accessLog != nil {
+		atomic.AddInt64(&r.accessLog.msgsReceived, 1)
+	}
+}
+
+// ReceivedMessageSize observes the size of a received message, computing it
+// only if a metric needs it.
+func (r *serverReporter) ReceivedMessageSize(msg interface{}) {
+	if r.config.recvSizeRatioHistogramEnabled || r.config.msgSizeReceivedHistogramEnabled || r.accessLog != nil ||
+		r.metrics.serverMsgSizeStats != nil || r.metrics.serverLargeMessageCounter != nil {
+		r.receivedMessageSize(messageSize(msg))
+	}
+}
+
+func (r *serverReporter) receivedMessageSize(size int) {
+	config := r.metrics.runtimeConfig()
+	if config.recvSizeRatioHistogramEnabled && !config.msgSizeMetricsPaused {
+		ratio := float64(size) / float64(r.metrics.serverMaxRecvMsgSize)
+		r.metrics.serverRecvSizeRatioHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Observe(ratio)
+	}
+	if config.msgSizeReceivedHistogramEnabled && !config.msgSizeMetricsPaused {
+		r.metrics.serverMsgSizeReceivedHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Observe(float64(size))
+	}
+	if r.accessLog != nil {
+		atomic.AddInt64(&r.accessLog.bytesReceived, int64(size))
+	}
+	if (r.metrics.serverMsgSizeStats != nil || r.metrics.serverLargeMessageCounter != nil) && !config.msgSizeMetricsPaused {
+		r.observeMessageSize("received", size)
+	}
+}
+
+func (r *serverReporter) ReceivedRequestCost(ctx context.Context, msg interface{}) {
+	if r.metrics.serverRequestCostFunc != nil {
+		cost := r.metrics.serverRequestCostFunc(ctx, msg)
+		if !(cost >= 0) {
+			// A counter can't decrease, skip negative and NaN costs.
+			return
+		}
+		r.metrics.serverRequestCostCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Add(cost)
+	}
+}
+
+func (r *serverReporter) ReceivedRequestBatchSize(msg interface{}) {
+	if r.metrics.serverBatchSizeFunc != nil {
+		size := r.metrics.serverBatchSizeFunc(msg)
+		r.metrics.serverBatchSizeHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Observe(float64(size))
+	}
+}
+
+// HandlerStarting is called right before the handler of the RPC is invoked.
+func (r *serverReporter) HandlerStarting(ctx context.Context) {
+	if r.config.cancellationCounterEnabled {
+		r.ctxErrAtStart = ctx.Err()
+	}
+	if r.metrics.transportFunc != nil {
+		r.transport = r.metrics.transportFunc(ctx)
+	}
+	if r.metrics.serverConsumerRequestsCounter != nil {
+		r.consumer = r.metrics.consumerOf(ctx)
+	}
+	if r.metrics.serverPeerCapabilityCounter != nil {
+		r.capabilities = peerCapabilitiesOf(ctx)
+	}
+	if t := r.metrics.serverTopK; t != nil {
+		if key := t.keyFunc(ctx, "/"+r.serviceName+"/"+r.methodName); key != "" {
+			t.observe(key, time.Now())
+		}
+	}
+	if r.metrics.serverWastedWorkCounter != nil {
+		r.handlerStart = time.Now()
+	}
+}
+
+// HandlerReturned is called right after the handler of the RPC returned.
+func (r *serverReporter) HandlerReturned(ctx context.Context, code codes.Code) {
+	if r.config.cancellationCounterEnabled {
+		if err := ctx.Err(); err != nil {
+			cause := cancellationCause(r.ctxErrAtStart, err, code)
+			r.metrics.serverCancellationCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName, cause).Inc()
+		}
+	}
+	if r.metrics.serverWastedWorkCounter != nil && code == codes.OK && ctx.Err() != nil {
+		r.metrics.serverWastedWorkCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+		r.metrics.serverWastedWorkHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Observe(time.Since(r.handlerStart).Seconds())
+	}
+	if r.metrics.spanStatusFunc != nil {
+		r.checkSpanStatus(ctx, code)
+	}
 }
 
 func (r *serverReporter) SentMessage() {
-	r.metrics.serverStreamMsgSent.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	if !r.config.msgCountersDisabled {
+		r.metrics.msgSentCounter(r.serviceName).WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	}
+	if r.metrics.recorder != nil {
+		r.metrics.recorder.MsgSent(string(r.rpcType), r.serviceName, r.methodName)
+	}
+	if r.responseItems != nil {
+		atomic.AddInt64(&r.responseItems.messages, 1)
+	}
+	if r.accessLog != nil {
+		atomic.AddInt64(&r.accessLog.msgsSent, 1)
+	}
+}
+
+// SentMessageSize observes the size of a sent message, computing it only if a
+// metric needs it.
+func (r *serverReporter) SentMessageSize(msg interface{}) {
+	if r.accessLog != nil || r.config.msgSizeSentHistogramEnabled || r.metrics.serverMsgSizeStats != nil ||
+		r.metrics.serverLargeMessageCounter != nil || r.metrics.serverMsgSizeLimits != nil && r.rpcType == Unary {
+		r.sentMessageSize(messageSize(msg))
+	}
+}
+
+func (r *serverReporter) sentMessageSize(size int) {
+	if r.accessLog != nil {
+		atomic.AddInt64(&r.accessLog.bytesSent, int64(size))
+	}
+	config := r.metrics.runtimeConfig()
+	if config.msgSizeSentHistogramEnabled && !config.msgSizeMetricsPaused {
+		r.metrics.serverMsgSizeSentHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Observe(float64(size))
+	}
+	if (r.metrics.serverMsgSizeStats != nil || r.metrics.serverLargeMessageCounter != nil) && !config.msgSizeMetricsPaused {
+		r.observeMessageSize("sent", size)
+	}
+	if l := r.metrics.serverMsgSizeLimits; l != nil && r.rpcType == Unary && size > l.maxSendMsgSize {
+		// gRPC rejects the response after the interceptors returned.
+		l.exceed(r.rpcType, r.serviceName, r.methodName, "sent")
+	}
 }
 
 func (r *serverReporter) Handled(code codes.Code) {
-	r.metrics.serverHandledCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName, code.String()).Inc()
-	if r.metrics.serverHandledHistogramEnabled {
-		r.metrics.serverHandledHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Observe(time.Since(r.startTime).Seconds())
+	r.metrics.handledCounter(r.serviceName).WithLabelValues(string(r.rpcType), r.serviceName, r.methodName, code.String()).Inc()
+	benign := r.metrics.benignCode(r.serviceName, r.methodName, code)
+	if benign {
+		r.metrics.serverBenignHandledCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName, code.String()).Inc()
+	}
+	if r.config.longTermHandledCounterEnabled {
+		r.metrics.serverLongTermHandledCounter.WithLabelValues(r.serviceName, r.methodName).Inc()
+	}
+	if !r.startTime.IsZero() {
+		elapsed := time.Since(r.startTime)
+		r.elapsed = elapsed
+		r.observeHandlingTime(elapsed)
+		if r.config.failedSecondsCounterEnabled && code != codes.OK && !benign {
+			r.metrics.serverFailedSecondsCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Add(elapsed.Seconds())
+		}
+		if r.metrics.recorder != nil {
+			r.metrics.recorder.RPCHandled(string(r.rpcType), r.serviceName, r.methodName, code.String(), elapsed)
+		}
+	}
+	if r.metrics.serverConsumerRequestsCounter != nil {
+		r.observeConsumer(code, benign)
+	}
+	if r.metrics.serverPeerCapabilityCounter != nil {
+		r.observePeerCapabilities(code)
+	}
+	if r.metrics.serverErrorBudget != nil && !benign {
+		r.metrics.serverErrorBudget.observe(r.serviceName, r.methodName, code, time.Now())
+	}
+	if r.metrics.serverErrorSpikes != nil && !benign {
+		r.metrics.serverErrorSpikes.observe(r.serviceName, r.methodName, code, time.Now())
+	}
+	if r.metrics.serverMethodTrigger != nil && !benign {
+		r.metrics.serverMethodTrigger.observe(r.serviceName, r.methodName, code, r.elapsed, time.Now())
+	}
+	if r.metrics.serverResourceAccounting != nil {
+		r.metrics.serverResourceAccounting.finish(r.resourceUsageStart, string(r.rpcType), r.serviceName, r.methodName)
+	}
+	if r.config.gcOverlapCounterEnabled && readGCCycles() != r.gcCyclesStart {
+		r.metrics.serverGCOverlapCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	}
+	if r.metrics.serverInFlight != nil {
+		r.metrics.serverInFlight.finish(r.inFlight)
+	}
+	if r.metrics.serverLongPollInFlight != nil {
+		r.metrics.serverLongPollInFlight.finish(r.longPollInFlight)
+	}
+	if r.credits != nil {
+		r.credits.finish()
+	}
+	if r.phases != nil {
+		r.phases.flush(r.metrics.serverPhaseHistogram, r.rpcType, r.serviceName, r.methodName)
+	}
+	if r.dependencies != nil {
+		r.dependencies.flush(r.metrics.serverDependencyHistogram, r.rpcType, r.serviceName, r.methodName)
+	}
+	if r.responseItems != nil {
+		r.metrics.serverResponseItemsHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Observe(float64(r.responseItems.count()))
+	}
+}
+
+func (r *serverReporter) observeHandlingTime(elapsed time.Duration) {
+	if r.metrics.inWarmup(r.startTime) {
+		if r.metrics.serverWarmupHandledHistogram != nil && r.handlingTimeHistogramActive() {
+			r.metrics.serverWarmupHandledHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Observe(elapsed.Seconds())
+		}
+		return
+	}
+	if r.metrics.bucketAdvisor != nil {
+		r.metrics.bucketAdvisor.Observe(elapsed.Seconds())
+	}
+	if r.handlingTimeHistogramActive() {
+		r.metrics.handledHistogram(r.serviceName).WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Observe(elapsed.Seconds())
+	}
+	if r.metrics.serverHeatmapHistogram != nil && r.histogramOverride != histogramSkip {
+		r.metrics.serverHeatmapHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Observe(elapsed.Seconds())
+	}
+	if r.metrics.serverTransportHistogram != nil && r.histogramOverride != histogramSkip {
+		r.metrics.serverTransportHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName, r.transport).Observe(elapsed.Seconds())
+	}
+	if r.metrics.serverRecentMax != nil {
+		r.metrics.serverRecentMax.observe(r.serviceName, r.methodName, elapsed, time.Now())
+	}
+	if r.metrics.serverScrapeMinMax != nil {
+		r.metrics.serverScrapeMinMax.observe(rpcKey{r.rpcType, r.serviceName, r.methodName}, elapsed)
+	}
+	if r.config.handledSummaryEnabled {
+		r.metrics.serverHandledSummary.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Observe(elapsed.Seconds())
+	}
+	if r.config.slowHandledCounterEnabled && elapsed > r.config.slowHandlingThreshold {
+		r.metrics.serverSlowHandledCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	}
+	if r.config.handledOverflowCounterEnabled && elapsed.Seconds() > r.metrics.serverHandledOverflowBound {
+		r.metrics.serverHandledOverflowCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+	}
+}
+
+// handlingTimeHistogramActive reports whether the handling time of the RPC is
+// observed in the handling time histograms, taking its override into account.
+func (r *serverReporter) handlingTimeHistogramActive() bool {
+	switch r.histogramOverride {
+	case histogramForce:
+		return r.config.handledHistogramEnabled
+	case histogramSkip:
+		return false
 	}
+	return r.config.handledHistogramEnabled && !r.config.handlingTimeHistogramPaused
 }
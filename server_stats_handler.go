@@ -0,0 +1,71 @@
+package grpc_prometheus
+
+import (
+	"context"
+
+	"github.com/grpc-ecosystem/go-grpc-prometheus/packages/grpcstatus"
+	"google.golang.org/grpc/stats"
+)
+
+// NewServerStatsHandler returns a stats.Handler, to install with
+// grpc.StatsHandler instead of the interceptors, recording the metrics of m
+// from the events gRPC reports for each RPC: messages received and sent, with
+// their sizes, and the end of RPCs. Unlike the interceptors, it also sees
+// RPCs failing before reaching them, e.g. requests exceeding the message
+// size limit. Installing both double counts RPCs.
+//
+// The type of RPCs isn't part of the events, it is learned from the services
+// given to InitializeMetrics: RPCs of other methods aren't recorded. To also
+// install other stats handlers of m, e.g. ConnBytesStatsHandler, combine them
+// with ChainStatsHandlers.
+func (m *ServerMetrics) NewServerStatsHandler() stats.Handler {
+	return serverStatsHandler{m}
+}
+
+type serverStatsKey struct{}
+
+// serverStatsHandler is a stats.Handler reporting server RPCs to a
+// serverReporter attached to their context.
+type serverStatsHandler struct {
+	m *ServerMetrics
+}
+
+func (h serverStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	rpcType, ok := h.m.methodTypes.Load(info.FullMethodName)
+	if !ok || metricsSuppressed(ctx) {
+		return ctx
+	}
+	monitor := newServerReporter(h.m, rpcType.(grpcType), info.FullMethodName)
+	monitor.histogramOverride = histogramOverrideFrom(ctx)
+	monitor.HandlerStarting(ctx)
+	return context.WithValue(ctx, serverStatsKey{}, monitor)
+}
+
+func (h serverStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if s.IsClient() {
+		return
+	}
+	monitor, ok := ctx.Value(serverStatsKey{}).(*serverReporter)
+	if !ok {
+		return
+	}
+	switch s := s.(type) {
+	case *stats.InPayload:
+		monitor.ReceivedMessage()
+		monitor.receivedMessageSize(s.Length)
+	case *stats.OutPayload:
+		monitor.SentMessage()
+		monitor.sentMessageSize(s.Length)
+	case *stats.End:
+		st, _ := grpcstatus.FromError(s.Error)
+		monitor.HandlerReturned(ctx, st.Code())
+		monitor.Handled(st.Code())
+		monitor.logAccess(ctx, st.Code())
+	}
+}
+
+func (h serverStatsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h serverStatsHandler) HandleConn(ctx context.Context, s stats.ConnStats) {}
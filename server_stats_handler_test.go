@@ -0,0 +1,82 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestServerStatsHandler(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableMsgSizeReceivedBytesHistogram()
+	server := grpc.NewServer(grpc.StatsHandler(m.NewServerStatsHandler()))
+	pb_testproto.RegisterTestServiceServer(server, &testService{t: t})
+	m.InitializeMetrics(server)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, lis.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb_testproto.NewTestServiceClient(conn)
+
+	_, err = client.Ping(ctx, &pb_testproto.PingRequest{Value: "ping"})
+	require.NoError(t, err)
+	_, err = client.PingError(ctx, &pb_testproto.PingRequest{ErrorCodeReturned: uint32(codes.FailedPrecondition)})
+	require.Error(t, err)
+	stream, err := client.PingList(ctx, &pb_testproto.PingRequest{})
+	require.NoError(t, err)
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+
+	requireValue(t, 1, m.serverStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValueWithRetry(ctx, t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+	requireValueWithRetry(ctx, t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "FailedPrecondition"))
+	requireValueWithRetry(ctx, t, 1, m.serverHandledCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "OK"))
+	requireValue(t, countListResponses, m.serverStreamMsgSent.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
+	requireValueHistCount(t, 1, m.serverMsgSizeReceivedHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+}
+
+func TestChainStatsHandlers(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableConnBytesCounters()
+	server := grpc.NewServer(grpc.StatsHandler(ChainStatsHandlers(m.NewServerStatsHandler(), m.ConnBytesStatsHandler())))
+	pb_testproto.RegisterTestServiceServer(server, &testService{t: t})
+	m.InitializeMetrics(server)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, lis.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = pb_testproto.NewTestServiceClient(conn).Ping(ctx, &pb_testproto.PingRequest{Value: "ping"})
+	require.NoError(t, err)
+
+	// Both handlers see the RPC.
+	requireValueWithRetry(ctx, t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+	received, sent := collectConnBytes(t, m.serverConnBytes)
+	require.Len(t, received, 1)
+	require.Len(t, sent, 1)
+	for _, v := range received {
+		require.True(t, v > 0, "received bytes must be counted")
+	}
+}
@@ -5,6 +5,7 @@ package grpc_prometheus
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -12,6 +13,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"runtime"
+	"runtime/debug"
 	"strings"
 	"testing"
 	"time"
@@ -22,11 +25,13 @@ import (
 	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
 	"google.golang.org/grpc/status"
 )
 
@@ -192,6 +197,20 @@ func (s *ServerInterceptorTestSuite) TestStreamingIncrementsMetrics() {
 		DefaultServerMetrics.serverHandledHistogram.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
 }
 
+func (s *ServerInterceptorTestSuite) TestDumpContainsOnlyServerMetrics() {
+	_, err := s.testClient.PingEmpty(s.ctx, &pb_testproto.Empty{}) // should return with code=OK
+	require.NoError(s.T(), err)
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), DefaultServerMetrics.Dump(&buf, expfmt.FmtText))
+	out := buf.String()
+	assert.Contains(s.T(), out, `grpc_server_handled_total{grpc_code="OK",grpc_method="PingEmpty",grpc_service="mwitkow.testproto.TestService",grpc_type="unary"} 1`)
+	assert.NotContains(s.T(), out, "grpc_client_")
+	assert.NotContains(s.T(), out, "go_goroutines")
+
+	require.Error(s.T(), DefaultServerMetrics.Dump(&buf, expfmt.FmtUnknown), "unknown formats must be rejected")
+}
+
 // fetchPrometheusLines does mocked HTTP GET request against real prometheus handler to get the same view that Prometheus
 // would have while scraping this endpoint.
 // Order of matching label vales does not matter.
@@ -352,3 +371,469 @@ func requireValueWithRetryHistCount(ctx context.Context, t *testing.T, expect in
 		}
 	}
 }
+
+func TestWarmupExclusionRecordsSeparately(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithWarmupExclusion(time.Hour))
+	m.EnableHandlingTimeHistogram()
+	_, err := m.UnaryServerInterceptor()(context.Background(), &pb_testproto.Empty{},
+		&grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	require.NoError(t, err)
+
+	requireValueHistCount(t, 0, m.serverHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty"))
+	requireValueHistCount(t, 1, m.serverWarmupHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty"))
+}
+
+func TestHeaderProcessingTimeNeedsStatsHandler(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableHeaderProcessingTimeHistogram()
+	interceptor := m.HeaderProcessingUnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+
+	_, err := interceptor(context.Background(), &pb_testproto.Empty{}, info, handler)
+	require.NoError(t, err)
+	requireValueHistCount(t, 0, m.serverHeaderProcessingHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty"))
+
+	sh := m.HeaderProcessingStatsHandler()
+	ctx := sh.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: info.FullMethod})
+	sh.HandleRPC(ctx, &stats.InHeader{FullMethod: info.FullMethod})
+	_, err = interceptor(ctx, &pb_testproto.Empty{}, info, handler)
+	require.NoError(t, err)
+	requireValueHistCount(t, 1, m.serverHeaderProcessingHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty"))
+}
+
+func TestTimedInterceptorExcludesHandlerTime(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableInterceptorTimingHistogram()
+	interceptor := m.TimedInterceptor("test_excludes_handler", func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(ctx, req)
+	})
+	_, err := interceptor(context.Background(), &pb_testproto.Empty{},
+		&grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			time.Sleep(100 * time.Millisecond)
+			return nil, nil
+		})
+	require.NoError(t, err)
+
+	h := &dto.Metric{}
+	require.NoError(t, m.serverInterceptorHistogram.WithLabelValues("test_excludes_handler").(prometheus.Histogram).Write(h))
+	require.EqualValues(t, 1, h.GetHistogram().GetSampleCount())
+	require.True(t, h.GetHistogram().GetSampleSum() < 0.05, "handler time must not be attributed to the interceptor")
+}
+
+func TestRequestCostFunc(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithRequestCostFunc(func(ctx context.Context, req interface{}) float64 {
+		if req.(*pb_testproto.PingRequest).ErrorCodeReturned != 0 {
+			return -1
+		}
+		return float64(len(req.(*pb_testproto.PingRequest).Value))
+	}))
+	for _, req := range []*pb_testproto.PingRequest{{Value: "abc"}, {Value: "de"}, {Value: "negative", ErrorCodeReturned: 1}} {
+		_, err := m.UnaryServerInterceptor()(context.Background(), req,
+			&grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+			func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+		require.NoError(t, err)
+	}
+	requireValue(t, 5, m.serverRequestCostCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+}
+
+func TestBatchSizeFunc(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithBatchSizeFunc(func(req interface{}) int {
+		return int(req.(*pb_testproto.PingRequest).SleepTimeMs)
+	}))
+	_, err := m.UnaryServerInterceptor()(context.Background(), &pb_testproto.PingRequest{SleepTimeMs: 7},
+		&grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	require.NoError(t, err)
+
+	h := &dto.Metric{}
+	require.NoError(t, m.serverBatchSizeHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping").(prometheus.Histogram).Write(h))
+	require.EqualValues(t, 1, h.GetHistogram().GetSampleCount())
+	require.EqualValues(t, 7, h.GetHistogram().GetSampleSum())
+}
+
+// fakeServerStream is a grpc.ServerStream discarding sent messages.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context { return s.ctx }
+
+func (s *fakeServerStream) SendMsg(m interface{}) error { return nil }
+
+func TestResponseItemsHistogram(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableResponseItemsHistogram()
+	interceptor := m.StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingList", IsServerStream: true}
+
+	for _, explicit := range []bool{true, false} {
+		err := interceptor(nil, &fakeServerStream{ctx: context.Background()}, info, func(srv interface{}, ss grpc.ServerStream) error {
+			for i := 0; i < 3; i++ {
+				if explicit {
+					AddItems(ss.Context(), 10)
+				}
+				if err := ss.SendMsg(&pb_testproto.PingResponse{}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	h := &dto.Metric{}
+	require.NoError(t, m.serverResponseItemsHistogram.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList").(prometheus.Histogram).Write(h))
+	require.EqualValues(t, 2, h.GetHistogram().GetSampleCount())
+	require.EqualValues(t, 30+3, h.GetHistogram().GetSampleSum(), "30 explicit items plus 3 messages as fallback")
+}
+
+type countingRecorder struct {
+	started, handled, received, sent int
+}
+
+func (r *countingRecorder) RPCStarted(rpcType, service, method string) { r.started++ }
+
+func (r *countingRecorder) RPCHandled(rpcType, service, method, code string, handlingTime time.Duration) {
+	r.handled++
+}
+
+func (r *countingRecorder) MsgReceived(rpcType, service, method string) { r.received++ }
+
+func (r *countingRecorder) MsgSent(rpcType, service, method string) { r.sent++ }
+
+func TestRecorderReceivesEvents(t *testing.T) {
+	rec := &countingRecorder{}
+	m := NewServerMetrics()
+	m.Configure(WithRecorder(rec))
+	_, err := m.UnaryServerInterceptor()(context.Background(), &pb_testproto.Empty{},
+		&grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	require.NoError(t, err)
+	require.Equal(t, countingRecorder{started: 1, handled: 1, received: 1, sent: 1}, *rec)
+}
+
+func TestMarkDeprecatedCountsCalls(t *testing.T) {
+	m := NewServerMetrics()
+	m.MarkDeprecated("/mwitkow.testproto.TestService/PingEmpty")
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	for _, method := range []string{"PingEmpty", "Ping", "PingEmpty"} {
+		_, err := m.UnaryServerInterceptor()(context.Background(), &pb_testproto.Empty{},
+			&grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/" + method}, handler)
+		require.NoError(t, err)
+	}
+	requireValue(t, 2, m.serverDeprecatedCallCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty"))
+	requireValue(t, 0, m.serverDeprecatedCallCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+}
+
+func TestCancellationCounterCauses(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableCancellationCounter()
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err := m.UnaryServerInterceptor()(expired, &pb_testproto.PingRequest{}, info,
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = m.UnaryServerInterceptor()(ctx, &pb_testproto.PingRequest{}, info,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			cancel()
+			return nil, nil
+		})
+	require.NoError(t, err)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	_, err = m.UnaryServerInterceptor()(ctx, &pb_testproto.PingRequest{}, info,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			cancel()
+			return nil, status.FromContextError(ctx.Err()).Err()
+		})
+	require.Error(t, err)
+
+	for _, cause := range []string{"deadline_exceeded_before_handler", "client_canceled", "handler_observed"} {
+		requireValue(t, 1, m.serverCancellationCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", cause))
+	}
+}
+
+func TestWastedWorkMetrics(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableWastedWorkMetrics()
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	for _, cancelInHandler := range []bool{true, false} {
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err := m.UnaryServerInterceptor()(ctx, &pb_testproto.PingRequest{}, info,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				if cancelInHandler {
+					cancel()
+				}
+				return &pb_testproto.PingResponse{}, nil
+			})
+		require.NoError(t, err)
+		cancel()
+	}
+	requireValue(t, 1, m.serverWastedWorkCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValueHistCount(t, 1, m.serverWastedWorkHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+}
+
+func TestRecordCoalesced(t *testing.T) {
+	m := NewServerMetrics()
+	// Coalesced RPCs aren't counted until the counter is enabled.
+	m.RecordCoalesced("/mwitkow.testproto.TestService/Ping")
+	require.Nil(t, m.serverCoalescedCounter)
+	m.EnableCoalescedCounter()
+	m.RecordCoalesced("/mwitkow.testproto.TestService/Ping")
+	requireValue(t, 1, m.serverCoalescedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+}
+
+func TestConcurrencyUtilization(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithConcurrencyLimits(map[string]int{"/mwitkow.testproto.TestService/Ping": 4}))
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+
+	expected := `
+# HELP grpc_server_concurrency_utilization Number of RPCs in flight on the server divided by the concurrency limit of their method.
+# TYPE grpc_server_concurrency_utilization gauge
+grpc_server_concurrency_utilization{grpc_method="Ping",grpc_service="mwitkow.testproto.TestService"} 0.25
+`
+	_, err := m.UnaryServerInterceptor()(context.Background(), &pb_testproto.PingRequest{}, info,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			require.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(expected), "grpc_server_concurrency_utilization"))
+			return &pb_testproto.PingResponse{}, nil
+		})
+	require.NoError(t, err)
+	require.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(strings.Replace(expected, "0.25", "0", 1)), "grpc_server_concurrency_utilization"))
+}
+
+func TestLongPollMethodsExcludedFromUtilization(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(
+		WithLongPollMethods("/mwitkow.testproto.TestService/PingStream"),
+		WithConcurrencyLimits(map[string]int{
+			"/mwitkow.testproto.TestService/Ping":       2,
+			"/mwitkow.testproto.TestService/PingStream": 2,
+		}))
+	info := &grpc.StreamServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingStream", IsClientStream: true, IsServerStream: true}
+
+	expected := `
+# HELP grpc_server_concurrency_utilization Number of RPCs in flight on the server divided by the concurrency limit of their method.
+# TYPE grpc_server_concurrency_utilization gauge
+grpc_server_concurrency_utilization{grpc_method="Ping",grpc_service="mwitkow.testproto.TestService"} 0
+# HELP grpc_server_long_poll_in_flight Number of RPCs of long-poll methods in flight on the server.
+# TYPE grpc_server_long_poll_in_flight gauge
+grpc_server_long_poll_in_flight{grpc_method="PingStream",grpc_service="mwitkow.testproto.TestService"} 1
+`
+	err := m.StreamServerInterceptor()(nil, &fakeServerStream{ctx: context.Background()}, info,
+		func(srv interface{}, stream grpc.ServerStream) error {
+			require.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(expected),
+				"grpc_server_concurrency_utilization", "grpc_server_long_poll_in_flight"))
+			return nil
+		})
+	require.NoError(t, err)
+}
+
+func TestSuppressMetrics(t *testing.T) {
+	m := NewServerMetrics()
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &pb_testproto.PingResponse{}, nil
+	}
+	for _, ctx := range []context.Context{SuppressMetrics(context.Background()), context.Background()} {
+		_, err := m.UnaryServerInterceptor()(ctx, &pb_testproto.PingRequest{}, info, handler)
+		require.NoError(t, err)
+	}
+	requireValue(t, 1, m.serverStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+}
+
+func TestHistogramRecordingOverride(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableHandlingTimeHistogram()
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &pb_testproto.PingResponse{}, nil
+	}
+	call := func(ctx context.Context) {
+		_, err := m.UnaryServerInterceptor()(ctx, &pb_testproto.PingRequest{}, info, handler)
+		require.NoError(t, err)
+	}
+
+	call(SkipHistogramRecording(context.Background()))
+	requireValueHistCount(t, 0, m.serverHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+
+	m.SetHandlingTimeHistogramActive(false)
+	call(context.Background())
+	call(ForceHistogramRecording(context.Background()))
+	requireValueHistCount(t, 1, m.serverHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+}
+
+func TestDisableStartedAndMsgCounters(t *testing.T) {
+	m := NewServerMetrics()
+	m.DisableStartedCounter()
+	m.DisableMsgCounters()
+	_, err := m.UnaryServerInterceptor()(context.Background(), &pb_testproto.PingRequest{},
+		&grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return &pb_testproto.PingResponse{}, nil
+		})
+	require.NoError(t, err)
+
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(m))
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range mfs {
+		require.NotContains(t, []string{"grpc_server_started_total", "grpc_server_msg_received_total", "grpc_server_msg_sent_total"}, mf.GetName())
+	}
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+}
+
+func TestFailedRPCSecondsCounter(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableFailedRPCSecondsCounter()
+	for _, code := range []codes.Code{codes.OK, codes.Unavailable} {
+		code := code
+		m.UnaryServerInterceptor()(context.Background(), &pb_testproto.PingRequest{},
+			&grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				time.Sleep(50 * time.Millisecond)
+				return nil, status.Error(code, "")
+			})
+	}
+	failed := testutil.ToFloat64(m.serverFailedSecondsCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	require.True(t, failed >= 0.05 && failed < 0.1, "only the failed RPC is accounted, got %v", failed)
+}
+
+func TestBenignCodes(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(
+		WithBenignCodes("/mwitkow.testproto.TestService/PingList", codes.Canceled),
+		WithBenignCodes("", codes.ResourceExhausted),
+	)
+	for _, tc := range []struct {
+		method string
+		code   codes.Code
+	}{{"PingList", codes.Canceled}, {"Ping", codes.Canceled}, {"Ping", codes.ResourceExhausted}} {
+		code := tc.code
+		m.UnaryServerInterceptor()(context.Background(), &pb_testproto.PingRequest{},
+			&grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/" + tc.method},
+			func(ctx context.Context, req interface{}) (interface{}, error) { return nil, status.Error(code, "") })
+	}
+	requireValue(t, 1, m.serverBenignHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingList", "Canceled"))
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingList", "Canceled"))
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "Canceled"))
+	requireValue(t, 0, m.serverBenignHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "Canceled"))
+	requireValue(t, 1, m.serverBenignHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "ResourceExhausted"))
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "ResourceExhausted"))
+}
+
+func TestReceivedSizeLimitRatioHistogram(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableReceivedSizeLimitRatioHistogram(100)
+	// The request is 50 bytes: a 2 bytes field header and a 48 bytes value.
+	req := &pb_testproto.PingRequest{Value: strings.Repeat("x", 48)}
+	_, err := m.UnaryServerInterceptor()(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	require.NoError(t, err)
+
+	h := &dto.Metric{}
+	require.NoError(t, m.serverRecvSizeRatioHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping").(prometheus.Histogram).Write(h))
+	require.EqualValues(t, 1, h.GetHistogram().GetSampleCount())
+	require.Equal(t, 0.5, h.GetHistogram().GetSampleSum())
+}
+
+func TestHandlingTimeOverflowCounter(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableHandlingTimeHistogram(WithHistogramBuckets([]float64{0.05}))
+	m.EnableHandlingTimeOverflowCounter()
+	for _, sleep := range []time.Duration{0, 60 * time.Millisecond} {
+		_, err := m.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				time.Sleep(sleep)
+				return nil, nil
+			})
+		require.NoError(t, err)
+	}
+	// Only the RPC slower than the largest bucket overflows.
+	requireValueHistCount(t, 2, m.serverHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValue(t, 1, m.serverHandledOverflowCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+}
+
+func TestLongTermHandledCounter(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableLongTermHandledCounter()
+	for _, code := range []codes.Code{codes.OK, codes.NotFound, codes.Internal} {
+		m.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, status.Error(code, "")
+			})
+	}
+	// RPCs are counted regardless of their code, which isn't a label.
+	requireValue(t, 3, m.serverLongTermHandledCounter.WithLabelValues("mwitkow.testproto.TestService", "Ping"))
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "NotFound"))
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(m)
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range mfs {
+		if mf.GetName() == "grpc_server_handled_longterm_total" {
+			require.Len(t, mf.GetMetric(), 1)
+			require.Len(t, mf.GetMetric()[0].GetLabel(), 2)
+			return
+		}
+	}
+	t.Fatal("grpc_server_handled_longterm_total not exported")
+}
+
+func TestGCOverlapCounter(t *testing.T) {
+	m := NewServerMetrics()
+	if !m.EnableGCOverlapCounter() {
+		t.Skip("GC cycles can't be read before Go 1.16")
+	}
+	// Disable automatic GC, so that only the forced cycle overlaps an RPC.
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	call := func(gc bool) {
+		_, err := m.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				if gc {
+					runtime.GC()
+				}
+				return nil, nil
+			})
+		require.NoError(t, err)
+	}
+	call(false)
+	requireValue(t, 0, m.serverGCOverlapCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	call(true)
+	requireValue(t, 1, m.serverGCOverlapCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+}
+
+func TestHandlingTimeSummary(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableHandlingTimeSummary()
+	for i := 0; i < 3; i++ {
+		_, err := m.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				time.Sleep(time.Millisecond)
+				return nil, nil
+			})
+		require.NoError(t, err)
+	}
+
+	s := &dto.Metric{}
+	require.NoError(t, m.serverHandledSummary.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping").(prometheus.Summary).Write(s))
+	require.EqualValues(t, 3, s.GetSummary().GetSampleCount())
+	require.True(t, s.GetSummary().GetSampleSum() >= 0.003, "handling times must be observed")
+	for _, q := range s.GetSummary().GetQuantile() {
+		require.True(t, q.GetValue() >= 0.001, "quantile %v must be a handling time", q.GetQuantile())
+	}
+}
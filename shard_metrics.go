@@ -0,0 +1,64 @@
+package grpc_prometheus
+
+import (
+	"context"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/metadata"
+)
+
+// otherShard is the shard label value of shards outside the configured set.
+const otherShard = "other"
+
+// ShardExtractor returns the logical shard or replica an outgoing RPC is
+// handled by. An empty string means the RPC is not accounted to any shard.
+type ShardExtractor func(ctx context.Context, fullMethod string) string
+
+// ShardFromOutgoingMetadata returns a ShardExtractor reading the shard from
+// the first value of the given key in the outgoing metadata of the call.
+func ShardFromOutgoingMetadata(key string) ShardExtractor {
+	return func(ctx context.Context, _ string) string {
+		md, ok := metadata.FromOutgoingContext(ctx)
+		if !ok {
+			return ""
+		}
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+}
+
+// EnableShardHandledCounter turns on grpc_client_shard_handled_total,
+// counting completed RPCs per shard as returned by extract, so that hot
+// shards can be detected from client-side metrics. shards is the bounded set
+// of expected shards, others are counted as "other".
+func (m *ClientMetrics) EnableShardHandledCounter(extract ShardExtractor, shards []string, counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnableShardHandledCounter")
+	known := make(map[string]bool, len(shards))
+	for _, s := range shards {
+		known[s] = true
+	}
+	m.clientShardExtractor = extract
+	m.clientKnownShards = known
+	if m.clientShardHandledCounter == nil {
+		m.clientShardHandledCounter = prom.NewCounterVec(
+			counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_client_shard_handled_total",
+				Help: "Total number of RPCs completed by the client per logical shard or replica, regardless of success or failure.",
+			})), []string{"grpc_service", "grpc_method", "shard", "grpc_code"})
+	}
+}
+
+// shardOf returns the bounded shard label of an outgoing RPC, or an empty
+// string if shards are not tracked or the RPC has no shard.
+func (m *ClientMetrics) shardOf(ctx context.Context, fullMethod string) string {
+	if m.clientShardExtractor == nil {
+		return ""
+	}
+	shard := m.clientShardExtractor(ctx, fullMethod)
+	if shard != "" && !m.clientKnownShards[shard] {
+		return otherShard
+	}
+	return shard
+}
@@ -0,0 +1,215 @@
+package grpc_prometheus
+
+import (
+	"sync"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// WithShardedCollectors partitions the started, handled and message counters
+// and the handling time histogram by service: each service gets its own
+// vectors, created on its first RPC or by InitializeMetrics. On servers
+// exposing hundreds of services this keeps the vectors, and the locks
+// guarding them, small, and allows dropping the series of a single service
+// with RemoveServiceMetrics.
+//
+// Sharded metrics are only exported by registering m itself, not its
+// individual vectors as the default metrics are.
+func WithShardedCollectors() ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		if m.serverShards == nil {
+			m.serverShards = &serviceShards{metrics: m, shards: make(map[string]*serviceShard)}
+		}
+	}
+}
+
+// RemoveServiceMetrics drops all series of the sharded metrics of service,
+// e.g. after the service was removed from the server, and frees their slots
+// in the budget of WithSeriesBudget. It does nothing unless
+// WithShardedCollectors is used.
+func (m *ServerMetrics) RemoveServiceMetrics(service string) {
+	if m.serverShards != nil {
+		m.serverShards.remove(service)
+	}
+}
+
+func (m *ServerMetrics) startedCounter(service string) *counterVec {
+	if m.serverShards != nil {
+		return m.serverShards.get(service).started
+	}
+	return m.serverStartedCounter
+}
+
+func (m *ServerMetrics) handledCounter(service string) *counterVec {
+	if m.serverShards != nil {
+		return m.serverShards.get(service).handled
+	}
+	return m.serverHandledCounter
+}
+
+func (m *ServerMetrics) msgReceivedCounter(service string) *counterVec {
+	if m.serverShards != nil {
+		return m.serverShards.get(service).msgReceived
+	}
+	return m.serverStreamMsgReceived
+}
+
+func (m *ServerMetrics) msgSentCounter(service string) *counterVec {
+	if m.serverShards != nil {
+		return m.serverShards.get(service).msgSent
+	}
+	return m.serverStreamMsgSent
+}
+
+func (m *ServerMetrics) handledHistogram(service string) *histogramVec {
+	if m.serverShards != nil {
+		return m.serverShards.get(service).histogram(m)
+	}
+	return m.serverHandledHistogram
+}
+
+// serviceShard holds the core metrics of a single service.
+type serviceShard struct {
+	started          *counterVec
+	handled          *counterVec
+	msgReceived      *counterVec
+	msgSent          *counterVec
+	handledHistogram *histogramVec
+	// histogramOnce creates handledHistogram on first use, as the histogram
+	// can be enabled after the shard was created.
+	histogramOnce sync.Once
+}
+
+// histogram returns the handling time histogram of shard, creating it if
+// needed. It must only be called once the histogram is enabled.
+func (shard *serviceShard) histogram(m *ServerMetrics) *histogramVec {
+	shard.histogramOnce.Do(func() {
+		if shard.handledHistogram == nil {
+			shard.handledHistogram = m.newHistogramVec(m.serverHandledHistogramOpts, []string{"grpc_type", "grpc_service", "grpc_method"})
+		}
+	})
+	return shard.handledHistogram
+}
+
+// serviceShards is a prom.Collector exporting the shards of all services. Its
+// descriptors are those of the unsharded metrics of ServerMetrics, which the
+// shards share.
+type serviceShards struct {
+	metrics *ServerMetrics
+
+	mu     sync.RWMutex
+	shards map[string]*serviceShard
+}
+
+func (s *serviceShards) get(service string) *serviceShard {
+	s.mu.RLock()
+	shard, ok := s.shards[service]
+	s.mu.RUnlock()
+	if ok {
+		return shard
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if shard, ok := s.shards[service]; ok {
+		return shard
+	}
+	shard = s.newShard()
+	s.shards[service] = shard
+	return shard
+}
+
+func (s *serviceShards) newShard() *serviceShard {
+	m := s.metrics
+	rpcLabels := []string{"grpc_type", "grpc_service", "grpc_method"}
+	shard := &serviceShard{
+		started:     m.newCounterVec(m.counterOpts.apply(serverStartedCounterOpts), rpcLabels),
+		handled:     m.newCounterVec(m.counterOpts.apply(serverHandledCounterOpts), append(rpcLabels, "grpc_code")),
+		msgReceived: m.newCounterVec(m.counterOpts.apply(serverStreamMsgReceivedOpts), rpcLabels),
+		msgSent:     m.newCounterVec(m.counterOpts.apply(serverStreamMsgSentOpts), rpcLabels),
+	}
+	if m.runtimeConfig().handledHistogramEnabled {
+		shard.handledHistogram = m.newHistogramVec(m.serverHandledHistogramOpts, rpcLabels)
+	}
+	return shard
+}
+
+// all returns the shards of all services.
+func (s *serviceShards) all() []*serviceShard {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	shards := make([]*serviceShard, 0, len(s.shards))
+	for _, shard := range s.shards {
+		shards = append(shards, shard)
+	}
+	return shards
+}
+
+func (s *serviceShards) remove(service string) {
+	s.mu.Lock()
+	shard, ok := s.shards[service]
+	delete(s.shards, service)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	// Free the series budget held by the series of the shard.
+	shard.started.releaseService(service)
+	shard.handled.releaseService(service)
+	shard.msgReceived.releaseService(service)
+	shard.msgSent.releaseService(service)
+	if s.metrics.serverHandledHistogramEnabled {
+		shard.histogram(s.metrics).releaseService(service)
+	}
+}
+
+// collectors returns the enabled metrics of shard, or of the unsharded
+// metrics if shard is nil.
+func (s *serviceShards) collectors(shard *serviceShard) []prom.Collector {
+	m := s.metrics
+	if shard == nil {
+		shard = &serviceShard{
+			started:          m.serverStartedCounter,
+			handled:          m.serverHandledCounter,
+			msgReceived:      m.serverStreamMsgReceived,
+			msgSent:          m.serverStreamMsgSent,
+			handledHistogram: m.serverHandledHistogram,
+		}
+	}
+	cs := []prom.Collector{shard.handled}
+	if !m.serverStartedCounterDisabled {
+		cs = append(cs, shard.started)
+	}
+	if !m.serverMsgCountersDisabled {
+		cs = append(cs, shard.msgReceived, shard.msgSent)
+	}
+	if m.serverHandledHistogramEnabled {
+		cs = append(cs, shard.histogram(m))
+	}
+	return cs
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (s *serviceShards) Describe(ch chan<- *prom.Desc) {
+	for _, c := range s.collectors(nil) {
+		c.Describe(ch)
+	}
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (s *serviceShards) Collect(ch chan<- prom.Metric) {
+	s.mu.RLock()
+	shards := make([]*serviceShard, 0, len(s.shards))
+	for _, shard := range s.shards {
+		shards = append(shards, shard)
+	}
+	s.mu.RUnlock()
+	for _, shard := range shards {
+		for _, c := range s.collectors(shard) {
+			c.Collect(ch)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// handledSeries returns the number of grpc_server_handled_total series of
+// each service gathered from reg.
+func handledSeries(t *testing.T, reg *prom.Registry) map[string]int {
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	series := map[string]int{}
+	for _, mf := range mfs {
+		if mf.GetName() != "grpc_server_handled_total" {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			for _, lp := range metric.GetLabel() {
+				if lp.GetName() == "grpc_service" {
+					series[lp.GetValue()]++
+				}
+			}
+		}
+	}
+	return series
+}
+
+func TestShardedCollectors(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableHandlingTimeHistogram()
+	m.Configure(WithShardedCollectors())
+	reg := prom.NewPedanticRegistry()
+	reg.MustRegister(m)
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	for _, service := range []string{"a.Service", "b.Service"} {
+		info := &grpc.UnaryServerInfo{FullMethod: "/" + service + "/Ping"}
+		m.UnaryServerInterceptor()(context.Background(), nil, info, handler)
+	}
+	requireValue(t, 1, m.handledCounter("a.Service").WithLabelValues("unary", "a.Service", "Ping", "OK"))
+	requireValueHistCount(t, 1, m.handledHistogram("b.Service").WithLabelValues("unary", "b.Service", "Ping"))
+	require.Equal(t, map[string]int{"a.Service": 1, "b.Service": 1}, handledSeries(t, reg))
+
+	m.RemoveServiceMetrics("a.Service")
+	require.Equal(t, map[string]int{"b.Service": 1}, handledSeries(t, reg))
+}
+
+func benchmarkCollect(b *testing.B, opts ...ServerMetricsOption) {
+	m := NewServerMetrics()
+	m.EnableHandlingTimeHistogram()
+	m.Configure(opts...)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	for s := 0; s < 500; s++ {
+		for i := 0; i < 10; i++ {
+			info := &grpc.UnaryServerInfo{FullMethod: fmt.Sprintf("/service%d.Service/Method%d", s, i)}
+			m.UnaryServerInterceptor()(context.Background(), nil, info, handler)
+		}
+	}
+	reg := prom.NewRegistry()
+	reg.MustRegister(m)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := reg.Gather(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCollect(b *testing.B) {
+	benchmarkCollect(b)
+}
+
+func BenchmarkCollectSharded(b *testing.B) {
+	benchmarkCollect(b, WithShardedCollectors())
+}
+
+func TestShardedCollectorsHistogramEnabledAfterFirstRPC(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithShardedCollectors())
+	call := func() {
+		_, err := m.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+			func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+		require.NoError(t, err)
+	}
+	// The shard of the service exists before the histogram is enabled.
+	call()
+	m.EnableHandlingTimeHistogram()
+	call()
+	requireValueHistCount(t, 1, m.handledHistogram("mwitkow.testproto.TestService").WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+
+	reg := prom.NewPedanticRegistry()
+	reg.MustRegister(m)
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	names := map[string]bool{}
+	for _, mf := range mfs {
+		names[mf.GetName()] = true
+	}
+	require.True(t, names["grpc_server_handling_seconds"])
+}
@@ -0,0 +1,45 @@
+package grpc_prometheus
+
+import (
+	"context"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+)
+
+// A SpanStatusFunc returns whether the tracing span of the RPC of ctx has an
+// error status, and false for ok if the span has no status set or there is no
+// span. With OpenTelemetry, it typically reads the status of the span
+// returned by trace.SpanFromContext through sdktrace.ReadOnlySpan.
+type SpanStatusFunc func(ctx context.Context) (isError bool, ok bool)
+
+// WithSpanStatusCheck turns on grpc_server_span_status_mismatches_total,
+// counting RPCs whose tracing span status, as returned by f, disagrees with
+// the gRPC code observed here: an error status for a RPC completed with OK,
+// or the reverse. Mismatches point to middleware swallowing or rewriting
+// errors inconsistently. The tracing interceptors must run inside the
+// interceptors of m, so that span statuses are set when they are checked.
+func WithSpanStatusCheck(f SpanStatusFunc, counterOpts ...CounterOption) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.spanStatusFunc = f
+		if m.serverSpanStatusMismatchCounter == nil {
+			m.serverSpanStatusMismatchCounter = m.newCounterVec(
+				counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+					Name: "grpc_server_span_status_mismatches_total",
+					Help: "Total number of RPCs completed on the server whose tracing span status disagrees with their gRPC code.",
+				})), []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code", "span_status"})
+		}
+	}
+}
+
+func (r *serverReporter) checkSpanStatus(ctx context.Context, code codes.Code) {
+	isError, ok := r.metrics.spanStatusFunc(ctx)
+	if !ok || isError == (code != codes.OK) {
+		return
+	}
+	spanStatus := "ok"
+	if isError {
+		spanStatus = "error"
+	}
+	r.metrics.serverSpanStatusMismatchCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName, code.String(), spanStatus).Inc()
+}
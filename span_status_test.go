@@ -0,0 +1,47 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeSpanKey struct{}
+
+// fakeSpan stands for a tracing span.
+type fakeSpan struct {
+	statusSet bool
+	isError   bool
+}
+
+func TestSpanStatusCheck(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithSpanStatusCheck(func(ctx context.Context) (bool, bool) {
+		span, ok := ctx.Value(fakeSpanKey{}).(*fakeSpan)
+		if !ok || !span.statusSet {
+			return false, false
+		}
+		return span.isError, true
+	}))
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	// The handler records its error in the span, unless swallow is set, as
+	// middleware swallowing errors would.
+	call := func(err error, swallow bool) {
+		span := &fakeSpan{}
+		ctx := context.WithValue(context.Background(), fakeSpanKey{}, span)
+		m.UnaryServerInterceptor()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			span.statusSet, span.isError = true, err != nil && !swallow
+			return nil, err
+		})
+	}
+	call(nil, false)
+	call(status.Error(codes.Internal, ""), false)
+	call(status.Error(codes.Internal, ""), true)
+
+	requireValue(t, 1, m.serverSpanStatusMismatchCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "Internal", "ok"))
+	requireValue(t, 0, m.serverSpanStatusMismatchCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "Internal", "error"))
+	requireValue(t, 0, m.serverSpanStatusMismatchCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK", "error"))
+}
@@ -0,0 +1,44 @@
+package grpc_prometheus
+
+import (
+	"context"
+
+	"google.golang.org/grpc/stats"
+)
+
+// ChainStatsHandlers returns a stats.Handler calling each of handlers in
+// order. grpc.StatsHandler and grpc.WithStatsHandler keep a single handler,
+// the last one given: install several handlers of this package, e.g.
+// NewServerStatsHandler and ConnBytesStatsHandler, as one chain instead.
+// Each handler tags the context returned by the previous one.
+func ChainStatsHandlers(handlers ...stats.Handler) stats.Handler {
+	return statsHandlerChain(handlers)
+}
+
+type statsHandlerChain []stats.Handler
+
+func (c statsHandlerChain) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	for _, h := range c {
+		ctx = h.TagRPC(ctx, info)
+	}
+	return ctx
+}
+
+func (c statsHandlerChain) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	for _, h := range c {
+		h.HandleRPC(ctx, s)
+	}
+}
+
+func (c statsHandlerChain) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	for _, h := range c {
+		ctx = h.TagConn(ctx, info)
+	}
+	return ctx
+}
+
+func (c statsHandlerChain) HandleConn(ctx context.Context, s stats.ConnStats) {
+	for _, h := range c {
+		h.HandleConn(ctx, s)
+	}
+}
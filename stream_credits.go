@@ -0,0 +1,85 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// WithStreamCreditMetrics turns on metrics for applications implementing
+// credit-based flow control over streams: the
+// grpc_server_stream_outstanding_credits gauge of credits granted to peers
+// and not yet consumed, summed over the open streams of each method, and the
+// grpc_server_stream_credit_grant_seconds histogram of the latency of credit
+// grants. Handlers report them with AddStreamCredits and
+// ObserveCreditGrant, which share the labels of the other stream metrics.
+// Credits still outstanding when a stream ends are removed from the gauge.
+func WithStreamCreditMetrics(opts ...HistogramOption) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		if m.serverStreamCreditsGauge != nil {
+			return
+		}
+		constLabels := m.counterOpts.apply(prom.CounterOpts{}).ConstLabels
+		histOpts := prom.HistogramOpts{
+			Name:        "grpc_server_stream_credit_grant_seconds",
+			Help:        "Histogram of latency (seconds) of flow control credit grants on gRPC streams handled by the server.",
+			Buckets:     prom.DefBuckets,
+			ConstLabels: constLabels,
+		}
+		for _, o := range opts {
+			o(&histOpts)
+		}
+		m.serverStreamCreditsGauge = m.newGaugeVec(
+			prom.GaugeOpts{
+				Name:        "grpc_server_stream_outstanding_credits",
+				Help:        "Number of flow control credits granted on open gRPC streams handled by the server and not yet consumed.",
+				ConstLabels: constLabels,
+			}, []string{"grpc_type", "grpc_service", "grpc_method"})
+		m.serverStreamCreditGrantHistogram = m.newHistogramVec(histOpts, []string{"grpc_type", "grpc_service", "grpc_method"})
+	}
+}
+
+// AddStreamCredits adds delta to the outstanding credits of the stream of
+// ctx: positive when credits are granted to the peer, negative when the peer
+// consumes them. It does nothing if ctx is not the context of a stream
+// monitored with WithStreamCreditMetrics.
+func AddStreamCredits(ctx context.Context, delta int) {
+	if c, ok := ctx.Value(streamCreditsKey{}).(*streamCredits); ok {
+		atomic.AddInt64(&c.outstanding, int64(delta))
+		c.gauge.Add(float64(delta))
+	}
+}
+
+// ObserveCreditGrant records the latency of a credit grant on the stream of
+// ctx, e.g. between the peer running out of credits and new ones being
+// granted. It does nothing if ctx is not the context of a stream monitored
+// with WithStreamCreditMetrics.
+func ObserveCreditGrant(ctx context.Context, latency time.Duration) {
+	if c, ok := ctx.Value(streamCreditsKey{}).(*streamCredits); ok {
+		c.grantLatency.Observe(latency.Seconds())
+	}
+}
+
+type streamCreditsKey struct{}
+
+// streamCredits tracks the outstanding credits of a stream.
+type streamCredits struct {
+	outstanding  int64 // accessed atomically
+	gauge        prom.Gauge
+	grantLatency prom.Observer
+}
+
+func newStreamCredits(r *serverReporter) *streamCredits {
+	return &streamCredits{
+		gauge:        r.metrics.serverStreamCreditsGauge.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName),
+		grantLatency: r.metrics.serverStreamCreditGrantHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName),
+	}
+}
+
+// finish removes the credits still outstanding at the end of the stream from
+// the gauge.
+func (c *streamCredits) finish() {
+	c.gauge.Sub(float64(atomic.SwapInt64(&c.outstanding, 0)))
+}
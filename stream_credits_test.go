@@ -0,0 +1,28 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestStreamCreditMetrics(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithStreamCreditMetrics())
+	gauge := m.serverStreamCreditsGauge.WithLabelValues("bidi_stream", "mwitkow.testproto.TestService", "PingStream")
+	info := &grpc.StreamServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingStream", IsClientStream: true, IsServerStream: true}
+	m.StreamServerInterceptor()(nil, &fakeServerStream{ctx: context.Background()}, info, func(srv interface{}, ss grpc.ServerStream) error {
+		AddStreamCredits(ss.Context(), 10)
+		AddStreamCredits(ss.Context(), -3)
+		require.Equal(t, 7.0, testutil.ToFloat64(gauge))
+		ObserveCreditGrant(ss.Context(), 20*time.Millisecond)
+		return nil
+	})
+	// Credits outstanding at the end of the stream are released.
+	require.Equal(t, 0.0, testutil.ToFloat64(gauge))
+	requireValueHistCount(t, 1, m.serverStreamCreditGrantHistogram.WithLabelValues("bidi_stream", "mwitkow.testproto.TestService", "PingStream"))
+}
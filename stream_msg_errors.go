@@ -0,0 +1,80 @@
+package grpc_prometheus
+
+import (
+	"io"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-prometheus/packages/grpcstatus"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+)
+
+// EnableStreamMsgErrorCounter turns on grpc_server_stream_msg_errors_total,
+// counting the messages that streams failed to send or receive by code and
+// direction ("received" or "sent"), e.g. a response exceeding the maximum
+// message size. Mid-stream failures are thus countable separately from the
+// final status of streams. The io.EOF ending a stream normally isn't an
+// error.
+func (m *ServerMetrics) EnableStreamMsgErrorCounter(counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnableStreamMsgErrorCounter")
+	if m.serverStreamMsgErrorCounter != nil {
+		return
+	}
+	m.serverStreamMsgErrorCounter = m.newCounterVec(
+		counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+			Name: "grpc_server_stream_msg_errors_total",
+			Help: "Total number of gRPC stream messages the server failed to send or receive.",
+		})), []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code", "direction"})
+}
+
+func (r *serverReporter) messageError(direction string, err error) {
+	if err == nil || err == io.EOF {
+		return
+	}
+	if r.metrics.serverStreamMsgErrorCounter != nil {
+		st, _ := grpcstatus.FromError(err)
+		r.metrics.serverStreamMsgErrorCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName, st.Code().String(), direction).Inc()
+	}
+	if r.metrics.serverMsgSizeLimits != nil && isMsgSizeLimitError(err) {
+		r.metrics.serverMsgSizeLimits.exceed(r.rpcType, r.serviceName, r.methodName, direction)
+	}
+}
+
+// EnableStreamMsgErrorCounter turns on grpc_client_stream_msg_errors_total,
+// counting the messages that streams failed to send or receive by code and
+// direction ("received" or "sent"), e.g. a request exceeding the maximum
+// message size. Mid-stream failures are thus countable separately from the
+// final status of streams. The io.EOF ending a stream normally isn't an
+// error. As a failed receive ends a client stream, received messages are only
+// counted once per stream, and only if the client failed to decode the
+// message or found it too large, not when receiving the final status sent by
+// the server.
+func (m *ClientMetrics) EnableStreamMsgErrorCounter(counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnableStreamMsgErrorCounter")
+	if m.clientStreamMsgErrorCounter != nil {
+		return
+	}
+	m.clientStreamMsgErrorCounter = prom.NewCounterVec(
+		counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+			Name: "grpc_client_stream_msg_errors_total",
+			Help: "Total number of gRPC stream messages the client failed to send or receive.",
+		})), []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code", "direction"})
+}
+
+func (r *clientReporter) messageError(direction string, err error) {
+	if r.metrics.clientStreamMsgErrorCounter == nil || err == nil || err == io.EOF {
+		return
+	}
+	st, _ := grpcstatus.FromError(err)
+	r.metrics.clientStreamMsgErrorCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName, st.Code().String(), direction).Inc()
+}
+
+// isRecvMsgError reports whether err is the client failing to receive a
+// message itself, rather than the final status of the stream.
+func isRecvMsgError(err error) bool {
+	if isMsgSizeLimitError(err) {
+		return true
+	}
+	st, _ := grpcstatus.FromError(err)
+	return st.Code() == codes.Internal && strings.Contains(st.Message(), "the received message")
+}
@@ -0,0 +1,85 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// failingServerStream is a grpc.ServerStream failing to send and receiving
+// io.EOF.
+type failingServerStream struct {
+	fakeServerStream
+}
+
+func (s *failingServerStream) SendMsg(m interface{}) error {
+	return status.Error(codes.ResourceExhausted, "message too large")
+}
+
+func (s *failingServerStream) RecvMsg(m interface{}) error {
+	return io.EOF
+}
+
+// tooLargeClientStream is a grpc.ClientStream receiving a message exceeding
+// the maximum message size.
+type tooLargeClientStream struct {
+	grpc.ClientStream
+}
+
+func (tooLargeClientStream) RecvMsg(m interface{}) error {
+	return status.Error(codes.ResourceExhausted, "grpc: received message larger than max (5 vs. 4)")
+}
+
+func TestServerStreamMsgErrorCounter(t *testing.T) {
+	m := NewServerMetrics()
+	m.EnableStreamMsgErrorCounter()
+	info := &grpc.StreamServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingStream", IsClientStream: true, IsServerStream: true}
+	err := m.StreamServerInterceptor()(nil, &failingServerStream{fakeServerStream{ctx: context.Background()}}, info, func(srv interface{}, ss grpc.ServerStream) error {
+		require.Equal(t, io.EOF, ss.RecvMsg(nil))
+		require.Error(t, ss.SendMsg(nil))
+		require.Error(t, ss.SendMsg(nil))
+		// The handler recovers, the stream completes successfully.
+		return nil
+	})
+	require.NoError(t, err)
+	requireValue(t, 2, m.serverStreamMsgErrorCounter.WithLabelValues("bidi_stream", "mwitkow.testproto.TestService", "PingStream", "ResourceExhausted", "sent"))
+	requireValue(t, 0, m.serverStreamMsgErrorCounter.WithLabelValues("bidi_stream", "mwitkow.testproto.TestService", "PingStream", "OK", "received"))
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("bidi_stream", "mwitkow.testproto.TestService", "PingStream", "OK"))
+}
+
+func TestClientStreamMsgErrorCounter(t *testing.T) {
+	m := NewClientMetrics()
+	m.EnableStreamMsgErrorCounter()
+	desc := &grpc.StreamDesc{StreamName: "PingList", ServerStreams: true}
+	stream, err := m.StreamClientInterceptor()(context.Background(), desc, nil, "/mwitkow.testproto.TestService/PingList",
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return brokenClientStream{}, nil
+		})
+	require.NoError(t, err)
+	require.Error(t, stream.RecvMsg(nil))
+	require.Error(t, stream.RecvMsg(nil))
+	// The final status of the stream isn't a message error.
+	requireValue(t, 0, m.clientStreamMsgErrorCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "Unavailable", "received"))
+
+	stream, err = m.StreamClientInterceptor()(context.Background(), desc, nil, "/mwitkow.testproto.TestService/PingList",
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return tooLargeClientStream{}, nil
+		})
+	require.NoError(t, err)
+	require.Error(t, stream.RecvMsg(nil))
+	require.Error(t, stream.RecvMsg(nil))
+	requireValue(t, 1, m.clientStreamMsgErrorCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "ResourceExhausted", "received"))
+
+	stream, err = m.StreamClientInterceptor()(context.Background(), desc, nil, "/mwitkow.testproto.TestService/PingList",
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &bidiClientStream{}, nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, io.EOF, stream.RecvMsg(nil))
+	requireValue(t, 0, m.clientStreamMsgErrorCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "OK", "received"))
+}
@@ -0,0 +1,74 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// EnableStreamReconnectMetrics turns on grpc_client_stream_reconnects_total
+// and the grpc_client_stream_reconnect_gap_seconds histogram for streams
+// created with a context returned by WithStreamReconnectTracking. The gap is
+// the time between a stream of the subscription ending and the next one
+// being established.
+func (m *ClientMetrics) EnableStreamReconnectMetrics(opts ...HistogramOption) {
+	m.checkNotFrozen("EnableStreamReconnectMetrics")
+	if m.clientStreamReconnectCounter == nil {
+		histOpts := prom.HistogramOpts{
+			Name:    "grpc_client_stream_reconnect_gap_seconds",
+			Help:    "Histogram of time (seconds) between a stream ending and the client re-establishing it.",
+			Buckets: prom.DefBuckets,
+		}
+		for _, o := range opts {
+			o(&histOpts)
+		}
+		m.clientStreamReconnectCounter = prom.NewCounterVec(
+			m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_client_stream_reconnects_total",
+				Help: "Total number of streams re-established by the client after a previous stream of the same subscription ended.",
+			}), []string{"grpc_service", "grpc_method"})
+		m.clientStreamReconnectGapHistogram = prom.NewHistogramVec(histOpts, []string{"grpc_service", "grpc_method"})
+	}
+}
+
+// WithStreamReconnectTracking returns a context marking all streams created
+// with it as attempts of one logical subscription, for applications that
+// automatically re-create broken streams. Use the returned context for every
+// attempt.
+func WithStreamReconnectTracking(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamReconnectKey{}, &streamReconnectTracker{})
+}
+
+type streamReconnectKey struct{}
+
+// streamReconnectTracker follows the streams of a subscription.
+type streamReconnectTracker struct {
+	mu          sync.Mutex
+	established bool
+	connected   bool
+	endedAt     time.Time
+}
+
+// streamEstablished notes a new stream, returning the gap since the previous
+// one ended if it is a reconnect.
+func (t *streamReconnectTracker) streamEstablished(now time.Time) (gap time.Duration, reconnect bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	reconnect = t.established && !t.connected
+	if reconnect {
+		gap = now.Sub(t.endedAt)
+	}
+	t.established, t.connected = true, true
+	return gap, reconnect
+}
+
+func (t *streamReconnectTracker) streamEnded(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.connected {
+		t.connected = false
+		t.endedAt = now
+	}
+}
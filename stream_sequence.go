@@ -0,0 +1,112 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"sync"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// sequenceWindow is the number of sequence numbers below the highest one
+// seen in which duplicates are detected.
+const sequenceWindow = 64
+
+// WithStreamSequenceMetrics turns on counters of out-of-order and duplicate
+// messages in streams, for applications numbering their messages:
+// grpc_server_stream_out_of_order_msgs_total and
+// grpc_server_stream_duplicate_msgs_total. Handlers report the sequence
+// number of each message with ReportSequence. A message is out of order if
+// its sequence number is lower than one reported before, and a duplicate if
+// its sequence number was already reported. Duplicates are only detected
+// among the 64 sequence numbers below the highest one, older ones are
+// counted as out of order.
+func WithStreamSequenceMetrics(counterOpts ...CounterOption) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		if m.serverOutOfOrderCounter != nil {
+			return
+		}
+		m.serverOutOfOrderCounter = m.newCounterVec(
+			counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_server_stream_out_of_order_msgs_total",
+				Help: "Total number of gRPC stream messages reported by the server with a sequence number lower than a previous one.",
+			})), []string{"grpc_type", "grpc_service", "grpc_method"})
+		m.serverDuplicateCounter = m.newCounterVec(
+			counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_server_stream_duplicate_msgs_total",
+				Help: "Total number of gRPC stream messages reported by the server with an already reported sequence number.",
+			})), []string{"grpc_type", "grpc_service", "grpc_method"})
+	}
+}
+
+// ReportSequence reports the sequence number of a message of the stream of
+// ctx, for the counters enabled with WithStreamSequenceMetrics. Messages
+// received and sent must be numbered independently, and only one of the
+// directions reported. It does nothing if ctx is not the context of a stream
+// monitored with WithStreamSequenceMetrics.
+func ReportSequence(ctx context.Context, seq uint64) {
+	if s, ok := ctx.Value(streamSequenceKey{}).(*streamSequence); ok {
+		switch s.observe(seq) {
+		case sequenceOutOfOrder:
+			s.outOfOrder.Inc()
+		case sequenceDuplicate:
+			s.duplicate.Inc()
+		}
+	}
+}
+
+type streamSequenceKey struct{}
+
+type sequenceResult int
+
+const (
+	sequenceInOrder sequenceResult = iota
+	sequenceOutOfOrder
+	sequenceDuplicate
+)
+
+// streamSequence tracks the sequence numbers of a stream with a sliding
+// window bitmap, as in anti-replay protection.
+type streamSequence struct {
+	outOfOrder prom.Counter
+	duplicate  prom.Counter
+
+	mu      sync.Mutex
+	started bool
+	highest uint64
+	// seen has bit i set if highest-i was reported.
+	seen uint64
+}
+
+func newStreamSequence(r *serverReporter) *streamSequence {
+	return &streamSequence{
+		outOfOrder: r.metrics.serverOutOfOrderCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName),
+		duplicate:  r.metrics.serverDuplicateCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName),
+	}
+}
+
+func (s *streamSequence) observe(seq uint64) sequenceResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		s.started, s.highest, s.seen = true, seq, 1
+		return sequenceInOrder
+	}
+	if seq > s.highest {
+		if shift := seq - s.highest; shift < sequenceWindow {
+			s.seen = s.seen<<shift | 1
+		} else {
+			s.seen = 1
+		}
+		s.highest = seq
+		return sequenceInOrder
+	}
+	offset := s.highest - seq
+	if offset >= sequenceWindow {
+		return sequenceOutOfOrder
+	}
+	if s.seen&(1<<offset) != 0 {
+		return sequenceDuplicate
+	}
+	s.seen |= 1 << offset
+	return sequenceOutOfOrder
+}
@@ -0,0 +1,44 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestStreamSequenceObserve(t *testing.T) {
+	s := &streamSequence{}
+	for _, tc := range []struct {
+		seq    uint64
+		result sequenceResult
+	}{
+		{5, sequenceInOrder},
+		{6, sequenceInOrder},
+		{6, sequenceDuplicate},
+		{9, sequenceInOrder}, // Gaps are not reordering.
+		{7, sequenceOutOfOrder},
+		{7, sequenceDuplicate},
+		{5, sequenceDuplicate},
+		{200, sequenceInOrder},
+		{100, sequenceOutOfOrder}, // Older than the window.
+		{100, sequenceOutOfOrder},
+	} {
+		require.Equal(t, tc.result, s.observe(tc.seq), "seq %d", tc.seq)
+	}
+}
+
+func TestStreamSequenceMetrics(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithStreamSequenceMetrics())
+	info := &grpc.StreamServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingStream", IsClientStream: true, IsServerStream: true}
+	m.StreamServerInterceptor()(nil, &fakeServerStream{ctx: context.Background()}, info, func(srv interface{}, ss grpc.ServerStream) error {
+		for _, seq := range []uint64{1, 3, 2, 3, 4} {
+			ReportSequence(ss.Context(), seq)
+		}
+		return nil
+	})
+	requireValue(t, 1, m.serverOutOfOrderCounter.WithLabelValues("bidi_stream", "mwitkow.testproto.TestService", "PingStream"))
+	requireValue(t, 1, m.serverDuplicateCounter.WithLabelValues("bidi_stream", "mwitkow.testproto.TestService", "PingStream"))
+}
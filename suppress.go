@@ -0,0 +1,23 @@
+package grpc_prometheus
+
+import (
+	"context"
+)
+
+type suppressMetricsKey struct{}
+
+// SuppressMetrics returns a copy of ctx which excludes the RPCs made with it
+// from all metrics of this package, e.g. for housekeeping calls such as cache
+// warmers or replication probes. Client interceptors honor it for calls made
+// with the returned context. Server interceptors honor it for RPCs whose
+// context was marked by an interceptor running before them, as contexts
+// don't cross the wire.
+func SuppressMetrics(ctx context.Context) context.Context {
+	return context.WithValue(ctx, suppressMetricsKey{}, true)
+}
+
+// metricsSuppressed reports whether ctx was returned by SuppressMetrics.
+func metricsSuppressed(ctx context.Context) bool {
+	suppressed, _ := ctx.Value(suppressMetricsKey{}).(bool)
+	return suppressed
+}
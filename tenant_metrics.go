@@ -0,0 +1,131 @@
+package grpc_prometheus
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+// TenantExtractor returns the tenant an incoming RPC is accounted to. An empty
+// string means the RPC is not accounted to any tenant.
+type TenantExtractor func(ctx context.Context, fullMethod string) string
+
+// TenantMetrics represents per-tenant usage counters for a gRPC server. The
+// set of tenants with series is bounded: once maxTenants are tracked, the
+// least recently seen tenant's series are deleted to make room for a new one.
+type TenantMetrics struct {
+	extract    TenantExtractor
+	maxTenants int
+
+	tenantRequests     *prom.CounterVec
+	tenantRequestBytes *prom.CounterVec
+
+	mu      sync.Mutex
+	lru     *list.List
+	tenants map[string]*list.Element
+}
+
+// NewTenantMetrics returns a TenantMetrics object accounting RPCs to the
+// tenants returned by extract, keeping series for at most maxTenants tenants.
+func NewTenantMetrics(extract TenantExtractor, maxTenants int, counterOpts ...CounterOption) *TenantMetrics {
+	opts := counterOptions(counterOpts)
+	return &TenantMetrics{
+		extract:    extract,
+		maxTenants: maxTenants,
+		tenantRequests: prom.NewCounterVec(
+			opts.apply(prom.CounterOpts{
+				Name: "grpc_server_tenant_requests_total",
+				Help: "Total number of RPCs started on the server per tenant.",
+			}), []string{"tenant"}),
+		tenantRequestBytes: prom.NewCounterVec(
+			opts.apply(prom.CounterOpts{
+				Name: "grpc_server_tenant_request_bytes_total",
+				Help: "Total number of request message bytes received by the server per tenant.",
+			}), []string{"tenant"}),
+		lru:     list.New(),
+		tenants: make(map[string]*list.Element),
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (m *TenantMetrics) Describe(ch chan<- *prom.Desc) {
+	m.tenantRequests.Describe(ch)
+	m.tenantRequestBytes.Describe(ch)
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (m *TenantMetrics) Collect(ch chan<- prom.Metric) {
+	m.tenantRequests.Collect(ch)
+	m.tenantRequestBytes.Collect(ch)
+}
+
+// UnaryServerInterceptor is a gRPC server-side interceptor that accounts Unary RPCs to tenants.
+func (m *TenantMetrics) UnaryServerInterceptor() func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if metricsSuppressed(ctx) {
+			return handler(ctx, req)
+		}
+		if tenant := m.extract(ctx, info.FullMethod); tenant != "" {
+			m.record(tenant, 1, messageSize(req))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is a gRPC server-side interceptor that accounts Streaming RPCs to tenants.
+func (m *TenantMetrics) StreamServerInterceptor() func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if metricsSuppressed(ss.Context()) {
+			return handler(srv, ss)
+		}
+		tenant := m.extract(ss.Context(), info.FullMethod)
+		if tenant == "" {
+			return handler(srv, ss)
+		}
+		m.record(tenant, 1, 0)
+		return handler(srv, &tenantServerStream{ss, m, tenant})
+	}
+}
+
+// record accounts requests and bytes to tenant and marks it as most recently
+// seen, evicting the series of the least recently seen tenant if the bound is
+// exceeded.
+func (m *TenantMetrics) record(tenant string, requests int, bytes int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.tenants[tenant]; ok {
+		m.lru.MoveToFront(e)
+	} else {
+		m.tenants[tenant] = m.lru.PushFront(tenant)
+		if m.maxTenants > 0 && m.lru.Len() > m.maxTenants {
+			evicted := m.lru.Remove(m.lru.Back()).(string)
+			delete(m.tenants, evicted)
+			m.tenantRequests.DeleteLabelValues(evicted)
+			m.tenantRequestBytes.DeleteLabelValues(evicted)
+		}
+	}
+	m.tenantRequests.WithLabelValues(tenant).Add(float64(requests))
+	m.tenantRequestBytes.WithLabelValues(tenant).Add(float64(bytes))
+}
+
+// tenantServerStream wraps grpc.ServerStream allowing each received message to be accounted to a tenant.
+type tenantServerStream struct {
+	grpc.ServerStream
+	metrics *TenantMetrics
+	tenant  string
+}
+
+func (s *tenantServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.metrics.record(s.tenant, 0, messageSize(m))
+	}
+	return err
+}
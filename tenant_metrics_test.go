@@ -0,0 +1,36 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type tenantCtxKey struct{}
+
+func TestTenantMetricsEvictsLeastRecentlySeenTenant(t *testing.T) {
+	m := NewTenantMetrics(func(ctx context.Context, _ string) string {
+		tenant, _ := ctx.Value(tenantCtxKey{}).(string)
+		return tenant
+	}, 2)
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	req := &pb_testproto.PingRequest{Value: "hello"}
+
+	for _, tenant := range []string{"a", "b", "a", "c", ""} {
+		_, err := interceptor(context.WithValue(context.Background(), tenantCtxKey{}, tenant), req, info, handler)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, 2.0, testutil.ToFloat64(m.tenantRequests.WithLabelValues("a")))
+	require.Equal(t, float64(2*proto.Size(req)), testutil.ToFloat64(m.tenantRequestBytes.WithLabelValues("a")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.tenantRequests.WithLabelValues("c")))
+	// "b" was evicted when "c" arrived, so its series start from zero again.
+	require.Equal(t, 0.0, testutil.ToFloat64(m.tenantRequests.WithLabelValues("b")))
+}
@@ -0,0 +1,158 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/metadata"
+)
+
+// topKCapacityFactor is the number of keys tracked per exported key, which
+// bounds the error of the space-saving estimates.
+const topKCapacityFactor = 4
+
+// A TopKKeyFunc returns the key an RPC is accounted to by the top-K tracker,
+// e.g. its method or consumer. An empty string means the RPC is not
+// accounted.
+type TopKKeyFunc func(ctx context.Context, fullMethod string) string
+
+// TopKByMethod is a TopKKeyFunc accounting RPCs to their full method name.
+func TopKByMethod(ctx context.Context, fullMethod string) string {
+	return fullMethod
+}
+
+// TopKByMetadata returns a TopKKeyFunc accounting RPCs to the first value of
+// the given key in their incoming metadata, e.g. a consumer ID. Unlike with
+// WithConsumerLabel, values need not be bounded, but they are exported
+// verbatim: use a custom TopKKeyFunc to hide secrets such as API keys.
+func TopKByMetadata(key string) TopKKeyFunc {
+	return func(ctx context.Context, _ string) string {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+}
+
+// WithTopK turns on the grpc_server_top_requests gauge, the estimated
+// request counts of the k keys, as returned by f, with the most requests.
+// Counts decay exponentially with the given half-life, so the gauge holds
+// the current top talkers, and the keys are tracked with the space-saving
+// algorithm in bounded memory. This answers "who is hammering us" without a
+// series per key.
+func WithTopK(k int, halfLife time.Duration, f TopKKeyFunc) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.serverTopK = newTopKTracker(k, halfLife, f, prom.NewDesc(
+			"grpc_server_top_requests",
+			"Estimated number of recent RPCs started on the server of the keys with the most RPCs, decaying exponentially.",
+			[]string{"key"}, m.counterOpts.apply(prom.CounterOpts{}).ConstLabels))
+	}
+}
+
+// topKTracker implements the space-saving algorithm over exponentially
+// decaying counts. Counts are stored scaled by 2^(t/halfLife) relative to
+// epoch, so that decay doesn't change their order and needs no updates.
+type topKTracker struct {
+	desc     *prom.Desc
+	k        int
+	halfLife time.Duration
+	keyFunc  TopKKeyFunc
+
+	mu     sync.Mutex
+	epoch  time.Time
+	counts map[string]float64
+}
+
+func newTopKTracker(k int, halfLife time.Duration, f TopKKeyFunc, desc *prom.Desc) *topKTracker {
+	return &topKTracker{
+		desc:     desc,
+		k:        k,
+		halfLife: halfLife,
+		keyFunc:  f,
+		counts:   make(map[string]float64, k*topKCapacityFactor),
+	}
+}
+
+// scale returns the weight of an event at now, relative to epoch.
+func (t *topKTracker) scale(now time.Time) float64 {
+	return math.Exp2(float64(now.Sub(t.epoch)) / float64(t.halfLife))
+}
+
+func (t *topKTracker) observe(key string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.epoch.IsZero() {
+		t.epoch = now
+	}
+	w := t.scale(now)
+	if w > 1e100 {
+		// Rebase counts on now before they overflow.
+		for k, c := range t.counts {
+			t.counts[k] = c / w
+		}
+		t.epoch, w = now, 1
+	}
+	if _, ok := t.counts[key]; ok || len(t.counts) < t.k*topKCapacityFactor {
+		t.counts[key] += w
+		return
+	}
+	// Replace the key with the lowest count, which the new key inherits.
+	minKey, minCount := "", math.Inf(1)
+	for k, c := range t.counts {
+		if c < minCount {
+			minKey, minCount = k, c
+		}
+	}
+	delete(t.counts, minKey)
+	t.counts[key] = minCount + w
+}
+
+type topKEntry struct {
+	key   string
+	count float64
+}
+
+// top returns the k keys with the highest counts at now, highest first.
+func (t *topKTracker) top(now time.Time) []topKEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entries := make([]topKEntry, 0, len(t.counts))
+	if len(t.counts) == 0 {
+		return entries
+	}
+	w := t.scale(now)
+	for k, c := range t.counts {
+		entries = append(entries, topKEntry{k, c / w})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return entries[i].key < entries[j].key
+	})
+	if len(entries) > t.k {
+		entries = entries[:t.k]
+	}
+	return entries
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (t *topKTracker) Describe(ch chan<- *prom.Desc) {
+	ch <- t.desc
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (t *topKTracker) Collect(ch chan<- prom.Metric) {
+	for _, e := range t.top(time.Now()) {
+		ch <- prom.MustNewConstMetric(t.desc, prom.GaugeValue, e.count, e.key)
+	}
+}
@@ -0,0 +1,62 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTopKTrackerFindsHeavyHitters(t *testing.T) {
+	tracker := newTopKTracker(2, time.Hour, TopKByMethod, nil)
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		tracker.observe(fmt.Sprintf("rare-%d", i), now)
+		if i%2 == 0 {
+			tracker.observe("heavy", now)
+		}
+		if i%4 == 0 {
+			tracker.observe("medium", now)
+		}
+	}
+	top := tracker.top(now)
+	require.Len(t, top, 2)
+	require.Equal(t, "heavy", top[0].key)
+	require.Equal(t, "medium", top[1].key)
+	// Space-saving estimates never underestimate.
+	require.True(t, top[0].count >= 500)
+	require.True(t, top[1].count >= 250)
+}
+
+func TestTopKTrackerDecays(t *testing.T) {
+	tracker := newTopKTracker(1, time.Minute, TopKByMethod, nil)
+	now := time.Now()
+	for i := 0; i < 8; i++ {
+		tracker.observe("old", now)
+	}
+	tracker.observe("new", now.Add(2*time.Minute))
+	tracker.observe("new", now.Add(2*time.Minute))
+	tracker.observe("new", now.Add(2*time.Minute))
+
+	top := tracker.top(now.Add(2 * time.Minute))
+	require.Equal(t, []topKEntry{{"new", 3}}, top)
+	// The count of old halved twice.
+	require.InDelta(t, 2, tracker.counts["old"]/tracker.scale(now.Add(2*time.Minute)), 1e-9)
+}
+
+func TestTopKByMetadata(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithTopK(1, time.Minute, TopKByMetadata("x-consumer")))
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	for _, consumer := range []string{"a", "b", "b"} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-consumer", consumer))
+		m.UnaryServerInterceptor()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	}
+	top := m.serverTopK.top(time.Now())
+	require.Len(t, top, 1)
+	require.Equal(t, "b", top[0].key)
+}
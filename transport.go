@@ -0,0 +1,54 @@
+package grpc_prometheus
+
+import (
+	"context"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/peer"
+)
+
+// A TransportFunc returns the name of the transport an RPC arrived over, e.g.
+// "h2" or "h2c", from its context. It must return a small, fixed set of
+// values, as each is a label value.
+type TransportFunc func(ctx context.Context) string
+
+// PeerTransport is a TransportFunc deriving the transport from the peer
+// information of the RPC: "h2" for TLS, "h2c" for plaintext connections, the
+// authentication type for other credentials, e.g. "alts", and "unknown" for
+// RPCs without peer, e.g. those of some in-process transports.
+func PeerTransport(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "unknown"
+	}
+	if p.AuthInfo == nil {
+		return "h2c"
+	}
+	if authType := p.AuthInfo.AuthType(); authType != "tls" {
+		return authType
+	}
+	return "h2"
+}
+
+// WithTransportLabel records handling times in the
+// grpc_server_transport_handling_seconds histogram, labeled by the transport
+// returned by f, e.g. PeerTransport, in addition to the usual labels. This
+// allows comparing the latency of transports, e.g. while experimenting with
+// alternative ones. Buckets are those of the handling time histogram at the
+// time the option is applied, unless overridden by opts.
+func WithTransportLabel(f TransportFunc, opts ...HistogramOption) ServerMetricsOption {
+	return func(m *ServerMetrics) {
+		m.transportFunc = f
+		if m.serverTransportHistogram != nil {
+			return
+		}
+		histOpts := m.serverHandledHistogramOpts
+		histOpts.Name = "grpc_server_transport_handling_seconds"
+		histOpts.Help = "Histogram of response latency (seconds) of gRPC that had been application-level handled by the server, by transport."
+		histOpts.ConstLabels = m.counterOpts.apply(prom.CounterOpts{}).ConstLabels
+		for _, o := range opts {
+			o(&histOpts)
+		}
+		m.serverTransportHistogram = m.newHistogramVec(histOpts, []string{"grpc_type", "grpc_service", "grpc_method", "transport"})
+	}
+}
@@ -0,0 +1,28 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestPeerTransport(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}
+	require.Equal(t, "unknown", PeerTransport(context.Background()))
+	require.Equal(t, "h2c", PeerTransport(peer.NewContext(context.Background(), &peer.Peer{Addr: addr})))
+	require.Equal(t, "h2", PeerTransport(peer.NewContext(context.Background(), &peer.Peer{Addr: addr, AuthInfo: credentials.TLSInfo{}})))
+}
+
+func TestTransportHistogram(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithTransportLabel(PeerTransport))
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{}})
+	m.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	requireValueHistCount(t, 1, m.serverTransportHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "h2c"))
+}
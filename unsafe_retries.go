@@ -0,0 +1,113 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/stats"
+)
+
+// EnableUnsafeRetryCounter turns on grpc_client_unsafe_retries_total,
+// counting retries of methods not declared safe to retry, i.e. whose
+// idempotency_level option is neither NO_SIDE_EFFECTS nor IDEMPOTENT, as
+// misconfigured retry policies on such methods cause duplicate writes.
+// Methods are looked up in the registered proto files of the given names,
+// e.g. "test.proto", methods missing from them are not checked.
+//
+// Retries performed by gRPC, e.g. following the retry policy of the service
+// config, are detected by the stats handler returned by RetryStatsHandler.
+// Retry middlewares report theirs with RecordRetry.
+func (m *ClientMetrics) EnableUnsafeRetryCounter(protoFiles []string, counterOpts ...CounterOption) {
+	m.checkNotFrozen("EnableUnsafeRetryCounter")
+	if m.clientUnsafeRetries != nil {
+		return
+	}
+	unsafe := make(map[methodKey]bool)
+	for _, file := range protoFiles {
+		for key, opts := range fileMethodOptions(file) {
+			switch opts.GetIdempotencyLevel() {
+			case descriptor.MethodOptions_NO_SIDE_EFFECTS, descriptor.MethodOptions_IDEMPOTENT:
+				unsafe[key] = false
+			default:
+				unsafe[key] = true
+			}
+		}
+	}
+	m.clientUnsafeRetries = &unsafeRetries{
+		counter: prom.NewCounterVec(
+			counterOptions(counterOpts).apply(m.counterOpts.apply(prom.CounterOpts{
+				Name: "grpc_client_unsafe_retries_total",
+				Help: "Total number of retries by the client of RPCs to methods not declared safe to retry.",
+			})), []string{"grpc_service", "grpc_method"}),
+		unsafe: unsafe,
+	}
+	for key, isUnsafe := range unsafe {
+		if isUnsafe {
+			m.clientUnsafeRetries.counter.GetMetricWithLabelValues(key.service, key.method)
+		}
+	}
+}
+
+// RecordRetry records that a retry middleware retried an RPC to fullMethod,
+// for the counter enabled with EnableUnsafeRetryCounter.
+func (m *ClientMetrics) RecordRetry(fullMethod string) {
+	if m.clientUnsafeRetries != nil {
+		m.clientUnsafeRetries.retried(fullMethod)
+	}
+}
+
+// RetryStatsHandler returns a stats.Handler, to install with
+// grpc.WithStatsHandler, detecting the retries performed by gRPC for the
+// counter enabled with EnableUnsafeRetryCounter. Every attempt of an RPC
+// after the first one is a retry. Combine it with other stats handlers, e.g.
+// AttemptStatsHandler, with ChainStatsHandlers.
+func (m *ClientMetrics) RetryStatsHandler() stats.Handler {
+	return retryStatsHandler{m}
+}
+
+type unsafeRetries struct {
+	counter *prom.CounterVec
+	// unsafe is read-only after creation.
+	unsafe map[methodKey]bool
+}
+
+func (u *unsafeRetries) retried(fullMethod string) {
+	serviceName, methodName := splitMethodName(fullMethod)
+	if u.unsafe[methodKey{serviceName, methodName}] {
+		u.counter.WithLabelValues(serviceName, methodName).Inc()
+	}
+}
+
+type retryAttemptsKey struct{}
+
+// retryAttempts counts the attempts of a client-side RPC.
+type retryAttempts struct {
+	fullMethod string
+	attempts   int32 // accessed atomically
+}
+
+// retryStatsHandler is a stats.Handler counting the attempts of client-side
+// RPCs, as each attempt sends its own request headers.
+type retryStatsHandler struct {
+	m *ClientMetrics
+}
+
+func (h retryStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, retryAttemptsKey{}, &retryAttempts{fullMethod: info.FullMethodName})
+}
+
+func (h retryStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if o, ok := s.(*stats.OutHeader); ok && o.Client && h.m.clientUnsafeRetries != nil {
+		if a, ok := ctx.Value(retryAttemptsKey{}).(*retryAttempts); ok && atomic.AddInt32(&a.attempts, 1) > 1 {
+			h.m.clientUnsafeRetries.retried(a.fullMethod)
+		}
+	}
+}
+
+func (h retryStatsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h retryStatsHandler) HandleConn(ctx context.Context, s stats.ConnStats) {}
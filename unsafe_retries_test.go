@@ -0,0 +1,33 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/stats"
+)
+
+func TestUnsafeRetryCounter(t *testing.T) {
+	m := NewClientMetrics()
+	// Declared in the proto file registered by method_info_test.go.
+	m.EnableUnsafeRetryCounter([]string{"grpc_prometheus_method_info_test.proto"})
+	h := m.RetryStatsHandler()
+	attempt := func(fullMethod string, attempts int) {
+		ctx := h.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: fullMethod})
+		for i := 0; i < attempts; i++ {
+			h.HandleRPC(ctx, &stats.OutHeader{Client: true, FullMethod: fullMethod})
+		}
+	}
+	attempt("/grpc_prometheus.test.InfoService/OldPut", 1)
+	attempt("/grpc_prometheus.test.InfoService/OldPut", 3)
+	attempt("/grpc_prometheus.test.InfoService/Get", 3)
+	m.RecordRetry("/grpc_prometheus.test.InfoService/OldPut")
+	m.RecordRetry("/grpc_prometheus.test.InfoService/Get")
+	m.RecordRetry("/other.Service/Put")
+
+	require.Equal(t, 3.0, testutil.ToFloat64(m.clientUnsafeRetries.counter.WithLabelValues("grpc_prometheus.test.InfoService", "OldPut")))
+	require.Equal(t, 0.0, testutil.ToFloat64(m.clientUnsafeRetries.counter.WithLabelValues("grpc_prometheus.test.InfoService", "Get")))
+	require.Equal(t, 0.0, testutil.ToFloat64(m.clientUnsafeRetries.counter.WithLabelValues("other.Service", "Put")))
+}
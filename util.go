@@ -4,14 +4,28 @@
 package grpc_prometheus
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"strings"
+	"time"
 
+	"github.com/golang/protobuf/proto"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 )
 
 type grpcType string
 
+// processStartTime approximates the start of the process by the time this
+// package got initialized.
+var processStartTime = time.Now()
+
+// defaultServerMaxRecvMsgSize mirrors the default of grpc.MaxRecvMsgSize.
+const defaultServerMaxRecvMsgSize = 1024 * 1024 * 4
+
 const (
 	Unary        grpcType = "unary"
 	ClientStream grpcType = "client_stream"
@@ -48,3 +62,63 @@ func typeFromMethodInfo(mInfo *grpc.MethodInfo) grpcType {
 	}
 	return BidiStream
 }
+
+// collectorList is a Collector of the metrics of several collectors.
+type collectorList []prom.Collector
+
+func (l collectorList) Describe(ch chan<- *prom.Desc) {
+	for _, c := range l {
+		c.Describe(ch)
+	}
+}
+
+func (l collectorList) Collect(ch chan<- prom.Metric) {
+	for _, c := range l {
+		c.Collect(ch)
+	}
+}
+
+// dumpCollector gathers the metrics of a single collector, using a throw-away
+// registry, and encodes them to w in the given exposition format.
+func dumpCollector(c prom.Collector, w io.Writer, format expfmt.Format) error {
+	switch format {
+	case expfmt.FmtText, expfmt.FmtProtoDelim, expfmt.FmtProtoText, expfmt.FmtProtoCompact:
+	default:
+		return fmt.Errorf("grpc_prometheus: unsupported exposition format %q", format)
+	}
+	reg := prom.NewRegistry()
+	if err := reg.Register(c); err != nil {
+		return err
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		return err
+	}
+	enc := expfmt.NewEncoder(w, format)
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// messageSize returns the encoded size of a protobuf message, or 0 for other
+// message types.
+func messageSize(msg interface{}) int {
+	if pm, ok := msg.(proto.Message); ok {
+		return proto.Size(pm)
+	}
+	return 0
+}
+
+// contextServerStream wraps grpc.ServerStream to override its context, e.g.
+// to expose per-RPC state to handlers.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
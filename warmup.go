@@ -0,0 +1,54 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Warmup establishes the connection of cc ahead of the first real RPC, by
+// issuing a wait-for-ready health check for the service of each of the given
+// full method names, e.g. "/mwitkow.testproto.TestService/Ping", or a single
+// server-wide check if none are given. The time it took is recorded in
+// grpc_client_warmup_seconds, making the cold-start cost of connections
+// visible. Servers without the health service, or unaware of a service, are
+// considered warm, as the connection was established anyway. The checks are
+// excluded from the other metrics. ctx bounds the whole warm-up.
+func (m *ClientMetrics) Warmup(ctx context.Context, cc *grpc.ClientConn, methods []string) error {
+	start := time.Now()
+	ctx = SuppressMetrics(ctx)
+	client := grpc_health_v1.NewHealthClient(cc)
+	services := []string{""}
+	if len(methods) > 0 {
+		services = services[:0]
+		seen := make(map[string]bool, len(methods))
+		for _, fullMethod := range methods {
+			service, _ := splitMethodName(fullMethod)
+			if !seen[service] {
+				seen[service] = true
+				services = append(services, service)
+			}
+		}
+	}
+	for _, service := range services {
+		_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service}, grpc.WaitForReady(true))
+		switch status.Code(err) {
+		case codes.OK, codes.Unimplemented, codes.NotFound:
+		default:
+			return err
+		}
+	}
+	m.clientWarmupHistogram.WithLabelValues(cc.Target()).Observe(time.Since(start).Seconds())
+	return nil
+}
+
+// Warmup establishes the connection of cc ahead of the first real RPC, see
+// ClientMetrics.Warmup. This function acts on the DefaultClientMetrics
+// variable.
+func Warmup(ctx context.Context, cc *grpc.ClientConn, methods []string) error {
+	return DefaultClientMetrics.Warmup(ctx, cc, methods)
+}
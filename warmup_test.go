@@ -0,0 +1,43 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestWarmup(t *testing.T) {
+	for _, withHealth := range []bool{true, false} {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		s := grpc.NewServer()
+		if withHealth {
+			grpc_health_v1.RegisterHealthServer(s, health.NewServer())
+		}
+		go s.Serve(lis)
+
+		m := NewClientMetrics()
+		cc, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(), grpc.WithUnaryInterceptor(m.UnaryClientInterceptor()))
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		require.NoError(t, m.Warmup(ctx, cc, []string{"/mwitkow.testproto.TestService/Ping", "/mwitkow.testproto.TestService/PingList"}))
+		cancel()
+
+		pb := &dto.Metric{}
+		require.NoError(t, m.clientWarmupHistogram.WithLabelValues(cc.Target()).(prometheus.Metric).Write(pb))
+		require.Equal(t, uint64(1), pb.GetHistogram().GetSampleCount())
+		handled := make(chan prometheus.Metric, 10)
+		m.clientHandledCounter.Collect(handled)
+		require.Len(t, handled, 0, "warm-up checks are excluded from the metrics")
+		cc.Close()
+		s.Stop()
+	}
+}
@@ -0,0 +1,129 @@
+package grpc_prometheus
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// slidingCounter counts events, and how many of them were errors, over a
+// sliding time window. The window is split into a ring of fixed-width
+// buckets, so old events expire with the granularity of one bucket.
+type slidingCounter struct {
+	mu      sync.Mutex
+	width   int64 // bucket width in nanoseconds
+	buckets []windowBucket
+}
+
+type windowBucket struct {
+	epoch  int64 // index of the bucket since the unix epoch
+	total  float64
+	errors float64
+}
+
+func newSlidingCounter(window time.Duration, numBuckets int) *slidingCounter {
+	width := int64(window) / int64(numBuckets)
+	if width <= 0 {
+		width = 1
+	}
+	return &slidingCounter{
+		width:   width,
+		buckets: make([]windowBucket, numBuckets),
+	}
+}
+
+// add records total events, errors of them failed, at time t.
+func (c *slidingCounter) add(t time.Time, total, errors float64) {
+	epoch := t.UnixNano() / c.width
+	c.mu.Lock()
+	b := &c.buckets[int(epoch%int64(len(c.buckets)))]
+	if b.epoch != epoch {
+		*b = windowBucket{epoch: epoch}
+	}
+	b.total += total
+	b.errors += errors
+	c.mu.Unlock()
+}
+
+// sum returns the events and errors recorded within the window ending at t.
+func (c *slidingCounter) sum(t time.Time) (total, errors float64) {
+	epoch := t.UnixNano() / c.width
+	oldest := epoch - int64(len(c.buckets)) + 1
+	c.mu.Lock()
+	for _, b := range c.buckets {
+		if b.epoch >= oldest && b.epoch <= epoch {
+			total += b.total
+			errors += b.errors
+		}
+	}
+	c.mu.Unlock()
+	return total, errors
+}
+
+// formatWindow formats a window duration the way Prometheus range selectors
+// are written, e.g. "5m" or "1h".
+func formatWindow(d time.Duration) string {
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	case d >= time.Minute && d%time.Minute == 0:
+		return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+	case d >= time.Second && d%time.Second == 0:
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	}
+	return d.String()
+}
+
+// slidingMax tracks the maximum of values observed over a sliding time
+// window, split into a ring of buckets like slidingCounter.
+type slidingMax struct {
+	mu      sync.Mutex
+	width   int64 // bucket width in nanoseconds
+	buckets []maxBucket
+}
+
+type maxBucket struct {
+	epoch int64 // index of the bucket since the unix epoch
+	max   float64
+}
+
+func newSlidingMax(window time.Duration, numBuckets int) *slidingMax {
+	width := int64(window) / int64(numBuckets)
+	if width <= 0 {
+		width = 1
+	}
+	return &slidingMax{
+		width:   width,
+		buckets: make([]maxBucket, numBuckets),
+	}
+}
+
+// observe records v at time t.
+func (s *slidingMax) observe(t time.Time, v float64) {
+	epoch := t.UnixNano() / s.width
+	s.mu.Lock()
+	b := &s.buckets[int(epoch%int64(len(s.buckets)))]
+	if b.epoch != epoch {
+		*b = maxBucket{epoch: epoch, max: v}
+	} else if v > b.max {
+		b.max = v
+	}
+	s.mu.Unlock()
+}
+
+// max returns the maximum value observed within the window ending at t, and
+// false if there was none.
+func (s *slidingMax) max(t time.Time) (float64, bool) {
+	epoch := t.UnixNano() / s.width
+	oldest := epoch - int64(len(s.buckets)) + 1
+	var max float64
+	var found bool
+	s.mu.Lock()
+	for _, b := range s.buckets {
+		if b.epoch >= oldest && b.epoch <= epoch && (!found || b.max > max) {
+			max, found = b.max, true
+		}
+	}
+	s.mu.Unlock()
+	return max, found
+}
@@ -0,0 +1,79 @@
+package grpc_prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestSlidingCounterExpiresOldBuckets(t *testing.T) {
+	c := newSlidingCounter(time.Minute, 6)
+	start := time.Unix(1000, 0)
+	c.add(start, 1, 1)
+	c.add(start.Add(30*time.Second), 2, 0)
+
+	total, errors := c.sum(start.Add(30 * time.Second))
+	require.Equal(t, 3.0, total)
+	require.Equal(t, 1.0, errors)
+
+	total, errors = c.sum(start.Add(70 * time.Second))
+	require.Equal(t, 2.0, total, "the first bucket must have expired")
+	require.Equal(t, 0.0, errors)
+}
+
+func TestErrorBudgetBurn(t *testing.T) {
+	b := newErrorBudget(map[string]float64{"/mwitkow.testproto.TestService/Ping": 0.9}, []time.Duration{5 * time.Minute}, nil)
+	now := time.Now()
+	for i := 0; i < 8; i++ {
+		b.observe("mwitkow.testproto.TestService", "Ping", codes.OK, now)
+	}
+	b.observe("mwitkow.testproto.TestService", "Ping", codes.Unavailable, now)
+	b.observe("mwitkow.testproto.TestService", "Ping", codes.NotFound, now)
+	b.observe("mwitkow.testproto.TestService", "PingList", codes.Internal, now)
+
+	ch := make(chan prometheus.Metric, 1)
+	b.collect(ch, now)
+	pb := &dto.Metric{}
+	require.NoError(t, (<-ch).Write(pb))
+	// One server error in ten requests against a 10% budget burns it at rate 1.
+	require.InDelta(t, 1.0, pb.GetGauge().GetValue(), 1e-9)
+	require.Len(t, pb.GetLabel(), 3)
+	require.Equal(t, "5m", pb.GetLabel()[2].GetValue())
+}
+
+func TestSlidingMaxExpiresOldBuckets(t *testing.T) {
+	s := newSlidingMax(time.Minute, 6)
+	start := time.Unix(1000, 0)
+	_, ok := s.max(start)
+	require.False(t, ok)
+	s.observe(start, 3)
+	s.observe(start, 1)
+	s.observe(start.Add(30*time.Second), 2)
+
+	max, ok := s.max(start.Add(30 * time.Second))
+	require.True(t, ok)
+	require.Equal(t, 3.0, max)
+
+	max, _ = s.max(start.Add(70 * time.Second))
+	require.Equal(t, 2.0, max, "the first bucket must have expired")
+	_, ok = s.max(start.Add(time.Hour))
+	require.False(t, ok)
+}
+
+func TestRecentMaxHandlingTime(t *testing.T) {
+	m := NewServerMetrics()
+	m.Configure(WithRecentMaxHandlingTime(5 * time.Minute))
+	now := time.Now()
+	m.serverRecentMax.observe("mwitkow.testproto.TestService", "Ping", 200*time.Millisecond, now)
+	m.serverRecentMax.observe("mwitkow.testproto.TestService", "Ping", 100*time.Millisecond, now)
+
+	ch := make(chan prometheus.Metric, 1)
+	m.serverRecentMax.collect(ch, now)
+	pb := &dto.Metric{}
+	require.NoError(t, (<-ch).Write(pb))
+	require.Equal(t, 0.2, pb.GetGauge().GetValue())
+}